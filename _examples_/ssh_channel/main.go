@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+
+	"github.com/adrianosela/authio"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	flagNameAddress = "address"
+	flagNameKey     = "key"
+	defaultAddress  = "localhost:2222"
+	defaultKey      = "mysupersecretstring"
+
+	// channelType is the type of the SSH channels carrying authio messages
+	channelType = "authio"
+	sshUser     = "authio"
+	sshPassword = "authio"
+)
+
+var (
+	address string
+	key     string
+)
+
+// main runs an SSH server and a client of it, which exchange authenticated
+// messages over an SSH channel (i.e. messages authenticated end-to-end,
+// regardless of where the SSH connection is terminated)
+func main() {
+	// initialize flags
+	flag.StringVar(&address, flagNameAddress, defaultAddress, "SSH server address (i.e. HOST:PORT) to use")
+	flag.StringVar(&key, flagNameKey, defaultKey, "key to use for message authentication codes")
+	flag.Parse()
+
+	// start server
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatalf("could not start listener on %s: %s", address, err)
+	}
+	defer l.Close()
+	go serve(l, []byte(key))
+
+	// connect to server
+	conn, err := dial(address, []byte(key))
+	if err != nil {
+		log.Fatalf("could not open channel to %s: %s", address, err)
+	}
+	defer conn.Close()
+
+	authedReader := bufio.NewReader(conn)
+	for {
+		fmt.Print(">> ")
+
+		// read input from stdin
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			log.Fatalf("failed to read from stdin: %s", err)
+		}
+
+		// write input to the channel as a single message
+		if _, err = conn.Write([]byte(input)); err != nil {
+			log.Fatalf("failed to write to channel: %s", err)
+		}
+
+		// read output from the channel
+		msg, err := authedReader.ReadString('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("failed to read channel: %s", err)
+			}
+			return
+		}
+
+		// print output to stdout
+		fmt.Print(msg)
+	}
+}
+
+// serve accepts SSH connections on the given listener, and echoes
+// back the messages received on every authio channel of them
+func serve(l net.Listener, key []byte) error {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate host key: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		return fmt.Errorf("failed to build host key signer: %w", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() != sshUser || string(password) != sshPassword {
+				return nil, errors.New("invalid credentials")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return fmt.Errorf("could not accept new connection: %w", err)
+		}
+		go handleConn(c, config, key)
+	}
+}
+
+func handleConn(c net.Conn, config *ssh.ServerConfig, key []byte) {
+	sshConn, channels, requests, err := ssh.NewServerConn(c, config)
+	if err != nil {
+		log.Printf("SSH handshake failed: %s", err)
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != channelType {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			log.Printf("failed to accept channel: %s", err)
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go handleChannel(authio.NewServerStreamConn(channel, key))
+	}
+}
+
+// handleChannel echoes back every message received on the channel, and
+// half-closes the channel once the peer is done writing
+func handleChannel(conn *authio.Conn) {
+	defer conn.Close()
+	for {
+		msg, _, err := conn.NextWithExtensions()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("failed to read channel: %s", err)
+			}
+			conn.CloseWrite()
+			return
+		}
+		if _, err = conn.Write(msg); err != nil {
+			log.Printf("failed to write to channel: %s", err)
+			return
+		}
+	}
+}
+
+// dial opens an authio channel to the SSH server at the given address
+func dial(address string, key []byte) (*authio.Conn, error) {
+	client, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User: sshUser,
+		Auth: []ssh.AuthMethod{ssh.Password(sshPassword)},
+		// the server generates a new host key every time it starts
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	channel, requests, err := client.OpenChannel(channelType, nil)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	go ssh.DiscardRequests(requests)
+	return authio.NewClientStreamConn(channel, key), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_SSHChannel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	go serve(l, []byte("mock key"))
+
+	tests := []struct {
+		name        string
+		key         []byte
+		expectError bool
+	}{
+		{name: "Echo", key: []byte("mock key")},
+		{name: "Mismatched keys", key: []byte("other key"), expectError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, err := dial(l.Addr().String(), test.key)
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("mock message"))
+			assert.NoError(t, err)
+			msg, _, err := conn.NextWithExtensions()
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "mock message", string(msg))
+		})
+	}
+}
+
+// Test_SSHChannelConcurrentWrites tests that messages written concurrently
+// over a channel (e.g. by the streams of a multiplexer) are never interleaved,
+// although ssh.Channel splits writes into packets of the channel's max size
+func Test_SSHChannelConcurrentWrites(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	go serve(l, []byte("mock key"))
+
+	conn, err := dial(l.Addr().String(), []byte("mock key"))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	const writers, writes, size = 4, 10, 64 * 1024
+	wg := sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				msg := make([]byte, size)
+				copy(msg, fmt.Sprintf("%d:%d", i, j))
+				_, err := conn.Write(msg)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		conn.CloseWrite()
+	}()
+
+	received := 0
+	for {
+		msg, _, err := conn.NextWithExtensions()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, size, len(msg))
+		received++
+	}
+	assert.Equal(t, writers*writes, received)
+}
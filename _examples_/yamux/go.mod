@@ -0,0 +1,18 @@
+module github.com/adrianosela/authio/_examples_/yamux
+
+go 1.19
+
+require (
+	github.com/adrianosela/authio v0.0.0
+	github.com/autarch/testify v1.2.2
+	github.com/hashicorp/yamux v0.1.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.4.0 // indirect
+	golang.org/x/sys v0.3.0 // indirect
+)
+
+replace github.com/adrianosela/authio => ../..
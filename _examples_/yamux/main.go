@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/adrianosela/authio"
+	"github.com/hashicorp/yamux"
+)
+
+const (
+	flagNameAddress = "address"
+	flagNameKey     = "key"
+	flagNameStreams = "streams"
+	flagNameSize    = "size"
+	defaultAddress  = "localhost:8080"
+	defaultKey      = "mysupersecretstring"
+	defaultStreams  = 8
+	defaultSize     = 1024 * 1024
+)
+
+var (
+	address string
+	key     string
+	streams int
+	size    int
+)
+
+// main runs a yamux server and a client of it over a single authio.Conn,
+// such that every frame of every stream is authenticated, and echoes
+// random data on several streams at once
+func main() {
+	// initialize flags
+	flag.StringVar(&address, flagNameAddress, defaultAddress, "server address (i.e. HOST:PORT) to use")
+	flag.StringVar(&key, flagNameKey, defaultKey, "key to use for message authentication codes")
+	flag.IntVar(&streams, flagNameStreams, defaultStreams, "number of streams to open at once")
+	flag.IntVar(&size, flagNameSize, defaultSize, "number of bytes to echo on every stream")
+	flag.Parse()
+
+	// start server
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatalf("could not start listener on %s: %s", address, err)
+	}
+	defer l.Close()
+	go serve(l, []byte(key))
+
+	// connect to server
+	session, err := dial(address, []byte(key))
+	if err != nil {
+		log.Fatalf("could not connect to %s: %s", address, err)
+	}
+	defer session.Close()
+
+	if err := echoAll(session, streams, size); err != nil {
+		log.Fatalf("failed to echo: %s", err)
+	}
+	log.Printf("echoed %d bytes on each of %d streams", size, streams)
+}
+
+// serve accepts connections on the given listener, and echoes back
+// the data received on every stream of the yamux session of each
+func serve(l net.Listener, key []byte) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return fmt.Errorf("could not accept new connection: %w", err)
+		}
+		go handleConn(c, key)
+	}
+}
+
+func handleConn(c net.Conn, key []byte) {
+	// the session runs over the authio.Conn, rather than
+	// the other way around, such that its frames are all
+	// authenticated (including window updates and pings)
+	session, err := yamux.Server(authio.NewServerConn(c, key), nil)
+	if err != nil {
+		log.Printf("failed to start session: %s", err)
+		c.Close()
+		return
+	}
+	defer session.Close()
+
+	for {
+		stream, err := session.AcceptStream()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, yamux.ErrSessionShutdown) {
+				log.Printf("failed to accept stream: %s", err)
+			}
+			return
+		}
+		go func() {
+			defer stream.Close()
+			io.Copy(stream, stream)
+		}()
+	}
+}
+
+// dial starts a yamux session with the server at the given address
+func dial(address string, key []byte) (*yamux.Session, error) {
+	c, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	session, err := yamux.Client(authio.NewClientConn(c, key), nil)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+	return session, nil
+}
+
+// echoAll echoes size random bytes on each of the given
+// number of streams of a session, all at once
+func echoAll(session *yamux.Session, streams, size int) error {
+	errs := make(chan error, streams)
+	var wg sync.WaitGroup
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- echo(session, size)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// echo echoes size random bytes on a new stream of a session
+func echo(session *yamux.Session, size int) error {
+	stream, err := session.OpenStream()
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return fmt.Errorf("failed to generate data: %w", err)
+	}
+	written := make(chan error, 1)
+	go func() {
+		_, err := stream.Write(data)
+		written <- err
+	}()
+
+	echoed := make([]byte, size)
+	if _, err := io.ReadFull(stream, echoed); err != nil {
+		return fmt.Errorf("failed to read stream %d: %w", stream.StreamID(), err)
+	}
+	if err := <-written; err != nil {
+		return fmt.Errorf("failed to write stream %d: %w", stream.StreamID(), err)
+	}
+	if !bytes.Equal(data, echoed) {
+		return fmt.Errorf("stream %d echoed other data", stream.StreamID())
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio"
+	"github.com/autarch/testify/assert"
+	"github.com/hashicorp/yamux"
+)
+
+func Test_Yamux(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	go serve(l, []byte("mock key"))
+
+	tests := []struct {
+		name        string
+		key         []byte
+		streams     int
+		size        int
+		expectError bool
+	}{
+		{name: "One stream", key: []byte("mock key"), streams: 1, size: 64},
+		// more data than the initial window of a stream (256 KiB),
+		// such that window updates flow both ways
+		{name: "Concurrent streams", key: []byte("mock key"), streams: 16, size: 1024 * 1024},
+		{name: "Mismatched keys", key: []byte("other key"), streams: 1, size: 64, expectError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, err := dial(l.Addr().String(), test.key)
+			assert.NoError(t, err)
+			defer session.Close()
+
+			err = echoAll(session, test.streams, test.size)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_YamuxTampering(t *testing.T) {
+	key := []byte("mock key")
+
+	// a man in the middle flips a bit of every chunk the client writes
+	client, mitm := net.Pipe()
+	server, upstream := net.Pipe()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := mitm.Read(buf)
+			if err != nil {
+				upstream.Close()
+				return
+			}
+			buf[n-1] ^= 1
+			if _, err := upstream.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+	go handleConn(server, key)
+
+	config := yamux.DefaultConfig()
+	config.StreamOpenTimeout = time.Second
+	session, err := yamux.Client(authio.NewClientConn(client, key), config)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	// the server drops the session rather than acting on tampered frames
+	assert.Error(t, echo(session, 64))
+}
@@ -0,0 +1,62 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithAlgorithm(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name        string
+		algorithm   string
+		opts        []Option
+		expectError error
+	}{
+		{
+			name:      "Registered algorithm",
+			algorithm: "hmac-sha512",
+		},
+		{
+			name:      "Allowed by policy",
+			algorithm: "hmac-sha512",
+			opts:      []Option{WithPolicy(FIPSPolicy())},
+		},
+		{
+			name:      "One-time MAC",
+			algorithm: "poly1305",
+		},
+		{
+			name:        "Not HMAC based",
+			algorithm:   "crc32c",
+			opts:        []Option{WithPolicy(FIPSPolicy())},
+			expectError: ErrPolicyViolation,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := append(test.opts, WithAlgorithm(test.algorithm))
+
+			buf := &bytes.Buffer{}
+			_, err := NewAppendMACWriter(buf, mockKey, opts...).Write([]byte("hello"))
+			if test.expectError != nil {
+				assert.True(t, errors.Is(err, test.expectError))
+				return
+			}
+			assert.Nil(t, err)
+
+			message, err := NewVerifyMACReader(buf, mockKey, opts...).Next()
+			assert.Nil(t, err)
+			assert.Equal(t, "hello", string(message))
+		})
+	}
+}
+
+func Test_WithAlgorithmUnknown(t *testing.T) {
+	_, err := NewAppendMACWriter(&bytes.Buffer{}, []byte("mock key"), WithAlgorithm("unknown")).Write([]byte("hello"))
+	assert.NotNil(t, err)
+}
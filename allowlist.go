@@ -0,0 +1,124 @@
+package authio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// Allowlist restricts the algorithms and key IDs verifiers accept, such that
+// a compromised or misconfigured peer cannot downgrade a connection to a weaker
+// algorithm (e.g. HMAC-SHA-1 or checksums) or to a key it should not use.
+// Algorithms are names of registered algorithms (see authenticator.Register),
+// e.g. "hmac-sha256" or "cbor-hmac-sha256". An empty list allows everything.
+type Allowlist struct {
+	// Algorithms are the names of the algorithms which may be used
+	Algorithms []string
+	// KeyIDs are the key IDs which may be used
+	KeyIDs []string
+}
+
+// WithAllowlist restricts the algorithms and key IDs readers, writers, and
+// connections may be configured with, as well as those announced by frames
+// read (in the algorithm and key ID extensions, and in CBOR headers). Since
+// constructors do not return errors, every read and write of a configuration
+// which is not allowed fails, and so does every read of a frame which is not
+// allowed, with errors wrapping authenticator.ErrNotAllowed. Configurations
+// with custom MessageAuthenticators or FrameCodecs (or unregistered hash
+// functions, e.g. SHA-1) are only allowed if the list of algorithms is empty.
+func WithAllowlist(allowlist Allowlist) Option {
+	return func(c *config) { c.allowlist = &allowlist }
+}
+
+// allowsAlgorithm returns whether the Allowlist allows the given algorithm
+func (a *Allowlist) allowsAlgorithm(name string) bool {
+	return a == nil || len(a.Algorithms) == 0 || (name != "" && contains(a.Algorithms, name))
+}
+
+// allowsKeyID returns whether the Allowlist allows the given key ID
+func (a *Allowlist) allowsKeyID(keyID string) bool {
+	return a == nil || len(a.KeyIDs) == 0 || contains(a.KeyIDs, keyID)
+}
+
+// checkExtensions checks the algorithm and key ID announced in the
+// extensions of a frame, if any, against the Allowlist
+func (a *Allowlist) checkExtensions(extensions []authenticator.Extension) error {
+	if a == nil {
+		return nil
+	}
+	for _, ext := range extensions {
+		switch ext.Type {
+		case authenticator.ExtensionAlgorithm:
+			if len(ext.Value) != 2 {
+				return fmt.Errorf("%w: malformed algorithm extension", authenticator.ErrNotAllowed)
+			}
+			id := binary.BigEndian.Uint16(ext.Value)
+			alg, ok := authenticator.Get(id)
+			if !ok || !a.allowsAlgorithm(alg.Name) {
+				return fmt.Errorf("%w: algorithm %d", authenticator.ErrNotAllowed, id)
+			}
+		case authenticator.ExtensionKeyID:
+			if !a.allowsKeyID(string(ext.Value)) {
+				return fmt.Errorf("%w: key ID %q", authenticator.ErrNotAllowed, ext.Value)
+			}
+		}
+	}
+	return nil
+}
+
+// checkAllowlist checks the configuration against the Allowlist, if any
+func (c *config) checkAllowlist() error {
+	if c.allowlist == nil {
+		return nil
+	}
+	if name := c.registeredAlgorithmName(c.cborHeaders); !c.allowlist.allowsAlgorithm(name) {
+		return fmt.Errorf("%w: algorithm %s", authenticator.ErrNotAllowed, c.algorithmName())
+	}
+	for _, keyID := range []string{c.keyID, c.cborKeyID} {
+		if keyID != "" && !c.allowlist.allowsKeyID(keyID) {
+			return fmt.Errorf("%w: key ID %q", authenticator.ErrNotAllowed, keyID)
+		}
+	}
+	return nil
+}
+
+// registeredAlgorithmName returns the name of the registered algorithm the
+// configuration amounts to (with or without CBOR headers), or an empty string
+// for custom MessageAuthenticators and FrameCodecs, and unregistered hash functions
+func (c *config) registeredAlgorithmName(cborHeaders bool) string {
+	switch {
+	case c.authenticator != nil, c.frameCodec != nil:
+		return ""
+	case c.algorithm != "":
+		return c.algorithm
+	case c.checksum:
+		if alg, ok := authenticator.Get(authenticator.AlgorithmCRC32C); ok {
+			return alg.Name
+		}
+		return ""
+	}
+	h, ok := identifyHash(c.hashFn)
+	if !ok {
+		return ""
+	}
+	for _, alg := range authenticator.Algorithms() {
+		if alg.HashFn == nil || strings.HasPrefix(alg.Name, "cbor-") != cborHeaders {
+			continue
+		}
+		if candidate, ok := identifyHash(alg.HashFn); ok && candidate == h {
+			return alg.Name
+		}
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
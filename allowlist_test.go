@@ -0,0 +1,129 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha512"
+	"errors"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithAllowlist(t *testing.T) {
+	mockKey := []byte("mock key")
+	allowlist := WithAllowlist(Allowlist{
+		Algorithms: []string{"hmac-sha256", "cbor-hmac-sha256"},
+		KeyIDs:     []string{"key-1"},
+	})
+
+	tests := []struct {
+		name        string
+		writerOpts  []Option
+		extensions  []authenticator.Extension
+		readerOpts  []Option
+		expectError error
+	}{
+		{
+			name:       "allowed algorithm",
+			readerOpts: []Option{allowlist},
+		},
+		{
+			name:       "allowed algorithm and key ID extensions",
+			extensions: []authenticator.Extension{authenticator.AlgorithmExtension(authenticator.AlgorithmHMACSHA256), authenticator.KeyIDExtension("key-1")},
+			readerOpts: []Option{allowlist},
+		},
+		{
+			name:       "allowed CBOR headers",
+			writerOpts: []Option{WithCBORHeaders("key-1")},
+			readerOpts: []Option{allowlist, WithCBORHeaders("")},
+		},
+		{
+			name:       "allowed CBOR headers with format detection",
+			writerOpts: []Option{WithCBORHeaders("key-1")},
+			readerOpts: []Option{allowlist, WithFormatDetection()},
+		},
+		{
+			name:        "unregistered hash function",
+			writerOpts:  []Option{WithHashFn(sha1.New)},
+			readerOpts:  []Option{allowlist, WithHashFn(sha1.New), WithPolicy(nil)},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:        "registered hash function not allowed",
+			writerOpts:  []Option{WithHashFn(sha512.New)},
+			readerOpts:  []Option{allowlist, WithHashFn(sha512.New)},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:        "checksums",
+			writerOpts:  []Option{WithChecksum()},
+			readerOpts:  []Option{allowlist, WithChecksum(), WithPolicy(nil)},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:        "algorithm extension not allowed",
+			extensions:  []authenticator.Extension{authenticator.AlgorithmExtension(authenticator.AlgorithmCRC32C)},
+			readerOpts:  []Option{allowlist},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:        "unknown algorithm extension",
+			extensions:  []authenticator.Extension{authenticator.AlgorithmExtension(authenticator.AlgorithmIDCustom)},
+			readerOpts:  []Option{allowlist},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:        "key ID extension not allowed",
+			extensions:  []authenticator.Extension{authenticator.KeyIDExtension("key-2")},
+			readerOpts:  []Option{allowlist},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:        "CBOR header key ID not allowed",
+			writerOpts:  []Option{WithCBORHeaders("key-2")},
+			readerOpts:  []Option{allowlist, WithCBORHeaders("")},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:        "CBOR headers not allowed with format detection",
+			writerOpts:  []Option{WithCBORHeaders("key-1")},
+			readerOpts:  []Option{WithAllowlist(Allowlist{Algorithms: []string{"hmac-sha256"}}), WithFormatDetection()},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:        "configured key ID not allowed",
+			writerOpts:  []Option{WithCBORHeaders("key-2")},
+			readerOpts:  []Option{allowlist, WithCBORHeaders("key-2")},
+			expectError: authenticator.ErrNotAllowed,
+		},
+		{
+			name:       "empty allowlist",
+			writerOpts: []Option{WithChecksum()},
+			readerOpts: []Option{WithAllowlist(Allowlist{}), WithChecksum(), WithPolicy(nil)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer := NewAppendMACWriter(buf, mockKey, append([]Option{WithPolicy(nil)}, test.writerOpts...)...)
+			if len(test.extensions) > 0 {
+				_, err := writer.WriteWithExtensions([]byte("hello"), test.extensions...)
+				assert.NoError(t, err)
+			} else {
+				_, err := writer.Write([]byte("hello"))
+				assert.NoError(t, err)
+			}
+
+			msg, err := NewVerifyMACReader(buf, mockKey, test.readerOpts...).Next()
+			if test.expectError != nil {
+				assert.True(t, errors.Is(err, test.expectError), "expected %v, got %v", test.expectError, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(msg))
+		})
+	}
+}
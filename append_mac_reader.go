@@ -1,11 +1,12 @@
 package authio
 
 import (
-	"crypto/sha256"
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 
+	"github.com/adrianosela/authio/metrics"
 	"github.com/adrianosela/authio/protocol/authenticator"
 )
 
@@ -14,52 +15,210 @@ type AppendMACReader struct {
 	reader        io.Reader // underlying io.Reader to read from
 	authenticator authenticator.MessageAuthenticator
 	authHeaderLen int
+	maxMessageLen int
+	frameSize     int           // if positive, the size of messages read (see WithReadFrameSize)
+	delimited     *bufio.Reader // if set, messages are records (see WithDelimiter)
+	delimiter     byte
+	recordSize    int // if positive, the size of fixed size records (see WithFixedRecords)
+	paddingBlock  int // if positive, the block size messages are padded to (see WithPadding)
+	compressor    *Compressor
+	pending       []byte // part of a frame which did not fit the buffer given to Read
+	closeNotify   bool
+	closed        bool // whether a close notification was sent
+	metrics       metrics.Metrics
 }
 
+// smallReadMessageSize is the max size of the messages read by AppendMACReaders
+// given buffers too small to fit a frame with any data at all
+const smallReadMessageSize = 512
+
 // ensure AppendMACReader implements io.Reader at compile-time
 var _ io.Reader = (*AppendMACReader)(nil)
 
 // NewAppendMACReader returns a new AppendMACReader
-func NewAppendMACReader(reader io.Reader, key []byte) *AppendMACReader {
-	authenticator := authenticator.NewDefaultMessageAuthenticator(sha256.New, key)
-	return &AppendMACReader{
+func NewAppendMACReader(reader io.Reader, key []byte, opts ...Option) *AppendMACReader {
+	config := newConfig(opts...)
+	authenticator := config.newAuthenticator(key)
+	r := &AppendMACReader{
 		reader:        reader,
 		authenticator: authenticator,
 		authHeaderLen: authenticator.GetMessageAuthenticationHeaderLength(),
+		maxMessageLen: config.maxMessageSize,
+		frameSize:     config.readFrameSize,
+		recordSize:    config.recordSize,
+		paddingBlock:  config.paddingBlockSize,
+		closeNotify:   config.closeNotify,
+		metrics:       config.metrics,
+	}
+	if c, ok := getCompressorByName(config.compression); ok {
+		r.compressor = &c
+	}
+	if config.delimited {
+		r.delimited = bufio.NewReader(reader)
+		r.delimiter = config.delimiter
 	}
+	return r
 }
 
 // Read reads data onto the given buffer
 func (r *AppendMACReader) Read(b []byte) (int, error) {
-	if len(b) < r.authHeaderLen {
-		return 0, fmt.Errorf("buffer too small, cannot fit MAC")
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if r.frameSize > 0 || r.delimited != nil || r.recordSize > 0 || len(r.pending) > 0 {
+		return r.readFramed(b)
 	}
-
 	// read at-most the size of the buffer minus size of mac
 	// (to leave space in the buffer for the added MAC)
-	buf := make([]byte, len(b)-r.authHeaderLen)
+	size := len(b) - r.authHeaderLen
+	if size <= 0 {
+		// the buffer cannot fit a MAC and any data, so the frame
+		// is kept to be returned over this and the next reads
+		size = smallReadMessageSize
+	}
+	if r.maxMessageLen > 0 && size > r.maxMessageLen {
+		size = r.maxMessageLen
+	}
+	buf := make([]byte, size)
 	reader := io.LimitReader(r.reader, int64(len(buf)))
 
 	n, err := reader.Read(buf)
 	if err != nil {
 		if errors.Is(err, io.EOF) {
+			if r.closeNotify && !r.closed {
+				// the end of the input is signaled with a close notification
+				r.closed = true
+				header, err := getCloseNotifyHeader(r.authenticator)
+				if err != nil {
+					return 0, fmt.Errorf("failed to compute close notification: %w", err)
+				}
+				n := copy(b, header)
+				r.pending = header[n:]
+				return n, nil
+			}
 			return 0, io.EOF
 		}
 		if errors.Is(err, io.ErrUnexpectedEOF) {
 			return 0, fmt.Errorf("bad message received, too short to have MAC")
 		}
-		return 0, fmt.Errorf("failed to read message: %s", err)
+		return 0, fmt.Errorf("failed to read message: %w", err)
 	}
 
 	// take portion of buffer actually read into
 	data := buf[:n]
 
 	// compute message authentication header
-	header, err := r.authenticator.GetMessageAuthenticationHeader(data)
+	header, payload, err := encodeMessage(r.authenticator, r.compressor, data, nil, r.paddingBlock)
 	if err != nil {
-		return 0, fmt.Errorf("failed to compute message authentication header for message: %s", err)
+		return 0, fmt.Errorf("failed to compute message authentication header for message: %w", err)
 	}
+	r.metrics.MessageSigned(len(data))
+
+	// copy the message onto the given buffer, keeping whatever
+	// (padding) does not fit to be returned on the next reads
+	frame := append(header, payload...)
+	n = copy(b, frame)
+	r.pending = frame[n:]
+	return n, nil
+}
+
+// readFramed reads a frame of exactly frameSize bytes of the underlying reader
+// (fewer at the end of input), or a (delimited or fixed size) record, onto the
+// given buffer, keeping whatever does not fit to be returned on the next reads
+func (r *AppendMACReader) readFramed(b []byte) (int, error) {
+	if len(r.pending) == 0 {
+		frame, err := r.nextFrame()
+		if err != nil {
+			return 0, err
+		}
+		r.pending = frame
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// nextFrame reads and authenticates the next frame
+func (r *AppendMACReader) nextFrame() ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case r.recordSize > 0:
+		if data, err = r.readFixed(recordCapacity(r.recordSize)); err == nil {
+			data = encodeRecord(r.recordSize, data)
+		}
+	case r.delimited != nil:
+		data, err = r.readRecord()
+	default:
+		data, err = r.readFixed(r.frameSize)
+	}
+	if errors.Is(err, io.EOF) {
+		if r.closeNotify && !r.closed {
+			// the end of the input is signaled with a close notification
+			r.closed = true
+			header, err := getCloseNotifyHeader(r.authenticator)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute close notification: %w", err)
+			}
+			return header, nil
+		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	header, payload, err := encodeMessage(r.authenticator, r.compressor, data, nil, r.paddingBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute message authentication header for message: %w", err)
+	}
+	r.metrics.MessageSigned(len(data))
+	return append(header, payload...), nil
+}
+
+// readFixed reads the given number of bytes of the underlying reader,
+// or whatever is left of it at the end of the input
+func (r *AppendMACReader) readFixed(size int) ([]byte, error) {
+	if r.maxMessageLen > 0 && size > r.maxMessageLen {
+		size = r.maxMessageLen
+	}
+	data := make([]byte, size)
+
+	n, err := io.ReadFull(r.reader, data)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		// a short read is the last frame of the input
+		return data[:n], nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readRecord reads the underlying reader up to and including the delimiter,
+// or up to the max message size, or whatever is left at the end of the input
+func (r *AppendMACReader) readRecord() ([]byte, error) {
+	record := []byte{}
+	for r.maxMessageLen <= 0 || len(record) < r.maxMessageLen {
+		c, err := r.delimited.ReadByte()
+		if errors.Is(err, io.EOF) && len(record) > 0 {
+			// the last record of the input need not be delimited
+			return record, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, c)
+		if c == r.delimiter {
+			break
+		}
+	}
+	return record, nil
+}
 
-	// copy the message onto the given buffer
-	return copy(b, append(header, data...)), nil
+// Close destroys the key material held by the AppendMACReader, after
+// which reads fail. It does not close the underlying reader.
+func (r *AppendMACReader) Close() error {
+	destroyKey(r.authenticator)
+	return nil
 }
@@ -0,0 +1,149 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_AppendMACReaderFrameSize(t *testing.T) {
+	mockKey := []byte("mock key")
+	input := "0123456789"
+
+	tests := []struct {
+		name    string
+		source  io.Reader
+		bufSize int
+	}{
+		{name: "whole input at once", source: strings.NewReader(input), bufSize: 1024},
+		{name: "one byte at a time", source: iotest.OneByteReader(strings.NewReader(input)), bufSize: 1024},
+		{name: "half reads", source: iotest.HalfReader(strings.NewReader(input)), bufSize: 1024},
+		{name: "buffer smaller than frame", source: strings.NewReader(input), bufSize: 5},
+	}
+
+	var expected []byte
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewAppendMACReader(test.source, mockKey, WithReadFrameSize(4))
+
+			out := &bytes.Buffer{}
+			buf := make([]byte, test.bufSize)
+			for {
+				n, err := reader.Read(buf)
+				out.Write(buf[:n])
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				assert.Nil(t, err)
+			}
+
+			// frames do not depend on how the source delivers bytes
+			if expected == nil {
+				expected = out.Bytes()
+			}
+			assert.Equal(t, expected, out.Bytes())
+
+			verifier := NewVerifyMACReader(out, mockKey)
+			for _, msg := range []string{"0123", "4567", "89"} {
+				message, err := verifier.Next()
+				assert.Nil(t, err)
+				assert.Equal(t, msg, string(message))
+			}
+			_, err := verifier.Next()
+			assert.True(t, errors.Is(err, io.EOF))
+		})
+	}
+}
+
+func Test_AppendMACReaderSmallBuffers(t *testing.T) {
+	mockKey := []byte("mock key")
+	input := strings.Repeat("0123456789", 100)
+	headerLen := NewAppendMACReader(nil, mockKey).authHeaderLen
+
+	tests := []struct {
+		name    string
+		bufSize int
+		opts    []Option
+	}{
+		{name: "one byte", bufSize: 1},
+		{name: "smaller than header", bufSize: headerLen - 1},
+		{name: "header only", bufSize: headerLen},
+		{name: "header and one byte", bufSize: headerLen + 1},
+		{name: "one byte with close notification", bufSize: 1, opts: []Option{WithCloseNotify()}},
+		{name: "larger than input", bufSize: 4096},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewAppendMACReader(strings.NewReader(input), mockKey, test.opts...)
+
+			// no data is lost however small the buffer
+			out := &bytes.Buffer{}
+			buf := make([]byte, test.bufSize)
+			for {
+				n, err := reader.Read(buf)
+				out.Write(buf[:n])
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				assert.Nil(t, err)
+			}
+
+			verified, err := io.ReadAll(NewVerifyMACReader(out, mockKey, test.opts...))
+			assert.Nil(t, err)
+			assert.Equal(t, input, string(verified))
+		})
+	}
+}
+
+func Test_WithDelimiter(t *testing.T) {
+	mockKey := []byte("mock key")
+	input := "hello\nworld\n\nno newline"
+	expected := []string{"hello\n", "world\n", "\n", "no newline"}
+
+	tests := []struct {
+		name   string
+		frames func(t *testing.T) []byte
+	}{
+		{
+			name: "AppendMACReader",
+			frames: func(t *testing.T) []byte {
+				source := iotest.OneByteReader(strings.NewReader(input))
+				frames, err := io.ReadAll(NewAppendMACReader(source, mockKey, WithDelimiter('\n')))
+				assert.Nil(t, err)
+				return frames
+			},
+		},
+		{
+			name: "AppendMACWriter",
+			frames: func(t *testing.T) []byte {
+				buf := &bytes.Buffer{}
+				writer := NewAppendMACWriter(buf, mockKey, WithDelimiter('\n'))
+				for _, chunk := range []string{"hel", "lo\nwor", "ld\n\nno new", "line"} {
+					n, err := writer.Write([]byte(chunk))
+					assert.Nil(t, err)
+					assert.Equal(t, len(chunk), n)
+				}
+				assert.Nil(t, writer.Close())
+				return buf.Bytes()
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifier := NewVerifyMACReader(bytes.NewReader(test.frames(t)), mockKey)
+			for _, record := range expected {
+				message, err := verifier.Next()
+				assert.Nil(t, err)
+				assert.Equal(t, record, string(message))
+			}
+			_, err := verifier.Next()
+			assert.True(t, errors.Is(err, io.EOF))
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package authio
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 
+	"github.com/adrianosela/authio/metrics"
 	"github.com/adrianosela/authio/protocol/authenticator"
 )
 
@@ -13,34 +16,274 @@ type AppendMACWriter struct {
 	writer        io.Writer // underlying io.Writer to write to
 	authenticator authenticator.MessageAuthenticator
 	authHeaderLen int
+	maxMessageLen int
+	delimited     bool // whether messages are records (see WithDelimiter)
+	delimiter     byte
+	recordSize    int // if positive, the size of fixed size records (see WithFixedRecords)
+	paddingBlock  int // if positive, the block size messages are padded to (see WithPadding)
+	compressor    *Compressor
+	partial       []byte // partial record written but not yet complete
+	closeNotify   bool
+	metrics       metrics.Metrics
+	config        EffectiveConfig
 }
 
 // ensure AppendMACWriter implements io.Writer at compile-time
 var _ io.Writer = (*AppendMACWriter)(nil)
 
 // NewAppendMACWriter wraps an io.Writer in an AppendMACWriter
-func NewAppendMACWriter(writer io.Writer, key []byte) *AppendMACWriter {
-	authenticator := authenticator.NewDefaultMessageAuthenticator(sha256.New, key)
-	return &AppendMACWriter{
+func NewAppendMACWriter(writer io.Writer, key []byte, opts ...Option) *AppendMACWriter {
+	config := newConfig(opts...)
+	authenticator := config.newAuthenticator(key)
+	w := &AppendMACWriter{
 		writer:        writer,
 		authenticator: authenticator,
 		authHeaderLen: authenticator.GetMessageAuthenticationHeaderLength(),
+		maxMessageLen: config.maxMessageSize,
+		delimited:     config.delimited,
+		delimiter:     config.delimiter,
+		recordSize:    config.recordSize,
+		paddingBlock:  config.paddingBlockSize,
+		closeNotify:   config.closeNotify,
+		metrics:       config.metrics,
+		config:        config.effectiveConfig(authenticator),
 	}
+	if c, ok := getCompressorByName(config.compression); ok {
+		w.compressor = &c
+	}
+	return w
 }
 
-// Write writes the contents of a buffer to a writer (with an included MAC)
+// Write writes the contents of a buffer to a writer (with an included MAC). If
+// a max message size is set, larger buffers are written as several messages.
+// If configured WithDelimiter or WithFixedRecords, every record is written as
+// a message instead.
 func (w *AppendMACWriter) Write(b []byte) (int, error) {
-	header, err := w.authenticator.GetMessageAuthenticationHeader(b)
+	if w.recordSize > 0 {
+		return w.writeFixedRecords(b)
+	}
+	if w.delimited {
+		return w.writeRecords(b)
+	}
+	if w.maxMessageLen <= 0 || len(b) <= w.maxMessageLen {
+		return w.writeMessage(b)
+	}
+
+	written := 0
+	for len(b) > 0 {
+		size := len(b)
+		if size > w.maxMessageLen {
+			size = w.maxMessageLen
+		}
+		n, err := w.writeMessage(b[:size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[size:]
+	}
+	return written, nil
+}
+
+// writeRecords writes every complete record (see WithDelimiter) in the partial
+// record written so far followed by the given buffer as a message, keeping the
+// rest until it is delimited
+func (w *AppendMACWriter) writeRecords(b []byte) (int, error) {
+	prevLen := len(w.partial)
+	w.partial = append(w.partial, b...)
+
+	consumed := 0
+	for {
+		end := bytes.IndexByte(w.partial[consumed:], w.delimiter) + 1
+		if end == 0 {
+			if w.maxMessageLen <= 0 || len(w.partial)-consumed < w.maxMessageLen {
+				break
+			}
+			end = w.maxMessageLen
+		}
+		if w.maxMessageLen > 0 && end > w.maxMessageLen {
+			end = w.maxMessageLen
+		}
+		if _, err := w.writeMessage(w.partial[consumed : consumed+end]); err != nil {
+			// keep what was written before, but not the rest of the buffer
+			if consumed < prevLen {
+				w.partial = append([]byte(nil), w.partial[consumed:prevLen]...)
+				return 0, err
+			}
+			w.partial = nil
+			return consumed - prevLen, err
+		}
+		consumed += end
+	}
+	w.partial = append([]byte(nil), w.partial[consumed:]...)
+	return len(b), nil
+}
+
+// writeFixedRecords writes the partial record written so far followed by the
+// given buffer as fixed size records (see WithFixedRecords), keeping the rest
+// until it fills a record
+func (w *AppendMACWriter) writeFixedRecords(b []byte) (int, error) {
+	prevLen := len(w.partial)
+	w.partial = append(w.partial, b...)
+
+	capacity := recordCapacity(w.recordSize)
+	consumed := 0
+	for len(w.partial)-consumed >= capacity {
+		record := encodeRecord(w.recordSize, w.partial[consumed:consumed+capacity])
+		if _, err := w.writeMessage(record); err != nil {
+			// keep what was written before, but not the rest of the buffer
+			if consumed < prevLen {
+				w.partial = append([]byte(nil), w.partial[consumed:prevLen]...)
+				return 0, err
+			}
+			w.partial = nil
+			return consumed - prevLen, err
+		}
+		consumed += capacity
+	}
+	w.partial = append([]byte(nil), w.partial[consumed:]...)
+	return len(b), nil
+}
+
+// writeMessage writes the contents of a buffer as a single message
+func (w *AppendMACWriter) writeMessage(b []byte) (int, error) {
+	header, payload, err := encodeMessage(w.authenticator, w.compressor, b, nil, w.paddingBlock)
 	if err != nil {
-		return 0, fmt.Errorf("failed to compute MAC for message: %s", err)
+		return 0, fmt.Errorf("failed to compute MAC for message: %w", err)
 	}
-	n, err := w.writer.Write(append(header, b...))
+	w.metrics.MessageSigned(len(b))
+	n, err := w.writer.Write(append(header, payload...))
 	if err != nil {
-		if n >= w.authHeaderLen {
-			return n - w.authHeaderLen, fmt.Errorf("failed to write authenticated message: %s", err)
+		return writtenMessageBytes(n, b, header, payload), fmt.Errorf("failed to write authenticated message: %w", err)
+	}
+	return len(b), nil
+}
+
+// WriteWithExtensions writes the contents of a buffer as a single message
+// (with an included MAC) with the given extensions (see authenticator.Extension),
+// which are covered by the MAC. It fails for messages larger than the max
+// message size, and if the MessageAuthenticator does not support extensions.
+func (w *AppendMACWriter) WriteWithExtensions(b []byte, extensions ...authenticator.Extension) (int, error) {
+	if w.recordSize > 0 {
+		return 0, errors.New("extensions are not supported with fixed size records")
+	}
+	if _, ok := w.authenticator.(authenticator.ExtensionFramer); !ok {
+		return 0, fmt.Errorf("%T does not support extensions", w.authenticator)
+	}
+	if w.maxMessageLen > 0 && len(b) > w.maxMessageLen {
+		return 0, fmt.Errorf("message too large, got %d and expected at most %d", len(b), w.maxMessageLen)
+	}
+	header, payload, err := encodeMessage(w.authenticator, w.compressor, b, extensions, w.paddingBlock)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute MAC for message: %w", err)
+	}
+	w.metrics.MessageSigned(len(b))
+	n, err := w.writer.Write(append(header, payload...))
+	if err != nil {
+		return writtenMessageBytes(n, b, header, payload), fmt.Errorf("failed to write authenticated message: %w", err)
+	}
+	return len(b), nil
+}
+
+// WriteBatch writes several messages (each with an included MAC) to the
+// underlying writer at once, and returns the number of messages written in
+// full. Messages larger than the max message size are split as with Write.
+// If the underlying writer is a net.Conn, the messages are written with
+// net.Buffers (i.e. a single writev syscall where supported), otherwise they
+// are written with a single call to Write.
+func (w *AppendMACWriter) WriteBatch(messages [][]byte) (int, error) {
+	if w.recordSize > 0 {
+		return 0, errors.New("batches are not supported with fixed size records")
+	}
+	frames := make(net.Buffers, 0, 2*len(messages))
+	ends := make([]int64, 0, len(messages)) // offset at which each message ends
+	size := int64(0)
+
+	for _, message := range messages {
+		for first := true; first || len(message) > 0; first = false {
+			chunk := message
+			if w.maxMessageLen > 0 && len(chunk) > w.maxMessageLen {
+				chunk = chunk[:w.maxMessageLen]
+			}
+			header, payload, err := encodeMessage(w.authenticator, w.compressor, chunk, nil, w.paddingBlock)
+			if err != nil {
+				return 0, fmt.Errorf("failed to compute MAC for message: %w", err)
+			}
+			w.metrics.MessageSigned(len(chunk))
+			frames = append(frames, header, payload)
+			size += int64(len(header) + len(payload))
+			message = message[len(chunk):]
 		}
-		// no message bytes were written (only header)
-		return 0, fmt.Errorf("failed to write authenticated message: %s", err)
+		ends = append(ends, size)
+	}
+
+	var n int64
+	var err error
+	if _, ok := w.writer.(net.Conn); ok {
+		n, err = frames.WriteTo(w.writer)
+	} else {
+		buf := make([]byte, 0, size)
+		for _, frame := range frames {
+			buf = append(buf, frame...)
+		}
+		var written int
+		written, err = w.writer.Write(buf)
+		n = int64(written)
+	}
+
+	written := 0
+	for written < len(ends) && ends[written] <= n {
+		written++
+	}
+	if err != nil {
+		return written, fmt.Errorf("failed to write authenticated messages: %w", err)
+	}
+	return written, nil
+}
+
+// writeControlFrame writes a control frame with the given payload
+func (w *AppendMACWriter) writeControlFrame(payload []byte) error {
+	framer, ok := w.authenticator.(authenticator.ControlFramer)
+	if !ok {
+		return fmt.Errorf("%T does not support control frames", w.authenticator)
+	}
+	header, err := framer.GetControlFrameHeader(payload)
+	if err != nil {
+		return fmt.Errorf("failed to compute MAC for control frame: %w", err)
+	}
+	if _, err := w.writer.Write(append(header, payload...)); err != nil {
+		return fmt.Errorf("failed to write control frame: %w", err)
+	}
+	return nil
+}
+
+// Close writes any partial record (see WithDelimiter), sends a close
+// notification if configured WithCloseNotify, and destroys the key
+// material held by the AppendMACWriter, after which writes fail. It
+// does not close the underlying writer.
+func (w *AppendMACWriter) Close() error {
+	defer destroyKey(w.authenticator)
+
+	if len(w.partial) > 0 {
+		// the last record need not be delimited nor full
+		last := w.partial
+		if w.recordSize > 0 {
+			last = encodeRecord(w.recordSize, last)
+		}
+		if _, err := w.writeMessage(last); err != nil {
+			return err
+		}
+		w.partial = nil
+	}
+	if !w.closeNotify {
+		return nil
+	}
+	header, err := getCloseNotifyHeader(w.authenticator)
+	if err != nil {
+		return fmt.Errorf("failed to compute close notification: %w", err)
+	}
+	if _, err := w.writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write close notification: %w", err)
 	}
-	return n - w.authHeaderLen, nil
+	return nil
 }
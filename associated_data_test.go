@@ -0,0 +1,56 @@
+package authio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithAssociatedData(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name        string
+		writeAAD    []byte
+		readAAD     []byte
+		expectError bool
+	}{
+		{
+			name:     "Same associated data",
+			writeAAD: []byte("connection 1"),
+			readAAD:  []byte("connection 1"),
+		},
+		{
+			name:        "Different associated data",
+			writeAAD:    []byte("connection 1"),
+			readAAD:     []byte("connection 2"),
+			expectError: true,
+		},
+		{
+			name:        "Associated data only when writing",
+			writeAAD:    []byte("connection 1"),
+			expectError: true,
+		},
+		{
+			name:        "Associated data only when reading",
+			readAAD:     []byte("connection 1"),
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			_, err := NewAppendMACWriter(buf, mockKey, WithAssociatedData(test.writeAAD)).Write([]byte("hello"))
+			assert.NoError(t, err)
+
+			msg, err := NewVerifyMACReader(buf, mockKey, WithAssociatedData(test.readAAD)).Next()
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(msg))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package authio
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"hash"
+	"time"
+)
+
+// MaxIdentityLength is the maximum length of identities (see WithIdentity),
+// which identity control frames carry after their type byte
+const MaxIdentityLength = 1024
+
+// AuthInfo describes how a Conn authenticates its peer
+type AuthInfo struct {
+	// KeyID is the ID of the key set with WithKeyProvider, if any
+	KeyID string
+
+	// Algorithm is the name of the algorithm MACs are computed with, e.g.
+	// "HMAC-SHA-256" or the name given to WithAlgorithm. It is configured
+	// on both sides, unless negotiated by a handshake (see WithAlgorithms).
+	Algorithm string
+
+	// Established is the time the Conn was created
+	Established time.Time
+
+	// PeerIdentity is the identity the peer announced with WithIdentity,
+	// which is empty until the first message of the peer is read. It is
+	// authenticated with the key of the Conn, so it can only be trusted
+	// as much as every holder of that key.
+	PeerIdentity string
+}
+
+// WithIdentity makes a Conn announce the given identity (e.g. the name of a
+// client) to its peer in an authenticated control frame before the first
+// message it writes, such that the peer can tell holders of the same key
+// apart (see Conn.AuthInfo). Both peers must use authio versions which
+// support control frames. It has no effect on anything other than Conns.
+func WithIdentity(identity string) Option {
+	return func(c *config) { c.identity = identity }
+}
+
+// AuthInfo returns how the Conn authenticates its peer. It is
+// safe to call concurrently with reads and writes.
+func (c *Conn) AuthInfo() AuthInfo {
+	info := AuthInfo{
+		KeyID:       c.keyID,
+		Algorithm:   c.algorithm,
+		Established: c.created,
+	}
+	if identity := c.peerIdentity.Load(); identity != nil {
+		info.PeerIdentity = *identity
+	}
+	return info
+}
+
+// writeIdentity writes the identity of the Conn (if any) unless it was
+// already written, the write lock must be held
+func (c *Conn) writeIdentity() error {
+	if c.identity == "" || c.identitySent {
+		return nil
+	}
+	if len(c.identity) > MaxIdentityLength {
+		return fmt.Errorf("identity too long, got %d bytes and expected at most %d", len(c.identity), MaxIdentityLength)
+	}
+	payload := controlPayload(ControlTypeIdentity, []byte(c.identity))
+	if err := c.writer.writeControlFrame(payload); err != nil {
+		return fmt.Errorf("failed to write identity: %w", err)
+	}
+	c.identitySent = true
+	return nil
+}
+
+// algorithmName returns the name of the algorithm MACs are computed with
+func (c *config) algorithmName() string {
+	switch {
+	case c.authenticator != nil:
+		return fmt.Sprintf("%T", c.authenticator)
+	case c.algorithm != "":
+		return c.algorithm
+	case c.checksum:
+		return "CRC-32C"
+	}
+	if h, ok := identifyHash(c.hashFn); ok {
+		return "HMAC-" + h.String()
+	}
+	return "HMAC"
+}
+
+// identifyHash returns the crypto.Hash the given hash function implements, if any
+func identifyHash(hashFn func() hash.Hash) (crypto.Hash, bool) {
+	h := hashFn()
+	h.Write(policyProbe)
+	sum := h.Sum(nil)
+	for candidate := crypto.MD4; candidate <= crypto.BLAKE2b_512; candidate++ {
+		if !candidate.Available() || candidate.Size() != len(sum) {
+			continue
+		}
+		ch := candidate.New()
+		ch.Write(policyProbe)
+		if bytes.Equal(sum, ch.Sum(nil)) {
+			return candidate, true
+		}
+	}
+	return 0, false
+}
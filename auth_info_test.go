@@ -0,0 +1,79 @@
+package authio
+
+import (
+	"crypto/sha512"
+	"net"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_ListenerAuthInfo(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	l, err := Listen("tcp", "127.0.0.1:0", mockKey, WithHashFn(sha512.New))
+	assert.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan *Conn, 1)
+	go func() {
+		conn, err := l.AcceptConn()
+		assert.NoError(t, err)
+		accepted <- conn
+	}()
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	client := NewClientConn(raw, mockKey, WithHashFn(sha512.New), WithIdentity("client-a"))
+	defer client.Close()
+
+	server := <-accepted
+	defer server.Close()
+
+	info := server.AuthInfo()
+	assert.Equal(t, "HMAC-SHA-512", info.Algorithm)
+	assert.Equal(t, "", info.PeerIdentity, "no message read yet")
+	assert.False(t, info.Established.IsZero())
+
+	_, err = client.Write([]byte("hello"))
+	assert.NoError(t, err)
+	_, err = client.Write([]byte("world"))
+	assert.NoError(t, err)
+
+	buf := make([]byte, 64)
+	for _, expected := range []string{"hello", "world"} {
+		n, err := server.Read(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(buf[:n]))
+	}
+	assert.Equal(t, "client-a", server.AuthInfo().PeerIdentity)
+	assert.Equal(t, "", client.AuthInfo().PeerIdentity)
+}
+
+func Test_AlgorithmName(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: "HMAC-SHA-256",
+		},
+		{
+			name:     "checksum",
+			opts:     []Option{WithChecksum()},
+			expected: "CRC-32C",
+		},
+		{
+			name:     "registered algorithm",
+			opts:     []Option{WithAlgorithm("some-algorithm")},
+			expected: "some-algorithm",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, newConfig(test.opts...).algorithmName())
+		})
+	}
+}
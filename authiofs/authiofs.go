@@ -0,0 +1,205 @@
+package authiofs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// DefaultSidecarExtension is the extension of sidecar MAC files, i.e.
+	// the MAC for a file "config.json" is in the file "config.json.mac"
+	DefaultSidecarExtension = ".mac"
+)
+
+// ErrMACMismatch is returned (as the error of a fs.PathError) upon
+// reaching the end of a file whose contents do not match its MAC
+var ErrMACMismatch = authenticator.ErrMACMismatch
+
+// Option represents a configuration option for an FS
+type Option func(*FS)
+
+// WithHashFn sets the hash function used to compute MACs
+func WithHashFn(hashFn func() hash.Hash) Option {
+	return func(f *FS) { f.hashFn = hashFn }
+}
+
+// WithSidecarExtension sets the extension of sidecar MAC files
+func WithSidecarExtension(ext string) Option {
+	return func(f *FS) { f.sidecarExt = ext }
+}
+
+// WithTrailer makes the FS expect MACs to be embedded as a trailer at the
+// end of every file rather than in sidecar files. The trailer is never
+// returned to the reader of the file and is excluded from its size.
+func WithTrailer() Option {
+	return func(f *FS) { f.trailer = true }
+}
+
+// FS is an fs.FS which verifies the MAC of every file opened, which covers
+// the (cleaned) path of the file as well as its contents, such that files
+// cannot be swapped along with their MACs (see ComputeMAC). Note that
+// file contents are returned as they are read, and that verification
+// only happens upon reaching the end of the file. Callers must read files
+// in full and discard the contents read if the final Read fails.
+type FS struct {
+	fsys       fs.FS // underlying fs.FS to open files from
+	key        []byte
+	hashFn     func() hash.Hash
+	sidecarExt string
+	trailer    bool
+}
+
+// ensure FS implements fs.FS at compile-time
+var _ fs.FS = (*FS)(nil)
+
+// New wraps an fs.FS in an FS
+func New(fsys fs.FS, key []byte, opts ...Option) *FS {
+	f := &FS{
+		fsys:       fsys,
+		key:        key,
+		hashFn:     sha256.New,
+		sidecarExt: DefaultSidecarExtension,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Open opens the named file. Directories are returned as-is.
+func (f *FS) Open(name string) (fs.File, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return file, nil
+	}
+
+	vf := &verifyingFile{
+		File:   file,
+		name:   name,
+		info:   info,
+		reader: file,
+		hash:   f.newMAC(name),
+	}
+
+	if f.trailer {
+		trailerLen := encodedMACLength(f.hashFn)
+		if info.Size() < trailerLen {
+			file.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("%w: file too short to have MAC trailer", ErrMACMismatch)}
+		}
+		vf.info = &trailerFileInfo{FileInfo: info, trailerLen: trailerLen}
+		vf.reader = io.LimitReader(file, info.Size()-trailerLen)
+		vf.trailerLen = trailerLen
+		return vf, nil
+	}
+
+	sidecar, err := fs.ReadFile(f.fsys, name+f.sidecarExt)
+	if err != nil {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("failed to read MAC sidecar file: %w", err)}
+	}
+	vf.expected = []byte(strings.TrimSpace(string(sidecar)))
+	return vf, nil
+}
+
+// ComputeMAC returns the (base64 encoded) MAC of a file with the given name
+// and contents, i.e. the contents of its sidecar file or its trailer
+func (f *FS) ComputeMAC(name string, data []byte) string {
+	mac := f.newMAC(name)
+	mac.Write(data)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newMAC returns an HMAC over the length-prefixed cleaned path of
+// the named file, to which the contents of the file are written
+func (f *FS) newMAC(name string) hash.Hash {
+	cleaned := path.Clean(name)
+	mac := hmac.New(f.hashFn, f.key)
+	// note: hash.Write() never returns an error as per godoc (https://pkg.go.dev/hash#Hash)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(len(cleaned))))
+	mac.Write([]byte(cleaned))
+	return mac
+}
+
+// verifyingFile is an fs.File which computes a MAC over its contents as
+// they are read and compares it against the expected MAC at the end
+type verifyingFile struct {
+	fs.File
+
+	name       string
+	info       fs.FileInfo
+	reader     io.Reader
+	hash       hash.Hash
+	expected   []byte // base64 encoded MAC (nil until read for trailers)
+	trailerLen int64
+	done       bool
+}
+
+func (f *verifyingFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *verifyingFile) Read(b []byte) (int, error) {
+	if f.done {
+		return 0, io.EOF
+	}
+
+	n, err := f.reader.Read(b)
+	f.hash.Write(b[:n])
+
+	if errors.Is(err, io.EOF) {
+		if verr := f.verify(); verr != nil {
+			return n, &fs.PathError{Op: "read", Path: f.name, Err: verr}
+		}
+		f.done = true
+	}
+	return n, err
+}
+
+func (f *verifyingFile) verify() error {
+	if f.trailerLen > 0 {
+		f.expected = make([]byte, f.trailerLen)
+		if _, err := io.ReadFull(f.File, f.expected); err != nil {
+			return fmt.Errorf("failed to read MAC trailer: %w", err)
+		}
+	}
+	computed := base64.StdEncoding.EncodeToString(f.hash.Sum(nil))
+	if !hmac.Equal(f.expected, []byte(computed)) {
+		return ErrMACMismatch
+	}
+	return nil
+}
+
+// trailerFileInfo is an fs.FileInfo which excludes the MAC trailer from the file size
+type trailerFileInfo struct {
+	fs.FileInfo
+	trailerLen int64
+}
+
+func (i *trailerFileInfo) Size() int64 {
+	return i.FileInfo.Size() - i.trailerLen
+}
+
+func encodedMACLength(hashFn func() hash.Hash) int64 {
+	return int64(base64.StdEncoding.EncodedLen(hashFn().Size()))
+}
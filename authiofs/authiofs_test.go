@@ -0,0 +1,135 @@
+package authiofs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_FS(t *testing.T) {
+	mockKey := []byte("mock key")
+	mockData := []byte("mock data")
+	mockMAC := computeMockMAC(mockKey, "file", mockData)
+	otherData := []byte("other data")
+	otherMAC := computeMockMAC(mockKey, "other", otherData)
+
+	tests := []struct {
+		name         string
+		fsys         fstest.MapFS
+		opts         []Option
+		expectedData string
+		expectedErr  error
+	}{
+		{
+			name: "Valid sidecar",
+			fsys: fstest.MapFS{
+				"file":     {Data: mockData},
+				"file.mac": {Data: append([]byte(mockMAC), '\n')},
+			},
+			expectedData: string(mockData),
+		},
+		{
+			name: "Valid sidecar with custom extension",
+			fsys: fstest.MapFS{
+				"file":      {Data: mockData},
+				"file.hmac": {Data: []byte(mockMAC)},
+			},
+			opts:         []Option{WithSidecarExtension(".hmac")},
+			expectedData: string(mockData),
+		},
+		{
+			name: "Tampered file with sidecar",
+			fsys: fstest.MapFS{
+				"file":     {Data: []byte("tampered data")},
+				"file.mac": {Data: []byte(mockMAC)},
+			},
+			expectedErr: ErrMACMismatch,
+		},
+		{
+			name: "Swapped file with sidecar",
+			fsys: fstest.MapFS{
+				"file":      {Data: otherData},
+				"file.mac":  {Data: []byte(otherMAC)},
+				"other":     {Data: mockData},
+				"other.mac": {Data: []byte(mockMAC)},
+			},
+			expectedErr: ErrMACMismatch,
+		},
+		{
+			name: "Missing sidecar",
+			fsys: fstest.MapFS{
+				"file": {Data: mockData},
+			},
+			expectedErr: fs.ErrNotExist,
+		},
+		{
+			name: "Valid trailer",
+			fsys: fstest.MapFS{
+				"file": {Data: append(append([]byte{}, mockData...), mockMAC...)},
+			},
+			opts:         []Option{WithTrailer()},
+			expectedData: string(mockData),
+		},
+		{
+			name: "Tampered file with trailer",
+			fsys: fstest.MapFS{
+				"file": {Data: append([]byte("tampered data"), mockMAC...)},
+			},
+			opts:        []Option{WithTrailer()},
+			expectedErr: ErrMACMismatch,
+		},
+		{
+			name: "Swapped file with trailer",
+			fsys: fstest.MapFS{
+				"file":  {Data: append(append([]byte{}, otherData...), otherMAC...)},
+				"other": {Data: append(append([]byte{}, mockData...), mockMAC...)},
+			},
+			opts:        []Option{WithTrailer()},
+			expectedErr: ErrMACMismatch,
+		},
+		{
+			name: "File too short to have trailer",
+			fsys: fstest.MapFS{
+				"file": {Data: mockData},
+			},
+			opts:        []Option{WithTrailer()},
+			expectedErr: ErrMACMismatch,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := fs.ReadFile(New(test.fsys, mockKey, test.opts...), "file")
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedData, string(data))
+		})
+	}
+}
+
+func Test_ComputeMAC(t *testing.T) {
+	mockKey := []byte("mock key")
+	mockData := []byte("mock data")
+
+	fsys := New(fstest.MapFS{}, mockKey)
+	assert.Equal(t, computeMockMAC(mockKey, "dir/file", mockData), fsys.ComputeMAC("dir/file", mockData))
+	assert.Equal(t, fsys.ComputeMAC("dir/file", mockData), fsys.ComputeMAC("dir/./file", mockData))
+	assert.NotEqual(t, fsys.ComputeMAC("dir/file", mockData), fsys.ComputeMAC("dir/other", mockData))
+}
+
+func computeMockMAC(key []byte, name string, data []byte) string {
+	computed := hmac.New(sha256.New, key)
+	computed.Write(binary.BigEndian.AppendUint64(nil, uint64(len(name))))
+	computed.Write([]byte(name))
+	computed.Write(data)
+	return base64.StdEncoding.EncodeToString(computed.Sum(nil))
+}
@@ -0,0 +1,160 @@
+package authiohttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// DefaultHeaderName is the name of the HTTP header MACs are sent in
+	DefaultHeaderName = "Authio-Mac"
+	// HeaderTimestamp is the HTTP header with the (signed) unix time a request was sent at
+	HeaderTimestamp = "Authio-Timestamp"
+	// HeaderKeyID is the HTTP header with the ID of the key a request was signed with
+	HeaderKeyID = "Authio-Key-Id"
+	// DefaultMaxBodySize is the maximum size of bodies the middleware will read
+	DefaultMaxBodySize = 10 * 1024 * 1024
+	// DefaultMaxAge is the maximum difference between the time a request
+	// was signed at and the time the middleware verifies it
+	DefaultMaxAge = 5 * time.Minute
+)
+
+var (
+	// ErrMACMismatch is returned when the MAC of a request is invalid
+	ErrMACMismatch = authenticator.ErrMACMismatch
+	// ErrRequestExpired is returned when the timestamp of a request is outside the max age
+	ErrRequestExpired = errors.New("request timestamp outside of max age")
+)
+
+// Option represents a configuration option for the middleware and transport
+type Option func(*config)
+
+type config struct {
+	hashFn        func() hash.Hash
+	headerName    string
+	signedHeaders []string
+	maxBodySize   int64
+	keyID         string
+	maxAge        time.Duration
+	clock         authio.Clock
+}
+
+func newConfig(opts ...Option) *config {
+	c := &config{
+		hashFn:      sha256.New,
+		headerName:  DefaultHeaderName,
+		maxBodySize: DefaultMaxBodySize,
+		maxAge:      DefaultMaxAge,
+		clock:       authio.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithHashFn sets the hash function used to compute MACs
+func WithHashFn(hashFn func() hash.Hash) Option {
+	return func(c *config) { c.hashFn = hashFn }
+}
+
+// WithHeaderName sets the name of the HTTP header MACs are sent in
+func WithHeaderName(name string) Option {
+	return func(c *config) { c.headerName = name }
+}
+
+// WithSignedHeaders sets the (order-sensitive) names of HTTP headers covered
+// by the MAC in addition to the method, request URI, timestamp, and body.
+// Both ends must agree on the same list.
+func WithSignedHeaders(names ...string) Option {
+	return func(c *config) { c.signedHeaders = names }
+}
+
+// WithMaxBodySize sets the maximum size of bodies the
+// middleware will read into memory for verification
+func WithMaxBodySize(size int64) Option {
+	return func(c *config) { c.maxBodySize = size }
+}
+
+// WithKeyID sets the ID of the key (of the KeyProvider) the transport signs
+// requests with, which is sent in the Authio-Key-Id header (default "")
+func WithKeyID(keyID string) Option {
+	return func(c *config) { c.keyID = keyID }
+}
+
+// WithMaxAge sets the maximum difference between the time a request was
+// signed at and the time the middleware verifies it (default DefaultMaxAge),
+// which bounds the window in which captured requests can be replayed
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(c *config) { c.maxAge = maxAge }
+}
+
+// WithClock sets the Clock requests are timestamped (by the transport)
+// and checked for freshness (by the middleware) with
+func WithClock(clock authio.Clock) Option {
+	return func(c *config) { c.clock = clock }
+}
+
+// newAuthenticator returns a MessageAuthenticator for request bodies which
+// binds the request's method, URI, timestamp, key ID, and signed headers
+func (c *config) newAuthenticator(key []byte, method, requestURI, timestamp, keyID string, header http.Header) *authenticator.DefaultMessageAuthenticator {
+	aad := &bytes.Buffer{}
+	for _, field := range []string{method, requestURI, timestamp, keyID} {
+		aad.WriteString(strconv.Itoa(len(field)) + ":" + field + "\n")
+	}
+	for _, name := range c.signedHeaders {
+		aad.WriteString(strings.ToLower(name) + ":" + strings.Join(header.Values(name), ",") + "\n")
+	}
+	return authenticator.NewDefaultMessageAuthenticator(c.hashFn, key).WithAssociatedData(aad.Bytes())
+}
+
+// sign returns the base64 encoded frame header (i.e. length and MAC) of the body
+func sign(a authenticator.MessageAuthenticator, body []byte) (string, error) {
+	header, err := a.GetMessageAuthenticationHeader(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute MAC: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(header), nil
+}
+
+// verify verifies the base64 encoded frame header of the body (see sign)
+func verify(a authenticator.MessageAuthenticator, mac string, body []byte) error {
+	header, err := base64.StdEncoding.DecodeString(mac)
+	if err != nil {
+		return fmt.Errorf("%w: MAC is not base64 encoded: %s", ErrMACMismatch, err)
+	}
+	payload, err := a.ReadNext(io.MultiReader(bytes.NewReader(header), bytes.NewReader(body)))
+	if err != nil {
+		return err
+	}
+	// the header must cover all of the body (rather than a prefix of it)
+	if len(payload) != len(body) {
+		return fmt.Errorf("%w: MAC covers %d of %d bytes of the body", ErrMACMismatch, len(payload), len(body))
+	}
+	return nil
+}
+
+// checkTimestamp returns an error if the given unix time is outside the max age
+func (c *config) checkTimestamp(timestamp string) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid or missing timestamp: %w", err)
+	}
+	age := c.clock.Now().Sub(time.Unix(seconds, 0))
+	if age > c.maxAge || age < -c.maxAge {
+		return ErrRequestExpired
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package authiohttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_MiddlewareAndTransport(t *testing.T) {
+	mockKey := []byte("mock key")
+	mockBody := "mock data"
+	keys := authio.KeyProviderFunc(func(_ context.Context, keyID string) ([]byte, error) {
+		switch keyID {
+		case "":
+			return mockKey, nil
+		case "other":
+			return []byte("other key"), nil
+		default:
+			return nil, fmt.Errorf("unknown key %q", keyID)
+		}
+	})
+	past := authio.ClockFunc(func() time.Time { return time.Now().Add(-time.Hour) })
+	future := authio.ClockFunc(func() time.Time { return time.Now().Add(time.Hour) })
+
+	tests := []struct {
+		name           string
+		clientKey      []byte
+		clientOpts     []Option
+		serverOpts     []Option
+		tamper         func(r *http.Request)
+		expectedStatus int
+	}{
+		{
+			name:           "Valid MAC",
+			clientKey:      mockKey,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Valid MAC with signed headers",
+			clientKey:      mockKey,
+			clientOpts:     []Option{WithSignedHeaders("Content-Type", "X-Mock")},
+			serverOpts:     []Option{WithSignedHeaders("Content-Type", "X-Mock")},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Wrong key",
+			clientKey:      []byte("other key"),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Tampered signed header",
+			clientKey:      mockKey,
+			clientOpts:     []Option{WithSignedHeaders("X-Mock")},
+			serverOpts:     []Option{WithSignedHeaders("X-Mock")},
+			tamper:         func(r *http.Request) { r.Header.Set("X-Mock", "tampered") },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Tampered method",
+			clientKey:      mockKey,
+			tamper:         func(r *http.Request) { r.Method = http.MethodPut },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Tampered request URI",
+			clientKey:      mockKey,
+			tamper:         func(r *http.Request) { r.URL.Path = "/other" },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Tampered query",
+			clientKey:      mockKey,
+			tamper:         func(r *http.Request) { r.URL.RawQuery = "mock=other" },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:      "Tampered timestamp",
+			clientKey: mockKey,
+			tamper: func(r *http.Request) {
+				r.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix()+1, 10))
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Missing timestamp",
+			clientKey:      mockKey,
+			tamper:         func(r *http.Request) { r.Header.Del(HeaderTimestamp) },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Expired timestamp",
+			clientKey:      mockKey,
+			clientOpts:     []Option{WithClock(past)},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Timestamp from the future",
+			clientKey:      mockKey,
+			clientOpts:     []Option{WithClock(future)},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Timestamp within max age",
+			clientKey:      mockKey,
+			clientOpts:     []Option{WithClock(past)},
+			serverOpts:     []Option{WithMaxAge(2 * time.Hour)},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Key ID",
+			clientKey:      []byte("other key"),
+			clientOpts:     []Option{WithKeyID("other")},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Tampered key ID",
+			clientKey:      mockKey,
+			tamper:         func(r *http.Request) { r.Header.Set(HeaderKeyID, "other") },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown key ID",
+			clientKey:      mockKey,
+			clientOpts:     []Option{WithKeyID("unknown")},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:      "Appended body",
+			clientKey: mockKey,
+			tamper: func(r *http.Request) {
+				r.Body = io.NopCloser(strings.NewReader(mockBody + " appended"))
+				r.ContentLength += int64(len(" appended"))
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Missing MAC",
+			clientKey:      mockKey,
+			tamper:         func(r *http.Request) { r.Header.Del(DefaultHeaderName) },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Body too large",
+			clientKey:      mockKey,
+			serverOpts:     []Option{WithMaxBodySize(int64(len(mockBody) - 1))},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, mockBody, string(body))
+			})
+			server := httptest.NewServer(NewMiddleware(keys, test.serverOpts...)(handler))
+			defer server.Close()
+
+			var base http.RoundTripper = http.DefaultTransport
+			if test.tamper != nil {
+				base = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+					test.tamper(r)
+					return http.DefaultTransport.RoundTrip(r)
+				})
+			}
+			client := &http.Client{Transport: NewTransport(base, authio.StaticKey(test.clientKey), test.clientOpts...)}
+
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/mock?mock=value", strings.NewReader(mockBody))
+			assert.NoError(t, err)
+			req.Header.Set("X-Mock", "mock value")
+
+			resp, err := client.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
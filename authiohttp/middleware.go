@@ -0,0 +1,64 @@
+package authiohttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/adrianosela/authio"
+)
+
+// NewMiddleware returns HTTP middleware which verifies the MAC over the
+// method, request URI, timestamp, body (and signed headers) of every request
+// before passing it on to the next handler, with the key (of the given
+// KeyProvider) with the ID in its Authio-Key-Id header. Requests with a
+// missing or invalid MAC, or a timestamp outside the max age (see
+// WithMaxAge), are rejected with 401.
+func NewMiddleware(provider authio.KeyProvider, opts ...Option) func(http.Handler) http.Handler {
+	config := newConfig(opts...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mac := r.Header.Get(config.headerName)
+			if mac == "" {
+				http.Error(w, "missing MAC", http.StatusUnauthorized)
+				return
+			}
+
+			timestamp := r.Header.Get(HeaderTimestamp)
+			if err := config.checkTimestamp(timestamp); err != nil {
+				http.Error(w, "stale or missing timestamp", http.StatusUnauthorized)
+				return
+			}
+
+			// read at-most one more byte than the max size to detect oversized bodies
+			body, err := io.ReadAll(io.LimitReader(r.Body, config.maxBodySize+1))
+			if err != nil {
+				http.Error(w, "failed to read body", http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > config.maxBodySize {
+				http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			keyID := r.Header.Get(HeaderKeyID)
+			key, err := provider.GetKey(r.Context(), keyID)
+			if err != nil {
+				http.Error(w, "unknown key", http.StatusUnauthorized)
+				return
+			}
+
+			auth := config.newAuthenticator(key, r.Method, r.RequestURI, timestamp, keyID, r.Header)
+			defer auth.Destroy()
+			if err := verify(auth, mac, body); err != nil {
+				http.Error(w, "invalid MAC", http.StatusUnauthorized)
+				return
+			}
+
+			// hand a fresh reader over the (verified) body to the next handler
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package authiohttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/adrianosela/authio"
+)
+
+// Transport is an http.RoundTripper which adds a MAC over the method,
+// request URI, timestamp, body (and signed headers) of every request it sends
+type Transport struct {
+	base     http.RoundTripper // underlying http.RoundTripper to send requests with
+	provider authio.KeyProvider
+	config   *config
+}
+
+// ensure Transport implements http.RoundTripper at compile-time
+var _ http.RoundTripper = (*Transport)(nil)
+
+// NewTransport wraps an http.RoundTripper in a Transport which signs requests
+// with the key (of the given KeyProvider) with the ID set with WithKeyID. If
+// base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, provider authio.KeyProvider, opts ...Option) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:     base,
+		provider: provider,
+		config:   newConfig(opts...),
+	}
+}
+
+// RoundTrip signs and sends a request
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body := []byte{}
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	key, err := t.provider.GetKey(r.Context(), t.config.keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key %q: %w", t.config.keyID, err)
+	}
+
+	// a RoundTripper must not modify the given request
+	signed := r.Clone(r.Context())
+	signed.Body = http.NoBody
+	if len(body) > 0 {
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	signed.ContentLength = int64(len(body))
+	if signed.Method == "" {
+		signed.Method = http.MethodGet
+	}
+
+	timestamp := strconv.FormatInt(t.config.clock.Now().Unix(), 10)
+	signed.Header.Set(HeaderTimestamp, timestamp)
+	signed.Header.Set(HeaderKeyID, t.config.keyID)
+
+	auth := t.config.newAuthenticator(key, signed.Method, signed.URL.RequestURI(), timestamp, t.config.keyID, signed.Header)
+	defer auth.Destroy()
+	mac, err := sign(auth, body)
+	if err != nil {
+		return nil, err
+	}
+	signed.Header.Set(t.config.headerName, mac)
+
+	return t.base.RoundTrip(signed)
+}
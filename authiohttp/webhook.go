@@ -0,0 +1,138 @@
+package authiohttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderHubSignature256 is the header GitHub style webhook signatures are sent in
+	HeaderHubSignature256 = "X-Hub-Signature-256"
+	// HeaderStripeSignature is the header Stripe style webhook signatures are sent in
+	HeaderStripeSignature = "Stripe-Signature"
+
+	hubSignaturePrefix     = "sha256="
+	timestampedTimeKey     = "t"
+	timestampedSignatureV1 = "v1"
+)
+
+var (
+	// ErrMalformedSignature is returned when a signature header can not be parsed
+	ErrMalformedSignature = errors.New("malformed signature")
+	// ErrInvalidSignature is returned when a signature matches none of the secrets
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrSignatureExpired is returned when a timestamped signature is outside the tolerance
+	ErrSignatureExpired = errors.New("signature timestamp outside of tolerance")
+)
+
+// SignHubSignature256 returns the value of the X-Hub-Signature-256
+// header (i.e. "sha256=" followed by the hex HMAC-SHA256) for a body
+func SignHubSignature256(secret, body []byte) string {
+	return hubSignaturePrefix + hex.EncodeToString(computeHMACSHA256(secret, body))
+}
+
+// VerifyHubSignature256 verifies the value of an X-Hub-Signature-256 header
+// for a body. The signature is accepted if it is valid for any of the given
+// secrets, which allows for rotating secrets without downtime.
+func VerifyHubSignature256(signature string, body []byte, secrets ...[]byte) error {
+	if !strings.HasPrefix(signature, hubSignaturePrefix) {
+		return fmt.Errorf("%w: missing %q prefix", ErrMalformedSignature, hubSignaturePrefix)
+	}
+	mac, err := hex.DecodeString(strings.TrimPrefix(signature, hubSignaturePrefix))
+	if err != nil {
+		return fmt.Errorf("%w: signature is not hex encoded: %s", ErrMalformedSignature, err)
+	}
+	for _, secret := range secrets {
+		if hmac.Equal(mac, computeHMACSHA256(secret, body)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// SignTimestamped returns the value of a Stripe-Signature style header
+// (i.e. "t=<unix timestamp>,v1=<hex HMAC-SHA256>") for a body, where
+// the MAC covers the timestamp as well as the body.
+func SignTimestamped(secret, body []byte, timestamp time.Time) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("%s=%s,%s=%s",
+		timestampedTimeKey, unix,
+		timestampedSignatureV1, hex.EncodeToString(computeHMACSHA256(secret, timestampedPayload(unix, body))),
+	)
+}
+
+// VerifyTimestamped verifies the value of a Stripe-Signature style header
+// for a body. The signature is accepted if its timestamp is within the given
+// tolerance of the current time and any of its v1 signatures is valid for
+// any of the given secrets.
+func VerifyTimestamped(signature string, body []byte, tolerance time.Duration, secrets ...[]byte) error {
+	return VerifyTimestampedAt(signature, body, time.Now(), tolerance, secrets...)
+}
+
+// VerifyTimestampedAt is like VerifyTimestamped, but checks the timestamp of
+// the signature against the given time rather than the current time, e.g. that
+// of an authio.Clock
+func VerifyTimestampedAt(signature string, body []byte, now time.Time, tolerance time.Duration, secrets ...[]byte) error {
+	unix := ""
+	macs := [][]byte{}
+
+	for _, pair := range strings.Split(signature, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("%w: %q is not a key=value pair", ErrMalformedSignature, pair)
+		}
+		switch key {
+		case timestampedTimeKey:
+			unix = value
+		case timestampedSignatureV1:
+			mac, err := hex.DecodeString(value)
+			if err != nil {
+				return fmt.Errorf("%w: signature is not hex encoded: %s", ErrMalformedSignature, err)
+			}
+			macs = append(macs, mac)
+		default:
+			// ignore unknown schemes (e.g. v0) for forward-compatibility
+		}
+	}
+
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid or missing timestamp: %s", ErrMalformedSignature, err)
+	}
+	if len(macs) == 0 {
+		return fmt.Errorf("%w: no %s signatures", ErrMalformedSignature, timestampedSignatureV1)
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	payload := timestampedPayload(unix, body)
+	for _, secret := range secrets {
+		computed := computeHMACSHA256(secret, payload)
+		for _, mac := range macs {
+			if hmac.Equal(mac, computed) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func timestampedPayload(unix string, body []byte) []byte {
+	return append([]byte(unix+"."), body...)
+}
+
+func computeHMACSHA256(secret, data []byte) []byte {
+	computed := hmac.New(sha256.New, secret)
+	// note: hash.Write() never returns an error as per godoc (https://pkg.go.dev/hash#Hash)
+	computed.Write(data)
+	return computed.Sum(nil)
+}
@@ -0,0 +1,129 @@
+package authiohttp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_HubSignature256(t *testing.T) {
+	mockBody := []byte("Hello, World!")
+
+	tests := []struct {
+		name        string
+		signature   string
+		secrets     [][]byte
+		expectedErr error
+	}{
+		{
+			// example from GitHub's webhook documentation
+			name:        "Known signature",
+			signature:   "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17",
+			secrets:     [][]byte{[]byte("It's a Secret to Everybody")},
+			expectedErr: nil,
+		},
+		{
+			name:        "Second secret",
+			signature:   SignHubSignature256([]byte("new secret"), mockBody),
+			secrets:     [][]byte{[]byte("old secret"), []byte("new secret")},
+			expectedErr: nil,
+		},
+		{
+			name:        "Wrong secret",
+			signature:   SignHubSignature256([]byte("other secret"), mockBody),
+			secrets:     [][]byte{[]byte("mock secret")},
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name:        "Missing prefix",
+			signature:   "757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17",
+			secrets:     [][]byte{[]byte("mock secret")},
+			expectedErr: ErrMalformedSignature,
+		},
+		{
+			name:        "Not hex",
+			signature:   "sha256=not-hex",
+			secrets:     [][]byte{[]byte("mock secret")},
+			expectedErr: ErrMalformedSignature,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyHubSignature256(test.signature, mockBody, test.secrets...)
+			assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+		})
+	}
+}
+
+func Test_Timestamped(t *testing.T) {
+	mockSecret := []byte("mock secret")
+	mockBody := []byte("mock data")
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name        string
+		signature   string
+		expectedErr error
+	}{
+		{
+			name:        "Valid signature",
+			signature:   SignTimestamped(mockSecret, mockBody, time.Now()),
+			expectedErr: nil,
+		},
+		{
+			name: "Multiple signatures",
+			signature: fmt.Sprintf("t=%s,v1=%s,v1=%s", now,
+				hex.EncodeToString(computeHMACSHA256([]byte("other secret"), timestampedPayload(now, mockBody))),
+				hex.EncodeToString(computeHMACSHA256(mockSecret, timestampedPayload(now, mockBody))),
+			),
+			expectedErr: nil,
+		},
+		{
+			name:        "Expired",
+			signature:   SignTimestamped(mockSecret, mockBody, time.Now().Add(-time.Hour)),
+			expectedErr: ErrSignatureExpired,
+		},
+		{
+			name:        "From the future",
+			signature:   SignTimestamped(mockSecret, mockBody, time.Now().Add(time.Hour)),
+			expectedErr: ErrSignatureExpired,
+		},
+		{
+			name:        "Wrong secret",
+			signature:   SignTimestamped([]byte("other secret"), mockBody, time.Now()),
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name:        "Missing timestamp",
+			signature:   "v1=abcd",
+			expectedErr: ErrMalformedSignature,
+		},
+		{
+			name:        "Missing signature",
+			signature:   "t=1492774577",
+			expectedErr: ErrMalformedSignature,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyTimestamped(test.signature, mockBody, 5*time.Minute, mockSecret)
+			assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+		})
+	}
+}
+
+func Test_VerifyTimestampedAt(t *testing.T) {
+	mockSecret := []byte("mock secret")
+	mockBody := []byte("mock data")
+	signedAt := time.Unix(1492774577, 0)
+	signature := SignTimestamped(mockSecret, mockBody, signedAt)
+
+	assert.Nil(t, VerifyTimestampedAt(signature, mockBody, signedAt.Add(time.Minute), 5*time.Minute, mockSecret))
+	err := VerifyTimestampedAt(signature, mockBody, signedAt.Add(time.Hour), 5*time.Minute, mockSecret)
+	assert.True(t, errors.Is(err, ErrSignatureExpired))
+}
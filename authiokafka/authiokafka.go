@@ -0,0 +1,117 @@
+// Package authiokafka authenticates the values of Kafka records with authio
+// framing, such that consumers only accept records produced by holders of the
+// key, even across brokers (or mirroring pipelines) which are not fully
+// trusted. It is client agnostic: Serializer and Deserializer are plain
+// functions to call on record values before producing and after consuming
+// them (e.g. with franz-go), and Encoder implements sarama.Encoder.
+package authiokafka
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/adrianosela/authio"
+)
+
+// Serializer authenticates the value of a record, returning the framed value
+// to produce. Nil values (i.e. tombstones) are returned as is, since brokers
+// can drop records anyway.
+type Serializer func(value []byte) ([]byte, error)
+
+// Deserializer verifies and strips the framing of the value of a record
+// produced with a Serializer. Nil values (i.e. tombstones) are returned as is.
+type Deserializer func(value []byte) ([]byte, error)
+
+// NewSerializer returns a Serializer which authenticates record values with
+// the given key. Options (e.g. authio.WithCBORHeaders) must match those of
+// the Deserializer.
+func NewSerializer(key []byte, opts ...authio.Option) Serializer {
+	return func(value []byte) ([]byte, error) {
+		return serialize(value, key, opts)
+	}
+}
+
+// NewDeserializer returns a Deserializer which verifies record values with
+// the given key
+func NewDeserializer(key []byte, opts ...authio.Option) Deserializer {
+	return func(value []byte) ([]byte, error) {
+		return deserialize(value, key, opts)
+	}
+}
+
+// NewTopicSerializer returns a function like a Serializer, which also binds
+// the topic of the record (see authio.WithAssociatedData), such that brokers
+// cannot move records from one topic to another
+func NewTopicSerializer(key []byte, opts ...authio.Option) func(topic string, value []byte) ([]byte, error) {
+	return func(topic string, value []byte) ([]byte, error) {
+		return serialize(value, key, withTopic(opts, topic))
+	}
+}
+
+// NewTopicDeserializer returns a function like a Deserializer, which only
+// accepts records produced with a NewTopicSerializer for the same topic
+func NewTopicDeserializer(key []byte, opts ...authio.Option) func(topic string, value []byte) ([]byte, error) {
+	return func(topic string, value []byte) ([]byte, error) {
+		return deserialize(value, key, withTopic(opts, topic))
+	}
+}
+
+// Encoder is a sarama.Encoder (i.e. a ProducerMessage value) which
+// authenticates the value it wraps when the producer encodes it
+type Encoder struct {
+	value   []byte
+	encoded []byte
+	err     error
+}
+
+// NewEncoder returns a new Encoder wrapping the given value, authenticated
+// with the given Serializer
+func NewEncoder(serializer Serializer, value []byte) *Encoder {
+	encoded, err := serializer(value)
+	return &Encoder{value: value, encoded: encoded, err: err}
+}
+
+// Encode returns the authenticated value
+func (e *Encoder) Encode() ([]byte, error) {
+	return e.encoded, e.err
+}
+
+// Length returns the length of the authenticated value
+func (e *Encoder) Length() int {
+	return len(e.encoded)
+}
+
+func serialize(value, key []byte, opts []authio.Option) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	buf := &bytes.Buffer{}
+	if _, err := authio.NewAppendMACWriter(buf, key, opts...).Write(value); err != nil {
+		return nil, fmt.Errorf("failed to authenticate record value: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func deserialize(value, key []byte, opts []authio.Option) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	reader := authio.NewVerifyMACReader(bytes.NewReader(value), key, opts...)
+	message, err := reader.Next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed to verify record value: %w", err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("failed to verify record value: unexpected data after the message")
+	}
+	return message, nil
+}
+
+func withTopic(opts []authio.Option, topic string) []authio.Option {
+	return append(opts[:len(opts):len(opts)], authio.WithAssociatedData([]byte(topic)))
+}
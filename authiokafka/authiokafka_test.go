@@ -0,0 +1,117 @@
+package authiokafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_SerializeDeserialize(t *testing.T) {
+	mockKey := []byte("mock key")
+	serialize := NewSerializer(mockKey)
+	mockRecord, err := serialize([]byte("mock value"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		value         []byte
+		key           []byte
+		expectedValue []byte
+		expectError   bool
+		expectedErr   error
+	}{
+		{
+			name:          "Valid",
+			value:         mockRecord,
+			key:           mockKey,
+			expectedValue: []byte("mock value"),
+		},
+		{
+			name:          "Tombstone",
+			value:         nil,
+			key:           mockKey,
+			expectedValue: nil,
+		},
+		{
+			name:        "Wrong key",
+			value:       mockRecord,
+			key:         []byte("wrong key"),
+			expectError: true,
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:        "Tampered value",
+			value:       append(append([]byte{}, mockRecord[:len(mockRecord)-1]...), 'X'),
+			key:         mockKey,
+			expectError: true,
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:        "Unauthenticated value",
+			value:       []byte("plain value"),
+			key:         mockKey,
+			expectError: true,
+		},
+		{
+			name:        "Empty value",
+			value:       []byte{},
+			key:         mockKey,
+			expectError: true,
+		},
+		{
+			name:        "Trailing data",
+			value:       append(append([]byte{}, mockRecord...), mockRecord...),
+			key:         mockKey,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := NewDeserializer(test.key)(test.value)
+			if test.expectError {
+				assert.Error(t, err)
+				if test.expectedErr != nil {
+					assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedValue, value)
+		})
+	}
+
+	// empty values are authenticated too
+	record, err := serialize([]byte{})
+	assert.NoError(t, err)
+	value, err := NewDeserializer(mockKey)(record)
+	assert.NoError(t, err)
+	assert.Empty(t, value)
+}
+
+func Test_TopicSerializer(t *testing.T) {
+	mockKey := []byte("mock key")
+	record, err := NewTopicSerializer(mockKey)("orders", []byte("mock value"))
+	assert.NoError(t, err)
+
+	deserialize := NewTopicDeserializer(mockKey)
+	value, err := deserialize("orders", record)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock value", string(value))
+
+	_, err = deserialize("payments", record)
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+}
+
+func Test_Encoder(t *testing.T) {
+	mockKey := []byte("mock key")
+	encoder := NewEncoder(NewSerializer(mockKey), []byte("mock value"))
+	encoded, err := encoder.Encode()
+	assert.NoError(t, err)
+	assert.Equal(t, len(encoded), encoder.Length())
+
+	value, err := NewDeserializer(mockKey)(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock value", string(value))
+}
@@ -0,0 +1,132 @@
+// Package authiopubsub signs and verifies individual pub/sub payloads (e.g.
+// NATS messages, MQTT publishes) with authio framing, such that subscribers
+// only accept messages published by holders of the key, end-to-end through
+// brokers which are not trusted. The ID of the key is sent in a header (e.g.
+// a NATS header, or an MQTT 5 user property) or, for protocols without
+// headers (e.g. MQTT 3.1.1), embedded at the start of the payload.
+package authiopubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/adrianosela/authio"
+)
+
+const (
+	// HeaderKeyID is the header (or MQTT 5 user property) with the ID of the
+	// key a message was signed with
+	HeaderKeyID = "Authio-Key-Id"
+
+	// maxEmbeddedKeyIDLength is the max length of key IDs embedded in
+	// payloads, which are prefixed with their length as a single byte
+	maxEmbeddedKeyIDLength = 255
+)
+
+// Message is a signed message to publish, or a received message to verify
+type Message struct {
+	// Payload is the framed payload of the message
+	Payload []byte
+	// Headers of the message, i.e. the ID of the key (see HeaderKeyID)
+	Headers map[string]string
+}
+
+// Embedded returns the payload of the message with the ID of the key
+// embedded at its start, for protocols without headers (e.g. MQTT 3.1.1)
+func (m Message) Embedded() ([]byte, error) {
+	keyID := m.Headers[HeaderKeyID]
+	if len(keyID) > maxEmbeddedKeyIDLength {
+		return nil, fmt.Errorf("key ID too long to embed, got %d and expected at most %d bytes", len(keyID), maxEmbeddedKeyIDLength)
+	}
+	embedded := make([]byte, 0, 1+len(keyID)+len(m.Payload))
+	embedded = append(embedded, byte(len(keyID)))
+	embedded = append(embedded, keyID...)
+	return append(embedded, m.Payload...), nil
+}
+
+// ParseEmbedded returns the message with the given payload, as returned by Embedded
+func ParseEmbedded(payload []byte) (Message, error) {
+	if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return Message{}, errors.New("payload too short to embed a key ID")
+	}
+	keyIDLen := int(payload[0])
+	return Message{
+		Payload: payload[1+keyIDLen:],
+		Headers: map[string]string{HeaderKeyID: string(payload[1 : 1+keyIDLen])},
+	}, nil
+}
+
+// Signer signs messages with a key (by ID) of a KeyProvider
+type Signer struct {
+	provider authio.KeyProvider
+	keyID    string
+	opts     []authio.Option
+}
+
+// NewSigner returns a new Signer which signs messages with the key with the
+// given ID. Options (e.g. authio.WithHashFn) must match those of the Verifier.
+func NewSigner(provider authio.KeyProvider, keyID string, opts ...authio.Option) *Signer {
+	return &Signer{provider: provider, keyID: keyID, opts: opts}
+}
+
+// Sign signs the given payload to be published on the given topic (i.e. NATS
+// subject or MQTT topic), which is bound into the MAC such that brokers cannot
+// deliver it on any other topic
+func (s *Signer) Sign(ctx context.Context, topic string, payload []byte) (Message, error) {
+	key, err := s.provider.GetKey(ctx, s.keyID)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to get key %q: %w", s.keyID, err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := authio.NewAppendMACWriter(buf, key, withContext(s.opts, s.keyID, topic)...).Write(payload); err != nil {
+		return Message{}, fmt.Errorf("failed to sign message: %w", err)
+	}
+	return Message{
+		Payload: buf.Bytes(),
+		Headers: map[string]string{HeaderKeyID: s.keyID},
+	}, nil
+}
+
+// Verifier verifies messages with the keys of a KeyProvider
+type Verifier struct {
+	provider authio.KeyProvider
+	opts     []authio.Option
+}
+
+// NewVerifier returns a new Verifier which verifies messages with the key
+// (of the given KeyProvider) with the ID in their headers
+func NewVerifier(provider authio.KeyProvider, opts ...authio.Option) *Verifier {
+	return &Verifier{provider: provider, opts: opts}
+}
+
+// Verify verifies a message received on the given topic, returning its payload
+func (v *Verifier) Verify(ctx context.Context, topic string, msg Message) ([]byte, error) {
+	keyID := msg.Headers[HeaderKeyID]
+	key, err := v.provider.GetKey(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key %q: %w", keyID, err)
+	}
+	reader := authio.NewVerifyMACReader(bytes.NewReader(msg.Payload), key, withContext(v.opts, keyID, topic)...)
+	payload, err := reader.Next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed to verify message: %w", err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("failed to verify message: unexpected data after the payload")
+	}
+	return payload, nil
+}
+
+// withContext binds the key ID and topic into MACs (see authio.WithAssociatedData)
+func withContext(opts []authio.Option, keyID, topic string) []authio.Option {
+	aad := append(binary.BigEndian.AppendUint64(nil, uint64(len(keyID))), keyID...)
+	aad = append(aad, topic...)
+	return append(opts[:len(opts):len(opts)], authio.WithAssociatedData(aad))
+}
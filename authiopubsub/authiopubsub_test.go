@@ -0,0 +1,111 @@
+package authiopubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_SignVerify(t *testing.T) {
+	keyring := authio.NewKeyring()
+	keyring.Add("k1", []byte("mock key 1"))
+	keyring.Add("k2", []byte("mock key 2"))
+
+	msg, err := NewSigner(keyring, "k1").Sign(context.Background(), "sensors.temperature", []byte("21.5"))
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", msg.Headers[HeaderKeyID])
+
+	tests := []struct {
+		name            string
+		topic           string
+		msg             func(Message) Message
+		expectedPayload string
+		expectError     bool
+		expectedErr     error
+	}{
+		{
+			name:            "Valid",
+			topic:           "sensors.temperature",
+			expectedPayload: "21.5",
+		},
+		{
+			name:        "Other topic",
+			topic:       "sensors.humidity",
+			expectError: true,
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:  "Tampered payload",
+			topic: "sensors.temperature",
+			msg: func(m Message) Message {
+				m.Payload = append(append([]byte{}, m.Payload[:len(m.Payload)-1]...), '9')
+				return m
+			},
+			expectError: true,
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:  "Other key ID",
+			topic: "sensors.temperature",
+			msg: func(m Message) Message {
+				m.Headers = map[string]string{HeaderKeyID: "k2"}
+				return m
+			},
+			expectError: true,
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:  "Unknown key ID",
+			topic: "sensors.temperature",
+			msg: func(m Message) Message {
+				m.Headers = map[string]string{HeaderKeyID: "k3"}
+				return m
+			},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := msg
+			if test.msg != nil {
+				m = test.msg(m)
+			}
+			payload, err := NewVerifier(keyring).Verify(context.Background(), test.topic, m)
+			if test.expectError {
+				assert.Error(t, err)
+				if test.expectedErr != nil {
+					assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedPayload, string(payload))
+		})
+	}
+}
+
+func Test_Embedded(t *testing.T) {
+	keyring := authio.NewKeyring()
+	keyring.Add("k1", []byte("mock key 1"))
+
+	msg, err := NewSigner(keyring, "k1").Sign(context.Background(), "sensors/temperature", []byte("21.5"))
+	assert.NoError(t, err)
+	embedded, err := msg.Embedded()
+	assert.NoError(t, err)
+
+	parsed, err := ParseEmbedded(embedded)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, parsed)
+	payload, err := NewVerifier(keyring).Verify(context.Background(), "sensors/temperature", parsed)
+	assert.NoError(t, err)
+	assert.Equal(t, "21.5", string(payload))
+
+	_, err = ParseEmbedded([]byte{5, 'k'})
+	assert.Error(t, err)
+	_, err = ParseEmbedded(nil)
+	assert.Error(t, err)
+}
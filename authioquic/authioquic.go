@@ -0,0 +1,142 @@
+// Package authioquic wraps QUIC streams (e.g. quic-go's quic.Stream) in
+// authio Conns keyed per stream, with keys derived from a pre-shared key, a
+// secret unique to the QUIC connection (e.g. exported from its TLS session,
+// see ExportConnectionSecret) and the ID of the stream, such that messages
+// cannot be moved from one stream (or direction) to another, nor from one
+// connection to another. It is client agnostic: any stream with the methods
+// of Stream (which quic-go streams have) works.
+//
+// QUIC streams are half-closed with a FIN (quic.Stream.Close closes the
+// write direction only), which the peer cannot tell apart from a FIN sent by
+// an attacker cutting the stream short. Conns are thereby configured with
+// authio.WithCloseNotify: CloseWrite (and Close) write an authenticated close
+// notification before the FIN, after which the peer reads io.EOF, whereas a
+// FIN without one fails reads with authio.ErrTruncatedStream.
+package authioquic
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/adrianosela/authio"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// ExporterLabel is the label (see RFC 5705 and RFC 8446) connection
+	// secrets are exported from TLS sessions with (see ExportConnectionSecret)
+	ExporterLabel = "EXPORTER-authio-quic"
+
+	// ConnectionSecretSize is the size of exported connection secrets
+	ConnectionSecretSize = 32
+
+	// keyInfo is the HKDF info stream keys are derived with, followed
+	// by the ID of the stream as a big endian uint64
+	keyInfo = "authio quic stream keys"
+
+	// keySize is the size of every key derived for a stream
+	keySize = 32
+)
+
+// Stream is a QUIC stream, e.g. a quic-go quic.Stream, whose Close
+// closes the write direction of the stream (i.e. sends a FIN)
+type Stream interface {
+	io.ReadWriteCloser
+	SetDeadline(t time.Time) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// ErrNoConnectionSecret is returned by DeriveStreamKeys (and thereby
+// NewClientStream and NewServerStream) when given no connection secret
+var ErrNoConnectionSecret = errors.New("no connection secret")
+
+// ExportConnectionSecret exports a secret unique to a QUIC connection from the
+// state of its TLS session (with quic-go, the TLS field of the ConnectionState
+// of a quic.Connection), see DeriveStreamKeys. Both peers export the same secret.
+func ExportConnectionSecret(state tls.ConnectionState) ([]byte, error) {
+	secret, err := state.ExportKeyingMaterial(ExporterLabel, nil, ConnectionSecretSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export keying material: %w", err)
+	}
+	return secret, nil
+}
+
+// DeriveStreamKeys derives the keys of either direction of the stream with the
+// given ID from a pre-shared key and a secret unique to its QUIC connection
+// (see ExportConnectionSecret), with HKDF-SHA256 (with the connection secret
+// as salt). Both peers derive the same keys, which are unique to the stream
+// as long as the connection secret is unique to the connection. Since the
+// pre-shared key is still required, a party which knows the connection secret
+// alone (e.g. one which terminates TLS) cannot forge messages.
+func DeriveStreamKeys(psk, connectionSecret []byte, streamID int64) (clientToServer, serverToClient []byte, err error) {
+	if len(connectionSecret) == 0 {
+		return nil, nil, ErrNoConnectionSecret
+	}
+	info := binary.BigEndian.AppendUint64([]byte(keyInfo), uint64(streamID))
+	keys := make([]byte, 2*keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, psk, connectionSecret, info), keys); err != nil {
+		return nil, nil, fmt.Errorf("failed to derive stream keys: %w", err)
+	}
+	return keys[:keySize], keys[keySize:], nil
+}
+
+// NewClientStream wraps a stream of the client side of a QUIC connection
+// (regardless of which side opened the stream) in a Conn keyed with keys
+// derived from the given pre-shared key and connection secret (see
+// DeriveStreamKeys). The peer must use NewServerStream. Options which set
+// the key or close notifications must not be used.
+func NewClientStream(stream Stream, psk, connectionSecret []byte, streamID int64, opts ...authio.Option) (*authio.Conn, error) {
+	clientToServer, serverToClient, err := DeriveStreamKeys(psk, connectionSecret, streamID)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamConn(stream, streamID, serverToClient, clientToServer, opts), nil
+}
+
+// NewServerStream wraps a stream of the server side of a QUIC connection in
+// a Conn keyed with keys derived from the given pre-shared key and connection
+// secret (see NewClientStream)
+func NewServerStream(stream Stream, psk, connectionSecret []byte, streamID int64, opts ...authio.Option) (*authio.Conn, error) {
+	clientToServer, serverToClient, err := DeriveStreamKeys(psk, connectionSecret, streamID)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamConn(stream, streamID, clientToServer, serverToClient, opts), nil
+}
+
+func newStreamConn(stream Stream, streamID int64, readKey, writeKey []byte, opts []authio.Option) *authio.Conn {
+	opts = append([]authio.Option{authio.WithCloseNotify()}, opts...)
+	return authio.NewConnWithKeys(&streamConn{Stream: stream, addr: streamAddr(streamID)}, readKey, writeKey, opts...)
+}
+
+// streamConn adapts a Stream to a net.Conn
+type streamConn struct {
+	Stream
+	addr net.Addr
+}
+
+// ensure streamConn implements net.Conn at compile-time
+var _ net.Conn = (*streamConn)(nil)
+
+// CloseWrite sends a FIN, after the Conn sends a close notification
+// (see authio.Conn.CloseWrite)
+func (s *streamConn) CloseWrite() error { return s.Stream.Close() }
+
+func (s *streamConn) LocalAddr() net.Addr { return s.addr }
+
+func (s *streamConn) RemoteAddr() net.Addr { return s.addr }
+
+// streamAddr is the net.Addr of a stream, i.e. its ID
+type streamAddr int64
+
+func (streamAddr) Network() string { return "quic" }
+
+func (a streamAddr) String() string { return strconv.FormatInt(int64(a), 10) }
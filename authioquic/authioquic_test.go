@@ -0,0 +1,189 @@
+package authioquic
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio"
+	"github.com/autarch/testify/assert"
+)
+
+// mockStream is a Stream whose Close only closes the write
+// direction (i.e. sends a FIN), like a quic-go quic.Stream
+type mockStream struct {
+	*io.PipeReader
+	w *io.PipeWriter
+}
+
+func (s *mockStream) Write(b []byte) (int, error) { return s.w.Write(b) }
+
+func (s *mockStream) Close() error { return s.w.Close() }
+
+func (s *mockStream) SetDeadline(time.Time) error { return nil }
+
+func (s *mockStream) SetReadDeadline(time.Time) error { return nil }
+
+func (s *mockStream) SetWriteDeadline(time.Time) error { return nil }
+
+func mockStreamPair() (*mockStream, *mockStream) {
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+	return &mockStream{PipeReader: ar, w: bw}, &mockStream{PipeReader: br, w: aw}
+}
+
+func Test_DeriveStreamKeys(t *testing.T) {
+	psk, secret := []byte("mock psk"), []byte("mock connection secret")
+
+	c2s, s2c, err := DeriveStreamKeys(psk, secret, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, keySize, len(c2s))
+	assert.False(t, bytes.Equal(c2s, s2c), "directions have different keys")
+
+	again, _, err := DeriveStreamKeys(psk, secret, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, c2s, again, "keys are deterministic")
+
+	other, _, err := DeriveStreamKeys(psk, secret, 4)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Equal(c2s, other), "streams have different keys")
+
+	other, _, err = DeriveStreamKeys(psk, []byte("other connection secret"), 0)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Equal(c2s, other), "connections with the same psk have different keys")
+
+	other, _, err = DeriveStreamKeys([]byte("other psk"), secret, 0)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Equal(c2s, other), "psks have different keys")
+
+	_, _, err = DeriveStreamKeys(psk, nil, 0)
+	assert.True(t, errors.Is(err, ErrNoConnectionSecret))
+}
+
+func Test_ExportConnectionSecret(t *testing.T) {
+	clientState, serverState := tlsStatePair(t)
+
+	clientSecret, err := ExportConnectionSecret(clientState)
+	assert.NoError(t, err)
+	serverSecret, err := ExportConnectionSecret(serverState)
+	assert.NoError(t, err)
+	assert.Equal(t, ConnectionSecretSize, len(clientSecret))
+	assert.Equal(t, clientSecret, serverSecret, "both peers export the same secret")
+
+	otherState, _ := tlsStatePair(t)
+	otherSecret, err := ExportConnectionSecret(otherState)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Equal(clientSecret, otherSecret), "connections have different secrets")
+}
+
+func Test_Stream(t *testing.T) {
+	mockPSK := []byte("mock psk")
+	mockSecret := []byte("mock connection secret")
+
+	tests := []struct {
+		name           string
+		serverStreamID int64
+		finOnly        bool
+		expectedErr    error
+		expectError    bool
+	}{
+		{
+			name:           "Close notification then FIN",
+			serverStreamID: 4,
+		},
+		{
+			name:           "FIN without close notification",
+			serverStreamID: 4,
+			finOnly:        true,
+			expectedErr:    authio.ErrTruncatedStream,
+		},
+		{
+			name:           "Mismatched stream IDs",
+			serverStreamID: 8,
+			expectError:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := mockStreamPair()
+			client, err := NewClientStream(a, mockPSK, mockSecret, 4)
+			assert.NoError(t, err)
+			server, err := NewServerStream(b, mockPSK, mockSecret, test.serverStreamID)
+			assert.NoError(t, err)
+			assert.Equal(t, "4", client.RemoteAddr().String())
+
+			go func() {
+				client.Write([]byte("mock message"))
+				if test.finOnly {
+					a.Close()
+					return
+				}
+				client.CloseWrite()
+			}()
+			data, err := io.ReadAll(server)
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+				return
+			}
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "mock message", string(data))
+
+			// the other direction is still open after a FIN
+			go func() {
+				server.Write([]byte("mock reply"))
+				server.Close()
+			}()
+			data, err = io.ReadAll(client)
+			assert.NoError(t, err)
+			assert.Equal(t, "mock reply", string(data))
+		})
+	}
+}
+
+// tlsStatePair returns the states of either side of a new
+// TLS session (with a self-signed certificate)
+func tlsStatePair(t *testing.T) (client, server tls.ConnectionState) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"mock"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	tlsServer := tls.Server(b, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		// TLS 1.3 servers write session tickets at the end of the
+		// handshake, which blocks over net.Pipe until the client reads
+		SessionTicketsDisabled: true,
+	})
+	handshake := make(chan error, 1)
+	go func() { handshake <- tlsServer.Handshake() }()
+	tlsClient := tls.Client(a, &tls.Config{RootCAs: pool, ServerName: "mock"})
+	assert.NoError(t, tlsClient.Handshake())
+	assert.NoError(t, <-handshake)
+	return tlsClient.ConnectionState(), tlsServer.ConnectionState()
+}
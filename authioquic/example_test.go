@@ -0,0 +1,55 @@
+package authioquic_test
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/adrianosela/authio/authioquic"
+)
+
+// Every stream of a QUIC connection is keyed with its own keys, derived
+// from a pre-shared key and a secret exported from the TLS session of the
+// connection. With quic-go, the client wraps the streams it opens or
+// accepts, e.g.
+//
+//	secret, err := authioquic.ExportConnectionSecret(quicConn.ConnectionState().TLS)
+//	stream, err := quicConn.OpenStreamSync(ctx)
+//	conn, err := authioquic.NewClientStream(stream, psk, secret, int64(stream.StreamID()))
+//
+// and the server those it accepts or opens with NewServerStream. Here,
+// in-memory pipes stand in for QUIC streams, and a random secret for
+// the exported one.
+func Example() {
+	psk := []byte("connection pre-shared key")
+	secret := make([]byte, authioquic.ConnectionSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+
+	for _, streamID := range []int64{0, 4} {
+		clientStream, serverStream := net.Pipe()
+		client, err := authioquic.NewClientStream(clientStream, psk, secret, streamID)
+		if err != nil {
+			panic(err)
+		}
+		server, err := authioquic.NewServerStream(serverStream, psk, secret, streamID)
+		if err != nil {
+			panic(err)
+		}
+
+		go func() {
+			fmt.Fprintf(client, "hello on stream %d", streamID)
+			client.Close()
+		}()
+		message, err := io.ReadAll(server)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(message))
+	}
+	// Output:
+	// hello on stream 0
+	// hello on stream 4
+}
@@ -0,0 +1,236 @@
+// Package authiotest provides utilities for testing applications built on
+// authio, namely readers and writers which inject faults (bit flips,
+// truncations, duplicated frames, and delays) into streams of frames, such
+// that applications can verify how they handle MAC failures and truncation.
+package authiotest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// Faults are the probabilities (from 0 to 1) of the faults injected into
+// every frame, any number of which may be injected into the same frame
+type Faults struct {
+	// BitFlip is the probability of flipping a random bit of the frame
+	BitFlip float64
+	// Truncate is the probability of the stream ending in the middle of the
+	// frame, after which nothing else is delivered
+	Truncate float64
+	// Duplicate is the probability of delivering the frame twice
+	Duplicate float64
+	// Delay is the probability of delaying the delivery of the frame
+	// by a random duration of up to MaxDelay
+	Delay    float64
+	MaxDelay time.Duration
+	// Seed seeds the pseudo-random faults, such that runs are reproducible
+	Seed int64
+}
+
+// Injected are the numbers of faults injected so far
+type Injected struct {
+	Frames      int // frames delivered (or cut short)
+	BitFlips    int
+	Truncations int
+	Duplicates  int
+	Delays      int
+}
+
+// injector injects Faults into frames
+type injector struct {
+	faults Faults
+
+	lock      sync.Mutex
+	rand      *rand.Rand
+	injected  Injected
+	truncated bool
+}
+
+func newInjector(faults Faults) *injector {
+	return &injector{faults: faults, rand: rand.New(rand.NewSource(faults.Seed))}
+}
+
+// Injected returns the numbers of faults injected so far
+func (i *injector) Injected() Injected {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.injected
+}
+
+// inject returns the bytes to deliver for the given frame (which are
+// nothing at all once the stream was truncated) and whether the stream
+// is truncated, and sleeps before returning if the frame is delayed
+func (i *injector) inject(frame []byte) ([]byte, bool) {
+	i.lock.Lock()
+	if i.truncated {
+		i.lock.Unlock()
+		return nil, true
+	}
+	i.injected.Frames++
+	out := append([]byte{}, frame...)
+	if i.happens(i.faults.BitFlip) {
+		i.injected.BitFlips++
+		out[i.rand.Intn(len(out))] ^= 1 << i.rand.Intn(8)
+	}
+	if i.happens(i.faults.Duplicate) {
+		i.injected.Duplicates++
+		out = append(out, out...)
+	}
+	if i.happens(i.faults.Truncate) {
+		i.injected.Truncations++
+		i.truncated = true
+		// at least a byte of the frame is delivered, otherwise
+		// the stream would end cleanly at a frame boundary
+		out = out[:1+i.rand.Intn(len(frame)-1)]
+	}
+	var delay time.Duration
+	if i.faults.MaxDelay > 0 && i.happens(i.faults.Delay) {
+		i.injected.Delays++
+		delay = time.Duration(i.rand.Int63n(int64(i.faults.MaxDelay)))
+	}
+	i.lock.Unlock()
+
+	time.Sleep(delay)
+	return out, i.truncated
+}
+
+// happens returns whether an event of the given
+// probability happens, the lock must be held
+func (i *injector) happens(probability float64) bool {
+	return probability > 0 && i.rand.Float64() < probability
+}
+
+// frameLength returns the length of the frame (of the default frame
+// format, see authenticator.DefaultMessageAuthenticator) with the given
+// header, including the header itself
+func frameLength(header []byte) (int, error) {
+	info, err := authenticator.ParseFrameHeader(header)
+	if err != nil {
+		return 0, fmt.Errorf("invalid frame header: %w", err)
+	}
+	if info.CloseNotify {
+		return len(header), nil
+	}
+	return int(info.Length), nil
+}
+
+// Reader is an io.Reader which injects Faults into the frames read
+// from an underlying reader. Frames must be of the default frame format
+// (i.e. neither CBOR headers nor a custom FrameCodec).
+type Reader struct {
+	reader    io.Reader
+	headerLen int
+	*injector
+
+	pending []byte // bytes left to deliver
+	err     error  // error to return once pending bytes are delivered
+}
+
+// ensure Reader implements io.Reader at compile-time
+var _ io.Reader = (*Reader)(nil)
+
+// NewReader returns a Reader which injects the given Faults into the frames
+// read from the given reader, whose headers are of the given length (see
+// authenticator.HeaderLength and authio.EffectiveConfig)
+func NewReader(reader io.Reader, headerLen int, faults Faults) *Reader {
+	return &Reader{reader: reader, headerLen: headerLen, injector: newInjector(faults)}
+}
+
+// Read reads data onto the given buffer
+func (r *Reader) Read(b []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		frame, err := r.readFrame()
+		if len(frame) > 0 && err == nil {
+			var truncated bool
+			if r.pending, truncated = r.inject(frame); truncated {
+				r.err = io.EOF
+			}
+			continue
+		}
+		// partial frames are delivered as they are
+		r.pending, r.err = frame, err
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// readFrame reads a whole frame, or as much of it as is available
+func (r *Reader) readFrame() ([]byte, error) {
+	frame := make([]byte, r.headerLen)
+	if n, err := io.ReadFull(r.reader, frame); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return frame[:n], err
+	}
+	length, err := frameLength(frame)
+	if err != nil {
+		return frame, err
+	}
+	frame = append(frame, make([]byte, length-r.headerLen)...)
+	if n, err := io.ReadFull(r.reader, frame[r.headerLen:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return frame[:r.headerLen+n], err
+	}
+	return frame, nil
+}
+
+// Writer is an io.Writer which injects Faults into the frames written to
+// an underlying writer, regardless of how frames are split across writes.
+// Frames must be of the default frame format (i.e. neither CBOR headers
+// nor a custom FrameCodec). Once the stream was truncated, everything
+// written is discarded.
+type Writer struct {
+	writer    io.Writer
+	headerLen int
+	*injector
+
+	lock    sync.Mutex
+	partial []byte // the partial frame written so far
+}
+
+// ensure Writer implements io.Writer at compile-time
+var _ io.Writer = (*Writer)(nil)
+
+// NewWriter returns a Writer which injects the given Faults into the frames
+// written to the given writer, whose headers are of the given length (see
+// authenticator.HeaderLength and authio.EffectiveConfig)
+func NewWriter(writer io.Writer, headerLen int, faults Faults) *Writer {
+	return &Writer{writer: writer, headerLen: headerLen, injector: newInjector(faults)}
+}
+
+// Write writes the given buffer, delivering every frame completed by it
+func (w *Writer) Write(b []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.partial = append(w.partial, b...)
+	for len(w.partial) >= w.headerLen {
+		length, err := frameLength(w.partial[:w.headerLen])
+		if err != nil {
+			return 0, err
+		}
+		if len(w.partial) < length {
+			break
+		}
+		if out, _ := w.inject(w.partial[:length]); len(out) > 0 {
+			if _, err := w.writer.Write(out); err != nil {
+				return 0, err
+			}
+		}
+		w.partial = w.partial[length:]
+	}
+	return len(b), nil
+}
@@ -0,0 +1,129 @@
+package authiotest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Faults(t *testing.T) {
+	mockKey := []byte("mock key")
+	messages := []string{"message 0", "message 1", "message 2"}
+
+	tests := []struct {
+		name             string
+		faults           Faults
+		expectedMessages []string
+		expectedInjected Injected
+		expectedWritten  Injected // if not the same as expectedInjected
+		expectedErr      error
+	}{
+		{
+			name:             "No faults",
+			expectedMessages: messages,
+			expectedInjected: Injected{Frames: 3},
+		},
+		{
+			name:             "Bit flips",
+			faults:           Faults{BitFlip: 1},
+			expectedInjected: Injected{Frames: 1, BitFlips: 1},
+			// frames are written regardless of what the reader makes of them
+			expectedWritten: Injected{Frames: 3, BitFlips: 3},
+			expectedErr:     authenticator.ErrMACMismatch,
+		},
+		{
+			name:             "Truncation",
+			faults:           Faults{Truncate: 1},
+			expectedInjected: Injected{Frames: 1, Truncations: 1},
+			expectedErr:      authenticator.ErrTruncatedMessage,
+		},
+		{
+			name:             "Duplicates",
+			faults:           Faults{Duplicate: 1},
+			expectedMessages: []string{"message 0", "message 0", "message 1", "message 1", "message 2", "message 2"},
+			expectedInjected: Injected{Frames: 3, Duplicates: 3},
+		},
+		{
+			name:             "Delays",
+			faults:           Faults{Delay: 1, MaxDelay: time.Millisecond},
+			expectedMessages: messages,
+			expectedInjected: Injected{Frames: 3, Delays: 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signed := &bytes.Buffer{}
+			w := authio.NewAppendMACWriter(signed, mockKey)
+			for _, message := range messages {
+				_, err := w.Write([]byte(message))
+				assert.NoError(t, err)
+			}
+			headerLen := w.Config().HeaderLength
+
+			// faults are injected alike when reading and writing
+			faulty := &bytes.Buffer{}
+			writer := NewWriter(faulty, headerLen, test.faults)
+			_, err := writer.Write(signed.Bytes())
+			assert.NoError(t, err)
+			reader := NewReader(bytes.NewReader(signed.Bytes()), headerLen, test.faults)
+
+			expectedWritten := test.expectedWritten
+			if expectedWritten == (Injected{}) {
+				expectedWritten = test.expectedInjected
+			}
+			for _, src := range []struct {
+				io.Reader
+				injected func() Injected
+				expected Injected
+			}{
+				{Reader: reader, injected: reader.Injected, expected: test.expectedInjected},
+				{Reader: faulty, injected: writer.Injected, expected: expectedWritten},
+			} {
+				r := authio.NewVerifyMACReader(src, mockKey)
+				received := []string{}
+				for {
+					message, err := r.Next()
+					if err != nil {
+						if test.expectedErr == nil {
+							assert.True(t, errors.Is(err, io.EOF))
+						} else {
+							assert.True(t, errors.Is(err, test.expectedErr))
+						}
+						break
+					}
+					received = append(received, string(message))
+				}
+				assert.Equal(t, len(test.expectedMessages), len(received))
+				for i := range test.expectedMessages {
+					assert.Equal(t, test.expectedMessages[i], received[i])
+				}
+				assert.Equal(t, src.expected, src.injected())
+			}
+		})
+	}
+}
+
+func Test_FaultsReproducible(t *testing.T) {
+	signed := &bytes.Buffer{}
+	w := authio.NewAppendMACWriter(signed, []byte("mock key"))
+	for i := 0; i < 100; i++ {
+		_, err := w.Write([]byte("hello world"))
+		assert.NoError(t, err)
+	}
+	faults := Faults{BitFlip: 0.5, Duplicate: 0.5, Seed: 42}
+
+	outputs := [][]byte{}
+	for i := 0; i < 2; i++ {
+		output, err := io.ReadAll(NewReader(bytes.NewReader(signed.Bytes()), w.Config().HeaderLength, faults))
+		assert.NoError(t, err)
+		outputs = append(outputs, output)
+	}
+	assert.Equal(t, outputs[0], outputs[1])
+	assert.NotEqual(t, signed.Bytes(), outputs[0])
+}
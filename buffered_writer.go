@@ -0,0 +1,95 @@
+package authio
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultBufferedFrameSize is the default size (in bytes, excluding MACs) at
+// which a BufferedAppendMACWriter writes the bytes it has buffered
+const DefaultBufferedFrameSize = 4096
+
+// BufferedAppendMACWriter is a writer that accumulates bytes across Writes
+// and computes and prepends a MAC to them on Flush, or once enough bytes are
+// buffered, such that many small Writes (e.g. with fmt.Fprintf) do not each
+// incur the overhead of a frame. Note that frames then do not match Writes.
+type BufferedAppendMACWriter struct {
+	writer *AppendMACWriter
+	buf    []byte
+	size   int
+	err    error // sticky error of a failed flush, as with bufio.Writer
+}
+
+// ensure BufferedAppendMACWriter implements io.WriteCloser at compile-time
+var _ io.WriteCloser = (*BufferedAppendMACWriter)(nil)
+
+// NewBufferedAppendMACWriter wraps an io.Writer in a BufferedAppendMACWriter
+// which writes a frame once the given number of bytes are buffered (or
+// DefaultBufferedFrameSize if not positive, capped to the max message size)
+func NewBufferedAppendMACWriter(writer io.Writer, key []byte, size int, opts ...Option) *BufferedAppendMACWriter {
+	w := NewAppendMACWriter(writer, key, opts...)
+	if size <= 0 {
+		size = DefaultBufferedFrameSize
+	}
+	if w.maxMessageLen > 0 && size > w.maxMessageLen {
+		size = w.maxMessageLen
+	}
+	return &BufferedAppendMACWriter{
+		writer: w,
+		buf:    make([]byte, 0, size),
+		size:   size,
+	}
+}
+
+// Write buffers the contents of a buffer, writing frames of the
+// buffered bytes whenever the size of the BufferedAppendMACWriter is reached
+func (w *BufferedAppendMACWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(b) > 0 {
+		n := copy(w.buf[len(w.buf):w.size], b)
+		w.buf = w.buf[:len(w.buf)+n]
+		b = b[n:]
+		if len(w.buf) == w.size {
+			if err := w.Flush(); err != nil {
+				return written, err
+			}
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Flush writes the buffered bytes, if any, as a single message. Once it
+// fails, every subsequent Write and Flush returns the same error.
+func (w *BufferedAppendMACWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if _, err := w.writer.writeMessage(w.buf); err != nil {
+		w.err = fmt.Errorf("failed to flush buffered message: %w", err)
+		return w.err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Buffered returns the number of bytes buffered but not yet written
+func (w *BufferedAppendMACWriter) Buffered() int {
+	return len(w.buf)
+}
+
+// Close flushes the buffered bytes and closes the underlying AppendMACWriter
+// (see AppendMACWriter.Close). It does not close the underlying writer.
+func (w *BufferedAppendMACWriter) Close() error {
+	if err := w.Flush(); err != nil {
+		w.writer.Close()
+		return err
+	}
+	return w.writer.Close()
+}
@@ -0,0 +1,52 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_BufferedAppendMACWriter(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	buf := &bytes.Buffer{}
+	writer := NewBufferedAppendMACWriter(buf, mockKey, 16)
+	for i := 0; i < 5; i++ {
+		n, err := fmt.Fprintf(writer, "line %d\n", i)
+		assert.Nil(t, err)
+		assert.Equal(t, len("line 0\n"), n)
+	}
+	// 35 bytes were written, as two full frames of 16 bytes
+	assert.Equal(t, 3, writer.Buffered())
+	assert.Nil(t, writer.Flush())
+	assert.Equal(t, 0, writer.Buffered())
+	assert.Nil(t, writer.Flush())
+
+	reader := NewVerifyMACReader(buf, mockKey)
+	for _, expected := range []string{"line 0\nline 1\nli", "ne 2\nline 3\nline", " 4\n"} {
+		message, err := reader.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(message))
+	}
+	_, err := reader.Next()
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func Test_BufferedAppendMACWriterClose(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	buf := &bytes.Buffer{}
+	writer := NewBufferedAppendMACWriter(buf, mockKey, 0, WithCloseNotify())
+	_, err := writer.Write([]byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, buf.Len())
+	assert.Nil(t, writer.Close())
+
+	out, err := io.ReadAll(NewVerifyMACReader(buf, mockKey, WithCloseNotify()))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(out))
+}
@@ -0,0 +1,27 @@
+package authio
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithCBORHeaders(t *testing.T) {
+	client, server := ConnPipe([]byte("mock key"), WithCBORHeaders("key-1"), WithCloseNotify())
+	defer server.Close()
+
+	go func() {
+		client.Write([]byte("hello"))
+		client.Close()
+	}()
+
+	buf := make([]byte, 64)
+	n, err := server.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+	// the close notification ends the stream gracefully
+	_, err = server.Read(buf)
+	assert.True(t, errors.Is(err, io.EOF))
+}
@@ -0,0 +1,52 @@
+package authio
+
+// Channel sends and receives values of a given type over a Conn, each as an
+// individually authenticated frame marshaled with a Codec (see WithCodec).
+// Send is safe for concurrent use, while Receive is not (as Conn.Read).
+type Channel[T any] struct {
+	conn  *Conn
+	codec Codec
+}
+
+// NewChannel returns a new Channel over the given Conn. Of the options, only
+// WithCodec applies, and it must match that of the peer. The Conn must not
+// be configured WithDelimiter or WithFixedRecords.
+func NewChannel[T any](conn *Conn, opts ...Option) *Channel[T] {
+	return &Channel[T]{
+		conn:  conn,
+		codec: newConfig(opts...).codec,
+	}
+}
+
+// Send marshals the given value and writes it as a single frame
+func (c *Channel[T]) Send(v T) error {
+	data, err := marshalValue(c.codec, v, c.conn.writer.maxMessageLen)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(data)
+	return err
+}
+
+// Receive reads the next frame and unmarshals it into a value. It returns
+// io.EOF once the peer is done, and a *DecodeError if the frame fails
+// verification or cannot be unmarshaled.
+func (c *Channel[T]) Receive() (T, error) {
+	var v T
+	data, _, err := c.conn.NextWithExtensions()
+	if err := unmarshalValue(c.codec, data, err, &v); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// Conn returns the underlying Conn
+func (c *Channel[T]) Conn() *Conn {
+	return c.conn
+}
+
+// Close closes the underlying Conn
+func (c *Channel[T]) Close() error {
+	return c.conn.Close()
+}
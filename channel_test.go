@@ -0,0 +1,54 @@
+package authio
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Channel(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "JSON"},
+		{name: "Gob", opts: []Option{WithCodec(GobCodec)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, server := ConnPipe(mockKey)
+			sender := NewChannel[mockValue](client, test.opts...)
+			receiver := NewChannel[mockValue](server, test.opts...)
+
+			go func() {
+				sender.Send(mockValue{Name: "first", Count: 1})
+				sender.Send(mockValue{Name: "second", Count: 2})
+				sender.Close()
+			}()
+
+			v, err := receiver.Receive()
+			assert.NoError(t, err)
+			assert.Equal(t, mockValue{Name: "first", Count: 1}, v)
+			v, err = receiver.Receive()
+			assert.NoError(t, err)
+			assert.Equal(t, mockValue{Name: "second", Count: 2}, v)
+			_, err = receiver.Receive()
+			assert.True(t, errors.Is(err, io.EOF))
+			receiver.Close()
+		})
+	}
+
+	// values of other types fail to unmarshal
+	client, server := ConnPipe(mockKey)
+	defer client.Close()
+	defer server.Close()
+	go NewChannel[string](client).Send("not a mockValue")
+	_, err := NewChannel[mockValue](server).Receive()
+	var decodeErr *DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.True(t, decodeErr.Verified)
+}
@@ -0,0 +1,43 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithChecksum(t *testing.T) {
+	tests := []struct {
+		name        string
+		readerOpts  []Option
+		expectError error
+	}{
+		{
+			name:       "Happy path",
+			readerOpts: []Option{WithChecksum()},
+		},
+		{
+			name:        "Rejected by policy",
+			readerOpts:  []Option{WithChecksum(), WithPolicy(&Policy{})},
+			expectError: ErrPolicyViolation,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			// the key is ignored
+			_, err := NewAppendMACWriter(buf, nil, WithChecksum()).Write([]byte("hello"))
+			assert.Nil(t, err)
+
+			message, err := NewVerifyMACReader(buf, []byte("any key"), test.readerOpts...).Next()
+			if test.expectError != nil {
+				assert.True(t, errors.Is(err, test.expectError))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, "hello", string(message))
+		})
+	}
+}
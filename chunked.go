@@ -0,0 +1,262 @@
+package authio
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// DefaultChunkSize is the default size of the chunks of ChunkedWriterAts
+	DefaultChunkSize = 64 * 1024
+
+	// ChunkIndexFileExtension is the conventional extension of
+	// chunk index files, e.g. "image.iso" -> "image.iso.idx"
+	ChunkIndexFileExtension = ".idx"
+
+	// chunk indexes start with a magic number, the chunk size, and
+	// the size of the data, followed by the MAC of those and then
+	// the MAC of every chunk
+	chunkIndexMagic          = "AIOX"
+	chunkIndexFieldsSize     = 4 + 4 + 8
+	chunkMACLabel            = "authio chunk"
+	chunkIndexHeaderMACLabel = "authio chunk index"
+)
+
+// ChunkedWriterAt is an io.WriterAt which writes data as is to an underlying
+// io.WriterAt, and the MAC of every fixed size chunk of it to an index (e.g.
+// a sidecar file), such that a ChunkedReaderAt can verify and read the data
+// at arbitrary offsets without scanning it from the start. The size of the
+// data must be known upfront, e.g. for downloads resumed at any chunk.
+type ChunkedWriterAt struct {
+	data      io.WriterAt // underlying io.WriterAt to write data to
+	index     io.WriterAt // underlying io.WriterAt to write the index to
+	key       []byte
+	hashFn    func() hash.Hash
+	size      int64
+	chunkSize int
+	err       error // why the ChunkedWriterAt is unusable, if it is
+
+	headerOnce sync.Once
+	headerErr  error
+}
+
+// ensure ChunkedWriterAt implements io.WriterAt at compile-time
+var _ io.WriterAt = (*ChunkedWriterAt)(nil)
+
+// NewChunkedWriterAt returns a new ChunkedWriterAt for data of the given size,
+// split in chunks of the given size (e.g. DefaultChunkSize). Of the options,
+// only WithHashFn applies.
+func NewChunkedWriterAt(data, index io.WriterAt, key []byte, size int64, chunkSize int, opts ...Option) *ChunkedWriterAt {
+	config := newConfig(opts...)
+	w := &ChunkedWriterAt{
+		data:      data,
+		index:     index,
+		key:       key,
+		hashFn:    config.hashFn,
+		size:      size,
+		chunkSize: chunkSize,
+	}
+	if chunkSize <= 0 || int64(chunkSize) > int64(^uint32(0)) {
+		w.err = fmt.Errorf("invalid chunk size %d", chunkSize)
+	} else if size < 0 {
+		w.err = fmt.Errorf("invalid size %d", size)
+	}
+	return w
+}
+
+// WriteAt writes the given chunks at the given offset, which must be at the
+// start of a chunk. Every chunk must be written in full at once, i.e. the
+// buffer must hold a whole number of chunks, or end at the end of the data.
+func (w *ChunkedWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if off%int64(w.chunkSize) != 0 {
+		return 0, fmt.Errorf("offset %d is not at the start of a chunk of %d bytes", off, w.chunkSize)
+	}
+	end := off + int64(len(p))
+	if end > w.size {
+		return 0, fmt.Errorf("write past the end of the data, at %d of %d bytes", end, w.size)
+	}
+	if len(p)%w.chunkSize != 0 && end != w.size {
+		return 0, fmt.Errorf("partial chunk written, %d bytes are not a whole number of %d byte chunks", len(p), w.chunkSize)
+	}
+	w.headerOnce.Do(func() { w.headerErr = w.writeHeader() })
+	if w.headerErr != nil {
+		return 0, w.headerErr
+	}
+
+	macSize := w.hashFn().Size()
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		if len(chunk) > w.chunkSize {
+			chunk = chunk[:w.chunkSize]
+		}
+		i := (off + int64(written)) / int64(w.chunkSize)
+
+		if _, err := w.data.WriteAt(chunk, off+int64(written)); err != nil {
+			return written, fmt.Errorf("failed to write chunk %d: %w", i, err)
+		}
+		mac := computeChunkMAC(w.hashFn, w.key, w.chunkSize, w.size, i, chunk)
+		if _, err := w.index.WriteAt(mac, chunkIndexHeaderSize(macSize)+i*int64(macSize)); err != nil {
+			return written, fmt.Errorf("failed to write MAC of chunk %d: %w", i, err)
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+// writeHeader writes the header of the index
+func (w *ChunkedWriterAt) writeHeader() error {
+	fields := encodeChunkIndexFields(w.chunkSize, w.size)
+	header := append(fields, computeChunkIndexHeaderMAC(w.hashFn, w.key, fields)...)
+	if _, err := w.index.WriteAt(header, 0); err != nil {
+		return fmt.Errorf("failed to write chunk index header: %w", err)
+	}
+	return nil
+}
+
+// ChunkedReaderAt is an io.ReaderAt which verifies the MACs of the chunks of
+// data written by a ChunkedWriterAt (with the index it wrote) as it reads them,
+// such that data can be read at arbitrary offsets without scanning it from the
+// start. Only data which was verified is returned: reads of chunks which fail
+// verification (or were never written) fail with an error wrapping authenticator.ErrMACMismatch.
+type ChunkedReaderAt struct {
+	data   io.ReaderAt // underlying io.ReaderAt to read data from
+	index  io.ReaderAt // underlying io.ReaderAt to read the index from
+	key    []byte
+	hashFn func() hash.Hash
+
+	headerOnce sync.Once
+	headerErr  error
+	size       int64
+	chunkSize  int
+}
+
+// ensure ChunkedReaderAt implements io.ReaderAt at compile-time
+var _ io.ReaderAt = (*ChunkedReaderAt)(nil)
+
+// NewChunkedReaderAt returns a new ChunkedReaderAt. Of the options,
+// only WithHashFn applies.
+func NewChunkedReaderAt(data, index io.ReaderAt, key []byte, opts ...Option) *ChunkedReaderAt {
+	config := newConfig(opts...)
+	return &ChunkedReaderAt{
+		data:   data,
+		index:  index,
+		key:    key,
+		hashFn: config.hashFn,
+	}
+}
+
+// Size returns the size of the data, as per the (verified) index
+func (r *ChunkedReaderAt) Size() (int64, error) {
+	if err := r.readHeader(); err != nil {
+		return 0, err
+	}
+	return r.size, nil
+}
+
+// ReadAt reads and verifies the chunks overlapping the given range of the data,
+// copying the range onto the given buffer. As per io.ReaderAt, it returns io.EOF
+// if the range goes past the end of the data.
+func (r *ChunkedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if err := r.readHeader(); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	macSize := r.hashFn().Size()
+	chunk := make([]byte, r.chunkSize)
+	mac := make([]byte, macSize)
+	n := 0
+	for n < len(p) && off+int64(n) < r.size {
+		pos := off + int64(n)
+		i := pos / int64(r.chunkSize)
+		start := i * int64(r.chunkSize)
+		length := int64(r.chunkSize)
+		if start+length > r.size {
+			length = r.size - start
+		}
+
+		// io.ReaderAts may return io.EOF along with the last chunk
+		if read, err := r.data.ReadAt(chunk[:length], start); int64(read) < length {
+			return n, fmt.Errorf("failed to read chunk %d: %w", i, err)
+		}
+		if read, err := r.index.ReadAt(mac, chunkIndexHeaderSize(macSize)+i*int64(macSize)); read < macSize {
+			return n, fmt.Errorf("failed to read MAC of chunk %d: %w", i, err)
+		}
+		if !hmac.Equal(mac, computeChunkMAC(r.hashFn, r.key, r.chunkSize, r.size, i, chunk[:length])) {
+			return n, fmt.Errorf("%w: chunk %d", authenticator.ErrMACMismatch, i)
+		}
+		n += copy(p[n:], chunk[pos-start:length])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readHeader reads and verifies the header of the index (once)
+func (r *ChunkedReaderAt) readHeader() error {
+	r.headerOnce.Do(func() {
+		macSize := r.hashFn().Size()
+		header := make([]byte, chunkIndexHeaderSize(macSize))
+		if read, err := r.index.ReadAt(header, 0); read < len(header) {
+			r.headerErr = fmt.Errorf("failed to read chunk index header: %w", err)
+			return
+		}
+		fields := header[:chunkIndexFieldsSize]
+		if string(fields[:len(chunkIndexMagic)]) != chunkIndexMagic {
+			r.headerErr = errors.New("not a chunk index")
+			return
+		}
+		if !hmac.Equal(header[chunkIndexFieldsSize:], computeChunkIndexHeaderMAC(r.hashFn, r.key, fields)) {
+			r.headerErr = fmt.Errorf("%w: chunk index header", authenticator.ErrMACMismatch)
+			return
+		}
+		r.chunkSize = int(binary.BigEndian.Uint32(fields[4:8]))
+		r.size = int64(binary.BigEndian.Uint64(fields[8:]))
+		if r.chunkSize <= 0 || r.size < 0 {
+			r.headerErr = errors.New("invalid chunk index header")
+		}
+	})
+	return r.headerErr
+}
+
+func chunkIndexHeaderSize(macSize int) int64 {
+	return int64(chunkIndexFieldsSize + macSize)
+}
+
+func encodeChunkIndexFields(chunkSize int, size int64) []byte {
+	fields := append([]byte(chunkIndexMagic), binary.BigEndian.AppendUint32(nil, uint32(chunkSize))...)
+	return binary.BigEndian.AppendUint64(fields, uint64(size))
+}
+
+func computeChunkIndexHeaderMAC(hashFn func() hash.Hash, key, fields []byte) []byte {
+	mac := hmac.New(hashFn, key)
+	mac.Write([]byte(chunkIndexHeaderMACLabel))
+	mac.Write(fields)
+	return mac.Sum(nil)
+}
+
+// computeChunkMAC computes the MAC of a chunk, which binds its position
+// and the size of the data, such that chunks cannot be reordered nor the
+// data truncated or extended
+func computeChunkMAC(hashFn func() hash.Hash, key []byte, chunkSize int, size, i int64, chunk []byte) []byte {
+	mac := hmac.New(hashFn, key)
+	mac.Write([]byte(chunkMACLabel))
+	mac.Write(encodeChunkIndexFields(chunkSize, size))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	mac.Write(chunk)
+	return mac.Sum(nil)
+}
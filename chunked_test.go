@@ -0,0 +1,96 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_ChunkedReaderAt(t *testing.T) {
+	mockKey := []byte("mock key")
+	data := bytes.Repeat([]byte("0123456789"), 10) // 100 bytes in chunks of 16
+
+	newFiles := func(t *testing.T) (*os.File, *os.File) {
+		dir := t.TempDir()
+		dataFile, err := os.Create(filepath.Join(dir, "data"))
+		assert.NoError(t, err)
+		indexFile, err := os.Create(filepath.Join(dir, "data"+ChunkIndexFileExtension))
+		assert.NoError(t, err)
+		t.Cleanup(func() { dataFile.Close(); indexFile.Close() })
+		return dataFile, indexFile
+	}
+
+	// chunks can be written in any order, e.g. by resumed downloads
+	dataFile, indexFile := newFiles(t)
+	w := NewChunkedWriterAt(dataFile, indexFile, mockKey, int64(len(data)), 16)
+	n, err := w.WriteAt(data[64:], 64)
+	assert.NoError(t, err)
+	assert.Equal(t, 36, n)
+	n, err = w.WriteAt(data[:64], 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 64, n)
+
+	_, err = w.WriteAt(data[:10], 16)
+	assert.Error(t, err, "partial chunk")
+	_, err = w.WriteAt(data[:16], 8)
+	assert.Error(t, err, "unaligned offset")
+	_, err = w.WriteAt(data[:32], 80)
+	assert.Error(t, err, "past the end")
+
+	r := NewChunkedReaderAt(dataFile, indexFile, mockKey)
+	size, err := r.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), size)
+
+	tests := []struct {
+		name        string
+		off         int64
+		length      int
+		expected    []byte
+		expectedErr error
+	}{
+		{name: "Within a chunk", off: 3, length: 5, expected: data[3:8]},
+		{name: "Across chunks", off: 10, length: 50, expected: data[10:60]},
+		{name: "Last chunk", off: 96, length: 4, expected: data[96:]},
+		{name: "Past the end", off: 90, length: 20, expected: data[90:], expectedErr: io.EOF},
+		{name: "At the end", off: 100, length: 1, expected: []byte{}, expectedErr: io.EOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := make([]byte, test.length)
+			n, err := r.ReadAt(buf, test.off)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf[:n])
+		})
+	}
+
+	// tampering with a chunk only fails reads of that chunk
+	_, err = dataFile.WriteAt([]byte("X"), 40)
+	assert.NoError(t, err)
+	_, err = r.ReadAt(make([]byte, 8), 36)
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+	_, err = r.ReadAt(make([]byte, 8), 48)
+	assert.NoError(t, err)
+
+	// as do chunks which were never written
+	dataFile, indexFile = newFiles(t)
+	w = NewChunkedWriterAt(dataFile, indexFile, mockKey, int64(len(data)), 16)
+	_, err = w.WriteAt(data[:16], 0)
+	assert.NoError(t, err)
+	assert.NoError(t, dataFile.Truncate(int64(len(data))))
+	r = NewChunkedReaderAt(dataFile, indexFile, mockKey)
+	_, err = r.ReadAt(make([]byte, 16), 0)
+	assert.NoError(t, err)
+	_, err = r.ReadAt(make([]byte, 16), 16)
+	assert.Error(t, err)
+
+	// as does reading with the wrong key
+	_, err = NewChunkedReaderAt(dataFile, indexFile, []byte("wrong key")).ReadAt(make([]byte, 16), 0)
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+}
@@ -0,0 +1,27 @@
+package authio
+
+import "time"
+
+// Clock is a source of the current time, e.g. to make tests deterministic
+// or to compensate for the odd clocks of embedded systems (see WithClock)
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock of the system (i.e. time.Now), used by default
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+// Now returns the current (local) time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ClockFunc is an adapter to use a function as a Clock
+type ClockFunc func() time.Time
+
+// Now returns the result of calling the function
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
@@ -0,0 +1,41 @@
+package authio
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithClock(t *testing.T) {
+	mockKey := []byte("mock key")
+	now := time.Unix(1700000000, 0)
+	clock := ClockFunc(func() time.Time { return now })
+
+	// conns record their creation time
+	client, server := ConnPipe(mockKey, WithClock(clock))
+	defer client.Close()
+	defer server.Close()
+	assert.True(t, now.Equal(server.AuthInfo().Established))
+	assert.True(t, now.Equal(server.Stats().LastRekey))
+
+	// CBOR headers are timestamped
+	frame := &bytes.Buffer{}
+	w := NewAppendMACWriter(frame, mockKey, WithCBORHeaders(""), WithClock(clock))
+	_, err := w.Write([]byte("hello"))
+	assert.NoError(t, err)
+	_, header, err := authenticator.NewCBORMessageAuthenticator(newConfig().hashFn, mockKey).ReadNextWithHeader(frame)
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(header.Timestamp))
+
+	// failures and bans expire as per the clock
+	peer := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}
+	limiter := NewFailureLimiter(0, time.Minute, time.Hour).WithClock(clock)
+	assert.True(t, limiter.Failed(peer))
+	assert.True(t, limiter.Banned(peer))
+	now = now.Add(time.Hour + time.Second)
+	assert.False(t, limiter.Banned(peer))
+}
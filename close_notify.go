@@ -0,0 +1,23 @@
+package authio
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// ErrTruncatedStream is returned by readers configured WithCloseNotify
+// when a stream ends without a close notification, e.g. because an
+// attacker (or a failure) cut the underlying connection
+var ErrTruncatedStream = errors.New("stream ended without close notification")
+
+// getCloseNotifyHeader returns a close notification from
+// the given MessageAuthenticator, if it supports them
+func getCloseNotifyHeader(a authenticator.MessageAuthenticator) ([]byte, error) {
+	notifier, ok := a.(authenticator.CloseNotifier)
+	if !ok {
+		return nil, fmt.Errorf("%T does not support close notifications", a)
+	}
+	return notifier.GetCloseNotifyHeader()
+}
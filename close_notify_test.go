@@ -0,0 +1,89 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_CloseNotify(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name        string
+		writeOpts   []Option
+		readOpts    []Option
+		closeWriter bool
+		expectedErr error
+	}{
+		{
+			name:        "Graceful close",
+			writeOpts:   []Option{WithCloseNotify()},
+			readOpts:    []Option{WithCloseNotify()},
+			closeWriter: true,
+			expectedErr: io.EOF,
+		},
+		{
+			name:        "Truncated stream",
+			writeOpts:   []Option{WithCloseNotify()},
+			readOpts:    []Option{WithCloseNotify()},
+			closeWriter: false,
+			expectedErr: ErrTruncatedStream,
+		},
+		{
+			name:        "Reader not requiring close notification",
+			writeOpts:   []Option{WithCloseNotify()},
+			closeWriter: true,
+			expectedErr: io.EOF,
+		},
+		{
+			name:        "Writer not sending close notification",
+			readOpts:    []Option{WithCloseNotify()},
+			closeWriter: true,
+			expectedErr: ErrTruncatedStream,
+		},
+		{
+			name:        "Neither side using close notifications",
+			closeWriter: true,
+			expectedErr: io.EOF,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := NewAppendMACWriter(buf, mockKey, test.writeOpts...)
+			_, err := w.Write([]byte("hello"))
+			assert.NoError(t, err)
+			if test.closeWriter {
+				assert.NoError(t, w.Close())
+			}
+
+			r := NewVerifyMACReader(buf, mockKey, test.readOpts...)
+			_, err = r.Next()
+			assert.NoError(t, err)
+			_, err = r.Next()
+			assert.True(t, errors.Is(err, test.expectedErr))
+		})
+	}
+}
+
+func Test_CloseNotifyAppendMACReaderToVerifyMACWriter(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	out := &bytes.Buffer{}
+	w := NewVerifyMACWriter(out, mockKey, WithCloseNotify())
+	_, err := io.Copy(w, NewAppendMACReader(strings.NewReader("hello world"), mockKey, WithCloseNotify()))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "hello world", out.String())
+
+	// a VerifyMACWriter which never saw a close notification reports truncation
+	w = NewVerifyMACWriter(&bytes.Buffer{}, mockKey, WithCloseNotify())
+	_, err = io.Copy(w, NewAppendMACReader(strings.NewReader("hello world"), mockKey))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(w.Close(), ErrTruncatedStream))
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/adrianosela/authio/internal/proxy"
+)
+
+func main() {
+	if err := proxy.RunCommand("authio-proxy", os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "authio-proxy: %s\n", err)
+		os.Exit(1)
+	}
+}
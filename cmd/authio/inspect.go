@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrianosela/authio/internal/cli"
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// frame formats which can be selected with -format
+const (
+	formatAuto    = "auto"
+	formatDefault = "default"
+	formatCBOR    = "cbor"
+)
+
+// cborMapLengthFieldSize is the size of the (big endian uint16)
+// length of the CBOR map which starts frames with CBOR headers
+const cborMapLengthFieldSize = 2
+
+// frameInfo is the (unverified) information in the header of a frame
+type frameInfo struct {
+	Index       int    `json:"index"`
+	Offset      int64  `json:"offset"`
+	Format      string `json:"format"`
+	Length      uint64 `json:"length"`
+	PayloadSize uint64 `json:"payload_size"`
+	MAC         string `json:"mac"`
+	CloseNotify bool   `json:"close_notify,omitempty"`
+	Control     bool   `json:"control,omitempty"`
+
+	// fields of the extension area (or of CBOR headers)
+	KeyID       string          `json:"key_id,omitempty"`
+	Sequence    *uint64         `json:"sequence,omitempty"`
+	Timestamp   *time.Time      `json:"timestamp,omitempty"`
+	Compression *byte           `json:"compression,omitempty"`
+	Padding     int             `json:"padding,omitempty"`
+	Extensions  []extensionInfo `json:"extensions,omitempty"`
+}
+
+// extensionInfo is an extension (or unknown CBOR header field) inspect does not know
+type extensionInfo struct {
+	Type  uint64 `json:"type"`
+	Value string `json:"value"` // hex encoded
+}
+
+func runInspect(args []string) error {
+	var (
+		hash        string
+		tagSize     int
+		macEncoding string
+		format      string
+		asJSON      bool
+	)
+	fs := cli.NewFlagSet("authio inspect", "[file]")
+	fs.StringVar(&hash, "hash", cli.DefaultHash, fmt.Sprintf("hash function the frames were signed with (one of %v)", cli.HashNames()))
+	fs.IntVar(&tagSize, "tag-size", 0, "size in bytes the MACs of the frames were truncated to (0 for no truncation)")
+	fs.StringVar(&macEncoding, "mac-encoding", cli.DefaultMACEncoding, fmt.Sprintf("text encoding of the MACs of the frames (one of %v)", cli.MACEncodingNames()))
+	fs.StringVar(&format, "format", formatAuto, fmt.Sprintf("format of the frames, detected per frame with %q (one of %v)", formatAuto, []string{formatAuto, formatDefault, formatCBOR}))
+	fs.BoolVar(&asJSON, "json", false, "print one JSON object per frame")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+
+	hashFn, err := cli.LookupHash(hash)
+	if err != nil {
+		return err
+	}
+	encoding, err := cli.LookupMACEncoding(macEncoding)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case formatAuto:
+		// raw MACs may look like the start of a CBOR header
+		if encoding == authenticator.Raw {
+			format = formatDefault
+		}
+	case formatDefault, formatCBOR:
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	macSize := hashFn().Size()
+	if tagSize != 0 {
+		macSize = tagSize
+	}
+	headerLen := authenticator.HeaderLengthWithEncoding(macSize, encoding)
+
+	input, err := cli.OpenInput(fs)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	reader := bufio.NewReader(input)
+	encoder := json.NewEncoder(os.Stdout)
+	offset := int64(0)
+
+	for index := 0; ; index++ {
+		frameFormat := format
+		if frameFormat == formatAuto {
+			start, err := reader.Peek(authenticator.CBORDetectionSize)
+			if len(start) == 0 && errors.Is(err, io.EOF) {
+				return nil
+			}
+			frameFormat = formatDefault
+			if authenticator.HasCBORHeader(start) {
+				frameFormat = formatCBOR
+			}
+		}
+
+		var info frameInfo
+		var payloadLeft uint64
+		if frameFormat == formatCBOR {
+			info, payloadLeft, err = inspectCBORHeader(reader, macSize)
+		} else {
+			info, payloadLeft, err = inspectHeader(reader, headerLen)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("frame %d at offset %d: %w", index, offset, err)
+		}
+		info.Index, info.Offset, info.Format = index, offset, frameFormat
+
+		if asJSON {
+			if err := encoder.Encode(info); err != nil {
+				return fmt.Errorf("failed to write JSON: %w", err)
+			}
+		} else {
+			fmt.Println(info.String())
+		}
+
+		// skip over the (rest of the) payload without keeping it in memory
+		n, err := io.CopyN(io.Discard, reader, int64(payloadLeft))
+		if err != nil {
+			return fmt.Errorf("frame %d at offset %d: payload truncated, got %d of %d bytes", index, offset, n, payloadLeft)
+		}
+		offset += int64(info.Length)
+	}
+}
+
+// inspectHeader reads the header (and extension area, if any) of a frame in
+// the default format, returning it and the number of payload bytes left
+func inspectHeader(r io.Reader, headerLen int) (frameInfo, uint64, error) {
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return frameInfo{}, 0, io.EOF
+		}
+		return frameInfo{}, 0, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	parsed, err := authenticator.ParseFrameHeader(header)
+	if err != nil {
+		return frameInfo{}, 0, err
+	}
+	info := frameInfo{
+		Length:      parsed.Length,
+		PayloadSize: parsed.PayloadLength,
+		MAC:         string(parsed.MAC),
+		CloseNotify: parsed.CloseNotify,
+		Control:     parsed.Control,
+	}
+	if parsed.CloseNotify {
+		info.Length = uint64(headerLen)
+		return info, 0, nil
+	}
+	if !parsed.Extensions {
+		return info, parsed.PayloadLength, nil
+	}
+
+	// the extension area, i.e. its (big endian uint16) length
+	// followed by the extensions, starts the payload
+	if parsed.PayloadLength < 2 {
+		return frameInfo{}, 0, errors.New("payload too short to have extension area")
+	}
+	area := make([]byte, 2)
+	if _, err := io.ReadFull(r, area); err != nil {
+		return frameInfo{}, 0, fmt.Errorf("failed to read extension area: %w", err)
+	}
+	areaLen := uint64(2) + uint64(binary.BigEndian.Uint16(area))
+	if areaLen > parsed.PayloadLength {
+		return frameInfo{}, 0, errors.New("extension area longer than payload")
+	}
+	area = append(area, make([]byte, areaLen-2)...)
+	if _, err := io.ReadFull(r, area[2:]); err != nil {
+		return frameInfo{}, 0, fmt.Errorf("failed to read extension area: %w", err)
+	}
+	extensions, _, err := authenticator.ParseExtensions(area)
+	if err != nil {
+		return frameInfo{}, 0, fmt.Errorf("invalid extension area: %w", err)
+	}
+	for _, extension := range extensions {
+		info.addExtension(extension)
+	}
+	return info, parsed.PayloadLength - areaLen, nil
+}
+
+// addExtension sets the field of the frameInfo for the given extension
+func (i *frameInfo) addExtension(extension authenticator.Extension) {
+	switch {
+	case extension.Type == authenticator.ExtensionKeyID:
+		i.KeyID = string(extension.Value)
+	case extension.Type == authenticator.ExtensionSequence && len(extension.Value) == 8:
+		seq := binary.BigEndian.Uint64(extension.Value)
+		i.Sequence = &seq
+	case extension.Type == authenticator.ExtensionTimestamp && len(extension.Value) == 8:
+		ts := time.Unix(0, int64(binary.BigEndian.Uint64(extension.Value))).UTC()
+		i.Timestamp = &ts
+	case extension.Type == authenticator.ExtensionCompression && len(extension.Value) == 1:
+		i.Compression = &extension.Value[0]
+	case extension.Type == authenticator.ExtensionPadding:
+		i.Padding += len(extension.Value)
+	default:
+		i.Extensions = append(i.Extensions, extensionInfo{Type: uint64(extension.Type), Value: hex.EncodeToString(extension.Value)})
+	}
+}
+
+// inspectCBORHeader reads the header of a frame with a CBOR header (see
+// authenticator.CBORMessageAuthenticator), returning it and the number
+// of payload bytes left
+func inspectCBORHeader(r io.Reader, macSize int) (frameInfo, uint64, error) {
+	mapLen := make([]byte, cborMapLengthFieldSize)
+	if _, err := io.ReadFull(r, mapLen); err != nil {
+		if errors.Is(err, io.EOF) {
+			return frameInfo{}, 0, io.EOF
+		}
+		return frameInfo{}, 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	fields := make([]byte, binary.BigEndian.Uint16(mapLen))
+	if _, err := io.ReadFull(r, fields); err != nil {
+		return frameInfo{}, 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	header, err := authenticator.ParseCBORHeader(fields)
+	if err != nil {
+		return frameInfo{}, 0, fmt.Errorf("invalid header: %w", err)
+	}
+	mac := make([]byte, macSize)
+	if _, err := io.ReadFull(r, mac); err != nil {
+		return frameInfo{}, 0, fmt.Errorf("failed to read MAC: %w", err)
+	}
+
+	info := frameInfo{
+		Length:      uint64(len(mapLen)+len(fields)+macSize) + header.Length,
+		PayloadSize: header.Length,
+		MAC:         base64.StdEncoding.EncodeToString(mac),
+		CloseNotify: header.CloseNotify(),
+		Control:     header.Control(),
+		KeyID:       header.KeyID,
+		Sequence:    &header.Sequence,
+	}
+	if !header.Timestamp.IsZero() {
+		ts := header.Timestamp.UTC()
+		info.Timestamp = &ts
+	}
+	keys := make([]uint64, 0, len(header.Extensions))
+	for key := range header.Extensions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, key := range keys {
+		info.Extensions = append(info.Extensions, extensionInfo{Type: key, Value: hex.EncodeToString(header.Extensions[key])})
+	}
+	return info, header.Length, nil
+}
+
+// String returns the frameInfo as a line of text
+func (i frameInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "frame %d at offset %d: ", i.Index, i.Offset)
+	switch {
+	case i.CloseNotify:
+		b.WriteString("close notification")
+	case i.Control:
+		fmt.Fprintf(&b, "control frame, length %d (payload %d)", i.Length, i.PayloadSize)
+	default:
+		fmt.Fprintf(&b, "length %d (payload %d)", i.Length, i.PayloadSize)
+	}
+	fmt.Fprintf(&b, ", MAC %s", i.MAC)
+	if i.Format == formatCBOR {
+		b.WriteString(", CBOR header")
+	}
+	if i.KeyID != "" {
+		fmt.Fprintf(&b, ", key ID %q", i.KeyID)
+	}
+	if i.Sequence != nil {
+		fmt.Fprintf(&b, ", sequence %d", *i.Sequence)
+	}
+	if i.Timestamp != nil {
+		fmt.Fprintf(&b, ", timestamp %s", i.Timestamp.Format(time.RFC3339Nano))
+	}
+	if i.Compression != nil {
+		fmt.Fprintf(&b, ", compressed with algorithm %d", *i.Compression)
+	}
+	if i.Padding > 0 {
+		fmt.Fprintf(&b, ", %d bytes of padding", i.Padding)
+	}
+	for _, extension := range i.Extensions {
+		fmt.Fprintf(&b, ", extension %d (%s)", extension.Type, extension.Value)
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/adrianosela/authio/internal/cli"
+)
+
+const (
+	defaultKeyLength = 32
+
+	// keys shorter than this are refused, see RFC 2104 section 3
+	minKeyLength = 16
+)
+
+func runKeygen(args []string) error {
+	var (
+		length   int
+		encoding string
+		out      string
+	)
+	fs := cli.NewFlagSet("authio keygen", "")
+	fs.IntVar(&length, "length", defaultKeyLength, "number of random bytes in the key")
+	fs.StringVar(&encoding, "encoding", "base64", "encoding of the key (base64 or hex)")
+	fs.StringVar(&out, "out", "", "file to write the key to with 0600 permissions, must not exist (default: stdout)")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if length < minKeyLength {
+		return fmt.Errorf("key length must be at least %d bytes", minKeyLength)
+	}
+
+	raw := make([]byte, length)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate random key: %w", err)
+	}
+
+	var key string
+	switch encoding {
+	case "base64":
+		key = base64.StdEncoding.EncodeToString(raw)
+	case "hex":
+		key = hex.EncodeToString(raw)
+	default:
+		return fmt.Errorf("unknown encoding %q, must be base64 or hex", encoding)
+	}
+
+	if out == "" {
+		fmt.Println(key)
+		return nil
+	}
+
+	file, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := fmt.Fprintln(file, key); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is an authio CLI subcommand
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{name: "sign", description: "sign data from a file or stdin", run: runSign},
+	{name: "inspect", description: "print the headers of authenticated messages without verifying them", run: runInspect},
+	{name: "pipe", description: "sign or verify data streamed from stdin to stdout", run: runPipe},
+	{name: "proxy", description: "proxy connections, adding MACs in one direction and verifying them in the other", run: runProxy},
+	{name: "keygen", description: "generate a random key", run: runKeygen},
+	{name: "verify", description: "verify data from a file or stdin, writing verified data to stdout", run: runVerify},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "authio %s: %s\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "authio: unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: authio <command> [flags] [file]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'authio <command> -h' for the flags of a command.\n")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/internal/cli"
+)
+
+const (
+	modeSign   = "sign"
+	modeVerify = "verify"
+)
+
+func runPipe(args []string) error {
+	var (
+		keys   cli.KeyFlags
+		frames cli.FrameFlags
+		mode   string
+	)
+	fs := cli.NewFlagSet("authio pipe", "")
+	keys.Register(fs)
+	frames.Register(fs)
+	fs.StringVar(&mode, "mode", modeSign, "whether to sign or verify data (sign or verify)")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	key, err := keys.Load()
+	if err != nil {
+		return err
+	}
+	opts, err := frames.Options()
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case modeSign:
+		return signStream(os.Stdout, os.Stdin, key, opts...)
+	case modeVerify:
+		return verifyStream(os.Stdout, os.Stdin, key, opts...)
+	default:
+		return fmt.Errorf("unknown mode %q, must be %s or %s", mode, modeSign, modeVerify)
+	}
+}
+
+// signStream writes authenticated messages for all data in src to dst.
+// Data is processed as it becomes available (one message per read of
+// src), so it never buffers more than a single read's worth of data.
+func signStream(dst io.Writer, src io.Reader, key []byte, opts ...authio.Option) error {
+	if _, err := io.Copy(authio.NewWriter(dst, key, opts...), src); err != nil {
+		return fmt.Errorf("failed to sign input: %w", err)
+	}
+	return nil
+}
+
+// verifyStream verifies authenticated messages in src and writes their
+// raw contents to dst. Messages are processed one at a time, and every
+// message is verified before it is written to dst, so only verified data
+// is ever emitted even if verification fails part-way through src.
+func verifyStream(dst io.Writer, src io.Reader, key []byte, opts ...authio.Option) error {
+	if _, err := io.Copy(dst, authio.NewReader(src, key, opts...)); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package main
+
+import "github.com/adrianosela/authio/internal/proxy"
+
+func runProxy(args []string) error {
+	return proxy.RunCommand("authio proxy", args)
+}
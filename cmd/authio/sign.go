@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/internal/cli"
+)
+
+const (
+	outputFrames    = "frames"
+	outputMAC       = "mac"
+	outputDecorated = "decorated"
+
+	// detached MACs printed with -output decorated are wrapped like
+	// the output of the now removed build_hmac command
+	decoratedMACPrefix = "----B64-HMAC-START----|"
+	decoratedMACSuffix = "|----B64-HMAC-END----"
+)
+
+func runSign(args []string) error {
+	var (
+		keys     cli.KeyFlags
+		frames   cli.FrameFlags
+		output   string
+		detached bool
+	)
+	fs := cli.NewFlagSet("authio sign", "[file]")
+	keys.Register(fs)
+	frames.Register(fs)
+	fs.StringVar(&output, "output", outputFrames, fmt.Sprintf("%s: write authenticated messages, %s: print a detached MAC over all data, %s: print a detached MAC decorated like build_hmac did", outputFrames, outputMAC, outputDecorated))
+	fs.BoolVar(&detached, "detached", false, fmt.Sprintf("same as -output %s", outputMAC))
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if detached {
+		output = outputMAC
+	}
+	if output != outputFrames && output != outputMAC && output != outputDecorated {
+		return fmt.Errorf("unknown output %q, must be %s, %s, or %s", output, outputFrames, outputMAC, outputDecorated)
+	}
+	if output != outputFrames && frames.Hash() != cli.DefaultHash {
+		return fmt.Errorf("detached MACs are always computed with %s", cli.DefaultHash)
+	}
+
+	key, err := keys.Load()
+	if err != nil {
+		return err
+	}
+	opts, err := frames.Options()
+	if err != nil {
+		return err
+	}
+
+	input, err := cli.OpenInput(fs)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	if output == outputFrames {
+		return signStream(os.Stdout, input, key, opts...)
+	}
+
+	// the input is hashed as it is read, never buffered in full
+	reader := authio.NewDetachedSignReader(input, key)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if output == outputDecorated {
+		fmt.Println(decoratedMACPrefix + reader.MAC() + decoratedMACSuffix)
+		return nil
+	}
+	fmt.Println(reader.MAC())
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/internal/cli"
+)
+
+func runVerify(args []string) error {
+	var (
+		keys    cli.KeyFlags
+		frames  cli.FrameFlags
+		mac     string
+		macFile string
+	)
+	fs := cli.NewFlagSet("authio verify", "[file]")
+	keys.Register(fs)
+	frames.Register(fs)
+	fs.StringVar(&mac, "mac", "", "detached MAC to verify all data against (default: verify authenticated messages)")
+	fs.StringVar(&macFile, "mac-file", "", "file to read the detached MAC to verify all data against from")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+
+	key, err := keys.Load()
+	if err != nil {
+		return err
+	}
+	opts, err := frames.Options()
+	if err != nil {
+		return err
+	}
+
+	if macFile != "" {
+		if mac != "" {
+			return errors.New("only one of -mac and -mac-file may be set")
+		}
+		data, err := os.ReadFile(macFile)
+		if err != nil {
+			return fmt.Errorf("failed to read MAC file: %w", err)
+		}
+		mac = string(data)
+	}
+	// detached MACs may be decorated (see sign -output decorated)
+	mac = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(mac), decoratedMACPrefix), decoratedMACSuffix)
+
+	input, err := cli.OpenInput(fs)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	if mac == "" {
+		return verifyStream(os.Stdout, input, key, opts...)
+	}
+
+	if _, err := io.Copy(io.Discard, authio.NewDetachedVerifyReader(input, mac, key)); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "OK")
+	return nil
+}
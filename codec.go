@@ -0,0 +1,142 @@
+package authio
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Codec marshals and unmarshals the values sent by Encoders and Decoders
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSONCodec is a Codec which marshals values as JSON (see encoding/json)
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec is a Codec which marshals values as gobs (see encoding/gob).
+	// Every value is self-contained, i.e. carries its own type information.
+	GobCodec Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// DecodeError is returned by Decoder.Decode when a value cannot be decoded,
+// either because its frame failed verification (in which case Err wraps
+// authenticator.ErrMACMismatch or a framing error) or because its verified
+// frame could not be unmarshaled
+type DecodeError struct {
+	// Verified is whether the frame was verified, i.e. whether
+	// the failure is in unmarshaling rather than in verification
+	Verified bool
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Verified {
+		return fmt.Sprintf("failed to unmarshal authenticated value: %v", e.Err)
+	}
+	return fmt.Sprintf("failed to verify value: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Encoder marshals values (see WithCodec) into individually authenticated
+// frames, one per value
+type Encoder struct {
+	writer *AppendMACWriter
+	codec  Codec
+}
+
+// NewEncoder returns a new Encoder which writes to the given writer. Of the
+// stream options, WithDelimiter and WithFixedRecords do not apply.
+func NewEncoder(w io.Writer, key []byte, opts ...Option) *Encoder {
+	return &Encoder{
+		writer: NewAppendMACWriter(w, key, opts...),
+		codec:  newConfig(opts...).codec,
+	}
+}
+
+// Encode marshals the given value and writes it as a single frame
+func (e *Encoder) Encode(v any) error {
+	data, err := marshalValue(e.codec, v, e.writer.maxMessageLen)
+	if err != nil {
+		return err
+	}
+	_, err = e.writer.writeMessage(data)
+	return err
+}
+
+// Decoder verifies and unmarshals values written by an Encoder
+type Decoder struct {
+	reader *VerifyMACReader
+	codec  Codec
+}
+
+// NewDecoder returns a new Decoder which reads from the given reader. Options
+// (e.g. WithCodec) must match those of the Encoder.
+func NewDecoder(r io.Reader, key []byte, opts ...Option) *Decoder {
+	return &Decoder{
+		reader: NewVerifyMACReader(r, key, opts...),
+		codec:  newConfig(opts...).codec,
+	}
+}
+
+// Decode reads the next frame and unmarshals it onto the given value. It
+// returns io.EOF once the underlying reader is exhausted at a frame boundary,
+// and a *DecodeError if the frame fails verification or cannot be unmarshaled.
+func (d *Decoder) Decode(v any) error {
+	data, err := d.reader.Next()
+	return unmarshalValue(d.codec, data, err, v)
+}
+
+// marshalValue marshals the given value into the payload of a single frame
+func marshalValue(codec Codec, v any, maxMessageLen int) ([]byte, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal value: %w", err)
+	}
+	if maxMessageLen > 0 && len(data) > maxMessageLen {
+		return nil, fmt.Errorf("marshaled value too large, got %d and expected at most %d bytes", len(data), maxMessageLen)
+	}
+	return data, nil
+}
+
+// unmarshalValue unmarshals the payload of a frame read (with the given
+// error) onto the given value
+func unmarshalValue(codec Codec, data []byte, err error, v any) error {
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return err
+		}
+		return &DecodeError{Err: err}
+	}
+	if err := codec.Unmarshal(data, v); err != nil {
+		return &DecodeError{Verified: true, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+type mockValue struct {
+	Name  string
+	Count int
+}
+
+func Test_EncoderDecoder(t *testing.T) {
+	mockKey := []byte("mock key")
+	values := []mockValue{{Name: "first", Count: 1}, {Name: "second", Count: 2}}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "JSON"},
+		{name: "Gob", opts: []Option{WithCodec(GobCodec)}},
+		{name: "CBOR headers", opts: []Option{WithCBORHeaders("key")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			enc := NewEncoder(buf, mockKey, test.opts...)
+			for _, v := range values {
+				assert.NoError(t, enc.Encode(v))
+			}
+
+			dec := NewDecoder(buf, mockKey, test.opts...)
+			for _, expected := range values {
+				var v mockValue
+				assert.NoError(t, dec.Decode(&v))
+				assert.Equal(t, expected, v)
+			}
+			assert.Equal(t, io.EOF, dec.Decode(&mockValue{}))
+		})
+	}
+}
+
+func Test_DecodeError(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, NewEncoder(buf, mockKey).Encode(mockValue{Name: "value"}))
+	frame := buf.Bytes()
+
+	// frames failing verification
+	err := NewDecoder(bytes.NewReader(frame), []byte("wrong key")).Decode(&mockValue{})
+	var decodeErr *DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.False(t, decodeErr.Verified)
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+
+	// verified frames which cannot be unmarshaled
+	err = NewDecoder(bytes.NewReader(frame), mockKey).Decode(&[]int{})
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.True(t, decodeErr.Verified)
+
+	// values too large for a single frame
+	err = NewEncoder(&bytes.Buffer{}, mockKey, WithMaxMessageSize(8)).Encode(mockValue{Name: "value"})
+	assert.Error(t, err)
+}
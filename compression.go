@@ -0,0 +1,165 @@
+package authio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// IDs of compression algorithms, carried in the (authenticated) extension area
+// of compressed frames (see authenticator.ExtensionCompression). Only gzip is
+// built in: zstd and snappy IDs are reserved for implementations registered
+// with RegisterCompressor (e.g. backed by third party packages).
+const (
+	CompressionGzip   byte = 1
+	CompressionZstd   byte = 2
+	CompressionSnappy byte = 3
+)
+
+// Compressor is a registered compression algorithm (see WithCompression)
+type Compressor struct {
+	ID       byte
+	Name     string
+	Compress func(data []byte) ([]byte, error)
+	// Decompress must fail rather than decompress data larger than
+	// maxSize (if positive), such that frames cannot be decompression
+	// bombs
+	Decompress func(data []byte, maxSize int) ([]byte, error)
+}
+
+var (
+	compressorsLock   sync.RWMutex
+	compressorsByID   = map[byte]Compressor{}
+	compressorsByName = map[string]Compressor{}
+)
+
+func init() {
+	if err := RegisterCompressor(Compressor{
+		ID:         CompressionGzip,
+		Name:       "gzip",
+		Compress:   gzipCompress,
+		Decompress: gzipDecompress,
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterCompressor registers a Compressor, such that it can be used with
+// WithCompression and readers can decompress frames compressed with it. It
+// fails if its ID or name is already registered.
+func RegisterCompressor(c Compressor) error {
+	if c.Name == "" || c.Compress == nil || c.Decompress == nil {
+		return fmt.Errorf("compressor %d must have a name and compression functions", c.ID)
+	}
+
+	compressorsLock.Lock()
+	defer compressorsLock.Unlock()
+
+	if existing, ok := compressorsByID[c.ID]; ok {
+		return fmt.Errorf("compressor ID %d already registered (as %q)", c.ID, existing.Name)
+	}
+	if existing, ok := compressorsByName[c.Name]; ok {
+		return fmt.Errorf("compressor name %q already registered (with ID %d)", c.Name, existing.ID)
+	}
+	compressorsByID[c.ID] = c
+	compressorsByName[c.Name] = c
+	return nil
+}
+
+func getCompressor(id byte) (Compressor, bool) {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+	c, ok := compressorsByID[id]
+	return c, ok
+}
+
+func getCompressorByName(name string) (Compressor, bool) {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+	c, ok := compressorsByName[name]
+	return c, ok
+}
+
+// compressMessage compresses the given data with the given Compressor, if
+// any, returning the extensions of its frame with an ExtensionCompression
+// extension added. Data which does not shrink is left uncompressed.
+func compressMessage(c *Compressor, data []byte, extensions []authenticator.Extension) ([]byte, []authenticator.Extension, error) {
+	if c == nil || len(data) == 0 {
+		return data, extensions, nil
+	}
+	compressed, err := c.Compress(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compress message with %s: %w", c.Name, err)
+	}
+	if len(compressed) >= len(data) {
+		return data, extensions, nil
+	}
+	extensions = append(extensions[:len(extensions):len(extensions)], authenticator.CompressionExtension(c.ID))
+	return compressed, extensions, nil
+}
+
+// decompressMessage decompresses the given (verified) message if its
+// extensions have an ExtensionCompression extension, returning the
+// extensions without it
+func decompressMessage(msg []byte, extensions []authenticator.Extension, maxSize int) ([]byte, []authenticator.Extension, error) {
+	value, ok := authenticator.FindExtension(extensions, authenticator.ExtensionCompression)
+	if !ok {
+		return msg, extensions, nil
+	}
+	if len(value) != 1 {
+		return nil, nil, fmt.Errorf("invalid compression extension of %d bytes", len(value))
+	}
+	c, ok := getCompressor(value[0])
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown compression algorithm %d", value[0])
+	}
+	decompressed, err := c.Decompress(msg, maxSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress message with %s: %w", c.Name, err)
+	}
+
+	rest := make([]authenticator.Extension, 0, len(extensions)-1)
+	for _, extension := range extensions {
+		if extension.Type != authenticator.ExtensionCompression {
+			rest = append(rest, extension)
+		}
+	}
+	return decompressed, rest, nil
+}
+
+func gzipCompress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipDecompress(data []byte, maxSize int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var limited io.Reader = r
+	if maxSize > 0 {
+		limited = io.LimitReader(r, int64(maxSize)+1)
+	}
+	decompressed, err := io.ReadAll(limited)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && len(decompressed) > maxSize {
+		return nil, fmt.Errorf("decompressed message larger than %d bytes", maxSize)
+	}
+	return decompressed, nil
+}
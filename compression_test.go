@@ -0,0 +1,110 @@
+package authio
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithCompression(t *testing.T) {
+	mockKey := []byte("mock key")
+	compressible := []byte(strings.Repeat("log line\n", 100))
+
+	tests := []struct {
+		name           string
+		message        []byte
+		readerOpts     []Option
+		expectCompress bool
+		expectErr      bool
+	}{
+		{
+			name:           "Compressible message",
+			message:        compressible,
+			expectCompress: true,
+		},
+		{
+			name:    "Incompressible message",
+			message: []byte("hi"),
+		},
+		{
+			name:           "Decompressed message too large",
+			message:        compressible,
+			readerOpts:     []Option{WithMaxMessageSize(len(compressible) - 1)},
+			expectCompress: true,
+			expectErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer := NewAppendMACWriter(buf, mockKey, WithCompression("gzip"))
+			n, err := writer.WriteWithExtensions(test.message, authenticator.KeyIDExtension("key-1"))
+			assert.Nil(t, err)
+			assert.Equal(t, len(test.message), n)
+			assert.Equal(t, test.expectCompress, buf.Len() < len(test.message))
+
+			message, extensions, err := NewVerifyMACReader(buf, mockKey, test.readerOpts...).NextWithExtensions()
+			if test.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.message, message)
+			assert.Equal(t, []authenticator.Extension{authenticator.KeyIDExtension("key-1")}, extensions)
+		})
+	}
+}
+
+func Test_WithCompressionAppendMACReader(t *testing.T) {
+	mockKey := []byte("mock key")
+	input := strings.Repeat("log line\n", 100)
+
+	frames, err := io.ReadAll(NewAppendMACReader(strings.NewReader(input), mockKey, WithCompression("gzip")))
+	assert.Nil(t, err)
+	assert.True(t, len(frames) < len(input))
+
+	out := &bytes.Buffer{}
+	_, err = NewVerifyMACWriter(out, mockKey).Write(frames)
+	assert.Nil(t, err)
+	assert.Equal(t, input, out.String())
+}
+
+func Test_RegisterCompressor(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	// a toy run-length encoding of a single repeated byte
+	rle := Compressor{
+		ID:   0x80,
+		Name: "test-rle",
+		Compress: func(data []byte) ([]byte, error) {
+			if len(data) > 255 || len(bytes.Trim(data, string(data[:1]))) > 0 {
+				return data, nil
+			}
+			return []byte{byte(len(data)), data[0]}, nil
+		},
+		Decompress: func(data []byte, maxSize int) ([]byte, error) {
+			return bytes.Repeat(data[1:2], int(data[0])), nil
+		},
+	}
+	if _, ok := getCompressorByName(rle.Name); !ok {
+		assert.Nil(t, RegisterCompressor(rle))
+	}
+	assert.NotNil(t, RegisterCompressor(rle))
+
+	buf := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(buf, mockKey, WithCompression("test-rle")).Write(bytes.Repeat([]byte("a"), 100))
+	assert.Nil(t, err)
+	assert.True(t, buf.Len() < 100)
+
+	message, err := NewVerifyMACReader(buf, mockKey).Next()
+	assert.Nil(t, err)
+	assert.Equal(t, bytes.Repeat([]byte("a"), 100), message)
+
+	_, err = NewAppendMACWriter(buf, mockKey, WithCompression("unknown")).Write([]byte("hello"))
+	assert.NotNil(t, err)
+}
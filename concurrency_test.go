@@ -0,0 +1,189 @@
+package authio
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+// the tests in this file back the concurrency guarantees of Conns,
+// and are meant to be run with the race detector: go test -race
+
+func Test_ConnConcurrentWrites(t *testing.T) {
+	mockKey := []byte("mock key")
+	writers, messages := 8, 50
+
+	a, b := net.Pipe()
+	// heartbeats are written concurrently with the application's messages
+	client := NewClientConn(a, mockKey, WithHeartbeat(time.Millisecond, time.Minute))
+	server := NewServerConn(b, mockKey, WithHeartbeat(time.Millisecond, time.Minute))
+	defer client.Close()
+	defer server.Close()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for j := 0; j < messages; j++ {
+				var err error
+				message := []byte(fmt.Sprintf("writer %d message %d", writer, j))
+				if j%2 == 0 {
+					_, err = client.Write(message)
+				} else {
+					_, err = client.WriteBatch([][]byte{message})
+				}
+				assert.NoError(t, err)
+				client.Stats()
+				client.Config()
+			}
+		}(i)
+	}
+
+	// every message is received whole, exactly once
+	received := map[string]int{}
+	for len(received) < writers*messages {
+		message, _, err := server.NextWithExtensions()
+		if !assert.NoError(t, err) {
+			break
+		}
+		received[string(message)]++
+	}
+	wg.Wait()
+	for i := 0; i < writers; i++ {
+		for j := 0; j < messages; j++ {
+			assert.Equal(t, 1, received[fmt.Sprintf("writer %d message %d", i, j)])
+		}
+	}
+}
+
+func Test_ConnConcurrentClose(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		write bool // whether Close is called during a Write, rather than a Read
+	}{
+		{name: "Close during Read"},
+		{name: "Close during Read with close notifications", opts: []Option{WithCloseNotify()}},
+		{name: "Close during Write", write: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			client := NewClientConn(a, mockKey, test.opts...)
+			defer b.Close()
+			if !test.write {
+				// the peer takes (but never answers) whatever is written
+				go func() {
+					buf := make([]byte, 1024)
+					for {
+						if _, err := b.Read(buf); err != nil {
+							return
+						}
+					}
+				}()
+			}
+
+			done := make(chan error)
+			go func() {
+				var err error
+				if test.write {
+					// the peer never reads, so the write blocks
+					_, err = client.Write([]byte("hello"))
+				} else {
+					_, err = client.Read(make([]byte, 1024))
+				}
+				done <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+			client.Close()
+
+			select {
+			case err := <-done:
+				assert.Error(t, err)
+			case <-time.After(time.Second):
+				t.Fatal("Close did not unblock the pending call")
+			}
+			// the Conn fails (rather than races) after Close
+			_, err := client.Write([]byte("hello"))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_ConnRekeyDuringTraffic(t *testing.T) {
+	oldKey, newKey := []byte("old key"), []byte("new key")
+	messages := 200
+
+	// the writer switches keys (atomically) while the reader accepts either
+	var current atomic.Pointer[[]byte]
+	current.Store(&oldKey)
+	rekeying := KeyProviderFunc(func(context.Context, string) ([]byte, error) {
+		return *current.Load(), nil
+	})
+	rotation := NewKeyRotation(oldKey, newKey, time.Hour)
+
+	a, b := net.Pipe()
+	client := NewClientConn(a, nil, WithKeyProvider(rekeying, "key"))
+	server := NewServerConn(b, nil, WithKeyProvider(rotation, "key"))
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		for i := 0; i < messages; i++ {
+			if i == messages/2 {
+				current.Store(&newKey)
+			}
+			_, err := client.Write([]byte(fmt.Sprintf("message %d", i)))
+			assert.NoError(t, err)
+		}
+	}()
+	go func() {
+		for i := 0; i < messages; i++ {
+			rotation.Stats()
+			server.Config()
+		}
+	}()
+
+	for i := 0; i < messages; i++ {
+		message, _, err := server.NextWithExtensions()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, fmt.Sprintf("message %d", i), string(message))
+	}
+	stats := rotation.Stats()
+	assert.Equal(t, uint64(messages/2), stats.OldKeyFrames)
+	assert.Equal(t, uint64(messages/2), stats.NewKeyFrames)
+	rotation.EndOverlap()
+}
+
+func Test_PacketConnConcurrentClose(t *testing.T) {
+	rawConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	conn := NewPacketConn(rawConn, []byte("mock key"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			if _, err := conn.WriteTo([]byte("hello"), rawConn.LocalAddr()); err != nil {
+				return
+			}
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// the key is destroyed while the writer computes MACs with it
+	assert.NoError(t, conn.Close())
+	wg.Wait()
+}
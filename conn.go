@@ -0,0 +1,300 @@
+package authio
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adrianosela/authio/metrics"
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// direction labels bound into the MACs of every message (as associated
+	// data) by Conns created with NewClientConn and NewServerConn
+	directionClientToServer = "authio client->server"
+	directionServerToClient = "authio server->client"
+)
+
+// Conn is a net.Conn that prepends MACs to every message written
+// and verifies and strips MACs from every message read.
+//
+// Conns created with NewClientConn and NewServerConn also bind the direction
+// of every message into its MAC, such that a peer cannot reflect our own
+// messages back to us. Conns created with the deprecated NewConn (or with
+// WithoutDirectionBinding) are symmetric and have no such protection.
+//
+// Writes are safe for concurrent use with each other and with reads, and
+// every message is written whole. Reads are not safe for concurrent use
+// with each other. Close unblocks pending reads and writes (but waits for
+// pending writes if configured WithCloseNotify).
+type Conn struct {
+	net.Conn // underlying net.Conn to read from and write to
+
+	reader       *VerifyMACReader
+	writer       *AppendMACWriter
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	// serializes messages written by the application and control frames
+	// (e.g. heartbeats) written by the Conn itself
+	writeLock sync.Mutex
+
+	readLimit    *rateLimiter
+	writeLimit   *rateLimiter
+	heartbeat    heartbeatState
+	stats        *connStats
+	keyID        string
+	algorithm    string
+	identity     string // announced to the peer (see WithIdentity)
+	identitySent bool   // guarded by writeLock
+	peerIdentity atomic.Pointer[string]
+	sessionID    []byte // see NewSessionClientConn
+	created      time.Time
+	closeOnce    sync.Once
+	done         chan struct{}
+}
+
+// ensure Conn implements net.Conn at compile-time
+var _ net.Conn = (*Conn)(nil)
+
+// NewConn wraps a net.Conn in a Conn, without direction binding.
+//
+// Deprecated: a peer can reflect the messages written by a Conn created with
+// NewConn back to it. Use NewClientConn or NewServerConn instead, and opt out
+// of direction binding explicitly with WithoutDirectionBinding if needed (e.g.
+// to talk to peers which still use NewConn).
+func NewConn(conn net.Conn, key []byte, opts ...Option) *Conn {
+	return newSymmetricConn(conn, key, opts...)
+}
+
+// NewClientConn wraps the client side of a net.Conn in a Conn which binds
+// the direction of every message into its MAC, such that it only accepts
+// messages written by a Conn created with NewServerConn (with the same key).
+// Use WithoutDirectionBinding for peers created with NewConn.
+func NewClientConn(conn net.Conn, key []byte, opts ...Option) *Conn {
+	return newDirectionalConn(conn, key, directionClientToServer, directionServerToClient, opts...)
+}
+
+// NewServerConn wraps the server side of a net.Conn in a Conn which binds
+// the direction of every message into its MAC, such that it only accepts
+// messages written by a Conn created with NewClientConn (with the same key).
+// Use WithoutDirectionBinding for peers created with NewConn.
+func NewServerConn(conn net.Conn, key []byte, opts ...Option) *Conn {
+	return newDirectionalConn(conn, key, directionServerToClient, directionClientToServer, opts...)
+}
+
+// NewConnWithKeys wraps a net.Conn in a Conn which authenticates the messages
+// it writes with writeKey, and verifies the messages it reads with readKey, e.g.
+// the session keys established by a handshake (see the noise package). Since
+// every direction has its own key, there is no need for direction binding.
+func NewConnWithKeys(conn net.Conn, readKey, writeKey []byte, opts ...Option) *Conn {
+	return newConn(conn, NewVerifyMACReader(conn, readKey, opts...), NewAppendMACWriter(conn, writeKey, opts...), opts...)
+}
+
+func newDirectionalConn(conn net.Conn, key []byte, writeDirection, readDirection string, opts ...Option) *Conn {
+	config := newConfig(opts...)
+	if config.noDirectionBinding {
+		return newSymmetricConn(conn, key, opts...)
+	}
+	return newConn(
+		conn,
+		NewVerifyMACReader(conn, key, append(opts, WithAssociatedData(directionalAAD(readDirection, config.aad)))...),
+		NewAppendMACWriter(conn, key, append(opts, WithAssociatedData(directionalAAD(writeDirection, config.aad)))...),
+		opts...,
+	)
+}
+
+// newSymmetricConn returns a Conn without direction binding
+func newSymmetricConn(conn net.Conn, key []byte, opts ...Option) *Conn {
+	return newConn(conn, NewVerifyMACReader(conn, key, opts...), NewAppendMACWriter(conn, key, opts...), opts...)
+}
+
+func newConn(conn net.Conn, reader *VerifyMACReader, writer *AppendMACWriter, opts ...Option) *Conn {
+	config := newConfig(opts...)
+	c := &Conn{
+		Conn:         conn,
+		reader:       reader,
+		writer:       writer,
+		readTimeout:  config.readTimeout,
+		writeTimeout: config.writeTimeout,
+		readLimit:    newRateLimiter(config.readRateLimit, SystemClock),
+		writeLimit:   newRateLimiter(config.writeRateLimit, SystemClock),
+		stats:        &connStats{},
+		keyID:        config.keyID,
+		algorithm:    config.algorithmName(),
+		identity:     config.identity,
+		created:      config.clock.Now(),
+		done:         make(chan struct{}),
+	}
+	c.reader.metrics = metrics.Multi{c.reader.metrics, c.stats}
+	c.writer.metrics = metrics.Multi{c.writer.metrics, c.stats}
+	if config.failureLimiter != nil {
+		c.reader.metrics = metrics.Multi{c.reader.metrics, &failureLimiterMetrics{limiter: config.failureLimiter, conn: conn}}
+	}
+
+	// pings are answered regardless of whether heartbeats are enabled
+	c.heartbeat.start = c.created
+	c.reader.onControl = c.handleControlFrame
+	if config.heartbeatInterval > 0 {
+		c.startHeartbeat(config.heartbeatInterval, config.heartbeatTimeout)
+	}
+	return c
+}
+
+// directionalAAD returns associated data made of a direction label,
+// a separator (labels never contain zero bytes), and any associated
+// data set with WithAssociatedData
+func directionalAAD(direction string, aad []byte) []byte {
+	return append(append([]byte(direction), 0), aad...)
+}
+
+// Read reads data onto the given buffer (with MACs excluded)
+func (c *Conn) Read(b []byte) (int, error) {
+	if c.readTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	messages := c.reader.messages
+	n, err := c.reader.Read(b)
+	if n > 0 {
+		c.heartbeat.received()
+		if limitErr := c.readLimit.wait(int(c.reader.messages-messages), n, c.done); limitErr != nil && err == nil {
+			err = limitErr
+		}
+	}
+	return n, err
+}
+
+// Write writes the contents of a buffer as a single message (with an included MAC)
+func (c *Conn) Write(b []byte) (int, error) {
+	// wait before taking the lock, such that throttled
+	// writes do not hold up control frames (e.g. heartbeats)
+	if err := c.writeLimit.wait(1, len(b), c.done); err != nil {
+		return 0, err
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.writeTimeout > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	if err := c.writeIdentity(); err != nil {
+		return 0, err
+	}
+	return c.writer.Write(b)
+}
+
+// NextWithExtensions reads exactly one verified message along with its
+// extensions (see VerifyMACReader.NextWithExtensions)
+func (c *Conn) NextWithExtensions() ([]byte, []authenticator.Extension, error) {
+	if c.readTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return nil, nil, err
+		}
+	}
+	message, extensions, err := c.reader.NextWithExtensions()
+	if err == nil {
+		c.heartbeat.received()
+		err = c.readLimit.wait(1, len(message), c.done)
+	}
+	return message, extensions, err
+}
+
+// WriteWithExtensions writes the contents of a buffer as a single message
+// (with an included MAC) with the given extensions, which are covered by
+// the MAC (see AppendMACWriter.WriteWithExtensions)
+func (c *Conn) WriteWithExtensions(b []byte, extensions ...authenticator.Extension) (int, error) {
+	if err := c.writeLimit.wait(1, len(b), c.done); err != nil {
+		return 0, err
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.writeTimeout > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	if err := c.writeIdentity(); err != nil {
+		return 0, err
+	}
+	return c.writer.WriteWithExtensions(b, extensions...)
+}
+
+// WriteBatch writes several messages (each with an included MAC) at once
+// (see AppendMACWriter.WriteBatch), and returns the number of messages
+// written in full
+func (c *Conn) WriteBatch(messages [][]byte) (int, error) {
+	size := 0
+	for _, message := range messages {
+		size += len(message)
+	}
+	if err := c.writeLimit.wait(len(messages), size, c.done); err != nil {
+		return 0, err
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.writeTimeout > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	if err := c.writeIdentity(); err != nil {
+		return 0, err
+	}
+	return c.writer.WriteBatch(messages)
+}
+
+// Close sends a close notification if configured WithCloseNotify,
+// destroys the key material held by the Conn, and closes the
+// underlying net.Conn
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	// a close notification must not be interleaved with a pending write,
+	// otherwise Close must not wait for pending writes (it unblocks them)
+	if c.writer.closeNotify {
+		c.writeLock.Lock()
+		defer c.writeLock.Unlock()
+	}
+
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	writerErr := c.writer.Close()
+	err := c.Conn.Close()
+	// the reader is closed last, such that it does not wait
+	// on pending reads which closing the net.Conn unblocks
+	c.reader.Close()
+	if err != nil {
+		return err
+	}
+	return writerErr
+}
+
+// CloseWrite shuts down the writing side of the Conn: it sends a close
+// notification if configured WithCloseNotify, destroys the key material
+// used for writing, and half-closes the underlying net.Conn if it supports
+// it (e.g. *net.TCPConn, *tls.Conn, or an ssh.Channel, see NewClientStreamConn),
+// such that the peer reads io.EOF while the Conn can still be read from.
+func (c *Conn) CloseWrite() error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	if err := c.writer.Close(); err != nil {
+		return err
+	}
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_ConnDirectionBinding(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name        string
+		newWriter   func(net.Conn) net.Conn
+		newReader   func(net.Conn) net.Conn
+		expectError bool
+	}{
+		{
+			name:      "Client to server",
+			newWriter: func(c net.Conn) net.Conn { return NewClientConn(c, mockKey) },
+			newReader: func(c net.Conn) net.Conn { return NewServerConn(c, mockKey) },
+		},
+		{
+			name:      "Server to client",
+			newWriter: func(c net.Conn) net.Conn { return NewServerConn(c, mockKey) },
+			newReader: func(c net.Conn) net.Conn { return NewClientConn(c, mockKey) },
+		},
+		{
+			name:        "Reflected to client",
+			newWriter:   func(c net.Conn) net.Conn { return NewClientConn(c, mockKey) },
+			newReader:   func(c net.Conn) net.Conn { return NewClientConn(c, mockKey) },
+			expectError: true,
+		},
+		{
+			name:        "Reflected to server",
+			newWriter:   func(c net.Conn) net.Conn { return NewServerConn(c, mockKey) },
+			newReader:   func(c net.Conn) net.Conn { return NewServerConn(c, mockKey) },
+			expectError: true,
+		},
+		{
+			name:        "Symmetric peer",
+			newWriter:   func(c net.Conn) net.Conn { return NewConn(c, mockKey) },
+			newReader:   func(c net.Conn) net.Conn { return NewServerConn(c, mockKey) },
+			expectError: true,
+		},
+		{
+			name:      "Symmetric peer without direction binding",
+			newWriter: func(c net.Conn) net.Conn { return NewConn(c, mockKey) },
+			newReader: func(c net.Conn) net.Conn { return NewServerConn(c, mockKey, WithoutDirectionBinding()) },
+		},
+		{
+			name:      "Stream client to server",
+			newWriter: func(c net.Conn) net.Conn { return NewClientStreamConn(c, mockKey) },
+			newReader: func(c net.Conn) net.Conn { return NewServerStreamConn(c, mockKey) },
+		},
+		{
+			name:        "Reflected to stream client",
+			newWriter:   func(c net.Conn) net.Conn { return NewClientStreamConn(c, mockKey) },
+			newReader:   func(c net.Conn) net.Conn { return NewClientStreamConn(c, mockKey) },
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			writer, reader := test.newWriter(a), test.newReader(b)
+			defer writer.Close()
+			defer reader.Close()
+
+			go writer.Write([]byte("hello"))
+
+			buf := make([]byte, 64)
+			n, err := reader.Read(buf)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, bytes.Equal([]byte("hello"), buf[:n]))
+		})
+	}
+}
+
+func Test_ConnReadTimeout(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	a, b := net.Pipe()
+	defer a.Close()
+	reader := NewConn(b, mockKey, WithReadTimeout(50*time.Millisecond))
+	defer reader.Close()
+
+	// a peer which stalls after the header
+	frame := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(frame, mockKey).Write([]byte("hello"))
+	assert.NoError(t, err)
+	go a.Write(frame.Bytes()[:frame.Len()-len("hello")])
+
+	_, err = reader.Read(make([]byte, 64))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+}
+
+func Test_ConnReadTimeoutMidFrame(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	frame := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(frame, mockKey).Write([]byte("hello"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		stalled []byte // written before the peer stalls
+		retry   bool   // whether reading on succeeds
+	}{
+		{name: "between frames", stalled: nil, retry: true},
+		{name: "after the header", stalled: frame.Bytes()[:frame.Len()-len("hello")], retry: false},
+		{name: "mid body", stalled: frame.Bytes()[:frame.Len()-2], retry: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer a.Close()
+			reader := NewConn(b, mockKey, WithReadTimeout(50*time.Millisecond))
+			defer reader.Close()
+
+			go a.Write(test.stalled)
+			_, err := reader.Read(make([]byte, 64))
+			assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+
+			// the peer resumes with the rest of the frame
+			go a.Write(frame.Bytes()[len(test.stalled):])
+			buf := make([]byte, 64)
+			n, retryErr := reader.Read(buf)
+			if test.retry {
+				assert.NoError(t, retryErr)
+				assert.Equal(t, "hello", string(buf[:n]))
+				return
+			}
+			// the partial frame is lost, so reads keep failing rather
+			// than parsing the rest of the frame as a new one
+			assert.Equal(t, err, retryErr)
+		})
+	}
+}
+
+func Test_ConnHeartbeat(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		assert.NoError(t, err)
+		accepted <- conn
+	}()
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	client := NewClientConn(raw, mockKey, WithHeartbeat(10*time.Millisecond, time.Second))
+	defer client.Close()
+
+	server := NewServerConn(<-accepted, mockKey)
+
+	// the server answers pings while reading, the client processes pongs while reading
+	go io.Copy(io.Discard, server)
+	go io.Copy(io.Discard, client)
+
+	deadline := time.Now().Add(time.Second)
+	for client.RTT() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(t, client.RTT() > 0)
+
+	// once the server stops answering, the client gives up
+	server.Close()
+	unresponsive := NewClientConn(mustPipe(t), mockKey, WithHeartbeat(10*time.Millisecond, 50*time.Millisecond))
+	_, err = unresponsive.Read(make([]byte, 8))
+	assert.Error(t, err)
+}
+
+// mustPipe returns one end of a synchronous in-memory connection whose
+// other end never reads nor writes
+func mustPipe(t *testing.T) net.Conn {
+	a, b := net.Pipe()
+	t.Cleanup(func() { a.Close(); b.Close() })
+	return a
+}
@@ -0,0 +1,89 @@
+package authio
+
+import (
+	"fmt"
+)
+
+// ControlType is the type of a control frame, encoded as the first byte of
+// its payload such that it is covered by the MAC. Frames are either data
+// frames, close notifications (see WithCloseNotify), or control frames, which
+// are told apart by their headers (see authenticator.ControlFramer); control
+// frames are then told apart by their ControlType.
+type ControlType byte
+
+// ControlTypes of the built-in control frames. Types from ControlTypeCustom on
+// are free for applications to use (see WithControlHandler).
+const (
+	// ControlTypePing and ControlTypePong are heartbeats (see WithHeartbeat)
+	ControlTypePing ControlType = 1
+	ControlTypePong ControlType = 2
+	// ControlTypeIdentity announces the identity of a peer (see WithIdentity)
+	ControlTypeIdentity ControlType = 3
+	// ControlTypeRekey is reserved for switching to a new key
+	ControlTypeRekey ControlType = 4
+
+	// ControlTypeCustom is the first ControlType free for applications to use
+	ControlTypeCustom ControlType = 0x80
+)
+
+// WithControlHandler registers a handler for (authenticated) control frames of
+// the given custom ControlType, which VerifyMACReaders (and Conns) call with
+// the payload of every such frame (without the type byte) as they read past
+// it, before returning the next message. Control frames of other types are
+// skipped over. The ControlType must be at least ControlTypeCustom, otherwise
+// every read and write fails. Both peers must use authio versions which
+// support control frames.
+func WithControlHandler(controlType ControlType, handler func(payload []byte)) Option {
+	return func(c *config) {
+		if c.controlHandlers == nil {
+			c.controlHandlers = map[ControlType]func(payload []byte){}
+		}
+		c.controlHandlers[controlType] = handler
+	}
+}
+
+// checkControlHandlers checks that control handlers are only registered for custom ControlTypes
+func (c *config) checkControlHandlers() error {
+	for controlType := range c.controlHandlers {
+		if controlType < ControlTypeCustom {
+			return fmt.Errorf("invalid control type %d, must be at least %d", controlType, ControlTypeCustom)
+		}
+	}
+	return nil
+}
+
+// WriteControl writes an (authenticated) control frame of the given custom
+// ControlType, which readers pass to the handler registered for it with
+// WithControlHandler (if any) and otherwise skip over. The ControlType
+// must be at least ControlTypeCustom.
+func (w *AppendMACWriter) WriteControl(controlType ControlType, payload []byte) error {
+	if controlType < ControlTypeCustom {
+		return fmt.Errorf("invalid control type %d, must be at least %d", controlType, ControlTypeCustom)
+	}
+	return w.writeControlFrame(controlPayload(controlType, payload))
+}
+
+// WriteControl writes an (authenticated) control frame of the given custom
+// ControlType to the peer (see AppendMACWriter.WriteControl), within the
+// write timeout of the Conn
+func (c *Conn) WriteControl(controlType ControlType, payload []byte) error {
+	if controlType < ControlTypeCustom {
+		return fmt.Errorf("invalid control type %d, must be at least %d", controlType, ControlTypeCustom)
+	}
+	return c.writeControlFrame(controlPayload(controlType, payload), 0)
+}
+
+// handleControlFrame passes the payload of a control frame to the
+// handler registered for its ControlType, if any
+func (r *VerifyMACReader) handleControlFrame(payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	if handler, ok := r.controlHandlers[ControlType(payload[0])]; ok {
+		handler(payload[1:])
+	}
+}
+
+func controlPayload(controlType ControlType, payload []byte) []byte {
+	return append([]byte{byte(controlType)}, payload...)
+}
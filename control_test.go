@@ -0,0 +1,74 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_ControlHandlers(t *testing.T) {
+	mockKey := []byte("mock key")
+	custom := ControlTypeCustom + 1
+
+	buf := &bytes.Buffer{}
+	w := NewAppendMACWriter(buf, mockKey)
+	assert.NoError(t, w.WriteControl(ControlTypeCustom, []byte("first")))
+	_, err := w.Write([]byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.WriteControl(custom, []byte("second")))
+	assert.NoError(t, w.WriteControl(ControlTypeCustom, nil))
+	_, err = w.Write([]byte("world"))
+	assert.NoError(t, err)
+
+	// built-in control types cannot be written by applications
+	assert.Error(t, w.WriteControl(ControlTypeRekey, nil))
+
+	// control frames are passed to their handlers in order, before the next
+	// message, and those of types without handlers are skipped over
+	received := []string{}
+	r := NewVerifyMACReader(buf, mockKey, WithControlHandler(ControlTypeCustom, func(payload []byte) {
+		received = append(received, string(payload))
+	}))
+	msg, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(msg))
+	assert.Equal(t, []string{"first"}, received)
+	msg, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(msg))
+	assert.Equal(t, []string{"first", ""}, received)
+	_, err = r.Next()
+	assert.True(t, errors.Is(err, io.EOF))
+
+	// handlers cannot be registered for built-in control types
+	_, err = NewVerifyMACReader(&bytes.Buffer{}, mockKey, WithControlHandler(ControlTypePing, func([]byte) {})).Next()
+	assert.Error(t, err)
+}
+
+func Test_ConnControlHandlers(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	received := make(chan string, 1)
+	a, b := net.Pipe()
+	client := NewClientConn(a, mockKey)
+	server := NewServerConn(b, mockKey, WithControlHandler(ControlTypeCustom, func(payload []byte) {
+		received <- string(payload)
+	}))
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		assert.NoError(t, client.WriteControl(ControlTypeCustom, []byte("control")))
+		_, err := client.Write([]byte("hello"))
+		assert.NoError(t, err)
+	}()
+	buf := make([]byte, 16)
+	n, err := server.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+	assert.Equal(t, "control", <-received)
+}
@@ -0,0 +1,76 @@
+package authio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// copyBufferSize is the size of the messages written by CopySigned
+// (unless the max message size is smaller)
+const copyBufferSize = 32 * 1024
+
+// CopyStats is a summary of the frames and (message) bytes processed by a copy
+type CopyStats struct {
+	Frames int64
+	Bytes  int64
+}
+
+// Copy verifies the authenticated messages read from src and writes them
+// (with MACs excluded) to dst until the end of src, which must be at a
+// message boundary. Note that messages read before an invalid one have
+// already been written to dst when Copy returns an error.
+func Copy(dst io.Writer, src io.Reader, key []byte, opts ...Option) (CopyStats, error) {
+	reader := NewVerifyMACReader(src, key, opts...)
+	defer reader.Close()
+
+	stats := CopyStats{}
+	for {
+		message, err := reader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return stats, nil
+			}
+			return stats, fmt.Errorf("failed to verify message %d: %w", stats.Frames, err)
+		}
+		if _, err := dst.Write(message); err != nil {
+			return stats, fmt.Errorf("failed to write message %d: %w", stats.Frames, err)
+		}
+		stats.Frames++
+		stats.Bytes += int64(len(message))
+	}
+}
+
+// CopySigned reads src until its end and writes it to dst as authenticated
+// messages (with included MACs), sending a close notification at the end if
+// configured WithCloseNotify
+func CopySigned(dst io.Writer, src io.Reader, key []byte, opts ...Option) (CopyStats, error) {
+	writer := NewAppendMACWriter(dst, key, opts...)
+
+	size := copyBufferSize
+	if writer.maxMessageLen > 0 && writer.maxMessageLen < size {
+		size = writer.maxMessageLen
+	}
+	buf := make([]byte, size)
+
+	stats := CopyStats{}
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, err := writer.writeMessage(buf[:n]); err != nil {
+				destroyKey(writer.authenticator)
+				return stats, fmt.Errorf("failed to write message %d: %w", stats.Frames, err)
+			}
+			stats.Frames++
+			stats.Bytes += int64(n)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return stats, writer.Close()
+			}
+			// the stream is incomplete, so it must not end with a close notification
+			destroyKey(writer.authenticator)
+			return stats, fmt.Errorf("failed to read: %w", err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Copy(t *testing.T) {
+	mockKey := []byte("mock key")
+	input := strings.Repeat("some data to authenticate ", 100)
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		tamper       func([]byte) []byte
+		expectFrames int64
+		expectError  error
+	}{
+		{
+			name:         "Happy path",
+			expectFrames: 1,
+		},
+		{
+			name:         "Several messages",
+			opts:         []Option{WithMaxMessageSize(1000)},
+			expectFrames: 3,
+		},
+		{
+			name:         "Close notification",
+			opts:         []Option{WithCloseNotify()},
+			expectFrames: 1,
+		},
+		{
+			name:        "Truncated stream",
+			opts:        []Option{WithCloseNotify()},
+			tamper:      func(b []byte) []byte { return b[:len(b)-authenticator.HeaderLength(sha256.New)] },
+			expectError: ErrTruncatedStream,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signed := &bytes.Buffer{}
+			stats, err := CopySigned(signed, strings.NewReader(input), mockKey, test.opts...)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(len(input)), stats.Bytes)
+
+			data := signed.Bytes()
+			if test.tamper != nil {
+				data = test.tamper(data)
+			}
+
+			verified := &bytes.Buffer{}
+			stats, err = Copy(verified, bytes.NewReader(data), mockKey, test.opts...)
+			if test.expectError != nil {
+				assert.True(t, errors.Is(err, test.expectError))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectFrames, stats.Frames)
+			assert.Equal(t, int64(len(input)), stats.Bytes)
+			assert.Equal(t, input, verified.String())
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package authio
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// MACFileExtension is the extension of detached MAC files
+	// written by SignFile, e.g. "backup.tar" -> "backup.tar.mac"
+	MACFileExtension = ".mac"
+)
+
+// DetachedSignReader is a reader that computes a MAC over
+// all data read through it, without modifying the data
+type DetachedSignReader struct {
+	reader io.Reader // underlying io.Reader to read from
+	hash   hash.Hash
+}
+
+// ensure DetachedSignReader implements io.Reader at compile-time
+var _ io.Reader = (*DetachedSignReader)(nil)
+
+// NewDetachedSignReader returns a new DetachedSignReader
+func NewDetachedSignReader(reader io.Reader, key []byte) *DetachedSignReader {
+	return &DetachedSignReader{
+		reader: reader,
+		hash:   newDetachedHMAC(key),
+	}
+}
+
+// Read reads data onto the given buffer
+func (r *DetachedSignReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	// note: hash.Write() never returns an error as per godoc (https://pkg.go.dev/hash#Hash)
+	r.hash.Write(b[:n])
+	return n, err
+}
+
+// MAC returns the (base64 encoded) MAC over all data read so far
+func (r *DetachedSignReader) MAC() string {
+	return base64.StdEncoding.EncodeToString(r.hash.Sum(nil))
+}
+
+// DetachedVerifyReader is a reader that computes a MAC over all data
+// read through it and compares it against an expected MAC upon reaching
+// the end of the underlying reader. Note that data is returned before
+// it is verified, callers must read until io.EOF and discard all data
+// read if authenticator.ErrMACMismatch is returned instead.
+type DetachedVerifyReader struct {
+	reader   io.Reader // underlying io.Reader to read from
+	hash     hash.Hash
+	expected string
+}
+
+// ensure DetachedVerifyReader implements io.Reader at compile-time
+var _ io.Reader = (*DetachedVerifyReader)(nil)
+
+// NewDetachedVerifyReader returns a new DetachedVerifyReader
+func NewDetachedVerifyReader(reader io.Reader, mac string, key []byte) *DetachedVerifyReader {
+	return &DetachedVerifyReader{
+		reader:   reader,
+		hash:     newDetachedHMAC(key),
+		expected: mac,
+	}
+}
+
+// Read reads data onto the given buffer
+func (r *DetachedVerifyReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	// note: hash.Write() never returns an error as per godoc (https://pkg.go.dev/hash#Hash)
+	r.hash.Write(b[:n])
+
+	if errors.Is(err, io.EOF) {
+		computed := base64.StdEncoding.EncodeToString(r.hash.Sum(nil))
+		if !hmac.Equal([]byte(r.expected), []byte(computed)) {
+			return n, authenticator.ErrMACMismatch
+		}
+	}
+	return n, err
+}
+
+// SignFile computes the MAC of the file at the given path and writes it
+// to a detached MAC file next to it (path + MACFileExtension). The file
+// is streamed, so it is never loaded in memory in full. The path of the
+// MAC file is returned.
+func SignFile(path string, key []byte) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader := NewDetachedSignReader(file, key)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	macPath := path + MACFileExtension
+	if err := os.WriteFile(macPath, []byte(reader.MAC()+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write MAC file: %w", err)
+	}
+	return macPath, nil
+}
+
+// VerifyFile verifies the file at the given path against the detached MAC
+// file at macPath. The file is streamed, so it is never loaded in memory
+// in full. authenticator.ErrMACMismatch is returned if the file does not match the MAC.
+func VerifyFile(path, macPath string, key []byte) error {
+	mac, err := os.ReadFile(macPath)
+	if err != nil {
+		return fmt.Errorf("failed to read MAC file: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader := NewDetachedVerifyReader(file, strings.TrimSpace(string(mac)), key)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		if errors.Is(err, authenticator.ErrMACMismatch) {
+			return err
+		}
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	return nil
+}
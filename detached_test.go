@@ -0,0 +1,95 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_SignFileVerifyFile(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name        string
+		verifyKey   []byte
+		tamper      func(path string) error
+		expectedErr error
+	}{
+		{
+			name:        "Untouched file",
+			verifyKey:   mockKey,
+			expectedErr: nil,
+		},
+		{
+			name:        "Wrong key",
+			verifyKey:   []byte("other key"),
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:      "Tampered file",
+			verifyKey: mockKey,
+			tamper: func(path string) error {
+				return os.WriteFile(path, []byte("tampered data"), 0644)
+			},
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:      "Truncated file",
+			verifyKey: mockKey,
+			tamper: func(path string) error {
+				return os.Truncate(path, 4)
+			},
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			assert.NoError(t, os.WriteFile(path, []byte("mock data"), 0644))
+
+			macPath, err := SignFile(path, mockKey)
+			assert.NoError(t, err)
+			assert.Equal(t, path+MACFileExtension, macPath)
+
+			if test.tamper != nil {
+				assert.NoError(t, test.tamper(path))
+			}
+
+			err = VerifyFile(path, macPath, test.verifyKey)
+			assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+		})
+	}
+}
+
+func Test_DetachedMACIsNotFrameMAC(t *testing.T) {
+	mockKey := []byte("mock key")
+	mockMessage := []byte("mock data")
+
+	header, err := authenticator.NewDefaultMessageAuthenticator(sha256.New, mockKey).GetMessageAuthenticationHeader(mockMessage)
+	assert.NoError(t, err)
+	frameMAC, length := header[:len(header)-8], header[len(header)-8:]
+
+	// the bytes covered by the MAC of the frame
+	covered := append(append([]byte{}, length...), mockMessage...)
+
+	// a detached MAC over them is not the MAC of the frame...
+	signer := NewDetachedSignReader(bytes.NewReader(covered), mockKey)
+	_, err = io.Copy(io.Discard, signer)
+	assert.NoError(t, err)
+	assert.NotEqual(t, string(frameMAC), signer.MAC())
+
+	forged := append([]byte(signer.MAC()), covered...)
+	_, err = authenticator.NewDefaultMessageAuthenticator(sha256.New, mockKey).ReadNext(bytes.NewReader(forged))
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch), "expected %v, got %v", authenticator.ErrMACMismatch, err)
+
+	// ...nor is the MAC of the frame a detached MAC over them
+	_, err = io.Copy(io.Discard, NewDetachedVerifyReader(bytes.NewReader(covered), string(frameMAC), mockKey))
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch), "expected %v, got %v", authenticator.ErrMACMismatch, err)
+}
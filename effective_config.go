@@ -0,0 +1,121 @@
+package authio
+
+import (
+	"fmt"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// HeaderFormat is the format of frame headers
+type HeaderFormat string
+
+// HeaderFormats of EffectiveConfigs
+const (
+	// HeaderFormatDefault is the default frame format
+	HeaderFormatDefault HeaderFormat = "default"
+	// HeaderFormatCBOR is the frame format with CBOR headers (see WithCBORHeaders)
+	HeaderFormatCBOR HeaderFormat = "cbor"
+	// HeaderFormatDetect is either frame format, detected for every frame read
+	// (see WithFormatDetection), and that of frames written (see WithCBORHeaders)
+	HeaderFormatDetect HeaderFormat = "detect"
+	// HeaderFormatCustom is the frame format of a custom MessageAuthenticator
+	// (see WithMessageAuthenticator and WithAlgorithm) or FrameCodec (see
+	// WithFrameCodec)
+	HeaderFormatCustom HeaderFormat = "custom"
+)
+
+// EffectiveConfig is the effective configuration of a reader or writer (see
+// VerifyMACReader.Config and AppendMACWriter.Config) after all options were
+// applied, mainly for logging at connection setup and for debugging peers
+// whose configurations do not match.
+type EffectiveConfig struct {
+	// Algorithm is the name of the algorithm MACs are computed with (see AuthInfo)
+	Algorithm string
+	// HeaderFormat is the format of frame headers
+	HeaderFormat HeaderFormat
+	// HeaderLength is the length of frame headers (of the format written)
+	HeaderLength int
+	// MaxMessageSize is the max size of messages, zero means no limit
+	MaxMessageSize int
+	// KeyID is the ID of the key (see WithKeyProvider and WithCBORHeaders)
+	KeyID string
+	// Sequence is the sequence number of the last frame read (by readers) or
+	// written (by writers), if HasSequence, i.e. if the frame format has
+	// sequence numbers (see WithCBORHeaders) and any frame was read or written
+	Sequence    uint64
+	HasSequence bool
+}
+
+// String returns the EffectiveConfig as key=value pairs, e.g. for logs
+func (c EffectiveConfig) String() string {
+	s := fmt.Sprintf("algorithm=%s header_format=%s header_length=%d max_message_size=%d key_id=%q", c.Algorithm, c.HeaderFormat, c.HeaderLength, c.MaxMessageSize, c.KeyID)
+	if c.HasSequence {
+		s += fmt.Sprintf(" sequence=%d", c.Sequence)
+	}
+	return s
+}
+
+// ConnConfig is the effective configuration of either direction of a Conn
+type ConnConfig struct {
+	Read  EffectiveConfig
+	Write EffectiveConfig
+}
+
+// effectiveConfig returns the EffectiveConfig of the configuration, for
+// a reader or writer with the given MessageAuthenticator (without sequence
+// numbers, which are tracked by the MessageAuthenticator)
+func (c *config) effectiveConfig(a authenticator.MessageAuthenticator) EffectiveConfig {
+	effective := EffectiveConfig{
+		Algorithm:      c.algorithmName(),
+		HeaderFormat:   HeaderFormatDefault,
+		HeaderLength:   a.GetMessageAuthenticationHeaderLength(),
+		MaxMessageSize: c.maxMessageSize,
+		KeyID:          c.keyID,
+	}
+	switch {
+	case c.formatDetection:
+		effective.HeaderFormat = HeaderFormatDetect
+	case c.authenticator != nil, c.algorithm != "", c.frameCodec != nil:
+		effective.HeaderFormat = HeaderFormatCustom
+	case c.cborHeaders:
+		effective.HeaderFormat = HeaderFormatCBOR
+	}
+	if c.cborHeaders {
+		effective.KeyID = c.cborKeyID
+	}
+	return effective
+}
+
+// Config returns the effective configuration of the VerifyMACReader. It is
+// safe to call concurrently with reads.
+func (r *VerifyMACReader) Config() EffectiveConfig {
+	config := r.config
+	if tracker, ok := r.authenticator.(authenticator.SequenceTracker); ok {
+		config.Sequence, config.HasSequence = tracker.ReadSequence()
+	}
+	return config
+}
+
+// Config returns the effective configuration of the AppendMACWriter. It is
+// safe to call concurrently with writes.
+func (w *AppendMACWriter) Config() EffectiveConfig {
+	config := w.config
+	if sequencer, ok := w.authenticator.(writeSequencer); ok {
+		config.Sequence, config.HasSequence = sequencer.WriteSequence()
+	}
+	return config
+}
+
+// Config returns the effective configuration of either direction of
+// the Conn. It is safe to call concurrently with reads and writes.
+func (c *Conn) Config() ConnConfig {
+	return ConnConfig{Read: c.reader.Config(), Write: c.writer.Config()}
+}
+
+// writeSequencer is a MessageAuthenticator which numbers the frames it writes
+// (e.g. authenticator.CBORMessageAuthenticator)
+type writeSequencer interface {
+	// WriteSequence returns the sequence number of the last frame
+	// written, and whether any frame was written at all
+	WriteSequence() (seq uint64, ok bool)
+}
@@ -0,0 +1,128 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"net"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_EffectiveConfig(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected EffectiveConfig
+	}{
+		{
+			name: "default",
+			expected: EffectiveConfig{
+				Algorithm:      "HMAC-SHA-256",
+				HeaderFormat:   HeaderFormatDefault,
+				HeaderLength:   authenticator.HeaderLengthWithEncoding(32, authenticator.StdBase64),
+				MaxMessageSize: DefaultMaxMessageSize,
+			},
+		},
+		{
+			name: "hash function, tag size, and max message size",
+			opts: []Option{WithHashFn(sha512.New), WithTagSize(32), WithMACEncoding(authenticator.Hex), WithMaxMessageSize(1024)},
+			expected: EffectiveConfig{
+				Algorithm:      "HMAC-SHA-512",
+				HeaderFormat:   HeaderFormatDefault,
+				HeaderLength:   authenticator.HeaderLengthWithEncoding(32, authenticator.Hex),
+				MaxMessageSize: 1024,
+			},
+		},
+		{
+			name: "CBOR headers",
+			opts: []Option{WithCBORHeaders("key-1")},
+			expected: EffectiveConfig{
+				Algorithm:      "HMAC-SHA-256",
+				HeaderFormat:   HeaderFormatCBOR,
+				HeaderLength:   authenticator.NewCBORMessageAuthenticator(sha256.New, nil).WithKeyID("key-1").GetMessageAuthenticationHeaderLength(),
+				MaxMessageSize: DefaultMaxMessageSize,
+				KeyID:          "key-1",
+			},
+		},
+		{
+			name: "format detection",
+			opts: []Option{WithFormatDetection()},
+			expected: EffectiveConfig{
+				Algorithm:      "HMAC-SHA-256",
+				HeaderFormat:   HeaderFormatDetect,
+				HeaderLength:   authenticator.HeaderLengthWithEncoding(32, authenticator.StdBase64),
+				MaxMessageSize: DefaultMaxMessageSize,
+			},
+		},
+		{
+			name: "registered algorithm",
+			opts: []Option{WithAlgorithm("crc32c"), WithPolicy(nil)},
+			expected: EffectiveConfig{
+				Algorithm:      "crc32c",
+				HeaderFormat:   HeaderFormatCustom,
+				HeaderLength:   authenticator.HeaderLengthWithEncoding(4, authenticator.StdBase64),
+				MaxMessageSize: DefaultMaxMessageSize,
+			},
+		},
+		{
+			name: "key provider",
+			opts: []Option{WithKeyProvider(NewKeyRotation(mockKey, mockKey, 0), "key-2")},
+			expected: EffectiveConfig{
+				Algorithm:      "HMAC-SHA-256",
+				HeaderFormat:   HeaderFormatDefault,
+				HeaderLength:   authenticator.HeaderLengthWithEncoding(32, authenticator.StdBase64),
+				MaxMessageSize: DefaultMaxMessageSize,
+				KeyID:          "key-2",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, NewAppendMACWriter(&bytes.Buffer{}, mockKey, test.opts...).Config())
+			assert.Equal(t, test.expected, NewVerifyMACReader(&bytes.Buffer{}, mockKey, test.opts...).Config())
+		})
+	}
+}
+
+func Test_EffectiveConfigSequence(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	buf := &bytes.Buffer{}
+	w := NewAppendMACWriter(buf, mockKey, WithCBORHeaders("key-1"))
+	r := NewVerifyMACReader(buf, mockKey, WithCBORHeaders(""))
+	assert.False(t, w.Config().HasSequence)
+	assert.False(t, r.Config().HasSequence)
+
+	for i := 0; i < 3; i++ {
+		_, err := w.Write([]byte("hello"))
+		assert.NoError(t, err)
+	}
+	assert.True(t, w.Config().HasSequence)
+	assert.Equal(t, uint64(2), w.Config().Sequence)
+	assert.Contains(t, w.Config().String(), "header_format=cbor")
+	assert.Contains(t, w.Config().String(), "sequence=2")
+
+	_, err := r.Next()
+	assert.NoError(t, err)
+	assert.True(t, r.Config().HasSequence)
+	assert.Equal(t, uint64(0), r.Config().Sequence)
+}
+
+func Test_ConnConfig(t *testing.T) {
+	a, b := net.Pipe()
+	client := NewClientConn(a, []byte("mock key"), WithMaxMessageSize(1024))
+	server := NewServerConn(b, []byte("mock key"))
+	defer client.Close()
+	defer server.Close()
+
+	// mismatched peers can be told apart by their configurations
+	assert.Equal(t, 1024, client.Config().Write.MaxMessageSize)
+	assert.Equal(t, DefaultMaxMessageSize, server.Config().Read.MaxMessageSize)
+	assert.Equal(t, client.Config().Write.HeaderLength, server.Config().Read.HeaderLength)
+}
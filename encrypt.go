@@ -0,0 +1,251 @@
+package authio
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Cipher encrypts and decrypts whole messages, e.g. for an EncryptWriter and
+// a DecryptReader. Ciphers need not authenticate ciphertexts themselves: the
+// MAC of the frame they are written in covers them. Note that the key of a
+// Cipher must be independent of the MAC key.
+type Cipher interface {
+	// Seal returns the ciphertext of the given plaintext
+	Seal(plaintext []byte) ([]byte, error)
+	// Open returns the plaintext of the given ciphertext
+	Open(ciphertext []byte) ([]byte, error)
+	// Overhead returns how much longer ciphertexts are than their plaintexts
+	Overhead() int
+}
+
+// aeadCipher is a Cipher of a cipher.AEAD with random nonces
+type aeadCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAEADCipher returns a Cipher which seals messages with the given
+// cipher.AEAD, prepending a random nonce to every ciphertext. Random nonces
+// are only safe for AEADs with large enough nonces (e.g. XChaCha20-Poly1305)
+// or for fewer than 2^32 messages per key (e.g. AES-GCM).
+func NewAEADCipher(aead cipher.AEAD) Cipher {
+	return &aeadCipher{aead: aead}
+}
+
+// NewXChaCha20Poly1305Cipher returns a Cipher which seals messages with
+// XChaCha20-Poly1305 (whose nonces are large enough to be random) under
+// the given (32 byte) key
+func NewXChaCha20Poly1305Cipher(key []byte) (Cipher, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create XChaCha20-Poly1305 cipher: %w", err)
+	}
+	return NewAEADCipher(aead), nil
+}
+
+// Seal returns a random nonce followed by the ciphertext of the given plaintext
+func (c *aeadCipher) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.Overhead()+len(plaintext))
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open returns the plaintext of the given nonce and ciphertext
+func (c *aeadCipher) Open(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce := ciphertext[:c.aead.NonceSize()]
+	return c.aead.Open(nil, nonce, ciphertext[len(nonce):], nil)
+}
+
+// Overhead returns the size of nonces and tags
+func (c *aeadCipher) Overhead() int {
+	return c.aead.NonceSize() + c.aead.Overhead()
+}
+
+// ctrCipher is a Cipher of a cipher.Block in CTR mode with random IVs
+type ctrCipher struct {
+	block cipher.Block
+}
+
+// NewCTRCipher returns a Cipher which encrypts messages with the given
+// cipher.Block (e.g. AES) in CTR mode, prepending a random IV to every
+// ciphertext. CTR mode is malleable: it is only safe along with the MAC
+// of the frames it is written in, which is what EncryptWriter is for.
+func NewCTRCipher(block cipher.Block) Cipher {
+	return &ctrCipher{block: block}
+}
+
+// Seal returns a random IV followed by the ciphertext of the given plaintext
+func (c *ctrCipher) Seal(plaintext []byte) ([]byte, error) {
+	ciphertext := make([]byte, c.Overhead()+len(plaintext))
+	iv := ciphertext[:c.Overhead()]
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, fmt.Errorf("failed to generate IV: %w", err)
+	}
+	cipher.NewCTR(c.block, iv).XORKeyStream(ciphertext[len(iv):], plaintext)
+	return ciphertext, nil
+}
+
+// Open returns the plaintext of the given IV and ciphertext
+func (c *ctrCipher) Open(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	iv := ciphertext[:c.Overhead()]
+	plaintext := make([]byte, len(ciphertext)-len(iv))
+	cipher.NewCTR(c.block, iv).XORKeyStream(plaintext, ciphertext[len(iv):])
+	return plaintext, nil
+}
+
+// Overhead returns the size of IVs
+func (c *ctrCipher) Overhead() int {
+	return c.block.BlockSize()
+}
+
+// EncryptWriter is a writer which encrypts every message with a Cipher and
+// then authenticates the ciphertext (encrypt-then-MAC), such that readers
+// verify ciphertexts before decrypting them (see DecryptReader)
+type EncryptWriter struct {
+	writer *AppendMACWriter
+	cipher Cipher
+	err    error // why the EncryptWriter is unusable, if it is
+}
+
+// ensure EncryptWriter implements io.WriteCloser at compile-time
+var _ io.WriteCloser = (*EncryptWriter)(nil)
+
+// NewEncryptWriter wraps an io.Writer in an EncryptWriter which encrypts
+// messages with the given Cipher, and authenticates their ciphertexts with
+// the given MAC key. Options configure the MAC framing as for an
+// AppendMACWriter, except for records and padding, which would apply to
+// ciphertexts rather than messages (and so are rejected).
+func NewEncryptWriter(writer io.Writer, cipher Cipher, macKey []byte, opts ...Option) *EncryptWriter {
+	w := &EncryptWriter{
+		writer: NewAppendMACWriter(writer, macKey, opts...),
+		cipher: cipher,
+	}
+	if w.writer.delimited || w.writer.recordSize > 0 || w.writer.paddingBlock > 0 {
+		w.err = errors.New("records and padding are not supported with encryption")
+	}
+	return w
+}
+
+// Write encrypts the contents of a buffer and writes the ciphertext as a
+// single message (with an included MAC). If a max message size is set,
+// larger buffers are encrypted and written as several messages.
+func (w *EncryptWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	size := len(b)
+	if w.writer.maxMessageLen > 0 {
+		size = w.writer.maxMessageLen - w.cipher.Overhead()
+		if size <= 0 {
+			return 0, fmt.Errorf("max message size %d too small for a cipher overhead of %d", w.writer.maxMessageLen, w.cipher.Overhead())
+		}
+	}
+
+	written := 0
+	for first := true; first || written < len(b); first = false {
+		chunk := b[written:]
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		ciphertext, err := w.cipher.Seal(chunk)
+		if err != nil {
+			return written, fmt.Errorf("failed to encrypt message: %w", err)
+		}
+		if _, err := w.writer.writeMessage(ciphertext); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+// Close closes the underlying AppendMACWriter (see AppendMACWriter.Close).
+// It does not close the underlying writer.
+func (w *EncryptWriter) Close() error {
+	return w.writer.Close()
+}
+
+// DecryptReader is a reader which verifies the MAC of every message before
+// decrypting it with a Cipher (see EncryptWriter), such that ciphertexts
+// which were tampered with never reach the Cipher
+type DecryptReader struct {
+	reader  *VerifyMACReader
+	cipher  Cipher
+	pending []byte // rest of the last message, not yet read
+}
+
+// ensure DecryptReader implements io.ReadCloser and MessageReader at compile-time
+var (
+	_ io.ReadCloser = (*DecryptReader)(nil)
+	_ MessageReader = (*DecryptReader)(nil)
+)
+
+// NewDecryptReader wraps an io.Reader in a DecryptReader which verifies
+// messages with the given MAC key and then decrypts them with the given
+// Cipher. Options configure the MAC framing as for a VerifyMACReader.
+func NewDecryptReader(reader io.Reader, cipher Cipher, macKey []byte, opts ...Option) *DecryptReader {
+	return &DecryptReader{
+		reader: NewVerifyMACReader(reader, macKey, opts...),
+		cipher: cipher,
+	}
+}
+
+// Read reads decrypted data onto the given buffer
+func (r *DecryptReader) Read(b []byte) (int, error) {
+	if len(r.pending) == 0 {
+		message, err := r.Next()
+		if err != nil {
+			return 0, err
+		}
+		r.pending = message
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Next reads, verifies, and decrypts exactly one message. If the previous
+// message was only partially consumed through Read, its remaining bytes are
+// returned instead.
+func (r *DecryptReader) Next() ([]byte, error) {
+	message, _, err := r.NextWithExtensions()
+	return message, err
+}
+
+// NextWithExtensions is like Next, but also returns the extensions (see
+// authenticator.Extension) of the message, which are covered by its MAC
+func (r *DecryptReader) NextWithExtensions() ([]byte, []authenticator.Extension, error) {
+	if len(r.pending) > 0 {
+		message := r.pending
+		r.pending = nil
+		return message, nil, nil
+	}
+	ciphertext, extensions, err := r.reader.NextWithExtensions()
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := r.cipher.Open(ciphertext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt verified message: %w", err)
+	}
+	return plaintext, extensions, nil
+}
+
+// Close closes the underlying VerifyMACReader (see VerifyMACReader.Close).
+// It does not close the underlying reader.
+func (r *DecryptReader) Close() error {
+	return r.reader.Close()
+}
@@ -0,0 +1,119 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+// recordingCipher is a Cipher which records the ciphertexts it opens
+type recordingCipher struct {
+	Cipher
+	opened [][]byte
+}
+
+func (c *recordingCipher) Open(ciphertext []byte) ([]byte, error) {
+	c.opened = append(c.opened, ciphertext)
+	return c.Cipher.Open(ciphertext)
+}
+
+func Test_EncryptThenMAC(t *testing.T) {
+	macKey := []byte("mock MAC key")
+	encryptionKey := bytes.Repeat([]byte{7}, 32)
+
+	block, err := aes.NewCipher(encryptionKey)
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	xchacha, err := NewXChaCha20Poly1305Cipher(encryptionKey)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		cipher Cipher
+		opts   []Option
+	}{
+		{name: "XChaCha20-Poly1305", cipher: xchacha},
+		{name: "AES-GCM", cipher: NewAEADCipher(gcm)},
+		{name: "AES-CTR", cipher: NewCTRCipher(block)},
+		{name: "AES-CTR with max message size", cipher: NewCTRCipher(block), opts: []Option{WithMaxMessageSize(aes.BlockSize + 4)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := []string{"first message", "", "second message"}
+
+			signed := &bytes.Buffer{}
+			w := NewEncryptWriter(signed, test.cipher, macKey, test.opts...)
+			for _, message := range messages {
+				n, err := w.Write([]byte(message))
+				assert.NoError(t, err)
+				assert.Equal(t, len(message), n)
+			}
+			assert.NoError(t, w.Close())
+			stream := append([]byte(nil), signed.Bytes()...)
+
+			// the MAC covers the ciphertext: frames verify without the
+			// cipher, and their messages are not the plaintext
+			ciphertexts := [][]byte{}
+			verifier := NewVerifyMACReader(bytes.NewReader(stream), macKey, test.opts...)
+			for {
+				ciphertext, err := verifier.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				assert.NoError(t, err)
+				assert.False(t, bytes.Contains(ciphertext, []byte("message")))
+				ciphertexts = append(ciphertexts, ciphertext)
+			}
+
+			// verified ciphertexts are decrypted, after verification
+			recording := &recordingCipher{Cipher: test.cipher}
+			plaintext, err := io.ReadAll(NewDecryptReader(bytes.NewReader(stream), recording, macKey, test.opts...))
+			assert.NoError(t, err)
+			assert.Equal(t, "first messagesecond message", string(plaintext))
+			assert.Equal(t, ciphertexts, recording.opened)
+
+			// ciphertexts which were tampered with never reach the cipher
+			stream[len(stream)-1] ^= 1
+			recording = &recordingCipher{Cipher: test.cipher}
+			_, err = io.ReadAll(NewDecryptReader(bytes.NewReader(stream), recording, macKey, test.opts...))
+			assert.True(t, errors.Is(err, authenticator.ErrMACMismatch), "expected %v, got %v", authenticator.ErrMACMismatch, err)
+			assert.Equal(t, len(ciphertexts)-1, len(recording.opened))
+		})
+	}
+}
+
+func Test_EncryptWriterMisuse(t *testing.T) {
+	macKey := []byte("mock MAC key")
+	block, err := aes.NewCipher(bytes.Repeat([]byte{7}, 32))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Records", opts: []Option{WithDelimiter('\n')}},
+		{name: "Fixed size records", opts: []Option{WithFixedRecords(64)}},
+		{name: "Padding", opts: []Option{WithPadding(16)}},
+		{name: "Max message size smaller than the overhead", opts: []Option{WithMaxMessageSize(aes.BlockSize)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewEncryptWriter(&bytes.Buffer{}, NewCTRCipher(block), macKey, test.opts...).Write([]byte("hello\n"))
+			assert.Error(t, err)
+		})
+	}
+
+	// plaintext frames are not decrypted as if they were ciphertexts
+	signed := &bytes.Buffer{}
+	_, err = NewWriter(signed, macKey).Write([]byte("short"))
+	assert.NoError(t, err)
+	_, err = NewDecryptReader(signed, NewCTRCipher(block), macKey).Next()
+	assert.Error(t, err)
+}
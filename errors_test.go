@@ -0,0 +1,138 @@
+package authio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+// failingReader fails every read with the given error
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func Test_WrappedErrors(t *testing.T) {
+	mockKey := []byte("mock key")
+	mockErr := errors.New("mock error")
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	// a TCP connection, whose errors once closed are net.ErrClosed (the
+	// client is closed by the test cases, after the server timed out reading)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	server, err := listener.Accept()
+	assert.NoError(t, err)
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		fn          func() error
+		expectedErr error
+	}{
+		{
+			name: "Conn read timeout",
+			fn: func() error {
+				conn := NewConn(server, mockKey)
+				assert.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Millisecond)))
+				_, err := conn.Read(make([]byte, 16))
+				return err
+			},
+			expectedErr: os.ErrDeadlineExceeded,
+		},
+		{
+			name: "Conn read after close",
+			fn: func() error {
+				conn := NewConn(client, mockKey)
+				client.Close()
+				_, err := conn.Read(make([]byte, 16))
+				return err
+			},
+			expectedErr: net.ErrClosed,
+		},
+		{
+			name: "Conn write after close",
+			fn: func() error {
+				_, err := NewConn(client, mockKey).Write([]byte("hello"))
+				return err
+			},
+			expectedErr: net.ErrClosed,
+		},
+		{
+			name: "Writer to closed pipe",
+			fn: func() error {
+				pr, pw := io.Pipe()
+				pr.Close()
+				_, err := NewWriter(pw, mockKey).Write([]byte("hello"))
+				return err
+			},
+			expectedErr: io.ErrClosedPipe,
+		},
+		{
+			name: "Reader from failing reader",
+			fn: func() error {
+				_, err := NewReader(failingReader{err: mockErr}, mockKey).Read(make([]byte, 16))
+				return err
+			},
+			expectedErr: mockErr,
+		},
+		{
+			name: "AppendMACReader from failing reader",
+			fn: func() error {
+				_, err := NewAppendMACReader(failingReader{err: mockErr}, mockKey).Read(make([]byte, 64))
+				return err
+			},
+			expectedErr: mockErr,
+		},
+		{
+			name: "VerifyMACWriter to failing writer",
+			fn: func() error {
+				frame := &bytes.Buffer{}
+				_, err := NewWriter(frame, mockKey).Write([]byte("hello"))
+				assert.NoError(t, err)
+				_, err = NewVerifyMACWriter(failingWriter{}, mockKey).Write(frame.Bytes())
+				return err
+			},
+			expectedErr: errMockWrite,
+		},
+		{
+			name: "SignFile of missing file",
+			fn: func() error {
+				_, err := SignFile(missing, mockKey)
+				return err
+			},
+			expectedErr: os.ErrNotExist,
+		},
+		{
+			name: "VerifyFile with missing MAC file",
+			fn: func() error {
+				return VerifyFile(missing, missing+MACFileExtension, mockKey)
+			},
+			expectedErr: os.ErrNotExist,
+		},
+		{
+			name: "FileKeyProvider of missing file",
+			fn: func() error {
+				_, err := NewFileKeyProvider(missing).GetKey(context.Background(), "")
+				return err
+			},
+			expectedErr: os.ErrNotExist,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.fn()
+			assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+		})
+	}
+}
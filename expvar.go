@@ -0,0 +1,75 @@
+package authio
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+
+	"github.com/adrianosela/authio/metrics"
+)
+
+// expvarName is the name of the expvar.Map the package-wide counters are published as
+const expvarName = "authio"
+
+var (
+	expvarOnce    sync.Once
+	expvarEnabled atomic.Bool
+	expvarTotals  = &expvarMetrics{}
+)
+
+// expvarMetrics is a Metrics implementation counting package-wide totals
+type expvarMetrics struct {
+	framesSigned         expvar.Int
+	framesVerified       expvar.Int
+	verificationFailures expvar.Int
+	bytesSigned          expvar.Int
+	bytesVerified        expvar.Int
+}
+
+// ensure expvarMetrics implements metrics.Metrics at compile-time
+var _ metrics.Metrics = (*expvarMetrics)(nil)
+
+// EnableExpvar publishes package-wide counters of the frames signed and
+// verified, verification failures, and bytes signed and verified by every
+// reader, writer, and connection created afterwards (on top of any Metrics
+// set with WithMetrics), as the expvar.Map "authio". Services which already
+// expose /debug/vars (e.g. by importing expvar) get basic visibility for
+// free. It is safe to call more than once.
+func EnableExpvar() {
+	expvarOnce.Do(func() {
+		m := new(expvar.Map).Init()
+		m.Set("frames_signed", &expvarTotals.framesSigned)
+		m.Set("frames_verified", &expvarTotals.framesVerified)
+		m.Set("verification_failures", &expvarTotals.verificationFailures)
+		m.Set("bytes_signed", &expvarTotals.bytesSigned)
+		m.Set("bytes_verified", &expvarTotals.bytesVerified)
+		expvar.Publish(expvarName, m)
+		expvarEnabled.Store(true)
+	})
+}
+
+// withExpvar returns the given Metrics along with the package-wide
+// counters if EnableExpvar was called, and as is otherwise
+func withExpvar(m metrics.Metrics) metrics.Metrics {
+	if !expvarEnabled.Load() {
+		return m
+	}
+	return metrics.Multi{m, expvarTotals}
+}
+
+// MessageSigned counts a frame signed
+func (m *expvarMetrics) MessageSigned(size int) {
+	m.framesSigned.Add(1)
+	m.bytesSigned.Add(int64(size))
+}
+
+// MessageVerified counts a frame verified
+func (m *expvarMetrics) MessageVerified(size int) {
+	m.framesVerified.Add(1)
+	m.bytesVerified.Add(int64(size))
+}
+
+// VerificationFailed counts a verification failure
+func (m *expvarMetrics) VerificationFailed() {
+	m.verificationFailures.Add(1)
+}
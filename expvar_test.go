@@ -0,0 +1,46 @@
+package authio
+
+import (
+	"bytes"
+	"expvar"
+	"testing"
+
+	"github.com/adrianosela/authio/metrics"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_EnableExpvar(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	EnableExpvar()
+	EnableExpvar()
+	totals, ok := expvar.Get("authio").(*expvar.Map)
+	assert.True(t, ok)
+	counter := func(name string) int64 {
+		return totals.Get(name).(*expvar.Int).Value()
+	}
+	before := map[string]int64{}
+	for _, name := range []string{"frames_signed", "frames_verified", "verification_failures", "bytes_signed", "bytes_verified"} {
+		before[name] = counter(name)
+	}
+
+	signed := &bytes.Buffer{}
+	w := NewWriter(signed, mockKey, WithMetrics(metrics.Noop{}))
+	for _, message := range []string{"hello", "world!"} {
+		_, err := w.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+	r := NewReader(bytes.NewReader(signed.Bytes()), mockKey)
+	for i := 0; i < 2; i++ {
+		_, err := r.Next()
+		assert.NoError(t, err)
+	}
+	_, err := NewReader(bytes.NewReader(signed.Bytes()), []byte("other key")).Next()
+	assert.Error(t, err)
+
+	assert.Equal(t, before["frames_signed"]+2, counter("frames_signed"))
+	assert.Equal(t, before["bytes_signed"]+11, counter("bytes_signed"))
+	assert.Equal(t, before["frames_verified"]+2, counter("frames_verified"))
+	assert.Equal(t, before["bytes_verified"]+11, counter("bytes_verified"))
+	assert.Equal(t, before["verification_failures"]+1, counter("verification_failures"))
+}
@@ -0,0 +1,32 @@
+package authio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WriteWithExtensions(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	buf := &bytes.Buffer{}
+	writer := NewAppendMACWriter(buf, mockKey)
+	n, err := writer.WriteWithExtensions([]byte("with"), authenticator.KeyIDExtension("key-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, len("with"), n)
+	_, err = writer.Write([]byte("without"))
+	assert.Nil(t, err)
+
+	reader := NewVerifyMACReader(buf, mockKey)
+	message, extensions, err := reader.NextWithExtensions()
+	assert.Nil(t, err)
+	assert.Equal(t, "with", string(message))
+	assert.Equal(t, []authenticator.Extension{authenticator.KeyIDExtension("key-1")}, extensions)
+
+	message, extensions, err = reader.NextWithExtensions()
+	assert.Nil(t, err)
+	assert.Equal(t, "without", string(message))
+	assert.Equal(t, 0, len(extensions))
+}
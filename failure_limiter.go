@@ -0,0 +1,148 @@
+package authio
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/adrianosela/authio/metrics"
+)
+
+// minPruneSize is the number of peers tracked by a
+// FailureLimiter before expired ones are pruned
+const minPruneSize = 64
+
+// FailureLimiter tracks messages failing verification per peer (by remote
+// IP address) across Conns (see WithFailureLimiter), such that peers sending
+// forged or garbage messages are dropped (and optionally banned) rather than
+// burning CPU on MAC computations. It is safe for concurrent use.
+type FailureLimiter struct {
+	maxFailures int
+	window      time.Duration
+	ban         time.Duration
+	clock       Clock
+
+	lock      sync.Mutex
+	peers     map[string]*peerFailures
+	pruneSize int // number of peers beyond which expired ones are pruned
+}
+
+// peerFailures are the failures of a single peer
+type peerFailures struct {
+	count       int
+	windowStart time.Time
+	bannedUntil time.Time
+}
+
+// NewFailureLimiter returns a FailureLimiter which drops peers after more than
+// maxFailures failures within the given window, and then bans them (i.e. their
+// connections are dropped as soon as they are accepted by a Listener) for the
+// given ban duration. Zero ban duration means peers are dropped but not banned.
+func NewFailureLimiter(maxFailures int, window, ban time.Duration) *FailureLimiter {
+	return &FailureLimiter{
+		maxFailures: maxFailures,
+		window:      window,
+		ban:         ban,
+		clock:       SystemClock,
+		peers:       map[string]*peerFailures{},
+		pruneSize:   minPruneSize,
+	}
+}
+
+// WithClock sets the Clock failures and bans are timed
+// with (default SystemClock) and returns the FailureLimiter
+func (l *FailureLimiter) WithClock(clock Clock) *FailureLimiter {
+	l.clock = clock
+	return l
+}
+
+// Banned returns whether the peer with the given address is banned
+func (l *FailureLimiter) Banned(addr net.Addr) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	peer, ok := l.peers[peerKey(addr)]
+	return ok && l.clock.Now().Before(peer.bannedUntil)
+}
+
+// Failed records a failure of the peer with the given address, and
+// returns whether it exceeded the limit (i.e. it must be dropped)
+func (l *FailureLimiter) Failed(addr net.Addr) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.clock.Now()
+	key := peerKey(addr)
+	peer, ok := l.peers[key]
+	if !ok {
+		l.prune(now)
+		peer = &peerFailures{windowStart: now}
+		l.peers[key] = peer
+	}
+	if now.Sub(peer.windowStart) > l.window {
+		peer.count = 0
+		peer.windowStart = now
+	}
+	peer.count++
+	if peer.count <= l.maxFailures {
+		return false
+	}
+	if l.ban > 0 {
+		peer.bannedUntil = now.Add(l.ban)
+	}
+	return true
+}
+
+// prune forgets peers whose window and ban expired once there are too many
+// of them, such that the memory used is bounded by the number of offending
+// peers rather than by every peer ever seen. The lock must be held.
+func (l *FailureLimiter) prune(now time.Time) {
+	if len(l.peers) < l.pruneSize {
+		return
+	}
+	for key, peer := range l.peers {
+		if now.Sub(peer.windowStart) > l.window && !now.Before(peer.bannedUntil) {
+			delete(l.peers, key)
+		}
+	}
+	l.pruneSize = 2 * len(l.peers)
+	if l.pruneSize < minPruneSize {
+		l.pruneSize = minPruneSize
+	}
+}
+
+// peerKey returns the key peers are tracked by: the host of their address,
+// such that new connections (i.e. ports) from the same host count together
+func peerKey(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// failureLimiterMetrics is a metrics.Metrics implementation
+// which reports the failures of a Conn to a FailureLimiter
+type failureLimiterMetrics struct {
+	limiter *FailureLimiter
+	conn    net.Conn
+}
+
+// ensure failureLimiterMetrics implements metrics.Metrics at compile-time
+var _ metrics.Metrics = (*failureLimiterMetrics)(nil)
+
+// MessageSigned does nothing
+func (m *failureLimiterMetrics) MessageSigned(int) {}
+
+// MessageVerified does nothing
+func (m *failureLimiterMetrics) MessageVerified(int) {}
+
+// VerificationFailed records a failure, and drops the connection
+// (failing any pending reads and writes) if the peer exceeded the limit
+func (m *failureLimiterMetrics) VerificationFailed() {
+	if m.limiter.Failed(m.conn.RemoteAddr()) {
+		m.conn.Close()
+	}
+}
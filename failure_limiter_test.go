@@ -0,0 +1,83 @@
+package authio
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_FailureLimiter(t *testing.T) {
+	peerA := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}
+	peerAOtherPort := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5678}
+	peerB := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1234}
+
+	limiter := NewFailureLimiter(2, time.Minute, time.Minute)
+	assert.False(t, limiter.Failed(peerA))
+	assert.False(t, limiter.Failed(peerAOtherPort))
+	assert.False(t, limiter.Banned(peerA))
+	assert.True(t, limiter.Failed(peerA), "third failure within the window")
+	assert.True(t, limiter.Banned(peerA))
+	assert.True(t, limiter.Banned(peerAOtherPort), "bans apply to the host")
+	assert.False(t, limiter.Banned(peerB))
+	assert.False(t, limiter.Failed(peerB))
+
+	expiring := NewFailureLimiter(0, time.Millisecond, 0)
+	assert.True(t, expiring.Failed(peerA))
+	assert.False(t, expiring.Banned(peerA), "no ban duration")
+
+	// expired peers are pruned once too many are tracked
+	for i := 0; i < 2*minPruneSize; i++ {
+		expiring.Failed(&net.TCPAddr{IP: net.IPv4(10, 1, byte(i/256), byte(i)), Port: 1})
+		if i == minPruneSize {
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	expiring.lock.Lock()
+	defer expiring.lock.Unlock()
+	assert.True(t, len(expiring.peers) < 2*minPruneSize)
+}
+
+func Test_ListenerFailureLimiter(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	limiter := NewFailureLimiter(0, time.Minute, time.Minute)
+	l, err := Listen("tcp", "127.0.0.1:0", mockKey, WithFailureLimiter(limiter))
+	assert.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan *Conn, 1)
+	go func() {
+		conn, err := l.AcceptConn()
+		assert.NoError(t, err)
+		accepted <- conn
+	}()
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	client := NewClientConn(raw, []byte("wrong key"))
+	defer client.Close()
+	_, err = client.Write([]byte("forged"))
+	assert.NoError(t, err)
+
+	server := <-accepted
+	defer server.Close()
+	_, err = server.Read(make([]byte, 64))
+	assert.Error(t, err)
+	assert.True(t, limiter.Banned(raw.LocalAddr()))
+
+	// the server closed the connection
+	raw.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = raw.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	// and drops new connections from the banned peer as soon as they are accepted
+	go l.AcceptConn()
+	banned, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	defer banned.Close()
+	banned.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = banned.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
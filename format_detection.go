@@ -0,0 +1,169 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// detectingAuthenticator is a MessageAuthenticator which reads frames in
+// either the default format or with CBOR headers, detecting the format of
+// every frame, and writes frames in the configured format
+type detectingAuthenticator struct {
+	primary  authenticator.MessageAuthenticator
+	standard *authenticator.DefaultMessageAuthenticator
+	cbor     *authenticator.CBORMessageAuthenticator
+
+	// whether frames of either format are allowed (see WithAllowlist)
+	allowStandard bool
+	allowCBOR     bool
+}
+
+// ensure detectingAuthenticator implements CloseNotifier, ControlFramer, and ExtensionFramer at compile-time
+var (
+	_ authenticator.CloseNotifier   = (*detectingAuthenticator)(nil)
+	_ authenticator.ControlFramer   = (*detectingAuthenticator)(nil)
+	_ authenticator.ExtensionFramer = (*detectingAuthenticator)(nil)
+)
+
+// newDetectingAuthenticator returns a detectingAuthenticator for the configuration
+func (c *config) newDetectingAuthenticator(key []byte) authenticator.MessageAuthenticator {
+	switch {
+	case c.authenticator != nil, c.algorithm != "", c.checksum, c.keyProvider != nil, c.frameCodec != nil:
+		return &failingAuthenticator{headerLen: c.headerLength(), err: errors.New("format detection only supports the built-in HMAC frame formats")}
+	case c.macEncoding == authenticator.Raw:
+		return &failingAuthenticator{headerLen: c.headerLength(), err: errors.New("format detection does not support raw MAC encoding")}
+	}
+
+	a := &detectingAuthenticator{
+		standard: authenticator.NewDefaultMessageAuthenticator(c.hashFn, key).
+			WithTagSize(c.tagSize).
+			WithMACEncoding(c.macEncoding).
+			WithAssociatedData(c.aad).
+			WithMaxMessageSize(c.maxMessageSize),
+		cbor:          c.newCBORAuthenticator(key),
+		allowStandard: c.allowlist.allowsAlgorithm(c.registeredAlgorithmName(false)),
+		allowCBOR:     c.allowlist.allowsAlgorithm(c.registeredAlgorithmName(true)),
+	}
+	a.primary = a.standard
+	if c.cborHeaders {
+		a.primary = a.cbor
+	}
+	return a
+}
+
+// detect reads the start of the next frame, returning the authenticator
+// for its format and a reader of the whole frame
+func (a *detectingAuthenticator) detect(r io.Reader) (authenticator.MessageAuthenticator, io.Reader, error) {
+	peek := make([]byte, authenticator.CBORDetectionSize)
+	n, err := io.ReadFull(r, peek)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, io.EOF
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, fmt.Errorf("%w: read data too short to have valid header", authenticator.ErrTruncatedMessage)
+		}
+		return nil, nil, fmt.Errorf("failed to read message header: %w", err)
+	}
+
+	frame := io.MultiReader(bytes.NewReader(peek[:n]), r)
+	if authenticator.HasCBORHeader(peek) {
+		if !a.allowCBOR {
+			return nil, nil, fmt.Errorf("%w: frame with CBOR headers", authenticator.ErrNotAllowed)
+		}
+		return a.cbor, frame, nil
+	}
+	if !a.allowStandard {
+		return nil, nil, fmt.Errorf("%w: frame without CBOR headers", authenticator.ErrNotAllowed)
+	}
+	return a.standard, frame, nil
+}
+
+// WriteSequence returns the sequence number of the last frame written, if
+// frames are written with CBOR headers (see writeSequencer)
+func (a *detectingAuthenticator) WriteSequence() (uint64, bool) {
+	if sequencer, ok := a.primary.(writeSequencer); ok {
+		return sequencer.WriteSequence()
+	}
+	return 0, false
+}
+
+func (a *detectingAuthenticator) GetMessageAuthenticationHeaderLength() int {
+	return a.primary.GetMessageAuthenticationHeaderLength()
+}
+
+func (a *detectingAuthenticator) GetMessageAuthenticationHeader(data []byte) ([]byte, error) {
+	return a.primary.GetMessageAuthenticationHeader(data)
+}
+
+func (a *detectingAuthenticator) GetCloseNotifyHeader() ([]byte, error) {
+	return a.primary.(authenticator.CloseNotifier).GetCloseNotifyHeader()
+}
+
+func (a *detectingAuthenticator) GetControlFrameHeader(payload []byte) ([]byte, error) {
+	return a.primary.(authenticator.ControlFramer).GetControlFrameHeader(payload)
+}
+
+func (a *detectingAuthenticator) GetMessageAuthenticationHeaderWithExtensions(data []byte, extensions []authenticator.Extension) ([]byte, error) {
+	framer, ok := a.primary.(authenticator.ExtensionFramer)
+	if !ok {
+		return nil, errors.New("extensions are not supported with CBOR headers")
+	}
+	return framer.GetMessageAuthenticationHeaderWithExtensions(data, extensions)
+}
+
+func (a *detectingAuthenticator) ReadNext(r io.Reader) ([]byte, error) {
+	auth, frame, err := a.detect(r)
+	if err != nil {
+		return nil, err
+	}
+	return auth.ReadNext(frame)
+}
+
+func (a *detectingAuthenticator) ReadNextFrame(r io.Reader) ([]byte, bool, error) {
+	auth, frame, err := a.detect(r)
+	if err != nil {
+		return nil, false, err
+	}
+	return auth.(authenticator.ControlFramer).ReadNextFrame(frame)
+}
+
+func (a *detectingAuthenticator) ReadNextFrameWithExtensions(r io.Reader) ([]byte, []authenticator.Extension, bool, error) {
+	auth, frame, err := a.detect(r)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if framer, ok := auth.(authenticator.ExtensionFramer); ok {
+		return framer.ReadNextFrameWithExtensions(frame)
+	}
+	msg, control, err := auth.(authenticator.ControlFramer).ReadNextFrame(frame)
+	return msg, nil, control, err
+}
+
+// AuthenticateMessages processes one or more frames (of either format) in a given byte
+// slice. It returns the raw messages processed successfully and the number of messages.
+func (a *detectingAuthenticator) AuthenticateMessages(data []byte) ([]byte, int, error) {
+	processed := []byte{}
+	reader := bytes.NewReader(data)
+	nMessages := 0
+
+	for reader.Len() > 0 {
+		msg, err := a.ReadNext(reader)
+		if err != nil {
+			return processed, nMessages, fmt.Errorf("failed decoding header: %w", err)
+		}
+		processed = append(processed, msg...)
+		nMessages++
+	}
+	return processed, nMessages, nil
+}
+
+// Destroy destroys the keys of the authenticators of both formats
+func (a *detectingAuthenticator) Destroy() {
+	a.standard.Destroy()
+	a.cbor.Destroy()
+}
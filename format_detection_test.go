@@ -0,0 +1,86 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithFormatDetection(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name       string
+		writerOpts [][]Option
+		readerOpts []Option
+		expectErr  bool
+	}{
+		{
+			name:       "default frames",
+			writerOpts: [][]Option{{}},
+			readerOpts: []Option{WithFormatDetection()},
+		},
+		{
+			name:       "CBOR frames",
+			writerOpts: [][]Option{{WithCBORHeaders("key-1")}},
+			readerOpts: []Option{WithFormatDetection()},
+		},
+		{
+			name:       "mixed frames",
+			writerOpts: [][]Option{{}, {WithCBORHeaders("")}, {}},
+			readerOpts: []Option{WithFormatDetection(), WithCBORHeaders("")},
+		},
+		{
+			name:       "mismatched MAC encoding",
+			writerOpts: [][]Option{{WithCBORHeaders("")}, {}},
+			readerOpts: []Option{WithFormatDetection(), WithMACEncoding(authenticator.Hex)},
+			expectErr:  true,
+		},
+		{
+			name:       "raw MAC encoding",
+			writerOpts: [][]Option{{WithMACEncoding(authenticator.Raw)}},
+			readerOpts: []Option{WithFormatDetection(), WithMACEncoding(authenticator.Raw)},
+			expectErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			for _, opts := range test.writerOpts {
+				_, err := NewAppendMACWriter(buf, mockKey, opts...).Write([]byte("hello"))
+				assert.Nil(t, err)
+			}
+
+			reader := NewVerifyMACReader(buf, mockKey, test.readerOpts...)
+			for i := 0; i < len(test.writerOpts); i++ {
+				msg, err := reader.Next()
+				if err != nil {
+					assert.True(t, test.expectErr)
+					return
+				}
+				assert.Equal(t, "hello", string(msg))
+			}
+			assert.False(t, test.expectErr)
+			_, err := reader.Next()
+			assert.True(t, errors.Is(err, io.EOF))
+		})
+	}
+}
+
+func Test_WithFormatDetectionWrites(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	// writers keep writing the configured format
+	buf := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(buf, mockKey, WithFormatDetection(), WithCBORHeaders("")).Write([]byte("hello"))
+	assert.Nil(t, err)
+
+	msg, err := NewVerifyMACReader(buf, mockKey, WithCBORHeaders("")).Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(msg))
+}
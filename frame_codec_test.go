@@ -0,0 +1,42 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithFrameCodec(t *testing.T) {
+	mockKey := []byte("mock key")
+	codec := WithFrameCodec(authenticator.NewDefaultFrameCodec(authenticator.Hex.(authenticator.MACDecoder)))
+
+	buf := &bytes.Buffer{}
+	w := NewAppendMACWriter(buf, mockKey, codec, WithTagSize(16), WithCloseNotify(), WithMaxMessageSize(4))
+	_, err := w.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	stream := append([]byte{}, buf.Bytes()...)
+
+	// the readers and writers work as usual with the frames laid out by the codec
+	read, err := io.ReadAll(NewReader(bytes.NewReader(stream), mockKey, codec, WithTagSize(16), WithCloseNotify()))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(read))
+
+	// which (with the default frame codec) are default frames
+	read, err = io.ReadAll(NewReader(bytes.NewReader(stream), mockKey, WithMACEncoding(authenticator.Hex), WithTagSize(16), WithCloseNotify()))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(read))
+
+	// tamper with the payload of the first frame
+	stream[authenticator.HeaderLengthWithEncoding(16, authenticator.Hex)] ^= 1
+	_, err = io.ReadAll(NewReader(bytes.NewReader(stream), mockKey, codec, WithTagSize(16), WithCloseNotify()))
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch), "expected %v, got %v", authenticator.ErrMACMismatch, err)
+
+	// frame codecs do not support key providers
+	_, err = NewAppendMACWriter(&bytes.Buffer{}, nil, codec, WithKeyProvider(NewKeyRotation(mockKey, mockKey, 0), "")).Write([]byte("hello"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package authio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// FrameError is returned (wrapping the cause) by VerifyMACReaders (and Conns)
+// for frames failing to be read, verified, or decoded, with the position of
+// the frame in the stream, e.g. to locate corruption in the middle of a large
+// file. Use errors.As to access it. Timeouts are not wrapped in FrameErrors,
+// such that they remain a net.Error.
+type FrameError struct {
+	// Frame is the index of the frame in the stream, counting from zero.
+	// Frames before the state a VerifyMACReader was resumed from (see
+	// WithVerifyState) which were not messages (e.g. heartbeats) are not
+	// counted.
+	Frame uint64
+	// Offset is the offset (in bytes) in the stream at which the frame starts
+	Offset int64
+	Err    error
+}
+
+// Error returns the error message
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("frame %d at offset %d: %v", e.Frame, e.Offset, e.Err)
+}
+
+// Unwrap returns the cause of the error
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// frameError wraps an error reading (or decoding) the frame with the
+// given index starting at the given offset in a FrameError, unless it
+// is not the error of a frame (i.e. the end of the stream, or a timeout)
+func frameError(index uint64, offset int64, err error) error {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, authenticator.ErrCloseNotify) || isTimeout(err) {
+		return err
+	}
+	return &FrameError{Frame: index, Offset: offset, Err: err}
+}
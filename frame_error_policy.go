@@ -0,0 +1,58 @@
+package authio
+
+import "errors"
+
+// FrameErrorPolicy is what VerifyMACReaders (and Conns) do
+// upon frames failing verification (see WithFrameErrorPolicy)
+type FrameErrorPolicy int
+
+const (
+	// FailClosed (the default) makes every read after a frame fails
+	// verification (or cannot be read, other than due to a timeout)
+	// fail with the same error, i.e. one bad frame kills the stream
+	FailClosed FrameErrorPolicy = iota
+
+	// SkipAndReport drops frames with invalid MACs and carries on reading.
+	// Frames which cannot be delimited (e.g. with a corrupted length) still
+	// kill the stream, unless configured WithResync.
+	SkipAndReport
+)
+
+// TrailingDataPolicy is what VerifyMACReaders (and Conns) do when the
+// underlying reader ends in the middle of a frame (see WithTrailingDataPolicy)
+type TrailingDataPolicy int
+
+const (
+	// FailOnTrailingData (the default) fails reads with (a wrapped)
+	// authenticator.ErrTruncatedMessage when the underlying reader ends
+	// mid-frame, i.e. a clean io.EOF is only returned at a frame boundary
+	FailOnTrailingData TrailingDataPolicy = iota
+
+	// IgnoreTrailingData treats a partial frame at the end of the underlying
+	// reader as the end of the stream (io.EOF), e.g. for files appended to by
+	// writers which may have crashed mid-write. The partial frame is dropped.
+	IgnoreTrailingData
+)
+
+// OversizedFramePolicy is what VerifyMACReaders (and Conns) do upon frames
+// larger than the max message size (see WithOversizedFramePolicy)
+type OversizedFramePolicy int
+
+const (
+	// AbortOnOversizedFrame (the default) fails reads upon a frame larger than
+	// the max message size with (a wrapped) authenticator.MessageTooLargeError,
+	// i.e. an oversized frame kills the stream as per the FrameErrorPolicy
+	AbortOnOversizedFrame OversizedFramePolicy = iota
+
+	// DrainOversizedFrame reads and discards the rest of frames larger than the
+	// max message size (without buffering them) and carries on reading, such
+	// that a single oversized message does not kill the stream. Drained frames
+	// are not verified, so their size is taken on trust.
+	DrainOversizedFrame
+)
+
+// isTimeout returns whether an error is a (e.g. read deadline) timeout
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
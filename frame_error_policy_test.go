@@ -0,0 +1,154 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_FrameErrorPolicy(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		expected      []string
+		expectBad     int
+		expectFailing bool
+	}{
+		{
+			name:          "Fail closed by default",
+			expected:      []string{"message 0"},
+			expectFailing: true,
+		},
+		{
+			name:          "Fail closed",
+			opts:          []Option{WithFrameErrorPolicy(FailClosed, nil)},
+			expected:      []string{"message 0"},
+			expectFailing: true,
+		},
+		{
+			name:      "Skip and report",
+			expected:  []string{"message 0", "message 2"},
+			expectBad: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer := NewAppendMACWriter(buf, mockKey)
+			for _, message := range []string{"message 0", "MESSAGE 1", "message 2"} {
+				_, err := writer.Write([]byte(message))
+				assert.Nil(t, err)
+			}
+			data := bytes.Replace(buf.Bytes(), []byte("MESSAGE 1"), []byte("message 1"), 1)
+
+			bad := 0
+			opts := test.opts
+			if !test.expectFailing {
+				opts = []Option{WithFrameErrorPolicy(SkipAndReport, func(err error) {
+					assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+					bad++
+				})}
+			}
+			reader := NewVerifyMACReader(bytes.NewReader(data), mockKey, opts...)
+
+			for _, expected := range test.expected {
+				message, err := reader.Next()
+				assert.Nil(t, err)
+				assert.Equal(t, expected, string(message))
+			}
+			_, err := reader.Next()
+			if test.expectFailing {
+				assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+				// the error sticks, even though the next frame is valid
+				_, err = reader.Next()
+				assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+				return
+			}
+			assert.True(t, errors.Is(err, io.EOF))
+			assert.Equal(t, test.expectBad, bad)
+		})
+	}
+}
+
+func Test_OversizedFramePolicy(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		truncate      int
+		expected      []string
+		expectDrained int
+		expectErr     error
+	}{
+		{
+			name:      "Abort by default",
+			expected:  []string{"small 0"},
+			expectErr: &authenticator.MessageTooLargeError{},
+		},
+		{
+			name:          "Drain",
+			opts:          []Option{WithOversizedFramePolicy(DrainOversizedFrame, nil)},
+			expected:      []string{"small 0", "small 2"},
+			expectDrained: 1,
+		},
+		{
+			name:          "Drain with CBOR headers",
+			opts:          []Option{WithOversizedFramePolicy(DrainOversizedFrame, nil), WithCBORHeaders("key-1")},
+			expected:      []string{"small 0", "small 2"},
+			expectDrained: 1,
+		},
+		{
+			name:      "Drain truncated frame",
+			opts:      []Option{WithOversizedFramePolicy(DrainOversizedFrame, nil)},
+			truncate:  len("small 2") + authenticator.HeaderLengthWithEncoding(32, authenticator.StdBase64) + 1,
+			expected:  []string{"small 0"},
+			expectErr: authenticator.ErrTruncatedMessage,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer := NewAppendMACWriter(buf, mockKey, append(test.opts, WithMaxMessageSize(0))...)
+			for _, message := range []string{"small 0", "a message larger than the max", "small 2"} {
+				_, err := writer.Write([]byte(message))
+				assert.Nil(t, err)
+			}
+			data := buf.Bytes()[:buf.Len()-test.truncate]
+
+			drained := 0
+			opts := append(test.opts, WithMaxMessageSize(8))
+			if test.expectDrained > 0 {
+				opts = append(opts, WithOversizedFramePolicy(DrainOversizedFrame, func(err error) {
+					var tooLarge *authenticator.MessageTooLargeError
+					assert.True(t, errors.As(err, &tooLarge))
+					drained++
+				}))
+			}
+			reader := NewVerifyMACReader(bytes.NewReader(data), mockKey, opts...)
+
+			for _, expected := range test.expected {
+				message, err := reader.Next()
+				assert.Nil(t, err)
+				assert.Equal(t, expected, string(message))
+			}
+			_, err := reader.Next()
+			switch expected := test.expectErr.(type) {
+			case nil:
+				assert.True(t, errors.Is(err, io.EOF))
+			case *authenticator.MessageTooLargeError:
+				assert.True(t, errors.As(err, &expected))
+				assert.Equal(t, uint64(len("a message larger than the max")), expected.Size)
+			default:
+				assert.True(t, errors.Is(err, expected))
+			}
+			assert.Equal(t, test.expectDrained, drained)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_FrameError(t *testing.T) {
+	mockKey := []byte("mock key")
+	pool := NewVerifierPool(2)
+	defer pool.Close()
+
+	signed := bytes.NewBuffer(nil)
+	writer := NewWriter(signed, mockKey, WithCloseNotify())
+	frameSizes := []int64{}
+	for _, message := range []string{"first", "second", "third"} {
+		before := signed.Len()
+		_, err := writer.Write([]byte(message))
+		assert.NoError(t, err)
+		frameSizes = append(frameSizes, int64(signed.Len()-before))
+	}
+	assert.NoError(t, writer.Close())
+	stream := signed.Bytes()
+
+	// the last byte of the second frame
+	tampered := append([]byte(nil), stream...)
+	tampered[frameSizes[0]+frameSizes[1]-1] ^= 1
+	// without the close notification
+	truncated := stream[:frameSizes[0]+frameSizes[1]+frameSizes[2]]
+
+	tests := []struct {
+		name           string
+		stream         []byte
+		opts           []Option
+		expectedFrame  uint64
+		expectedOffset int64
+		expectedErr    error
+	}{
+		{
+			name:           "Tampered frame",
+			stream:         tampered,
+			expectedFrame:  1,
+			expectedOffset: frameSizes[0],
+			expectedErr:    authenticator.ErrMACMismatch,
+		},
+		{
+			name:           "Tampered frame with verifier pool",
+			stream:         tampered,
+			opts:           []Option{WithVerifierPool(pool)},
+			expectedFrame:  1,
+			expectedOffset: frameSizes[0],
+			expectedErr:    authenticator.ErrMACMismatch,
+		},
+		{
+			name:           "Truncated stream with resync",
+			stream:         truncated,
+			opts:           []Option{WithResync(nil)},
+			expectedFrame:  3,
+			expectedOffset: frameSizes[0] + frameSizes[1] + frameSizes[2],
+			expectedErr:    ErrTruncatedStream,
+		},
+		{
+			name:           "Truncated stream",
+			stream:         truncated,
+			expectedFrame:  3,
+			expectedOffset: frameSizes[0] + frameSizes[1] + frameSizes[2],
+			expectedErr:    ErrTruncatedStream,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewVerifyMACReader(bytes.NewReader(test.stream), mockKey, append(test.opts, WithCloseNotify())...)
+			_, err := io.ReadAll(reader)
+			assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+
+			var frameErr *FrameError
+			assert.True(t, errors.As(err, &frameErr))
+			assert.Equal(t, test.expectedFrame, frameErr.Frame)
+			assert.Equal(t, test.expectedOffset, frameErr.Offset)
+		})
+	}
+
+	// errors of skipped frames carry their position
+	var skipped []error
+	reader := NewVerifyMACReader(bytes.NewReader(tampered), mockKey,
+		WithFrameErrorPolicy(SkipAndReport, func(err error) { skipped = append(skipped, err) }))
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "firstthird", string(data))
+	assert.Equal(t, 1, len(skipped))
+	var frameErr *FrameError
+	assert.True(t, errors.As(skipped[0], &frameErr))
+	assert.Equal(t, uint64(1), frameErr.Frame)
+	assert.Equal(t, frameSizes[0], frameErr.Offset)
+
+	// the end of the stream is not a FrameError
+	_, err = NewVerifyMACReader(bytes.NewReader(nil), mockKey).Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
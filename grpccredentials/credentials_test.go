@@ -0,0 +1,103 @@
+package grpccredentials
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func Test_TransportCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		serverKey   []byte
+		clientKey   []byte
+		expectError bool
+	}{
+		{
+			name:        "Matching keys",
+			serverKey:   []byte("mock key"),
+			clientKey:   []byte("mock key"),
+			expectError: false,
+		},
+		{
+			name:        "Mismatched keys",
+			serverKey:   []byte("mock key"),
+			clientKey:   []byte("other key"),
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+
+			server := grpc.NewServer(grpc.Creds(New(test.serverKey)))
+			healthpb.RegisterHealthServer(server, health.NewServer())
+			go server.Serve(lis)
+			defer server.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithTransportCredentials(New(test.clientKey)))
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			if test.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Handshake(t *testing.T) {
+	key := []byte("mock key that is long enough....")
+
+	t.Run("auth info", func(t *testing.T) {
+		a, b := net.Pipe()
+		defer a.Close()
+		defer b.Close()
+
+		served := make(chan credentials.AuthInfo, 1)
+		go func() {
+			_, info, err := New(key).ServerHandshake(b)
+			assert.NoError(t, err)
+			served <- info
+		}()
+
+		_, info, err := New(key).ClientHandshake(context.Background(), "", a)
+		assert.NoError(t, err)
+		assert.Equal(t, AuthInfo{
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.IntegrityOnly},
+			Algorithm:      "HMAC-SHA-256",
+		}, info)
+		assert.Equal(t, info, <-served)
+	})
+
+	t.Run("context deadline", func(t *testing.T) {
+		a, b := net.Pipe()
+		defer a.Close()
+		defer b.Close()
+		// a server which never answers
+		go io.Copy(io.Discard, b)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, _, err := New(key).ClientHandshake(ctx, "", a)
+		assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	})
+}
@@ -0,0 +1,23 @@
+module github.com/adrianosela/authio/grpccredentials
+
+go 1.19
+
+require (
+	github.com/adrianosela/authio v0.0.0
+	github.com/autarch/testify v1.2.2
+	google.golang.org/grpc v1.56.3
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
+	google.golang.org/protobuf v1.30.0 // indirect
+)
+
+replace github.com/adrianosela/authio => ..
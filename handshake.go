@@ -0,0 +1,260 @@
+package authio
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// IDs of the handshakes, which peers send ahead of their nonce (along with
+// the version of the handshake), such that peers running different handshakes
+// fail with a clear error rather than misreading each other's messages
+const (
+	pskHandshakeID     = byte(1)
+	sessionHandshakeID = byte(2)
+)
+
+// handshakeHeaderSize is the size of the ID and version sent ahead of nonces
+const handshakeHeaderSize = 2
+
+// handshakeNames are the names of the handshakes, by ID
+var handshakeNames = map[byte]string{
+	pskHandshakeID:     "PSK",
+	sessionHandshakeID: "session",
+}
+
+// handshakeProtocol is a handshake which starts with an exchange of random
+// nonces, the client's first, each preceded by the ID and version of the
+// handshake
+type handshakeProtocol struct {
+	id        byte
+	version   byte
+	nonceSize int
+	// negotiatesAlgorithms is whether the client follows its nonce with the
+	// IDs of the algorithms it offers, and the server its nonce with the ID
+	// of the one it picked (see WithAlgorithms)
+	negotiatesAlgorithms bool
+	err                  error // wrapped by the errors of peers running another handshake
+}
+
+var (
+	pskHandshakeProtocol     = handshakeProtocol{id: pskHandshakeID, version: 2, nonceSize: PSKNonceSize, negotiatesAlgorithms: true, err: ErrPSKHandshakeFailed}
+	sessionHandshakeProtocol = handshakeProtocol{id: sessionHandshakeID, version: 1, nonceSize: SessionNonceSize, err: ErrSessionHandshakeFailed}
+)
+
+// handshakeHello is the outcome of the exchange of nonces (and
+// algorithms) which starts a handshake
+type handshakeHello struct {
+	clientNonce []byte
+	serverNonce []byte
+	// algorithm is the name of the negotiated algorithm, if any
+	algorithm string
+	// negotiation is the algorithms offered by the client followed by the
+	// one picked by the server (as sent), if any were negotiated
+	negotiation []byte
+}
+
+// exchangeNonces exchanges random nonces with the peer, along with the
+// given algorithms (offered by clients, accepted by servers) if the
+// handshake negotiates algorithms
+func (p handshakeProtocol) exchangeNonces(conn net.Conn, client bool, algorithms []string) (handshakeHello, error) {
+	ids, err := algorithmIDs(algorithms)
+	if err != nil {
+		return handshakeHello{}, err
+	}
+	nonce := make([]byte, p.nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return handshakeHello{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// the server checks the client's header before answering, such that
+	// it does not wait for a nonce of the wrong size
+	if client {
+		offer := []byte{}
+		if p.negotiatesAlgorithms {
+			offer = append(offer, byte(len(ids)))
+			for _, id := range ids {
+				offer = binary.BigEndian.AppendUint16(offer, id)
+			}
+		}
+		if err := p.writeNonce(conn, nonce, offer, "client"); err != nil {
+			return handshakeHello{}, err
+		}
+		serverNonce, err := p.readNonce(conn, "server")
+		if err != nil {
+			return handshakeHello{}, err
+		}
+		hello := handshakeHello{clientNonce: nonce, serverNonce: serverNonce}
+		if !p.negotiatesAlgorithms {
+			return hello, nil
+		}
+		picked := make([]byte, 2)
+		if _, err := io.ReadFull(conn, picked); err != nil {
+			return handshakeHello{}, fmt.Errorf("failed to read server algorithm: %w", err)
+		}
+		return hello, hello.pick(p.err, ids, binary.BigEndian.Uint16(picked), offer)
+	}
+
+	clientNonce, err := p.readNonce(conn, "client")
+	if err != nil {
+		return handshakeHello{}, err
+	}
+	hello := handshakeHello{clientNonce: clientNonce, serverNonce: nonce}
+	if !p.negotiatesAlgorithms {
+		return hello, p.writeNonce(conn, nonce, nil, "server")
+	}
+	offer, offered, err := readAlgorithmOffer(conn)
+	if err != nil {
+		return handshakeHello{}, err
+	}
+	picked, err := pickAlgorithm(p.err, offered, ids)
+	if err != nil {
+		return handshakeHello{}, err
+	}
+	if err := hello.pick(p.err, offered, picked, offer); err != nil {
+		return handshakeHello{}, err
+	}
+	return hello, p.writeNonce(conn, nonce, binary.BigEndian.AppendUint16(nil, picked), "server")
+}
+
+// pick records the algorithm picked by the server, which clients check
+// against the ones they offered (zero means none, if none were offered)
+func (h *handshakeHello) pick(handshakeErr error, offered []uint16, picked uint16, offer []byte) error {
+	if len(offered) == 0 && picked == 0 {
+		return nil
+	}
+	if !containsID(offered, picked) {
+		return fmt.Errorf("%w: server picked algorithm %d, which was not offered", handshakeErr, picked)
+	}
+	alg, ok := authenticator.Get(picked)
+	if !ok {
+		return fmt.Errorf("%w: unknown algorithm %d", handshakeErr, picked)
+	}
+	h.algorithm = alg.Name
+	h.negotiation = binary.BigEndian.AppendUint16(append([]byte{}, offer...), picked)
+	return nil
+}
+
+// options returns the given options followed by one selecting
+// the negotiated algorithm, if any
+func (h handshakeHello) options(opts []Option) []Option {
+	if h.algorithm == "" {
+		return opts
+	}
+	return append(append([]Option{}, opts...), WithAlgorithm(h.algorithm))
+}
+
+// pickAlgorithm returns the first algorithm offered by the client
+// which the server accepts. Either both or neither must have any.
+func pickAlgorithm(handshakeErr error, offered, accepted []uint16) (uint16, error) {
+	switch {
+	case len(offered) == 0 && len(accepted) == 0:
+		return 0, nil
+	case len(offered) == 0:
+		return 0, fmt.Errorf("%w: client offers no algorithms (see WithAlgorithms)", handshakeErr)
+	case len(accepted) == 0:
+		return 0, fmt.Errorf("%w: client offers algorithms, but none are accepted (see WithAlgorithms)", handshakeErr)
+	}
+	for _, id := range offered {
+		if containsID(accepted, id) {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: no common algorithm, client offers %v", handshakeErr, offered)
+}
+
+// readAlgorithmOffer reads the algorithms offered by a client, returning
+// them both as sent and decoded
+func readAlgorithmOffer(conn net.Conn) ([]byte, []uint16, error) {
+	count := make([]byte, 1)
+	if _, err := io.ReadFull(conn, count); err != nil {
+		return nil, nil, fmt.Errorf("failed to read client algorithms: %w", err)
+	}
+	encoded := make([]byte, 2*int(count[0]))
+	if _, err := io.ReadFull(conn, encoded); err != nil {
+		return nil, nil, fmt.Errorf("failed to read client algorithms: %w", err)
+	}
+	ids := []uint16{}
+	for i := 0; i < len(encoded); i += 2 {
+		ids = append(ids, binary.BigEndian.Uint16(encoded[i:]))
+	}
+	return append(count, encoded...), ids, nil
+}
+
+// algorithmIDs returns the IDs of the registered algorithms with the given names
+func algorithmIDs(names []string) ([]uint16, error) {
+	if len(names) > math.MaxUint8 {
+		return nil, fmt.Errorf("at most %d algorithms can be negotiated, got %d", math.MaxUint8, len(names))
+	}
+	ids := []uint16{}
+	for _, name := range names {
+		alg, ok := authenticator.GetByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown algorithm %q", name)
+		}
+		ids = append(ids, alg.ID)
+	}
+	return ids, nil
+}
+
+func containsID(ids []uint16, id uint16) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (p handshakeProtocol) writeNonce(conn net.Conn, nonce, trailer []byte, side string) error {
+	msg := append(append([]byte{p.id, p.version}, nonce...), trailer...)
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("failed to write %s nonce: %w", side, err)
+	}
+	return nil
+}
+
+func (p handshakeProtocol) readNonce(conn net.Conn, side string) ([]byte, error) {
+	header := make([]byte, handshakeHeaderSize)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, fmt.Errorf("failed to read %s nonce: %w", side, err)
+	}
+	if id := header[0]; id != p.id {
+		if name, ok := handshakeNames[id]; ok {
+			return nil, fmt.Errorf("%w: peer runs the %s handshake rather than the %s handshake", p.err, name, handshakeNames[p.id])
+		}
+		return nil, fmt.Errorf("%w: peer runs an unknown handshake %d", p.err, id)
+	}
+	if version := header[1]; version != p.version {
+		return nil, fmt.Errorf("%w: unsupported version %d", p.err, version)
+	}
+	nonce := make([]byte, p.nonceSize)
+	if _, err := io.ReadFull(conn, nonce); err != nil {
+		return nil, fmt.Errorf("failed to read %s nonce: %w", side, err)
+	}
+	return nonce, nil
+}
+
+// runHandshake runs a handshake over a connection, within the handshake
+// timeout if any (see WithHandshakeTimeout), after which the deadline of
+// the connection is cleared
+func runHandshake(conn net.Conn, timeout time.Duration, handshake func() (*Conn, error)) (*Conn, error) {
+	if timeout <= 0 {
+		return handshake()
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("failed to set handshake deadline: %w", err)
+	}
+	c, err := handshake()
+	// the deadline is cleared regardless, such that it does
+	// not outlive the handshake even if the caller moves on
+	if clearErr := conn.SetDeadline(time.Time{}); clearErr != nil && err == nil {
+		return nil, fmt.Errorf("failed to clear handshake deadline: %w", clearErr)
+	}
+	return c, err
+}
@@ -0,0 +1,118 @@
+package authio
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_HandshakeTimeout(t *testing.T) {
+	key := []byte("mock pre-shared key")
+	timeout := WithHandshakeTimeout(50 * time.Millisecond)
+
+	tests := []struct {
+		name      string
+		handshake func(conn net.Conn, opts ...Option) (*Conn, error)
+		peer      func(conn net.Conn, opts ...Option) (*Conn, error)
+	}{
+		{
+			name:      "PSK client",
+			handshake: func(conn net.Conn, opts ...Option) (*Conn, error) { return NewPSKClientConn(conn, key, opts...) },
+			peer:      func(conn net.Conn, opts ...Option) (*Conn, error) { return NewPSKServerConn(conn, key, opts...) },
+		},
+		{
+			name:      "PSK server",
+			handshake: func(conn net.Conn, opts ...Option) (*Conn, error) { return NewPSKServerConn(conn, key, opts...) },
+			peer:      func(conn net.Conn, opts ...Option) (*Conn, error) { return NewPSKClientConn(conn, key, opts...) },
+		},
+		{
+			name:      "session client",
+			handshake: func(conn net.Conn, opts ...Option) (*Conn, error) { return NewSessionClientConn(conn, key, opts...) },
+			peer:      func(conn net.Conn, opts ...Option) (*Conn, error) { return NewSessionServerConn(conn, key, opts...) },
+		},
+		{
+			name:      "session server",
+			handshake: func(conn net.Conn, opts ...Option) (*Conn, error) { return NewSessionServerConn(conn, key, opts...) },
+			peer:      func(conn net.Conn, opts ...Option) (*Conn, error) { return NewSessionClientConn(conn, key, opts...) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// a peer which stalls
+			stalled, raw := net.Pipe()
+			defer raw.Close()
+			go io.Copy(io.Discard, raw)
+
+			_, err := test.handshake(stalled, timeout)
+			assert.True(t, errors.Is(err, os.ErrDeadlineExceeded), "expected %v, got %v", os.ErrDeadlineExceeded, err)
+
+			// a peer which completes the handshake
+			a, b := net.Pipe()
+			defer a.Close()
+			defer b.Close()
+			go test.peer(b)
+
+			conn, err := test.handshake(a, timeout)
+			assert.NoError(t, err)
+
+			// the deadline is cleared once the handshake is done
+			time.Sleep(100 * time.Millisecond)
+			go conn.Close()
+			_, err = conn.Read(make([]byte, 8))
+			assert.False(t, errors.Is(err, os.ErrDeadlineExceeded))
+		})
+	}
+}
+
+func Test_HandshakeMismatch(t *testing.T) {
+	key := []byte("mock pre-shared key")
+
+	tests := []struct {
+		name          string
+		client        func(conn net.Conn) (*Conn, error)
+		server        func(conn net.Conn) (*Conn, error)
+		expectedErr   error
+		expectedError string
+	}{
+		{
+			name:          "PSK client with session server",
+			client:        func(conn net.Conn) (*Conn, error) { return NewPSKClientConn(conn, key) },
+			server:        func(conn net.Conn) (*Conn, error) { return NewSessionServerConn(conn, key) },
+			expectedErr:   ErrSessionHandshakeFailed,
+			expectedError: "peer runs the PSK handshake rather than the session handshake",
+		},
+		{
+			name:          "session client with PSK server",
+			client:        func(conn net.Conn) (*Conn, error) { return NewSessionClientConn(conn, key) },
+			server:        func(conn net.Conn) (*Conn, error) { return NewPSKServerConn(conn, key) },
+			expectedErr:   ErrPSKHandshakeFailed,
+			expectedError: "peer runs the session handshake rather than the PSK handshake",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+
+			clientErr := make(chan error, 1)
+			go func() {
+				_, err := test.client(clientConn)
+				clientErr <- err
+			}()
+			_, err := test.server(serverConn)
+			assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+
+			// the client fails rather than waiting for the server's nonce
+			serverConn.Close()
+			assert.Error(t, <-clientErr)
+		})
+	}
+}
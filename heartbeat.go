@@ -0,0 +1,122 @@
+package authio
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeat control frames are a type byte followed by the time
+// (in nanoseconds since the pinging Conn was created) of the ping,
+// which pongs echo back such that the pinging Conn can compute RTTs
+const heartbeatPayloadSize = 1 + 8
+
+// heartbeatState is the state of the heartbeat of a Conn
+type heartbeatState struct {
+	start        time.Time
+	lastReceived int64 // nanoseconds since start, accessed atomically
+	rtt          int64 // nanoseconds, accessed atomically
+}
+
+// received records that something was received from the peer
+func (h *heartbeatState) received() {
+	atomic.StoreInt64(&h.lastReceived, int64(time.Since(h.start)))
+}
+
+// sinceReceived returns the time since anything was received from the peer
+func (h *heartbeatState) sinceReceived() time.Duration {
+	return time.Since(h.start) - time.Duration(atomic.LoadInt64(&h.lastReceived))
+}
+
+// RTT returns the round trip time measured by the most recent heartbeat
+// (see WithHeartbeat), or zero if none has been measured yet
+func (c *Conn) RTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.heartbeat.rtt))
+}
+
+func (c *Conn) startHeartbeat(interval, timeout time.Duration) {
+	go c.runHeartbeat(interval, timeout)
+}
+
+func (c *Conn) runHeartbeat(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		if timeout > 0 && c.heartbeat.sinceReceived() > timeout {
+			// closing the underlying net.Conn fails any pending reads and writes
+			c.Conn.Close()
+			return
+		}
+		// a ping which cannot be written within the timeout means the peer is gone
+		if err := c.writeControlFrame(heartbeatPayload(ControlTypePing, time.Since(c.heartbeat.start)), timeout); err != nil {
+			c.Conn.Close()
+			return
+		}
+	}
+}
+
+// handleControlFrame handles a control frame received from the peer, passing
+// those of custom types to their handlers (see WithControlHandler)
+func (c *Conn) handleControlFrame(payload []byte) {
+	c.heartbeat.received()
+
+	if len(payload) > 0 && ControlType(payload[0]) >= ControlTypeCustom {
+		c.reader.handleControlFrame(payload)
+		return
+	}
+	if len(payload) > 0 && ControlType(payload[0]) == ControlTypeIdentity {
+		if identity := string(payload[1:]); len(identity) <= MaxIdentityLength {
+			c.peerIdentity.Store(&identity)
+		}
+		return
+	}
+	if len(payload) != heartbeatPayloadSize {
+		return
+	}
+	switch ControlType(payload[0]) {
+	case ControlTypePing:
+		// reply without blocking the reader
+		pong := heartbeatPayload(ControlTypePong, time.Duration(binary.BigEndian.Uint64(payload[1:])))
+		go c.writeControlFrame(pong, 0)
+	case ControlTypePong:
+		sent := time.Duration(binary.BigEndian.Uint64(payload[1:]))
+		atomic.StoreInt64(&c.heartbeat.rtt, int64(time.Since(c.heartbeat.start)-sent))
+	}
+}
+
+// writeControlFrame writes a control frame, within the given
+// timeout (if non-zero) or the write timeout of the Conn
+func (c *Conn) writeControlFrame(payload []byte, timeout time.Duration) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+
+	if timeout == 0 {
+		timeout = c.writeTimeout
+	}
+	if timeout > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+	}
+	return c.writer.writeControlFrame(payload)
+}
+
+func heartbeatPayload(controlType ControlType, t time.Duration) []byte {
+	payload := make([]byte, heartbeatPayloadSize)
+	payload[0] = byte(controlType)
+	binary.BigEndian.PutUint64(payload[1:], uint64(t))
+	return payload
+}
@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	flagNameKey     = "key"
+	flagNameKeyFile = "key-file"
+
+	// envNameKey is the environment variable the key is read from when
+	// no key flag is given. envNameLegacyKey is the variable used by the
+	// now removed build_hmac command, still honored for compatibility.
+	envNameKey       = "AUTHIO_KEY"
+	envNameLegacyKey = "MAC_PSK"
+
+	// DefaultHash is the name of the default hash function
+	DefaultHash = "sha256"
+
+	// DefaultMACEncoding is the name of the default MAC encoding
+	DefaultMACEncoding = "base64"
+)
+
+// hashes are the hash functions which can be selected by name
+var hashes = map[string]func() hash.Hash{
+	"sha256":   sha256.New,
+	"sha384":   sha512.New384,
+	"sha512":   sha512.New,
+	"sha3-256": sha3.New256,
+	"sha3-384": sha3.New384,
+	"sha3-512": sha3.New512,
+}
+
+// macEncodings are the MAC encodings which can be selected by name
+var macEncodings = map[string]authenticator.MACEncoding{
+	"base64":     authenticator.StdBase64,
+	"base64-url": authenticator.URLBase64,
+	"hex":        authenticator.Hex,
+	"raw":        authenticator.Raw,
+}
+
+// KeyFlags are the flags shared by all commands which need a key
+type KeyFlags struct {
+	key     string
+	keyFile string
+}
+
+// Register registers the key flags on a flag.FlagSet
+func (k *KeyFlags) Register(fs *flag.FlagSet) {
+	fs.StringVar(&k.key, flagNameKey, "", fmt.Sprintf("key to use for message authentication codes (default: $%s)", envNameKey))
+	fs.StringVar(&k.keyFile, flagNameKeyFile, "", "file to read the key to use for message authentication codes from")
+}
+
+// Load returns the key from (in order of precedence) the key
+// flag, the key file flag, or the key environment variables
+func (k *KeyFlags) Load() ([]byte, error) {
+	if k.key != "" && k.keyFile != "" {
+		return nil, fmt.Errorf("only one of -%s and -%s may be set", flagNameKey, flagNameKeyFile)
+	}
+	if k.key != "" {
+		return []byte(k.key), nil
+	}
+	if k.keyFile != "" {
+		data, err := os.ReadFile(k.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		// drop trailing newline(s) added by editors and echo
+		key := strings.TrimRight(string(data), "\r\n")
+		if key == "" {
+			return nil, fmt.Errorf("key file %s is empty", k.keyFile)
+		}
+		return []byte(key), nil
+	}
+	for _, env := range []string{envNameKey, envNameLegacyKey} {
+		if key := os.Getenv(env); key != "" {
+			return []byte(key), nil
+		}
+	}
+	return nil, fmt.Errorf("no key given, use -%s, -%s, or $%s", flagNameKey, flagNameKeyFile, envNameKey)
+}
+
+// FrameFlags are the flags shared by all commands which sign or verify
+// authenticated messages, selecting how their frames are authenticated
+type FrameFlags struct {
+	hash        string
+	tagSize     int
+	macEncoding string
+}
+
+// Register registers the frame flags on a flag.FlagSet
+func (f *FrameFlags) Register(fs *flag.FlagSet) {
+	fs.StringVar(&f.hash, "hash", DefaultHash, fmt.Sprintf("hash function to use for message authentication codes (one of %v)", HashNames()))
+	fs.IntVar(&f.tagSize, "tag-size", 0, "size in bytes to truncate MACs to, reducing overhead per message (0 for no truncation)")
+	fs.StringVar(&f.macEncoding, "mac-encoding", DefaultMACEncoding, fmt.Sprintf("text encoding of MACs (one of %v)", MACEncodingNames()))
+}
+
+// Hash returns the name of the selected hash function
+func (f *FrameFlags) Hash() string {
+	return f.hash
+}
+
+// Options returns the authio options for the selected hash function,
+// tag size, and MAC encoding
+func (f *FrameFlags) Options() ([]authio.Option, error) {
+	hashFn, err := LookupHash(f.hash)
+	if err != nil {
+		return nil, err
+	}
+	encoding, err := LookupMACEncoding(f.macEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return []authio.Option{authio.WithHashFn(hashFn), authio.WithTagSize(f.tagSize), authio.WithMACEncoding(encoding)}, nil
+}
+
+// HashNames returns the names of all hash functions accepted by LookupHash
+func HashNames() []string {
+	names := []string{}
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupHash returns the hash function with the given name
+func LookupHash(name string) (func() hash.Hash, error) {
+	hashFn, ok := hashes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash %q, must be one of %s", name, strings.Join(HashNames(), ", "))
+	}
+	return hashFn, nil
+}
+
+// MACEncodingNames returns the names of all MAC encodings accepted by LookupMACEncoding
+func MACEncodingNames() []string {
+	names := []string{}
+	for name := range macEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupMACEncoding returns the MAC encoding with the given name
+func LookupMACEncoding(name string) (authenticator.MACEncoding, error) {
+	encoding, ok := macEncodings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown MAC encoding %q, must be one of %s", name, strings.Join(MACEncodingNames(), ", "))
+	}
+	return encoding, nil
+}
+
+// NewFlagSet returns a flag.FlagSet for a command
+func NewFlagSet(command, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] %s\n\nFlags:\n", command, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Parse parses a command's flags, exiting successfully on -h
+func Parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		return err
+	}
+	return nil
+}
+
+// OpenInput returns the file named by the (at most one) positional
+// argument of a command, or stdin if there is none or it is "-"
+func OpenInput(fs *flag.FlagSet) (io.ReadCloser, error) {
+	switch fs.NArg() {
+	case 0:
+		return io.NopCloser(os.Stdin), nil
+	case 1:
+		if fs.Arg(0) == "-" {
+			return io.NopCloser(os.Stdin), nil
+		}
+		return os.Open(fs.Arg(0))
+	default:
+		return nil, fmt.Errorf("expected at most one file, got %d", fs.NArg())
+	}
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/internal/cli"
+)
+
+const (
+	// ModeClient accepts plain connections and forwards them to an
+	// upstream which expects authenticated messages (e.g. the listener
+	// of a proxy in ModeServer)
+	ModeClient = "client"
+	// ModeServer accepts connections with authenticated messages
+	// and forwards them to an upstream which expects plain data
+	ModeServer = "server"
+
+	defaultListenAddress  = "localhost:1234"
+	defaultMaxMessageSize = 64 * 1024
+)
+
+// Config is the configuration of a proxy
+type Config struct {
+	Upstream string
+	Mode     string
+	Key      []byte
+	Options  []authio.Option
+	Logger   *log.Logger
+}
+
+// Serve accepts connections on the given listener and forwards each to a
+// new connection to the upstream, adding MACs to data sent towards the
+// authenticated side and verifying and removing them from data received
+// from it. Serve only returns once the listener fails.
+func Serve(l net.Listener, config Config) error {
+	if err := validateMode(config.Mode); err != nil {
+		return err
+	}
+	if config.Logger == nil {
+		config.Logger = log.New(io.Discard, "", 0)
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		go handleConn(conn, config)
+	}
+}
+
+func handleConn(downstream net.Conn, config Config) {
+	defer downstream.Close()
+
+	upstream, err := net.Dial("tcp", config.Upstream)
+	if err != nil {
+		config.Logger.Printf("[%s] failed to dial upstream %s: %s", downstream.RemoteAddr(), config.Upstream, err)
+		return
+	}
+	defer upstream.Close()
+
+	var plain, authed net.Conn
+	switch config.Mode {
+	case ModeClient:
+		plain, authed = downstream, authio.NewClientConn(upstream, config.Key, config.Options...)
+	case ModeServer:
+		plain, authed = upstream, authio.NewServerConn(downstream, config.Key, config.Options...)
+	}
+
+	// once either direction is done (or fails) both connections are
+	// closed, which in turn unblocks the copy in the other direction
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			downstream.Close()
+			upstream.Close()
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		if _, err := io.Copy(authed, plain); err != nil && !errors.Is(err, net.ErrClosed) {
+			config.Logger.Printf("[%s] failed to forward plain data: %s", downstream.RemoteAddr(), err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		if _, err := io.Copy(plain, authed); err != nil && !errors.Is(err, net.ErrClosed) {
+			config.Logger.Printf("[%s] failed to forward authenticated data: %s", downstream.RemoteAddr(), err)
+		}
+	}()
+	wg.Wait()
+}
+
+// RunCommand runs the proxy as a command line tool with the given name and arguments
+func RunCommand(command string, args []string) error {
+	var (
+		keys           cli.KeyFlags
+		listen         string
+		upstream       string
+		mode           string
+		hash           string
+		maxMessageSize int
+		tagSize        int
+		macEncoding    string
+	)
+	fs := cli.NewFlagSet(command, "")
+	keys.Register(fs)
+	fs.StringVar(&listen, "listen", defaultListenAddress, "address (i.e. HOST:PORT) to accept connections on")
+	fs.StringVar(&upstream, "upstream", "", "address (i.e. HOST:PORT) to forward connections to")
+	fs.StringVar(&mode, "mode", ModeClient, fmt.Sprintf("%s: accept plain and forward authenticated connections, %s: accept authenticated and forward plain connections", ModeClient, ModeServer))
+	fs.StringVar(&hash, "hash", cli.DefaultHash, fmt.Sprintf("hash function to use for message authentication codes (one of %v)", cli.HashNames()))
+	fs.IntVar(&maxMessageSize, "max-message-size", defaultMaxMessageSize, "maximum size of authenticated messages in bytes, larger messages are split when sent and rejected when received")
+	fs.IntVar(&tagSize, "tag-size", 0, "size in bytes to truncate MACs to, reducing overhead per message (0 for no truncation)")
+	fs.StringVar(&macEncoding, "mac-encoding", cli.DefaultMACEncoding, fmt.Sprintf("text encoding of MACs (one of %v)", cli.MACEncodingNames()))
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if upstream == "" {
+		return errors.New("-upstream is required")
+	}
+	if err := validateMode(mode); err != nil {
+		return err
+	}
+
+	key, err := keys.Load()
+	if err != nil {
+		return err
+	}
+	hashFn, err := cli.LookupHash(hash)
+	if err != nil {
+		return err
+	}
+	encoding, err := cli.LookupMACEncoding(macEncoding)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+	defer l.Close()
+
+	logger := log.Default()
+	logger.Printf("proxying %s -> %s (mode %s)", l.Addr(), upstream, mode)
+
+	return Serve(l, Config{
+		Upstream: upstream,
+		Mode:     mode,
+		Key:      key,
+		Options:  []authio.Option{authio.WithHashFn(hashFn), authio.WithMaxMessageSize(maxMessageSize), authio.WithTagSize(tagSize), authio.WithMACEncoding(encoding)},
+		Logger:   logger,
+	})
+}
+
+func validateMode(mode string) error {
+	if mode != ModeClient && mode != ModeServer {
+		return fmt.Errorf("unknown mode %q, must be %s or %s", mode, ModeClient, ModeServer)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/adrianosela/authio"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Serve(t *testing.T) {
+	mockKey := []byte("mock key")
+	mockData := []byte("mock data")
+
+	// plain echo server
+	echo := listen(t)
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	// client proxy -> server proxy -> echo server
+	serverProxy := listen(t)
+	go Serve(serverProxy, Config{Upstream: echo.Addr().String(), Mode: ModeServer, Key: mockKey})
+	clientProxy := listen(t)
+	go Serve(clientProxy, Config{Upstream: serverProxy.Addr().String(), Mode: ModeClient, Key: mockKey})
+
+	t.Run("Through both proxies", func(t *testing.T) {
+		conn, err := net.Dial("tcp", clientProxy.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write(mockData)
+		assert.NoError(t, err)
+
+		buf := make([]byte, len(mockData))
+		_, err = io.ReadFull(conn, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, string(mockData), string(buf))
+	})
+
+	t.Run("Authenticated client to server proxy", func(t *testing.T) {
+		raw, err := net.Dial("tcp", serverProxy.Addr().String())
+		assert.NoError(t, err)
+		conn := authio.NewClientConn(raw, mockKey)
+		defer conn.Close()
+
+		_, err = conn.Write(mockData)
+		assert.NoError(t, err)
+
+		buf := make([]byte, len(mockData))
+		_, err = io.ReadFull(conn, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, string(mockData), string(buf))
+	})
+
+	t.Run("Plain client to server proxy", func(t *testing.T) {
+		conn, err := net.Dial("tcp", serverProxy.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("this is not an authenticated message, not even close"))
+		assert.NoError(t, err)
+
+		// the proxy drops the connection rather than forwarding anything
+		_, err = io.ReadAll(conn)
+		assert.NoError(t, err)
+	})
+}
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	return l
+}
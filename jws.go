@@ -0,0 +1,138 @@
+package authio
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// JWS algorithms (see RFC 7518), i.e. HMACs with the given hash functions
+const (
+	JWSHS256 = "HS256"
+	JWSHS384 = "HS384"
+	JWSHS512 = "HS512"
+)
+
+// ErrMalformedJWS is returned (wrapped) when a JWS cannot be parsed, or
+// uses features (e.g. an algorithm or critical header) not supported
+var ErrMalformedJWS = errors.New("malformed JWS")
+
+// jwsHashFns are the hash functions of the supported JWS algorithms
+var jwsHashFns = map[string]func() hash.Hash{
+	JWSHS256: sha256.New,
+	JWSHS384: sha512.New384,
+	JWSHS512: sha512.New,
+}
+
+// jwsHeader is the protected header of a JWS
+type jwsHeader struct {
+	Alg  string   `json:"alg"`
+	B64  *bool    `json:"b64,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+}
+
+// SignJWS returns a JWS compact serialization with detached content (see
+// RFC 7515, Appendix F) of the payload read from the given reader, i.e.
+// "<header>..<signature>", which standard JOSE tooling verifies given the
+// payload. The payload is streamed, so it is never loaded in memory in full.
+func SignJWS(payload io.Reader, key []byte, alg string) (string, error) {
+	return signJWS(payload, key, jwsHeader{Alg: alg})
+}
+
+// SignJWSUnencoded is like SignJWS, but signs the payload as is rather than
+// its base64url encoding (see RFC 7797), which is cheaper for large payloads
+// but not supported by all JOSE tooling
+func SignJWSUnencoded(payload io.Reader, key []byte, alg string) (string, error) {
+	b64 := false
+	return signJWS(payload, key, jwsHeader{Alg: alg, B64: &b64, Crit: []string{"b64"}})
+}
+
+// VerifyJWS verifies a JWS compact serialization with detached content (as
+// returned by SignJWS or SignJWSUnencoded, or by other JOSE tooling) against
+// the payload read from the given reader. Only the HS256, HS384, and HS512
+// algorithms are accepted. authenticator.ErrMACMismatch is returned if the payload does
+// not match the signature.
+func VerifyJWS(payload io.Reader, jws string, key []byte) error {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: expected 3 parts, got %d", ErrMalformedJWS, len(parts))
+	}
+	if parts[1] != "" {
+		return fmt.Errorf("%w: content is not detached", ErrMalformedJWS)
+	}
+	encoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("%w: header is not base64url encoded: %s", ErrMalformedJWS, err)
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(encoded, &header); err != nil {
+		return fmt.Errorf("%w: invalid header: %s", ErrMalformedJWS, err)
+	}
+	for _, crit := range header.Crit {
+		if crit != "b64" {
+			return fmt.Errorf("%w: unsupported critical header %q", ErrMalformedJWS, crit)
+		}
+	}
+	if header.B64 != nil && len(header.Crit) == 0 {
+		return fmt.Errorf("%w: b64 header must be critical", ErrMalformedJWS)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: signature is not base64url encoded: %s", ErrMalformedJWS, err)
+	}
+
+	computed, err := computeJWSSignature(payload, key, header, parts[0])
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(signature, computed) {
+		return authenticator.ErrMACMismatch
+	}
+	return nil
+}
+
+func signJWS(payload io.Reader, key []byte, header jwsHeader) (string, error) {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWS header: %w", err)
+	}
+	protected := base64.RawURLEncoding.EncodeToString(encoded)
+	signature, err := computeJWSSignature(payload, key, header, protected)
+	if err != nil {
+		return "", err
+	}
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// computeJWSSignature computes the signature of a JWS with the given
+// header (and its encoding) over the payload read from the given reader
+func computeJWSSignature(payload io.Reader, key []byte, header jwsHeader, protected string) ([]byte, error) {
+	hashFn, ok := jwsHashFns[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrMalformedJWS, header.Alg)
+	}
+	mac := hmac.New(hashFn, key)
+	mac.Write([]byte(protected + "."))
+
+	if header.B64 != nil && !*header.B64 {
+		if _, err := io.Copy(mac, payload); err != nil {
+			return nil, fmt.Errorf("failed to read payload: %w", err)
+		}
+		return mac.Sum(nil), nil
+	}
+	encoder := base64.NewEncoder(base64.RawURLEncoding, mac)
+	if _, err := io.Copy(encoder, payload); err != nil {
+		return nil, fmt.Errorf("failed to read payload: %w", err)
+	}
+	encoder.Close()
+	return mac.Sum(nil), nil
+}
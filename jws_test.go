@@ -0,0 +1,107 @@
+package authio
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_JWS(t *testing.T) {
+	// the key of the examples in RFC 7515, Appendix A.1 and RFC 7797, Section 4
+	rfcKey, err := base64.RawURLEncoding.DecodeString("AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow")
+	assert.NoError(t, err)
+	rfc7515Payload, err := base64.RawURLEncoding.DecodeString("eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		payload     string
+		jws         string
+		key         []byte
+		expectedErr error
+	}{
+		{
+			name:    "RFC 7515 Appendix A.1 (detached)",
+			payload: string(rfc7515Payload),
+			jws:     "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9..dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+			key:     rfcKey,
+		},
+		{
+			name:    "RFC 7797 Section 4.2",
+			payload: "$.02",
+			jws:     "eyJhbGciOiJIUzI1NiIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19..A5dxf2s96_n5FLueVuW1Z_vh161FwXZC4YLPff6dmDY",
+			key:     rfcKey,
+		},
+		{
+			name:        "Tampered payload",
+			payload:     "$.03",
+			jws:         "eyJhbGciOiJIUzI1NiIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19..A5dxf2s96_n5FLueVuW1Z_vh161FwXZC4YLPff6dmDY",
+			key:         rfcKey,
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:        "Wrong key",
+			payload:     "$.02",
+			jws:         "eyJhbGciOiJIUzI1NiIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19..A5dxf2s96_n5FLueVuW1Z_vh161FwXZC4YLPff6dmDY",
+			key:         []byte("wrong key"),
+			expectedErr: authenticator.ErrMACMismatch,
+		},
+		{
+			name:        "Unsupported algorithm",
+			payload:     "payload",
+			jws:         base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "..",
+			key:         rfcKey,
+			expectedErr: ErrMalformedJWS,
+		},
+		{
+			name:        "Attached content",
+			payload:     "payload",
+			jws:         "eyJhbGciOiJIUzI1NiJ9.cGF5bG9hZA.c2lnbmF0dXJl",
+			key:         rfcKey,
+			expectedErr: ErrMalformedJWS,
+		},
+		{
+			name:        "Unsupported critical header",
+			payload:     "payload",
+			jws:         base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","crit":["exp"]}`)) + "..",
+			key:         rfcKey,
+			expectedErr: ErrMalformedJWS,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyJWS(strings.NewReader(test.payload), test.jws, test.key)
+			if test.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+		})
+	}
+}
+
+func Test_SignJWS(t *testing.T) {
+	mockKey := []byte("mock key")
+	payload := strings.Repeat("mock payload ", 100)
+
+	for _, alg := range []string{JWSHS256, JWSHS384, JWSHS512} {
+		jws, err := SignJWS(strings.NewReader(payload), mockKey, alg)
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyJWS(strings.NewReader(payload), jws, mockKey))
+
+		jws, err = SignJWSUnencoded(strings.NewReader(payload), mockKey, alg)
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyJWS(strings.NewReader(payload), jws, mockKey))
+	}
+
+	jws, err := SignJWSUnencoded(strings.NewReader("$.02"), []byte("mock key"), JWSHS256)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(jws, "eyJhbGciOiJIUzI1NiIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19.."))
+
+	_, err = SignJWS(strings.NewReader(payload), mockKey, "RS256")
+	assert.True(t, errors.Is(err, ErrMalformedJWS))
+}
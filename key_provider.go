@@ -0,0 +1,368 @@
+package authio
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// KeyProvider provides the keys used to compute and verify MACs, which
+// decouples message authentication from how keys are distributed. Note
+// that frames do not carry key IDs, so readers and writers look up a
+// single key ID set with WithKeyProvider (which, for a Keyring, may be
+// CurrentKeyID to follow scheduled rotations).
+type KeyProvider interface {
+	GetKey(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// KeyProviderFunc is an adapter to allow the use of
+// ordinary functions as a KeyProvider
+type KeyProviderFunc func(ctx context.Context, keyID string) ([]byte, error)
+
+// GetKey calls f(ctx, keyID)
+func (f KeyProviderFunc) GetKey(ctx context.Context, keyID string) ([]byte, error) {
+	return f(ctx, keyID)
+}
+
+// StaticKey returns a KeyProvider which provides the given key for every key ID
+func StaticKey(key []byte) KeyProvider {
+	return KeyProviderFunc(func(context.Context, string) ([]byte, error) {
+		return key, nil
+	})
+}
+
+// EnvKey returns a KeyProvider which provides the value of the given
+// environment variable (at the time of the lookup) for every key ID
+func EnvKey(name string) KeyProvider {
+	return KeyProviderFunc(func(context.Context, string) ([]byte, error) {
+		key := os.Getenv(name)
+		if key == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+		return []byte(key), nil
+	})
+}
+
+// DefaultFileKeyCheckInterval is the default interval at which a
+// FileKeyProvider checks whether its file changed (see WithCheckInterval)
+const DefaultFileKeyCheckInterval = time.Second
+
+// FileKeyProvider is a KeyProvider which provides the contents of a file
+// (without trailing newlines) for every key ID. The file is checked for
+// changes at most once per check interval, and read again whenever its
+// size or modification time changed, so keys can be rotated by replacing
+// the file.
+type FileKeyProvider struct {
+	path     string
+	interval time.Duration
+	now      func() time.Time
+
+	lock    sync.Mutex
+	key     []byte
+	size    int64
+	modTime time.Time
+	checked time.Time
+}
+
+// ensure FileKeyProvider implements KeyProvider at compile-time
+var _ KeyProvider = (*FileKeyProvider)(nil)
+
+// NewFileKeyProvider returns a new FileKeyProvider for the file at the given path
+func NewFileKeyProvider(path string) *FileKeyProvider {
+	return &FileKeyProvider{
+		path:     path,
+		interval: DefaultFileKeyCheckInterval,
+		now:      SystemClock.Now,
+	}
+}
+
+// WithCheckInterval sets the interval at which the file is checked for
+// changes (default DefaultFileKeyCheckInterval) and returns the
+// FileKeyProvider. Zero means the file is checked on every lookup.
+func (p *FileKeyProvider) WithCheckInterval(interval time.Duration) *FileKeyProvider {
+	p.interval = interval
+	return p
+}
+
+// WithClock sets the Clock the check interval is measured
+// with (default SystemClock) and returns the FileKeyProvider
+func (p *FileKeyProvider) WithClock(clock Clock) *FileKeyProvider {
+	p.now = clock.Now
+	return p
+}
+
+// GetKey returns the contents of the file, reading it again if it changed
+func (p *FileKeyProvider) GetKey(ctx context.Context, keyID string) ([]byte, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	if p.key != nil && now.Sub(p.checked) < p.interval {
+		return p.key, nil
+	}
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat key file: %w", err)
+	}
+	if p.key != nil && info.Size() == p.size && info.ModTime().Equal(p.modTime) {
+		p.checked = now
+		return p.key, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	// drop trailing newline(s) added by editors and echo
+	key := strings.TrimRight(string(data), "\r\n")
+	if key == "" {
+		return nil, fmt.Errorf("key file %s is empty", p.path)
+	}
+
+	p.key, p.size, p.modTime, p.checked = []byte(key), info.Size(), info.ModTime(), now
+	return p.key, nil
+}
+
+// keyProviderAuthenticator is a MessageAuthenticator which looks up
+// its key from a KeyProvider on every message, such that changes to
+// the key take effect without having to rebuild readers and writers
+type keyProviderAuthenticator struct {
+	provider       KeyProvider
+	keyID          string
+	hashFn         func() hash.Hash
+	maxMessageSize int
+	tagSize        int
+	encoding       authenticator.MACEncoding
+	headerLen      int
+	aad            []byte
+	minKeyLength   int
+
+	// guards key and cached, the MessageAuthenticator of the last key
+	// provided, which is replaced (and destroyed) when the key changes
+	lock      sync.Mutex
+	key       []byte
+	cached    *authenticator.DefaultMessageAuthenticator
+	destroyed bool
+}
+
+// ensure keyProviderAuthenticator implements CloseNotifier, ControlFramer, and ExtensionFramer at compile-time
+var (
+	_ authenticator.CloseNotifier   = (*keyProviderAuthenticator)(nil)
+	_ authenticator.ControlFramer   = (*keyProviderAuthenticator)(nil)
+	_ authenticator.ExtensionFramer = (*keyProviderAuthenticator)(nil)
+)
+
+// candidateKeyProvider is implemented by KeyProviders which may hold several
+// keys frames could have been authenticated with (see Keyring.candidateKeys)
+type candidateKeyProvider interface {
+	candidateKeys(ctx context.Context, keyID string) ([][]byte, error)
+}
+
+// keyUsageRecorder is implemented by candidateKeyProviders which count the
+// frames verified with each of their candidate keys (see KeyRotation)
+type keyUsageRecorder interface {
+	verifiedWith(index int)
+}
+
+// current returns a MessageAuthenticator with the key to authenticate frames
+// with, which is built only when the key changed since the last call. The one
+// it replaces is destroyed once operations in progress with it return.
+func (a *keyProviderAuthenticator) current() (*authenticator.DefaultMessageAuthenticator, error) {
+	key, err := a.provider.GetKey(context.Background(), a.keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key %q: %w", a.keyID, err)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.destroyed {
+		return nil, authenticator.ErrDestroyed
+	}
+	if a.cached != nil && subtle.ConstantTimeCompare(key, a.key) == 1 {
+		return a.cached, nil
+	}
+	current, err := a.withKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if replaced := a.cached; replaced != nil {
+		// not under the lock, as Destroy waits for operations in progress
+		// (e.g. a ReadNext blocked on its reader) to return
+		go replaced.Destroy()
+		zeroize(a.key)
+	}
+	a.key, a.cached = append([]byte{}, key...), current
+	return current, nil
+}
+
+// withCurrent calls fn with the current MessageAuthenticator (see current),
+// again if it was replaced (and destroyed) before fn got to use it
+func (a *keyProviderAuthenticator) withCurrent(fn func(*authenticator.DefaultMessageAuthenticator) error) error {
+	for {
+		current, err := a.current()
+		if err != nil {
+			return err
+		}
+		if err := fn(current); !errors.Is(err, authenticator.ErrDestroyed) {
+			return err
+		}
+	}
+}
+
+// Destroy destroys the current MessageAuthenticator (and its copy of
+// the key), after which all operations fail with ErrDestroyed
+func (a *keyProviderAuthenticator) Destroy() {
+	a.lock.Lock()
+	cached := a.cached
+	zeroize(a.key)
+	a.key, a.cached, a.destroyed = nil, nil, true
+	a.lock.Unlock()
+
+	if cached != nil {
+		cached.Destroy()
+	}
+}
+
+func (a *keyProviderAuthenticator) withKey(key []byte) (*authenticator.DefaultMessageAuthenticator, error) {
+	if a.minKeyLength > 0 {
+		if err := CheckKey(key, a.minKeyLength); err != nil {
+			return nil, fmt.Errorf("key %q: %w", a.keyID, err)
+		}
+	}
+	return authenticator.NewDefaultMessageAuthenticator(a.hashFn, key).
+		WithTagSize(a.tagSize).
+		WithMACEncoding(a.encoding).
+		WithAssociatedData(a.aad).
+		WithMaxMessageSize(a.maxMessageSize), nil
+}
+
+// verify verifies a frame read from the given reader with the given function,
+// trying every candidate key (see candidateKeyProvider) in turn for as long as
+// the MAC does not match, replaying the frame read by failed attempts
+func (a *keyProviderAuthenticator) verify(r io.Reader, fn func(*authenticator.DefaultMessageAuthenticator, io.Reader) error) error {
+	provider, ok := a.provider.(candidateKeyProvider)
+	if !ok {
+		return a.withCurrent(func(current *authenticator.DefaultMessageAuthenticator) error {
+			return fn(current, r)
+		})
+	}
+	keys, err := provider.candidateKeys(context.Background(), a.keyID)
+	if err != nil {
+		return fmt.Errorf("failed to get key %q: %w", a.keyID, err)
+	}
+
+	recorded := &bytes.Buffer{}
+	src := r
+	if len(keys) > 1 {
+		src = io.TeeReader(r, recorded)
+	}
+	for i, key := range keys {
+		current, err := a.withKey(key)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			src = bytes.NewReader(recorded.Bytes())
+		}
+		err = fn(current, src)
+		if recorder, ok := provider.(keyUsageRecorder); ok && err == nil {
+			recorder.verifiedWith(i)
+		}
+		if i == len(keys)-1 || !errors.Is(err, authenticator.ErrMACMismatch) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *keyProviderAuthenticator) GetMessageAuthenticationHeaderLength() int {
+	return a.headerLen
+}
+
+func (a *keyProviderAuthenticator) GetMessageAuthenticationHeader(data []byte) ([]byte, error) {
+	var header []byte
+	err := a.withCurrent(func(current *authenticator.DefaultMessageAuthenticator) (err error) {
+		header, err = current.GetMessageAuthenticationHeader(data)
+		return err
+	})
+	return header, err
+}
+
+func (a *keyProviderAuthenticator) ReadNext(r io.Reader) ([]byte, error) {
+	var msg []byte
+	err := a.verify(r, func(current *authenticator.DefaultMessageAuthenticator, r io.Reader) (err error) {
+		msg, err = current.ReadNext(r)
+		return err
+	})
+	return msg, err
+}
+
+func (a *keyProviderAuthenticator) AuthenticateMessages(data []byte) ([]byte, int, error) {
+	var processed []byte
+	var nMessages int
+	err := a.verify(bytes.NewReader(data), func(current *authenticator.DefaultMessageAuthenticator, _ io.Reader) (err error) {
+		processed, nMessages, err = current.AuthenticateMessages(data)
+		return err
+	})
+	return processed, nMessages, err
+}
+
+func (a *keyProviderAuthenticator) GetCloseNotifyHeader() ([]byte, error) {
+	var header []byte
+	err := a.withCurrent(func(current *authenticator.DefaultMessageAuthenticator) (err error) {
+		header, err = current.GetCloseNotifyHeader()
+		return err
+	})
+	return header, err
+}
+
+func (a *keyProviderAuthenticator) GetControlFrameHeader(payload []byte) ([]byte, error) {
+	var header []byte
+	err := a.withCurrent(func(current *authenticator.DefaultMessageAuthenticator) (err error) {
+		header, err = current.GetControlFrameHeader(payload)
+		return err
+	})
+	return header, err
+}
+
+func (a *keyProviderAuthenticator) ReadNextFrame(r io.Reader) ([]byte, bool, error) {
+	var msg []byte
+	var control bool
+	err := a.verify(r, func(current *authenticator.DefaultMessageAuthenticator, r io.Reader) (err error) {
+		msg, control, err = current.ReadNextFrame(r)
+		return err
+	})
+	return msg, control, err
+}
+
+func (a *keyProviderAuthenticator) GetMessageAuthenticationHeaderWithExtensions(data []byte, extensions []authenticator.Extension) ([]byte, error) {
+	var header []byte
+	err := a.withCurrent(func(current *authenticator.DefaultMessageAuthenticator) (err error) {
+		header, err = current.GetMessageAuthenticationHeaderWithExtensions(data, extensions)
+		return err
+	})
+	return header, err
+}
+
+func (a *keyProviderAuthenticator) ReadNextFrameWithExtensions(r io.Reader) ([]byte, []authenticator.Extension, bool, error) {
+	var msg []byte
+	var extensions []authenticator.Extension
+	var control bool
+	err := a.verify(r, func(current *authenticator.DefaultMessageAuthenticator, r io.Reader) (err error) {
+		msg, extensions, control, err = current.ReadNextFrameWithExtensions(r)
+		return err
+	})
+	return msg, extensions, control, err
+}
@@ -0,0 +1,125 @@
+package authio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_FileKeyProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	now := time.Unix(1700000000, 0)
+	p := NewFileKeyProvider(path).WithClock(ClockFunc(func() time.Time { return now }))
+
+	_, err := p.GetKey(context.Background(), "")
+	assert.Error(t, err, "missing file")
+
+	assert.NoError(t, os.WriteFile(path, []byte("first key\n"), 0600))
+	key, err := p.GetKey(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first key"), key)
+
+	// a different size guarantees the change is noticed regardless of mtime granularity
+	assert.NoError(t, os.WriteFile(path, []byte("rotated key\n"), 0600))
+	key, err = p.GetKey(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first key"), key, "file checked within the check interval")
+
+	now = now.Add(DefaultFileKeyCheckInterval)
+	key, err = p.GetKey(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("rotated key"), key)
+
+	assert.NoError(t, os.WriteFile(path, []byte("\n"), 0600))
+	now = now.Add(DefaultFileKeyCheckInterval)
+	_, err = p.GetKey(context.Background(), "")
+	assert.Error(t, err, "empty file")
+}
+
+func Test_FileKeyProviderNoCheckInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	p := NewFileKeyProvider(path).WithCheckInterval(0)
+
+	assert.NoError(t, os.WriteFile(path, []byte("first key\n"), 0600))
+	key, err := p.GetKey(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first key"), key)
+
+	assert.NoError(t, os.WriteFile(path, []byte("rotated key\n"), 0600))
+	key, err = p.GetKey(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("rotated key"), key)
+}
+
+func Test_KeyProviderAuthenticatorCache(t *testing.T) {
+	key := []byte("first key")
+	provider := KeyProviderFunc(func(context.Context, string) ([]byte, error) {
+		return key, nil
+	})
+	a := newConfig(WithKeyProvider(provider, "")).newAuthenticator(nil).(*keyProviderAuthenticator)
+
+	first, err := a.current()
+	assert.NoError(t, err)
+	again, err := a.current()
+	assert.NoError(t, err)
+	assert.True(t, first == again, "MessageAuthenticator rebuilt for the same key")
+
+	key = []byte("second key")
+	second, err := a.current()
+	assert.NoError(t, err)
+	assert.True(t, first != second, "MessageAuthenticator not rebuilt for a new key")
+
+	// the replaced MessageAuthenticator is destroyed (in the background)
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		_, err := first.GetMessageAuthenticationHeader([]byte("hello"))
+		if errors.Is(err, authenticator.ErrDestroyed) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("replaced MessageAuthenticator not destroyed")
+		}
+	}
+
+	// operations still work with the current key
+	header, err := a.GetMessageAuthenticationHeader([]byte("hello"))
+	assert.NoError(t, err)
+	msg, err := NewVerifyMACReader(bytes.NewReader(append(header, "hello"...)), []byte("second key")).Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(msg))
+
+	a.Destroy()
+	_, err = second.GetMessageAuthenticationHeader([]byte("hello"))
+	assert.True(t, errors.Is(err, authenticator.ErrDestroyed))
+	_, err = a.GetMessageAuthenticationHeader([]byte("hello"))
+	assert.True(t, errors.Is(err, authenticator.ErrDestroyed))
+}
+
+func Test_WithKeyProvider(t *testing.T) {
+	keys := map[string][]byte{"current": []byte("first key")}
+	provider := KeyProviderFunc(func(_ context.Context, keyID string) ([]byte, error) {
+		return keys[keyID], nil
+	})
+
+	buf := &bytes.Buffer{}
+	w := NewAppendMACWriter(buf, nil, WithKeyProvider(provider, "current"))
+	_, err := w.Write([]byte("hello"))
+	assert.NoError(t, err)
+
+	keys["current"] = []byte("second key")
+	_, err = w.Write([]byte("world"))
+	assert.NoError(t, err)
+
+	r := NewVerifyMACReader(buf, nil, WithKeyProvider(StaticKey([]byte("first key")), ""))
+	msg, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(msg))
+	_, err = r.Next()
+	assert.Error(t, err, "message written after key rotation")
+}
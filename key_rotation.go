@@ -0,0 +1,117 @@
+package authio
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyRotation is a KeyProvider for rotating from an old key to a new one
+// without a flag day: writers always sign with the new key, while readers
+// accept frames signed with either key until the overlap ends, after which
+// the old key is rejected (and destroyed). It counts the frames verified
+// under either key, such that operators can tell when every writer switched
+// to the new key, i.e. when rotation is complete.
+type KeyRotation struct {
+	overlap time.Duration
+
+	lock   sync.Mutex
+	clock  Clock
+	old    []byte
+	new    []byte
+	ending time.Time // when the overlap ends
+
+	oldKeyFrames uint64 // accessed atomically
+	newKeyFrames uint64 // accessed atomically
+}
+
+// KeyRotationStats are the counters of a KeyRotation
+type KeyRotationStats struct {
+	// NewKeyFrames is the number of frames verified under the new key
+	NewKeyFrames uint64
+	// OldKeyFrames is the number of frames which only verified under the
+	// old key, i.e. written by writers which were not rotated yet
+	OldKeyFrames uint64
+	// OverlapEnds is when the old key stops being accepted
+	OverlapEnds time.Time
+}
+
+// ensure KeyRotation implements KeyProvider at compile-time
+var _ KeyProvider = (*KeyRotation)(nil)
+
+// NewKeyRotation returns a KeyRotation from copies of the old to the new key,
+// accepting frames signed with the old key for the given overlap from now on.
+// Readers and writers use it with WithKeyProvider (with any key ID).
+func NewKeyRotation(oldKey, newKey []byte, overlap time.Duration) *KeyRotation {
+	return (&KeyRotation{
+		overlap: overlap,
+		old:     append([]byte{}, oldKey...),
+		new:     append([]byte{}, newKey...),
+	}).WithClock(SystemClock)
+}
+
+// WithClock sets the Clock the overlap is measured with (default
+// SystemClock), starting the overlap anew, and returns the KeyRotation
+func (r *KeyRotation) WithClock(clock Clock) *KeyRotation {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.clock = clock
+	r.ending = clock.Now().Add(r.overlap)
+	return r
+}
+
+// GetKey returns a copy of the new key, which frames are always signed with
+func (r *KeyRotation) GetKey(ctx context.Context, keyID string) ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]byte{}, r.new...), nil
+}
+
+// candidateKeys returns copies of the new key and, until the overlap ends,
+// of the old key (see candidateKeyProvider)
+func (r *KeyRotation) candidateKeys(ctx context.Context, keyID string) ([][]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	keys := [][]byte{append([]byte{}, r.new...)}
+	if r.old == nil {
+		return keys, nil
+	}
+	if r.clock.Now().After(r.ending) {
+		zeroize(r.old)
+		r.old = nil
+		return keys, nil
+	}
+	return append(keys, append([]byte{}, r.old...)), nil
+}
+
+// verifiedWith counts a frame verified with the candidate key at the given
+// index, i.e. the new key first, then the old one (see keyUsageRecorder)
+func (r *KeyRotation) verifiedWith(index int) {
+	if index == 0 {
+		atomic.AddUint64(&r.newKeyFrames, 1)
+		return
+	}
+	atomic.AddUint64(&r.oldKeyFrames, 1)
+}
+
+// Stats returns the counters of the KeyRotation
+func (r *KeyRotation) Stats() KeyRotationStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return KeyRotationStats{
+		NewKeyFrames: atomic.LoadUint64(&r.newKeyFrames),
+		OldKeyFrames: atomic.LoadUint64(&r.oldKeyFrames),
+		OverlapEnds:  r.ending,
+	}
+}
+
+// EndOverlap stops accepting (and destroys) the old key right away, e.g. once
+// Stats show that no frames verify under it anymore
+func (r *KeyRotation) EndOverlap() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	zeroize(r.old)
+	r.old = nil
+	r.ending = r.clock.Now()
+}
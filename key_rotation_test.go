@@ -0,0 +1,69 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_KeyRotation(t *testing.T) {
+	oldKey := []byte("mock old key")
+	newKey := []byte("mock new key")
+
+	now := time.Unix(1700000000, 0)
+	clock := ClockFunc(func() time.Time { return now })
+	rotation := NewKeyRotation(oldKey, newKey, time.Hour).WithClock(clock)
+
+	sign := func(key []byte, message string) []byte {
+		frame := &bytes.Buffer{}
+		_, err := NewWriter(frame, key).Write([]byte(message))
+		assert.NoError(t, err)
+		return frame.Bytes()
+	}
+	verify := func(frame []byte) (string, error) {
+		message, err := NewVerifyMACReader(bytes.NewReader(frame), nil, WithKeyProvider(rotation, "")).Next()
+		return string(message), err
+	}
+
+	// writers always sign with the new key
+	signed := &bytes.Buffer{}
+	_, err := NewWriter(signed, nil, WithKeyProvider(rotation, "")).Write([]byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, sign(newKey, "hello"), signed.Bytes())
+
+	// readers accept either key during the overlap
+	message, err := verify(sign(newKey, "new"))
+	assert.NoError(t, err)
+	assert.Equal(t, "new", message)
+	message, err = verify(sign(oldKey, "old"))
+	assert.NoError(t, err)
+	assert.Equal(t, "old", message)
+	_, err = verify(sign([]byte("mock other key"), "other"))
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+
+	stats := rotation.Stats()
+	assert.Equal(t, uint64(1), stats.NewKeyFrames)
+	assert.Equal(t, uint64(1), stats.OldKeyFrames)
+	assert.Equal(t, now.Add(time.Hour), stats.OverlapEnds)
+
+	// the old key is rejected once the overlap ends
+	now = now.Add(time.Hour + time.Second)
+	_, err = verify(sign(oldKey, "old"))
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+	_, err = verify(sign(newKey, "new"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), rotation.Stats().OldKeyFrames)
+
+	// the overlap can be ended early
+	rotation = NewKeyRotation(oldKey, newKey, time.Hour).WithClock(clock)
+	rotation.EndOverlap()
+	_, err = verify(sign(oldKey, "old"))
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+
+	// the caller's keys are copied, never destroyed
+	assert.Equal(t, []byte("mock old key"), oldKey)
+}
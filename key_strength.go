@@ -0,0 +1,45 @@
+package authio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultMinKeyLength is the recommended minimum key length (in bytes)
+	DefaultMinKeyLength = 16
+)
+
+// ErrWeakKey is returned for keys which are too short or well-known placeholders
+var ErrWeakKey = errors.New("weak key")
+
+// placeholderKeys are well-known (e.g. documentation example) keys,
+// compared case insensitively, which must never be used for real
+var placeholderKeys = map[string]bool{
+	"mysupersecretpassword": true,
+	"supersecretpassword":   true,
+	"changeme":              true,
+	"change-me":             true,
+	"changeit":              true,
+	"password":              true,
+	"secret":                true,
+	"secretkey":             true,
+	"mysecretkey":           true,
+	"your-secret-key":       true,
+	"default":               true,
+	"test":                  true,
+	"example":               true,
+}
+
+// CheckKey returns an error wrapping ErrWeakKey if the given key is shorter
+// than the given minimum length (in bytes) or a well-known placeholder value
+func CheckKey(key []byte, minLength int) error {
+	if len(key) < minLength {
+		return fmt.Errorf("%w: got %d bytes and expected at least %d", ErrWeakKey, len(key), minLength)
+	}
+	if placeholderKeys[strings.ToLower(string(key))] {
+		return fmt.Errorf("%w: key is a well-known placeholder value", ErrWeakKey)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_CheckKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         []byte
+		expectError bool
+	}{
+		{
+			name: "Strong key",
+			key:  []byte("k3Q9vX2pL7mN4rT8wZ1yB6cF"),
+		},
+		{
+			name:        "Short key",
+			key:         []byte("short"),
+			expectError: true,
+		},
+		{
+			name:        "Empty key",
+			key:         []byte{},
+			expectError: true,
+		},
+		{
+			name:        "Placeholder key",
+			key:         []byte("MySuperSecretPassword"),
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckKey(test.key, DefaultMinKeyLength)
+			if test.expectError {
+				assert.True(t, errors.Is(err, ErrWeakKey))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_WithMinKeyLength(t *testing.T) {
+	weakKey := []byte("mysupersecretpassword")
+
+	// without strict mode weak keys are (still) accepted
+	_, err := NewAppendMACWriter(&bytes.Buffer{}, weakKey).Write([]byte("hello"))
+	assert.NoError(t, err)
+
+	_, err = NewAppendMACWriter(&bytes.Buffer{}, weakKey, WithMinKeyLength(DefaultMinKeyLength)).Write([]byte("hello"))
+	assert.True(t, errors.Is(err, ErrWeakKey))
+
+	_, err = NewVerifyMACReader(&bytes.Buffer{}, weakKey, WithMinKeyLength(DefaultMinKeyLength)).Read(make([]byte, 8))
+	assert.True(t, errors.Is(err, ErrWeakKey))
+
+	_, err = NewPacketConn(nil, weakKey, WithMinKeyLength(DefaultMinKeyLength)).WriteTo([]byte("hello"), &net.UDPAddr{})
+	assert.True(t, errors.Is(err, ErrWeakKey))
+
+	_, err = NewAppendMACWriter(&bytes.Buffer{}, nil, WithKeyProvider(StaticKey(weakKey), ""), WithMinKeyLength(DefaultMinKeyLength)).Write([]byte("hello"))
+	assert.True(t, errors.Is(err, ErrWeakKey))
+}
@@ -0,0 +1,111 @@
+// Package keyprovider implements authio.KeyProviders backed by external key
+// management systems. To keep dependencies light, nothing in here depends on
+// a cloud provider SDK: data keys encrypted by a KMS (e.g. AWS KMS or GCP KMS)
+// are decrypted with a DecryptFunc wrapping the caller's own client, and the
+// vault subpackage talks to HashiCorp Vault's HTTP API directly.
+package keyprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adrianosela/authio"
+)
+
+// Cache is an authio.KeyProvider which caches the keys
+// of another authio.KeyProvider for a fixed time to live
+type Cache struct {
+	provider authio.KeyProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	key     []byte
+	expires time.Time
+}
+
+// ensure Cache implements authio.KeyProvider at compile-time
+var _ authio.KeyProvider = (*Cache)(nil)
+
+// NewCache returns a new Cache for the given authio.KeyProvider. Keys are
+// fetched again from it once they are older than the given time to live.
+func NewCache(provider authio.KeyProvider, ttl time.Duration) *Cache {
+	return &Cache{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]cacheEntry),
+	}
+}
+
+// WithClock sets the Clock the expiry of cached keys is
+// checked with (default authio.SystemClock) and returns the Cache
+func (c *Cache) WithClock(clock authio.Clock) *Cache {
+	c.now = clock.Now
+	return c
+}
+
+// GetKey returns the cached key with the given ID, fetching it if
+// it is not cached yet or expired. Note that the lock is held while
+// fetching, so concurrent lookups do not fetch the same key twice.
+func (c *Cache) GetKey(ctx context.Context, keyID string) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if entry, ok := c.entries[keyID]; ok && c.now().Before(entry.expires) {
+		return entry.key, nil
+	}
+
+	key, err := c.provider.GetKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	c.entries[keyID] = cacheEntry{key: key, expires: c.now().Add(c.ttl)}
+	return key, nil
+}
+
+// DecryptFunc decrypts a data key encrypted by a key management system,
+// e.g. for AWS KMS:
+//
+//	func(ctx context.Context, ciphertext []byte) ([]byte, error) {
+//		out, err := client.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: ciphertext})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return out.Plaintext, nil
+//	}
+//
+// or for GCP KMS:
+//
+//	func(ctx context.Context, ciphertext []byte) ([]byte, error) {
+//		resp, err := client.Decrypt(ctx, &kmspb.DecryptRequest{Name: keyName, Ciphertext: ciphertext})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return resp.Plaintext, nil
+//	}
+type DecryptFunc func(ctx context.Context, ciphertext []byte) ([]byte, error)
+
+// NewEnvelope returns an authio.KeyProvider for data keys encrypted
+// by a key management system (i.e. envelope encryption). The data key
+// with a given ID is decrypted with the given DecryptFunc and cached
+// for the given time to live.
+func NewEnvelope(decrypt DecryptFunc, encryptedKeys map[string][]byte, ttl time.Duration) *Cache {
+	return NewCache(authio.KeyProviderFunc(func(ctx context.Context, keyID string) ([]byte, error) {
+		ciphertext, ok := encryptedKeys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", keyID)
+		}
+		key, err := decrypt(ctx, ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key %q: %w", keyID, err)
+		}
+		return key, nil
+	}), ttl)
+}
@@ -0,0 +1,81 @@
+package keyprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_NewEnvelope(t *testing.T) {
+	now := time.Unix(0, 0)
+	decrypts := 0
+	decrypt := func(_ context.Context, ciphertext []byte) ([]byte, error) {
+		decrypts++
+		if string(ciphertext) == "bad ciphertext" {
+			return nil, errors.New("mock error")
+		}
+		return append([]byte("decrypted "), ciphertext...), nil
+	}
+
+	p := NewEnvelope(decrypt, map[string][]byte{"good": []byte("ciphertext"), "bad": []byte("bad ciphertext")}, time.Minute).
+		WithClock(authio.ClockFunc(func() time.Time { return now }))
+
+	tests := []struct {
+		name             string
+		keyID            string
+		elapsed          time.Duration
+		expectedKey      []byte
+		expectedDecrypts int
+		expectError      bool
+	}{
+		{
+			name:             "First lookup",
+			keyID:            "good",
+			expectedKey:      []byte("decrypted ciphertext"),
+			expectedDecrypts: 1,
+		},
+		{
+			name:             "Cached",
+			keyID:            "good",
+			elapsed:          time.Second,
+			expectedKey:      []byte("decrypted ciphertext"),
+			expectedDecrypts: 1,
+		},
+		{
+			name:             "Expired",
+			keyID:            "good",
+			elapsed:          time.Minute,
+			expectedKey:      []byte("decrypted ciphertext"),
+			expectedDecrypts: 2,
+		},
+		{
+			name:             "Unknown key ID",
+			keyID:            "unknown",
+			expectedDecrypts: 2,
+			expectError:      true,
+		},
+		{
+			name:             "Decrypt failure",
+			keyID:            "bad",
+			expectedDecrypts: 3,
+			expectError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now = now.Add(test.elapsed)
+			key, err := p.GetKey(context.Background(), test.keyID)
+			if test.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedKey, key)
+			}
+			assert.Equal(t, test.expectedDecrypts, decrypts)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package vault implements an authio.KeyProvider which reads keys from
+// the HashiCorp Vault KV (version 2) secrets engine over its HTTP API.
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/adrianosela/authio"
+)
+
+const (
+	// DefaultMount is the default mount path of the KV secrets engine
+	DefaultMount = "secret"
+	// DefaultField is the default field of the secret holding the key
+	DefaultField = "key"
+
+	tokenHeaderName = "X-Vault-Token"
+)
+
+// Option represents a configuration option for a KeyProvider
+type Option func(*KeyProvider)
+
+// WithMount sets the mount path of the KV secrets engine (default DefaultMount)
+func WithMount(mount string) Option {
+	return func(p *KeyProvider) { p.mount = strings.Trim(mount, "/") }
+}
+
+// WithField sets the field of the secret holding the key (default DefaultField)
+func WithField(field string) Option {
+	return func(p *KeyProvider) { p.field = field }
+}
+
+// WithHTTPClient sets the http.Client used to talk to Vault (default http.DefaultClient)
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *KeyProvider) { p.client = client }
+}
+
+// KeyProvider is an authio.KeyProvider which reads the key with a given
+// ID from the latest version of the KV secret at the path of the same
+// name. Wrap it in a keyprovider.Cache to avoid a request per message.
+type KeyProvider struct {
+	address string
+	token   string
+	mount   string
+	field   string
+	client  *http.Client
+}
+
+// ensure KeyProvider implements authio.KeyProvider at compile-time
+var _ authio.KeyProvider = (*KeyProvider)(nil)
+
+// New returns a new KeyProvider for the Vault server at the
+// given address (e.g. https://vault.example.com:8200) and token
+func New(address, token string, opts ...Option) *KeyProvider {
+	p := &KeyProvider{
+		address: strings.TrimRight(address, "/"),
+		token:   token,
+		mount:   DefaultMount,
+		field:   DefaultField,
+		client:  http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// GetKey reads the key with the given ID from Vault
+func (p *KeyProvider) GetKey(ctx context.Context, keyID string) ([]byte, error) {
+	u := fmt.Sprintf("%s/v1/%s/data/%s", p.address, p.mount, url.PathEscape(keyID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set(tokenHeaderName, p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %q: %w", keyID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read secret %q: unexpected status %s", keyID, resp.Status)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("failed to decode secret %q: %w", keyID, err)
+	}
+
+	key, ok := secret.Data.Data[p.field]
+	if !ok || key == "" {
+		return nil, fmt.Errorf("secret %q has no field %q", keyID, p.field)
+	}
+	return []byte(key), nil
+}
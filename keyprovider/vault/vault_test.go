@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_KeyProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(tokenHeaderName) != "mock token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/authio":
+			w.Write([]byte(`{"data":{"data":{"key":"mock key"},"metadata":{"version":3}}}`))
+		case "/v1/secret/data/nokey":
+			w.Write([]byte(`{"data":{"data":{"other":"value"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		token       string
+		keyID       string
+		expectedKey []byte
+		expectError bool
+	}{
+		{
+			name:        "Existing secret",
+			token:       "mock token",
+			keyID:       "authio",
+			expectedKey: []byte("mock key"),
+		},
+		{
+			name:        "Missing field",
+			token:       "mock token",
+			keyID:       "nokey",
+			expectError: true,
+		},
+		{
+			name:        "Missing secret",
+			token:       "mock token",
+			keyID:       "missing",
+			expectError: true,
+		},
+		{
+			name:        "Bad token",
+			token:       "other token",
+			keyID:       "authio",
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := New(server.URL, test.token).GetKey(context.Background(), test.keyID)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedKey, key)
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package authio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CurrentKeyID is the key ID which refers to the newest valid key of a
+// Keyring, such that readers and writers configured WithKeyProvider(keyring,
+// CurrentKeyID) follow scheduled rotations (see Keyring.AddWithValidity)
+const CurrentKeyID = ""
+
+var (
+	// ErrKeyExpired is returned (wrapped) for keys looked up
+	// past their validity window (plus any grace period)
+	ErrKeyExpired = errors.New("key expired")
+	// ErrKeyNotYetValid is returned (wrapped) for keys looked up
+	// before their validity window (minus any grace period)
+	ErrKeyNotYetValid = errors.New("key not yet valid")
+)
+
+// Keyring is a KeyProvider holding keys in memory by key ID, which can
+// be destroyed (i.e. overwritten with zeros) once no longer needed to
+// bound how long key material lives in memory. Keys may carry a validity
+// window, outside of which they are rejected.
+type Keyring struct {
+	clock      Clock
+	grace      time.Duration
+	warnBefore time.Duration
+	onExpiring func(keyID string, notAfter time.Time)
+
+	lock sync.Mutex
+	keys map[string]*keyringEntry
+}
+
+// keyringEntry is a key of a Keyring
+type keyringEntry struct {
+	key       []byte
+	notBefore time.Time // zero means valid since forever
+	notAfter  time.Time // zero means valid forever
+	warned    bool      // whether onExpiring was called for the key
+}
+
+// ensure Keyring implements KeyProvider at compile-time
+var _ KeyProvider = (*Keyring)(nil)
+
+// NewKeyring returns a new empty Keyring
+func NewKeyring() *Keyring {
+	return &Keyring{clock: SystemClock, keys: make(map[string]*keyringEntry)}
+}
+
+// WithClock sets the Clock the validity of keys is checked
+// with (default SystemClock) and returns the Keyring
+func (k *Keyring) WithClock(clock Clock) *Keyring {
+	k.clock = clock
+	return k
+}
+
+// WithGracePeriod makes the Keyring accept keys for verification for the given
+// time before and after their validity window, to tolerate clock skew between
+// peers and messages in flight during rotations, and returns the Keyring.
+// Writers only ever sign with keys within their validity window.
+func (k *Keyring) WithGracePeriod(grace time.Duration) *Keyring {
+	k.grace = grace
+	return k
+}
+
+// WithExpiryWarning makes the Keyring call the given function (once per key)
+// when a key is looked up less than the given time before it expires, e.g. to
+// alert that the next key must be added, and returns the Keyring
+func (k *Keyring) WithExpiryWarning(before time.Duration, onExpiring func(keyID string, notAfter time.Time)) *Keyring {
+	k.warnBefore = before
+	k.onExpiring = onExpiring
+	return k
+}
+
+// Add adds a copy of the given key to the Keyring, valid forever,
+// destroying any key previously added with the same key ID
+func (k *Keyring) Add(keyID string, key []byte) {
+	k.AddWithValidity(keyID, key, time.Time{}, time.Time{})
+}
+
+// AddWithValidity adds a copy of the given key to the Keyring, valid from
+// notBefore until notAfter (either of which may be zero for no bound),
+// destroying any key previously added with the same key ID. Scheduled
+// rotations are set up by adding the next key ahead of time, with a
+// validity window starting where that of the current key ends.
+func (k *Keyring) AddWithValidity(keyID string, key []byte, notBefore, notAfter time.Time) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if entry, ok := k.keys[keyID]; ok {
+		zeroize(entry.key)
+	}
+	k.keys[keyID] = &keyringEntry{
+		key:       append([]byte{}, key...),
+		notBefore: notBefore,
+		notAfter:  notAfter,
+	}
+}
+
+// GetKey returns a copy of the key with the given ID, or of the newest
+// valid key (i.e. the one with the latest validity window start) for
+// CurrentKeyID. Keys outside their validity window (give or take the
+// grace period, for any key ID but CurrentKeyID) are rejected.
+func (k *Keyring) GetKey(ctx context.Context, keyID string) ([]byte, error) {
+	k.lock.Lock()
+	now := k.clock.Now()
+
+	var entry *keyringEntry
+	var err error
+	if keyID == CurrentKeyID {
+		keyID, entry, err = k.newest(now)
+	} else {
+		entry, err = k.lookup(keyID, now)
+	}
+	if err != nil {
+		k.lock.Unlock()
+		return nil, err
+	}
+	key := append([]byte{}, entry.key...)
+	warn := k.checkExpiry(entry, now)
+	k.lock.Unlock()
+
+	if warn {
+		k.onExpiring(keyID, entry.notAfter)
+	}
+	return key, nil
+}
+
+// candidateKeys returns copies of the keys frames authenticated with the
+// given key ID may have been authenticated with, the most likely first:
+// for CurrentKeyID that is every key valid within the grace period, such
+// that frames signed with the previous (or next) key are still accepted
+// by readers whose clock is slightly ahead of (or behind) that of writers
+func (k *Keyring) candidateKeys(ctx context.Context, keyID string) ([][]byte, error) {
+	if keyID != CurrentKeyID {
+		key, err := k.GetKey(ctx, keyID)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{key}, nil
+	}
+
+	k.lock.Lock()
+	now := k.clock.Now()
+	newestID, newest, err := k.newest(now)
+	if err != nil && !errors.Is(err, ErrKeyExpired) {
+		k.lock.Unlock()
+		return nil, err
+	}
+	keys := [][]byte{}
+	if newest != nil {
+		keys = append(keys, append([]byte{}, newest.key...))
+	}
+	others := []*keyringEntry{}
+	for id, entry := range k.keys {
+		if id != newestID && entry.validAt(now, k.grace) {
+			others = append(others, entry)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].notBefore.After(others[j].notBefore) })
+	for _, entry := range others {
+		keys = append(keys, append([]byte{}, entry.key...))
+	}
+	warn := newest != nil && k.checkExpiry(newest, now)
+	k.lock.Unlock()
+
+	if warn {
+		k.onExpiring(newestID, newest.notAfter)
+	}
+	if len(keys) == 0 {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// lookup returns the key with the given ID if it is valid within the
+// grace period at the given time, the lock must be held
+func (k *Keyring) lookup(keyID string, now time.Time) (*keyringEntry, error) {
+	entry, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", keyID)
+	}
+	if !entry.notBefore.IsZero() && now.Before(entry.notBefore.Add(-k.grace)) {
+		return nil, fmt.Errorf("%w: key %q is valid from %s", ErrKeyNotYetValid, keyID, entry.notBefore)
+	}
+	if !entry.notAfter.IsZero() && now.After(entry.notAfter.Add(k.grace)) {
+		return nil, fmt.Errorf("%w: key %q was valid until %s", ErrKeyExpired, keyID, entry.notAfter)
+	}
+	return entry, nil
+}
+
+// newest returns the ID of and the newest key valid (without grace
+// period) at the given time, the lock must be held
+func (k *Keyring) newest(now time.Time) (string, *keyringEntry, error) {
+	newestID := ""
+	var newest *keyringEntry
+	for id, entry := range k.keys {
+		if !entry.validAt(now, 0) {
+			continue
+		}
+		if newest == nil || entry.notBefore.After(newest.notBefore) ||
+			(entry.notBefore.Equal(newest.notBefore) && id > newestID) {
+			newestID, newest = id, entry
+		}
+	}
+	if newest == nil {
+		return "", nil, fmt.Errorf("%w: no valid key", ErrKeyExpired)
+	}
+	return newestID, newest, nil
+}
+
+// checkExpiry returns whether onExpiring must be called for the given key
+// (marking it as warned), the lock must be held
+func (k *Keyring) checkExpiry(entry *keyringEntry, now time.Time) bool {
+	if k.onExpiring == nil || entry.warned || entry.notAfter.IsZero() {
+		return false
+	}
+	if now.Add(k.warnBefore).Before(entry.notAfter) {
+		return false
+	}
+	entry.warned = true
+	return true
+}
+
+// validAt returns whether the key is valid at the given time, give or take the given grace period
+func (e *keyringEntry) validAt(now time.Time, grace time.Duration) bool {
+	if !e.notBefore.IsZero() && now.Before(e.notBefore.Add(-grace)) {
+		return false
+	}
+	return e.notAfter.IsZero() || !now.After(e.notAfter.Add(grace))
+}
+
+// Destroy overwrites the key with the given ID with zeros and removes
+// it from the Keyring. Note that copies previously returned by GetKey
+// are not affected, readers and writers destroy theirs on Close.
+func (k *Keyring) Destroy(keyID string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if entry, ok := k.keys[keyID]; ok {
+		zeroize(entry.key)
+	}
+	delete(k.keys, keyID)
+}
+
+// destroyer is implemented by MessageAuthenticators
+// which can destroy the key material they hold
+type destroyer interface {
+	Destroy()
+}
+
+// destroyKey destroys the key material held by the given
+// MessageAuthenticator, if it supports doing so
+func destroyKey(a any) {
+	if d, ok := a.(destroyer); ok {
+		d.Destroy()
+	}
+}
+
+// zeroize overwrites the given bytes with zeros
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
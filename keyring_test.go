@@ -0,0 +1,187 @@
+package authio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_KeyringDestroy(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	k := NewKeyring()
+	k.Add("current", mockKey)
+
+	key, err := k.GetKey(context.Background(), "current")
+	assert.NoError(t, err)
+	assert.Equal(t, mockKey, key)
+
+	k.Destroy("current")
+	_, err = k.GetKey(context.Background(), "current")
+	assert.Error(t, err)
+
+	// copies are destroyed, never the caller's key
+	assert.Equal(t, []byte("mock key"), mockKey)
+	assert.Equal(t, []byte("mock key"), key)
+}
+
+func Test_CloseDestroysKey(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	w := NewAppendMACWriter(&bytes.Buffer{}, mockKey)
+	_, err := w.Write([]byte("hello"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.Close())
+	_, err = w.Write([]byte("hello"))
+	assert.True(t, errors.Is(err, authenticator.ErrDestroyed))
+	assert.Equal(t, []byte("mock key"), mockKey)
+
+	r := NewVerifyMACReader(&bytes.Buffer{}, mockKey)
+	assert.NoError(t, r.Close())
+	_, err = r.Read(make([]byte, 8))
+	assert.True(t, errors.Is(err, authenticator.ErrDestroyed))
+}
+
+func Test_KeyringValidity(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	now := start
+	warnings := []string{}
+
+	k := NewKeyring().
+		WithClock(ClockFunc(func() time.Time { return now })).
+		WithGracePeriod(time.Minute).
+		WithExpiryWarning(time.Hour, func(keyID string, _ time.Time) { warnings = append(warnings, keyID) })
+	k.AddWithValidity("old", []byte("old key"), time.Time{}, start.Add(24*time.Hour))
+	k.AddWithValidity("new", []byte("new key"), start.Add(24*time.Hour), start.Add(48*time.Hour))
+
+	tests := []struct {
+		name          string
+		elapsed       time.Duration
+		keyID         string
+		expectedKey   string
+		expectedErr   error
+		expectWarning []string
+	}{
+		{
+			name:        "Current key",
+			keyID:       CurrentKeyID,
+			expectedKey: "old key",
+		},
+		{
+			name:        "Next key not yet valid",
+			keyID:       "new",
+			expectedErr: ErrKeyNotYetValid,
+		},
+		{
+			name:          "Current key about to expire",
+			elapsed:       23*time.Hour + 30*time.Minute,
+			keyID:         CurrentKeyID,
+			expectedKey:   "old key",
+			expectWarning: []string{"old"},
+		},
+		{
+			name:          "Warnings are only given once",
+			elapsed:       23*time.Hour + 45*time.Minute,
+			keyID:         "old",
+			expectedKey:   "old key",
+			expectWarning: []string{"old"},
+		},
+		{
+			name:          "Next key within grace period",
+			elapsed:       24*time.Hour - 30*time.Second,
+			keyID:         "new",
+			expectedKey:   "new key",
+			expectWarning: []string{"old"},
+		},
+		{
+			name:          "Rotated",
+			elapsed:       24 * time.Hour,
+			keyID:         CurrentKeyID,
+			expectedKey:   "new key",
+			expectWarning: []string{"old"},
+		},
+		{
+			name:          "Previous key within grace period",
+			elapsed:       24*time.Hour + 30*time.Second,
+			keyID:         "old",
+			expectedKey:   "old key",
+			expectWarning: []string{"old"},
+		},
+		{
+			name:          "Previous key expired",
+			elapsed:       25 * time.Hour,
+			keyID:         "old",
+			expectedErr:   ErrKeyExpired,
+			expectWarning: []string{"old"},
+		},
+		{
+			name:          "No valid key",
+			elapsed:       72 * time.Hour,
+			keyID:         CurrentKeyID,
+			expectedErr:   ErrKeyExpired,
+			expectWarning: []string{"old"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now = start.Add(test.elapsed)
+			key, err := k.GetKey(context.Background(), test.keyID)
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedKey, string(key))
+			}
+			assert.Equal(t, append([]string{}, test.expectWarning...), warnings)
+		})
+	}
+}
+
+func Test_KeyringRotation(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	writerNow, readerNow := start, start
+
+	newKeyring := func(now *time.Time) *Keyring {
+		k := NewKeyring().WithClock(ClockFunc(func() time.Time { return *now })).WithGracePeriod(time.Minute)
+		k.AddWithValidity("old", []byte("old key"), time.Time{}, start.Add(time.Hour))
+		k.AddWithValidity("new", []byte("new key"), start.Add(time.Hour), time.Time{})
+		return k
+	}
+
+	stream := &bytes.Buffer{}
+	w := NewAppendMACWriter(stream, nil, WithKeyProvider(newKeyring(&writerNow), CurrentKeyID))
+	r := NewVerifyMACReader(stream, nil, WithKeyProvider(newKeyring(&readerNow), CurrentKeyID))
+
+	buf := make([]byte, 64)
+	for _, step := range []struct {
+		writerElapsed time.Duration
+		readerElapsed time.Duration
+		expectErr     bool
+	}{
+		{writerElapsed: 0, readerElapsed: 0},
+		// the writer rotated, the reader's clock is slightly behind
+		{writerElapsed: time.Hour, readerElapsed: time.Hour - 30*time.Second},
+		// the writer's clock is slightly behind, the reader rotated
+		{writerElapsed: time.Hour - 30*time.Second, readerElapsed: time.Hour},
+		{writerElapsed: 2 * time.Hour, readerElapsed: 2 * time.Hour},
+		// the writer's clock is too far behind
+		{writerElapsed: time.Hour - 30*time.Second, readerElapsed: 2 * time.Hour, expectErr: true},
+	} {
+		writerNow, readerNow = start.Add(step.writerElapsed), start.Add(step.readerElapsed)
+		_, err := w.Write([]byte("hello"))
+		assert.NoError(t, err)
+		n, err := r.Read(buf)
+		if step.expectErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(buf[:n]))
+	}
+}
@@ -0,0 +1,55 @@
+package authio
+
+import "net"
+
+// Listener is a net.Listener which wraps every accepted net.Conn
+// in a Conn, as the server side (see NewServerConn)
+type Listener struct {
+	net.Listener // underlying net.Listener to accept from
+
+	key     []byte
+	opts    []Option
+	limiter *FailureLimiter
+}
+
+// ensure Listener implements net.Listener at compile-time
+var _ net.Listener = (*Listener)(nil)
+
+// NewListener wraps a net.Listener in a Listener, whose
+// Conns are created with the given key and options
+func NewListener(l net.Listener, key []byte, opts ...Option) *Listener {
+	return &Listener{Listener: l, key: key, opts: opts, limiter: newConfig(opts...).failureLimiter}
+}
+
+// Listen announces on the given network address (see net.Listen)
+// and returns a Listener whose Conns are created with the given
+// key and options
+func Listen(network, address string, key []byte, opts ...Option) (*Listener, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewListener(l, key, opts...), nil
+}
+
+// Accept waits for and returns the next connection (a *Conn)
+func (l *Listener) Accept() (net.Conn, error) {
+	return l.AcceptConn()
+}
+
+// AcceptConn waits for and returns the next connection. Connections of
+// peers banned by the FailureLimiter set with WithFailureLimiter (if any)
+// are closed as soon as they are accepted.
+func (l *Listener) AcceptConn() (*Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.limiter != nil && l.limiter.Banned(conn.RemoteAddr()) {
+			conn.Close()
+			continue
+		}
+		return NewServerConn(conn, l.key, l.opts...), nil
+	}
+}
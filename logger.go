@@ -0,0 +1,18 @@
+package authio
+
+// Logger is a minimal structured logging interface. Arguments
+// following the message are alternating key-value pairs, so
+// *slog.Logger satisfies it as-is.
+type Logger interface {
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+}
+
+// nopLogger is a Logger that discards all log lines
+type nopLogger struct{}
+
+// ensure nopLogger implements Logger at compile-time
+var _ Logger = (*nopLogger)(nil)
+
+func (nopLogger) Info(string, ...any) {}
+func (nopLogger) Warn(string, ...any) {}
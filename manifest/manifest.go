@@ -0,0 +1,328 @@
+// Package manifest signs and verifies directory trees, e.g. build artifacts
+// in deploy pipelines. A manifest lists the MAC of every regular file in the
+// tree (by path, in the order fs.WalkDir visits them), followed by a root
+// MAC over a Merkle tree of those entries, such that adding, removing,
+// renaming, or modifying any file (or manifest entry) is detected. Both
+// writing and verifying manifests stream files and the manifest itself,
+// holding only a logarithmic number of tree nodes in memory.
+package manifest
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// rootPrefix starts the last line of a manifest, which holds the root MAC
+	rootPrefix = "root "
+
+	// domain separation of the hashes of leaves and nodes of the Merkle tree
+	leafPrefix = byte(0)
+	nodePrefix = byte(1)
+
+	// rootLabel is bound into root MACs
+	rootLabel = "authio manifest v1"
+)
+
+var (
+	// ErrMACMismatch is returned (wrapped) when a file does not match its
+	// MAC, or the manifest does not match its root MAC
+	ErrMACMismatch = authenticator.ErrMACMismatch
+	// ErrMissingFile is returned (wrapped) when a file in the manifest is not in the tree
+	ErrMissingFile = errors.New("missing file")
+	// ErrUnexpectedFile is returned (wrapped) when a file in the tree is not in the manifest
+	ErrUnexpectedFile = errors.New("unexpected file")
+	// ErrMalformedManifest is returned (wrapped) when a manifest cannot be parsed
+	ErrMalformedManifest = errors.New("malformed manifest")
+)
+
+// Option represents a configuration option for writing and verifying manifests
+type Option func(*config)
+
+type config struct {
+	hashFn  func() hash.Hash
+	exclude []string
+}
+
+func newConfig(opts ...Option) *config {
+	c := &config{hashFn: sha256.New}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithHashFn sets the hash function used to compute MACs (default SHA-256)
+func WithHashFn(hashFn func() hash.Hash) Option {
+	return func(c *config) { c.hashFn = hashFn }
+}
+
+// WithExclude excludes the files (and directories) whose paths match any of
+// the given patterns (see path.Match) from the manifest, e.g. the manifest
+// itself when it is stored within the tree
+func WithExclude(patterns ...string) Option {
+	return func(c *config) { c.exclude = append(c.exclude, patterns...) }
+}
+
+// Entry is a file listed in a manifest
+type Entry struct {
+	Path string
+	Size int64
+	MAC  string // base64 encoded
+}
+
+// Write walks the given tree and writes a manifest of it to the given writer,
+// returning the root MAC (which is also the last line of the manifest)
+func Write(w io.Writer, fsys fs.FS, key []byte, opts ...Option) (string, error) {
+	c := newConfig(opts...)
+	tree := &merkleTree{hashFn: c.hashFn}
+	bw := bufio.NewWriter(w)
+
+	err := c.walk(fsys, func(p string) error {
+		if strings.ContainsAny(p, "\r\n") {
+			return fmt.Errorf("unsupported file name %q", p)
+		}
+		entry, err := c.computeEntry(fsys, p, key)
+		if err != nil {
+			return err
+		}
+		tree.add(c.leaf(entry))
+		_, err = fmt.Fprintf(bw, "%s %d %s\n", entry.MAC, entry.Size, entry.Path)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	root := c.rootMAC(key, tree.root())
+	if _, err := fmt.Fprintf(bw, "%s%s\n", rootPrefix, root); err != nil {
+		return "", err
+	}
+	if err := bw.Flush(); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return root, nil
+}
+
+// Verify walks the given tree and verifies it against the manifest read
+// from the given reader, returning the first discrepancy found (an error
+// wrapping ErrMACMismatch, ErrMissingFile, or ErrUnexpectedFile)
+func Verify(r io.Reader, fsys fs.FS, key []byte, opts ...Option) error {
+	c := newConfig(opts...)
+	tree := &merkleTree{hashFn: c.hashFn}
+	manifest := &manifestReader{scanner: bufio.NewScanner(r)}
+
+	err := c.walk(fsys, func(p string) error {
+		expected, err := manifest.peek()
+		if err != nil {
+			return err
+		}
+		if expected != nil && comparePaths(expected.Path, p) < 0 {
+			return fmt.Errorf("%w: %s", ErrMissingFile, expected.Path)
+		}
+		if expected == nil || expected.Path != p {
+			return fmt.Errorf("%w: %s", ErrUnexpectedFile, p)
+		}
+		manifest.next()
+
+		entry, err := c.computeEntry(fsys, p, key)
+		if err != nil {
+			return err
+		}
+		if entry.Size != expected.Size || !hmac.Equal([]byte(entry.MAC), []byte(expected.MAC)) {
+			return fmt.Errorf("%w: %s", ErrMACMismatch, p)
+		}
+		tree.add(c.leaf(*expected))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	expected, err := manifest.peek()
+	if err != nil {
+		return err
+	}
+	if expected != nil {
+		return fmt.Errorf("%w: %s", ErrMissingFile, expected.Path)
+	}
+	if !hmac.Equal([]byte(manifest.root), []byte(c.rootMAC(key, tree.root()))) {
+		return fmt.Errorf("%w: root", ErrMACMismatch)
+	}
+	return nil
+}
+
+// walk calls the given function with the path of every regular file of
+// the given tree which is not excluded, in the order of fs.WalkDir
+func (c *config) walk(fsys fs.FS, fn func(p string) error) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, pattern := range c.exclude {
+			if matched, _ := path.Match(pattern, p); matched {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(p)
+	})
+}
+
+// computeEntry computes the entry of the file at the given path
+func (c *config) computeEntry(fsys fs.FS, p string, key []byte) (Entry, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer f.Close()
+
+	mac := hmac.New(c.hashFn, key)
+	n, err := io.Copy(mac, f)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	return Entry{Path: p, Size: n, MAC: base64.StdEncoding.EncodeToString(mac.Sum(nil))}, nil
+}
+
+// leaf returns the hash of the Merkle tree leaf of the given entry
+func (c *config) leaf(entry Entry) []byte {
+	h := c.hashFn()
+	h.Write([]byte{leafPrefix})
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(entry.Path))))
+	h.Write([]byte(entry.Path))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(entry.Size)))
+	h.Write([]byte(entry.MAC))
+	return h.Sum(nil)
+}
+
+// rootMAC returns the (base64 encoded) MAC over the root of the Merkle tree
+func (c *config) rootMAC(key, root []byte) string {
+	mac := hmac.New(c.hashFn, key)
+	mac.Write([]byte(rootLabel))
+	mac.Write(root)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// merkleTree computes the root of a Merkle tree (shaped as in RFC 6962) over
+// leaves added one at a time, keeping only the roots of its complete subtrees
+type merkleTree struct {
+	hashFn func() hash.Hash
+	stack  []merkleNode
+}
+
+type merkleNode struct {
+	hash   []byte
+	leaves int // number of leaves under the node, a power of two
+}
+
+// add adds a leaf, merging complete subtrees of the same size
+func (t *merkleTree) add(leaf []byte) {
+	node := merkleNode{hash: leaf, leaves: 1}
+	for len(t.stack) > 0 && t.stack[len(t.stack)-1].leaves == node.leaves {
+		left := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		node = merkleNode{hash: t.node(left.hash, node.hash), leaves: 2 * node.leaves}
+	}
+	t.stack = append(t.stack, node)
+}
+
+// root returns the root of the tree, the hash of nothing for an empty tree
+func (t *merkleTree) root() []byte {
+	if len(t.stack) == 0 {
+		return t.hashFn().Sum(nil)
+	}
+	root := t.stack[len(t.stack)-1].hash
+	for i := len(t.stack) - 2; i >= 0; i-- {
+		root = t.node(t.stack[i].hash, root)
+	}
+	return root
+}
+
+func (t *merkleTree) node(left, right []byte) []byte {
+	h := t.hashFn()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// manifestReader reads the entries of a manifest one at a time
+type manifestReader struct {
+	scanner *bufio.Scanner
+	line    int
+	entry   *Entry // the next entry, if peeked
+	root    string // the root MAC, once read
+	done    bool
+}
+
+// peek returns the next entry without consuming it, or nil
+// (with the root MAC read) once there are no more entries
+func (m *manifestReader) peek() (*Entry, error) {
+	if m.entry != nil || m.done {
+		return m.entry, nil
+	}
+	if !m.scanner.Scan() {
+		if err := m.scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		return nil, fmt.Errorf("%w: missing root MAC", ErrMalformedManifest)
+	}
+	m.line++
+	line := m.scanner.Text()
+
+	if strings.HasPrefix(line, rootPrefix) {
+		m.root = strings.TrimPrefix(line, rootPrefix)
+		m.done = true
+		if m.scanner.Scan() {
+			return nil, fmt.Errorf("%w: line %d: content after root MAC", ErrMalformedManifest, m.line+1)
+		}
+		return nil, nil
+	}
+
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("%w: line %d: expected \"<MAC> <size> <path>\"", ErrMalformedManifest, m.line)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("%w: line %d: invalid size %q", ErrMalformedManifest, m.line, fields[1])
+	}
+	m.entry = &Entry{Path: fields[2], Size: size, MAC: fields[0]}
+	return m.entry, nil
+}
+
+// next consumes the peeked entry
+func (m *manifestReader) next() {
+	m.entry = nil
+}
+
+// comparePaths compares paths in the order fs.WalkDir visits them,
+// i.e. element by element rather than byte by byte
+func comparePaths(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
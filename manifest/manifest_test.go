@@ -0,0 +1,130 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/autarch/testify/assert"
+)
+
+func mockTree() fstest.MapFS {
+	return fstest.MapFS{
+		"a.txt":          {Data: []byte("a")},
+		"a/b.txt":        {Data: []byte("b")},
+		"a/c/d.txt":      {Data: []byte("d")},
+		"bin/app":        {Data: []byte("binary")},
+		"empty":          {Data: []byte{}},
+		"with space.txt": {Data: []byte("space")},
+	}
+}
+
+func Test_WriteVerify(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	manifest := &bytes.Buffer{}
+	root, err := Write(manifest, mockTree(), mockKey)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(manifest.String(), "root "+root+"\n"))
+	assert.Equal(t, 7, strings.Count(manifest.String(), "\n"))
+
+	tests := []struct {
+		name        string
+		tree        func(fstest.MapFS)
+		manifest    func(string) string
+		key         []byte
+		opts        []Option
+		expectedErr error
+	}{
+		{
+			name: "Valid",
+		},
+		{
+			name:        "Modified file",
+			tree:        func(fsys fstest.MapFS) { fsys["a/b.txt"] = &fstest.MapFile{Data: []byte("B")} },
+			expectedErr: ErrMACMismatch,
+		},
+		{
+			name:        "Missing file",
+			tree:        func(fsys fstest.MapFS) { delete(fsys, "a/c/d.txt") },
+			expectedErr: ErrMissingFile,
+		},
+		{
+			name:        "Missing last file",
+			tree:        func(fsys fstest.MapFS) { delete(fsys, "with space.txt") },
+			expectedErr: ErrMissingFile,
+		},
+		{
+			name:        "Unexpected file",
+			tree:        func(fsys fstest.MapFS) { fsys["a/evil.sh"] = &fstest.MapFile{Data: []byte("evil")} },
+			expectedErr: ErrUnexpectedFile,
+		},
+		{
+			name:        "Excluded file",
+			tree:        func(fsys fstest.MapFS) { fsys["MANIFEST"] = &fstest.MapFile{Data: []byte("manifest")} },
+			opts:        []Option{WithExclude("MANIFEST")},
+			expectedErr: nil,
+		},
+		{
+			name: "Entry removed from manifest",
+			tree: func(fsys fstest.MapFS) { delete(fsys, "bin/app") },
+			manifest: func(m string) string {
+				lines := strings.SplitAfter(m, "\n")
+				return strings.Join(append(lines[:3:3], lines[4:]...), "")
+			},
+			expectedErr: ErrMACMismatch,
+		},
+		{
+			name:        "Tampered root",
+			manifest:    func(m string) string { return m[:strings.LastIndex(m, "root ")] + "root AAAA\n" },
+			expectedErr: ErrMACMismatch,
+		},
+		{
+			name:        "Truncated manifest",
+			manifest:    func(m string) string { return m[:strings.LastIndex(m, "root ")] },
+			expectedErr: ErrMalformedManifest,
+		},
+		{
+			name:        "Wrong key",
+			key:         []byte("wrong key"),
+			expectedErr: ErrMACMismatch,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree := mockTree()
+			if test.tree != nil {
+				test.tree(tree)
+			}
+			m := manifest.String()
+			if test.manifest != nil {
+				m = test.manifest(m)
+			}
+			key := mockKey
+			if test.key != nil {
+				key = test.key
+			}
+			err := Verify(strings.NewReader(m), tree, key, test.opts...)
+			if test.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+		})
+	}
+}
+
+func Test_MerkleTree(t *testing.T) {
+	leaves := [][]byte{[]byte("l0"), []byte("l1"), []byte("l2"), []byte("l3"), []byte("l4")}
+	tree := &merkleTree{hashFn: sha256.New}
+	for _, leaf := range leaves {
+		tree.add(leaf)
+	}
+	node := tree.node
+	expected := node(node(node(leaves[0], leaves[1]), node(leaves[2], leaves[3])), leaves[4])
+	assert.Equal(t, expected, tree.root())
+	assert.Equal(t, 2, len(tree.stack), "only the roots of complete subtrees are kept")
+}
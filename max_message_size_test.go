@@ -0,0 +1,78 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_MaxMessageSize(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name           string
+		size           int
+		writerOpts     []Option
+		readerOpts     []Option
+		expectedFrames int
+		expectTooLarge bool
+	}{
+		{
+			name:           "default, at the limit",
+			size:           DefaultMaxMessageSize,
+			expectedFrames: 1,
+		},
+		{
+			name:           "default, split on write",
+			size:           DefaultMaxMessageSize + 1,
+			expectedFrames: 2,
+		},
+		{
+			name:           "custom, split on write",
+			size:           10,
+			writerOpts:     []Option{WithMaxMessageSize(4)},
+			readerOpts:     []Option{WithMaxMessageSize(4)},
+			expectedFrames: 3,
+		},
+		{
+			name:           "no limit on write, rejected on read",
+			size:           10,
+			writerOpts:     []Option{WithMaxMessageSize(0)},
+			readerOpts:     []Option{WithMaxMessageSize(4)},
+			expectedFrames: 1,
+			expectTooLarge: true,
+		},
+		{
+			name:           "no limit either way",
+			size:           DefaultMaxMessageSize + 1,
+			writerOpts:     []Option{WithMaxMessageSize(0)},
+			readerOpts:     []Option{WithMaxMessageSize(0)},
+			expectedFrames: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := bytes.Repeat([]byte("a"), test.size)
+
+			buf := &bytes.Buffer{}
+			n, err := NewAppendMACWriter(buf, mockKey, test.writerOpts...).Write(message)
+			assert.NoError(t, err)
+			assert.Equal(t, test.size, n)
+
+			r := NewVerifyMACReader(buf, mockKey, test.readerOpts...)
+			read, err := io.ReadAll(r)
+			if test.expectTooLarge {
+				var tooLarge *authenticator.MessageTooLargeError
+				assert.True(t, errors.As(err, &tooLarge), "expected %T, got %v", tooLarge, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, bytes.Equal(message, read))
+			assert.Equal(t, uint64(test.expectedFrames), r.frames)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package authio
+
+import (
+	"errors"
+	"io"
+)
+
+// MessageScanner reads verified messages one at a time from a
+// VerifyMACReader, analogous to how a bufio.Scanner reads lines.
+// Scanning stops at the end of the input or at the first error.
+type MessageScanner struct {
+	reader  *VerifyMACReader
+	message []byte
+	err     error
+}
+
+// NewMessageScanner returns a new MessageScanner reading from the given VerifyMACReader
+func NewMessageScanner(reader *VerifyMACReader) *MessageScanner {
+	return &MessageScanner{reader: reader}
+}
+
+// Scan advances the MessageScanner to the next message, which is then available
+// through Message. It returns false once there are no more messages or on error.
+func (s *MessageScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	s.message, s.err = s.reader.Next()
+	if s.err != nil {
+		s.message = nil
+		return false
+	}
+	return true
+}
+
+// Message returns the most recent message read by Scan
+func (s *MessageScanner) Message() []byte {
+	return s.message
+}
+
+// Err returns the first error encountered by the MessageScanner, or nil if
+// the input ended at a message boundary (i.e. with io.EOF)
+func (s *MessageScanner) Err() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+	return s.err
+}
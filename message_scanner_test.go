@@ -0,0 +1,97 @@
+package authio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_MessageScanner(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name             string
+		messages         []string
+		tamper           func(data []byte) []byte
+		expectedMessages []string
+		expectError      bool
+	}{
+		{
+			name:             "No messages",
+			messages:         []string{},
+			expectedMessages: []string{},
+		},
+		{
+			name:             "Multiple messages",
+			messages:         []string{"hello", "", "world"},
+			expectedMessages: []string{"hello", "", "world"},
+		},
+		{
+			name:             "Tampered last message",
+			messages:         []string{"hello", "world"},
+			tamper:           func(data []byte) []byte { data[len(data)-1] = 'D'; return data },
+			expectedMessages: []string{"hello"},
+			expectError:      true,
+		},
+		{
+			name:             "Truncated last message",
+			messages:         []string{"hello", "world"},
+			tamper:           func(data []byte) []byte { return data[:len(data)-1] },
+			expectedMessages: []string{"hello"},
+			expectError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := NewAppendMACWriter(buf, mockKey)
+			for _, msg := range test.messages {
+				_, err := w.Write([]byte(msg))
+				assert.NoError(t, err)
+			}
+			data := buf.Bytes()
+			if test.tamper != nil {
+				data = test.tamper(data)
+			}
+
+			s := NewMessageScanner(NewVerifyMACReader(bytes.NewReader(data), mockKey))
+			messages := []string{}
+			for s.Scan() {
+				messages = append(messages, string(s.Message()))
+			}
+			assert.Equal(t, test.expectedMessages, messages)
+			if test.expectError {
+				assert.Error(t, s.Err())
+			} else {
+				assert.NoError(t, s.Err())
+			}
+		})
+	}
+}
+
+func Test_VerifyMACReaderNextAfterRead(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	buf := &bytes.Buffer{}
+	w := NewAppendMACWriter(buf, mockKey)
+	for _, msg := range []string{"hello world", "bye"} {
+		_, err := w.Write([]byte(msg))
+		assert.NoError(t, err)
+	}
+
+	r := NewVerifyMACReader(buf, mockKey)
+	partial := make([]byte, 6)
+	n, err := r.Read(partial)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello ", string(partial[:n]))
+
+	// the rest of the partially read message comes first
+	msg, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(msg))
+
+	msg, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "bye", string(msg))
+}
@@ -0,0 +1,53 @@
+package metrics
+
+// Metrics represents a sink for message authentication events
+type Metrics interface {
+	// MessageSigned is called for every message a MAC is computed for
+	MessageSigned(size int)
+	// MessageVerified is called for every message with a valid MAC
+	MessageVerified(size int)
+	// VerificationFailed is called for every message that fails MAC verification
+	VerificationFailed()
+}
+
+// Noop is a Metrics implementation that discards all events
+type Noop struct{}
+
+// ensure Noop implements Metrics at compile-time
+var _ Metrics = (*Noop)(nil)
+
+// MessageSigned does nothing
+func (Noop) MessageSigned(int) {}
+
+// MessageVerified does nothing
+func (Noop) MessageVerified(int) {}
+
+// VerificationFailed does nothing
+func (Noop) VerificationFailed() {}
+
+// Multi is a Metrics implementation that fans events out to several Metrics
+type Multi []Metrics
+
+// ensure Multi implements Metrics at compile-time
+var _ Metrics = (*Multi)(nil)
+
+// MessageSigned calls MessageSigned on every Metrics
+func (m Multi) MessageSigned(size int) {
+	for _, metrics := range m {
+		metrics.MessageSigned(size)
+	}
+}
+
+// MessageVerified calls MessageVerified on every Metrics
+func (m Multi) MessageVerified(size int) {
+	for _, metrics := range m {
+		metrics.MessageVerified(size)
+	}
+}
+
+// VerificationFailed calls VerificationFailed on every Metrics
+func (m Multi) VerificationFailed() {
+	for _, metrics := range m {
+		metrics.VerificationFailed()
+	}
+}
@@ -0,0 +1,112 @@
+package prometheus
+
+import (
+	"github.com/adrianosela/authio/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "authio"
+
+	labelConnection = "connection"
+	labelOperation  = "operation"
+
+	operationSign   = "sign"
+	operationVerify = "verify"
+)
+
+// Collector is a prometheus.Collector exposing authio message
+// authentication metrics with a label for every connection
+type Collector struct {
+	messages *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	sizes    *prometheus.HistogramVec
+}
+
+// ensure Collector implements prometheus.Collector at compile-time
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector returns a newly initialized Collector. The given
+// labels are added as constant labels on every exposed metric.
+func NewCollector(constLabels prometheus.Labels) *Collector {
+	return &Collector{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "messages_total",
+			Help:        "Number of messages signed or successfully verified.",
+			ConstLabels: constLabels,
+		}, []string{labelConnection, labelOperation}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "verification_failures_total",
+			Help:        "Number of messages that failed MAC verification.",
+			ConstLabels: constLabels,
+		}, []string{labelConnection}),
+		sizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "message_size_bytes",
+			Help:        "Size of signed or successfully verified messages (excluding MAC header).",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(64, 4, 8),
+		}, []string{labelConnection, labelOperation}),
+	}
+}
+
+// Describe sends the descriptors of all metrics exposed by the Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.messages.Describe(ch)
+	c.failures.Describe(ch)
+	c.sizes.Describe(ch)
+}
+
+// Collect sends the current values of all metrics exposed by the Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.messages.Collect(ch)
+	c.failures.Collect(ch)
+	c.sizes.Collect(ch)
+}
+
+// ForConnection returns a metrics.Metrics recording events for a single connection
+func (c *Collector) ForConnection(connection string) metrics.Metrics {
+	return &connectionMetrics{
+		signed:   c.messages.WithLabelValues(connection, operationSign),
+		verified: c.messages.WithLabelValues(connection, operationVerify),
+		failures: c.failures.WithLabelValues(connection),
+
+		signedSizes:   c.sizes.WithLabelValues(connection, operationSign),
+		verifiedSizes: c.sizes.WithLabelValues(connection, operationVerify),
+	}
+}
+
+// Forget removes all metrics for a connection (e.g. once it has been closed)
+func (c *Collector) Forget(connection string) {
+	c.messages.DeletePartialMatch(prometheus.Labels{labelConnection: connection})
+	c.failures.DeletePartialMatch(prometheus.Labels{labelConnection: connection})
+	c.sizes.DeletePartialMatch(prometheus.Labels{labelConnection: connection})
+}
+
+type connectionMetrics struct {
+	signed   prometheus.Counter
+	verified prometheus.Counter
+	failures prometheus.Counter
+
+	signedSizes   prometheus.Observer
+	verifiedSizes prometheus.Observer
+}
+
+// ensure connectionMetrics implements metrics.Metrics at compile-time
+var _ metrics.Metrics = (*connectionMetrics)(nil)
+
+func (m *connectionMetrics) MessageSigned(size int) {
+	m.signed.Inc()
+	m.signedSizes.Observe(float64(size))
+}
+
+func (m *connectionMetrics) MessageVerified(size int) {
+	m.verified.Inc()
+	m.verifiedSizes.Observe(float64(size))
+}
+
+func (m *connectionMetrics) VerificationFailed() {
+	m.failures.Inc()
+}
@@ -0,0 +1,39 @@
+package prometheus
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio"
+	"github.com/autarch/testify/assert"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_Collector(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	collector := NewCollector(nil)
+	connMetrics := collector.ForConnection("mock-conn")
+
+	buf := &bytes.Buffer{}
+	_, err := authio.NewWriter(buf, mockKey, authio.WithMetrics(connMetrics)).Write([]byte("mock data"))
+	assert.NoError(t, err)
+
+	// corrupt a copy of the authenticated message
+	corrupted := append([]byte{}, buf.Bytes()...)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	_, err = io.ReadAll(authio.NewReader(buf, mockKey, authio.WithMetrics(connMetrics)))
+	assert.NoError(t, err)
+
+	_, err = io.ReadAll(authio.NewReader(bytes.NewReader(corrupted), mockKey, authio.WithMetrics(connMetrics)))
+	assert.Error(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.messages.WithLabelValues("mock-conn", operationSign)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.messages.WithLabelValues("mock-conn", operationVerify)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.failures.WithLabelValues("mock-conn")))
+
+	collector.Forget("mock-conn")
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+}
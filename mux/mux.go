@@ -0,0 +1,412 @@
+package mux
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/adrianosela/authio"
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// DefaultAcceptBacklog is the default number of streams opened by
+	// the peer which may wait to be accepted with AcceptStream
+	DefaultAcceptBacklog = 64
+
+	// DefaultMaxStreamBuffer is the default maximum number of bytes received
+	// on a stream but not yet read, beyond which the stream is reset. There
+	// is no flow control, so the application must keep reading every stream.
+	DefaultMaxStreamBuffer = 1024 * 1024
+
+	// maxFrameData is the maximum number of bytes of a stream per frame
+	maxFrameData = 32 * 1024
+)
+
+// kinds of frames, carried in an ExtensionStreamFlags extension
+const (
+	flagData  byte = 0
+	flagOpen  byte = 1
+	flagClose byte = 2
+	flagReset byte = 3
+)
+
+var (
+	// ErrSessionClosed is returned by operations on a closed Session
+	// (and on its streams)
+	ErrSessionClosed = errors.New("session closed")
+	// ErrStreamClosed is returned by writes to a stream after Close
+	ErrStreamClosed = errors.New("stream closed")
+	// ErrStreamReset is returned by operations on a stream reset by
+	// the peer, or for receiving more than DefaultMaxStreamBuffer bytes
+	ErrStreamReset = errors.New("stream reset")
+)
+
+// Option represents a configuration option for a Session
+type Option func(*Session)
+
+// WithAcceptBacklog sets the number of streams opened by the peer which may
+// wait to be accepted, beyond which they are reset (default DefaultAcceptBacklog)
+func WithAcceptBacklog(backlog int) Option {
+	return func(s *Session) { s.accept = make(chan *Stream, backlog) }
+}
+
+// WithMaxStreamBuffer sets the maximum number of bytes received on a stream but
+// not yet read, beyond which the stream is reset (default DefaultMaxStreamBuffer)
+func WithMaxStreamBuffer(size int) Option {
+	return func(s *Session) { s.maxStreamBuffer = size }
+}
+
+// Session multiplexes streams over a single authio.Conn. The ID of the stream
+// every frame belongs to is carried in an extension (see
+// authenticator.ExtensionStreamID), covered by the MAC of the frame, such that
+// routing frames to streams cannot be tampered with. Use Client and Server
+// over Conns created with authio.NewClientConn and authio.NewServerConn, such
+// that frames cannot be reflected either.
+type Session struct {
+	conn            *authio.Conn
+	accept          chan *Stream
+	maxStreamBuffer int
+
+	lock    sync.Mutex
+	streams map[uint32]*Stream
+	nextID  uint32
+	err     error // why the session is closed, if it is
+	done    chan struct{}
+}
+
+// Client returns the Session of the client side of the given Conn, which opens
+// streams with odd IDs. The Session reads from the Conn until it is closed.
+func Client(conn *authio.Conn, opts ...Option) *Session {
+	return newSession(conn, 1, opts...)
+}
+
+// Server returns the Session of the server side of the given Conn, which opens
+// streams with even IDs. The Session reads from the Conn until it is closed.
+func Server(conn *authio.Conn, opts ...Option) *Session {
+	return newSession(conn, 2, opts...)
+}
+
+func newSession(conn *authio.Conn, firstID uint32, opts ...Option) *Session {
+	s := &Session{
+		conn:            conn,
+		accept:          make(chan *Stream, DefaultAcceptBacklog),
+		maxStreamBuffer: DefaultMaxStreamBuffer,
+		streams:         map[uint32]*Stream{},
+		nextID:          firstID,
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	go s.readLoop()
+	return s
+}
+
+// OpenStream opens a new stream, which the peer accepts with AcceptStream
+func (s *Session) OpenStream() (*Stream, error) {
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return nil, s.err
+	}
+	id := s.nextID
+	s.nextID += 2
+	stream := s.newStream(id)
+	s.lock.Unlock()
+
+	if err := s.writeFrame(id, flagOpen, nil); err != nil {
+		s.removeStream(id)
+		return nil, err
+	}
+	return stream, nil
+}
+
+// AcceptStream waits for and returns the next stream opened by the peer
+func (s *Session) AcceptStream() (*Stream, error) {
+	select {
+	case stream := <-s.accept:
+		return stream, nil
+	case <-s.done:
+		return nil, s.closeErr()
+	}
+}
+
+// Close closes the Session, its streams, and the underlying Conn
+func (s *Session) Close() error {
+	return s.shutdown(ErrSessionClosed)
+}
+
+// readLoop reads frames from the Conn, routing them to their streams
+func (s *Session) readLoop() {
+	for {
+		payload, extensions, err := s.conn.NextWithExtensions()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = ErrSessionClosed
+			}
+			s.shutdown(err)
+			return
+		}
+		if err := s.handleFrame(payload, extensions); err != nil {
+			s.shutdown(err)
+			return
+		}
+	}
+}
+
+// handleFrame handles a single (verified) frame
+func (s *Session) handleFrame(payload []byte, extensions []authenticator.Extension) error {
+	value, ok := authenticator.FindExtension(extensions, authenticator.ExtensionStreamID)
+	if !ok || len(value) != 4 {
+		return errors.New("frame without a valid stream ID")
+	}
+	id := binary.BigEndian.Uint32(value)
+	flag := flagData
+	if value, ok := authenticator.FindExtension(extensions, authenticator.ExtensionStreamFlags); ok {
+		if len(value) != 1 {
+			return errors.New("frame with invalid stream flags")
+		}
+		flag = value[0]
+	}
+
+	s.lock.Lock()
+	stream, exists := s.streams[id]
+	if flag == flagOpen {
+		if exists || id%2 == s.nextID%2 {
+			s.lock.Unlock()
+			return fmt.Errorf("peer opened invalid stream %d", id)
+		}
+		stream = s.newStream(id)
+	}
+	s.lock.Unlock()
+
+	if stream == nil {
+		// e.g. data in flight for a stream reset locally
+		return nil
+	}
+	switch flag {
+	case flagOpen:
+		select {
+		case s.accept <- stream:
+		default:
+			s.resetStream(stream)
+		}
+	case flagData:
+		if !stream.push(payload, s.maxStreamBuffer) {
+			s.resetStream(stream)
+		}
+	case flagClose:
+		stream.remoteClose()
+	case flagReset:
+		stream.reset(ErrStreamReset)
+		s.removeStream(id)
+	default:
+		return fmt.Errorf("frame with unknown stream flags %d", flag)
+	}
+	return nil
+}
+
+// resetStream resets a stream on both sides. The reset is written
+// asynchronously, such that reading frames does not wait on writing.
+func (s *Session) resetStream(stream *Stream) {
+	stream.reset(ErrStreamReset)
+	s.removeStream(stream.id)
+	go s.writeFrame(stream.id, flagReset, nil)
+}
+
+// writeFrame writes a single frame of the stream with the given ID
+func (s *Session) writeFrame(id uint32, flag byte, payload []byte) error {
+	extensions := []authenticator.Extension{{
+		Type:  authenticator.ExtensionStreamID,
+		Value: binary.BigEndian.AppendUint32(nil, id),
+	}}
+	if flag != flagData {
+		extensions = append(extensions, authenticator.Extension{
+			Type:  authenticator.ExtensionStreamFlags,
+			Value: []byte{flag},
+		})
+	}
+	if _, err := s.conn.WriteWithExtensions(payload, extensions...); err != nil {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.err != nil {
+			return s.err
+		}
+		return fmt.Errorf("failed to write frame of stream %d: %w", id, err)
+	}
+	return nil
+}
+
+// newStream registers a new stream, the session lock must be held
+func (s *Session) newStream(id uint32) *Stream {
+	stream := &Stream{id: id, session: s}
+	stream.cond = sync.NewCond(&stream.lock)
+	s.streams[id] = stream
+	return stream
+}
+
+func (s *Session) removeStream(id uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.streams, id)
+}
+
+// shutdown closes the Session (once) with the given error, failing its
+// streams, and closes the underlying Conn, returning the error of doing so
+func (s *Session) shutdown(err error) error {
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return nil
+	}
+	s.err = err
+	streams := s.streams
+	s.streams = map[uint32]*Stream{}
+	close(s.done)
+	s.lock.Unlock()
+
+	for _, stream := range streams {
+		stream.reset(err)
+	}
+	return s.conn.Close()
+}
+
+func (s *Session) closeErr() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// Stream is a logical byte stream multiplexed over a Session
+type Stream struct {
+	id      uint32
+	session *Session
+
+	lock         sync.Mutex
+	cond         *sync.Cond // signaled as data is received or the stream ends
+	buf          []byte
+	remoteClosed bool
+	localClosed  bool
+	err          error // why the stream was reset, if it was
+}
+
+// ensure Stream implements io.ReadWriteCloser at compile-time
+var _ io.ReadWriteCloser = (*Stream)(nil)
+
+// ID returns the ID of the stream
+func (st *Stream) ID() uint32 {
+	return st.id
+}
+
+// Read reads data received on the stream onto the given buffer. It
+// returns io.EOF once the peer closed the stream and all data was read.
+func (st *Stream) Read(b []byte) (int, error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	for len(st.buf) == 0 && !st.remoteClosed && st.err == nil {
+		st.cond.Wait()
+	}
+	if len(st.buf) > 0 {
+		n := copy(b, st.buf)
+		st.buf = st.buf[n:]
+		return n, nil
+	}
+	if st.err != nil {
+		return 0, st.err
+	}
+	return 0, io.EOF
+}
+
+// Write writes the contents of a buffer to the stream
+func (st *Stream) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		if err := st.writable(); err != nil {
+			return written, err
+		}
+		chunk := b
+		if len(chunk) > maxFrameData {
+			chunk = chunk[:maxFrameData]
+		}
+		if err := st.session.writeFrame(st.id, flagData, chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
+
+func (st *Stream) writable() error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.err != nil {
+		return st.err
+	}
+	if st.localClosed {
+		return ErrStreamClosed
+	}
+	return nil
+}
+
+// Close closes the stream for writing, signaling the end of the stream to
+// the peer. Data sent by the peer can still be read until it closes it too.
+func (st *Stream) Close() error {
+	st.lock.Lock()
+	if st.localClosed || st.err != nil {
+		st.lock.Unlock()
+		return nil
+	}
+	st.localClosed = true
+	done := st.remoteClosed
+	st.lock.Unlock()
+
+	if done {
+		st.session.removeStream(st.id)
+	}
+	return st.session.writeFrame(st.id, flagClose, nil)
+}
+
+// push adds received data to the stream, returning false if
+// that would buffer more than the given maximum
+func (st *Stream) push(data []byte, max int) bool {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.err != nil || st.remoteClosed {
+		return true
+	}
+	if max > 0 && len(st.buf)+len(data) > max {
+		return false
+	}
+	st.buf = append(st.buf, data...)
+	st.cond.Broadcast()
+	return true
+}
+
+// remoteClose records that the peer closed the stream
+func (st *Stream) remoteClose() {
+	st.lock.Lock()
+	st.remoteClosed = true
+	done := st.localClosed
+	st.cond.Broadcast()
+	st.lock.Unlock()
+
+	if done {
+		st.session.removeStream(st.id)
+	}
+}
+
+// reset fails every pending and future operation of the stream with the given error
+func (st *Stream) reset(err error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.err == nil {
+		st.err = err
+	}
+	st.buf = nil
+	st.cond.Broadcast()
+}
@@ -0,0 +1,99 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/adrianosela/authio"
+	"github.com/autarch/testify/assert"
+)
+
+func newSessions(opts ...Option) (*Session, *Session) {
+	clientConn, serverConn := net.Pipe()
+	mockKey := []byte("mock key")
+	return Client(authio.NewClientConn(clientConn, mockKey), opts...), Server(authio.NewServerConn(serverConn, mockKey), opts...)
+}
+
+func Test_Streams(t *testing.T) {
+	client, server := newSessions()
+	defer client.Close()
+	defer server.Close()
+
+	// echo every stream back
+	go func() {
+		for {
+			stream, err := server.AcceptStream()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(stream, stream)
+				stream.Close()
+			}()
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			stream, err := client.OpenStream()
+			assert.Nil(t, err)
+			assert.Equal(t, uint32(1), stream.ID()%2)
+
+			message := bytes.Repeat([]byte(fmt.Sprintf("stream %d ", i)), 10000)
+			go func() {
+				stream.Write(message)
+				stream.Close()
+			}()
+
+			echoed, err := io.ReadAll(stream)
+			assert.Nil(t, err)
+			assert.Equal(t, message, echoed)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Test_StreamReset(t *testing.T) {
+	client, server := newSessions(WithMaxStreamBuffer(8))
+	defer client.Close()
+	defer server.Close()
+
+	stream, err := client.OpenStream()
+	assert.Nil(t, err)
+	accepted, err := server.AcceptStream()
+	assert.Nil(t, err)
+	assert.Equal(t, stream.ID(), accepted.ID())
+
+	// the server does not read, so the stream is reset once 8 bytes are buffered
+	_, err = stream.Write([]byte("more than 8 bytes"))
+	assert.Nil(t, err)
+	_, err = stream.Read(make([]byte, 1))
+	assert.True(t, errors.Is(err, ErrStreamReset))
+	_, err = accepted.Read(make([]byte, 1))
+	assert.True(t, errors.Is(err, ErrStreamReset))
+}
+
+func Test_SessionClose(t *testing.T) {
+	client, server := newSessions()
+
+	stream, err := client.OpenStream()
+	assert.Nil(t, err)
+
+	assert.Nil(t, server.Close())
+	_, err = stream.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+	_, err = client.AcceptStream()
+	assert.NotNil(t, err)
+	_, err = client.OpenStream()
+	assert.NotNil(t, err)
+	client.Close()
+}
@@ -0,0 +1,248 @@
+// Package noise establishes authio Conns with fresh session keys, rather
+// than a static pre-shared key, through a handshake based on the Noise
+// protocol framework (https://noiseprotocol.org/noise.html), in which peers
+// authenticate one another with static Curve25519 public keys. Two handshake
+// patterns are supported:
+//
+//   - XX (the default): both peers send their static public keys during the
+//     handshake, and must verify the one of the other (see WithPeerStaticKey
+//     and WithPeerVerifier)
+//   - NK: the client knows the static public key of the server beforehand
+//     (see WithPeerStaticKey) and stays anonymous, e.g. for public services
+//
+// The keys resulting from the handshake (Noise_XX_25519_ChaChaPoly_SHA256 or
+// Noise_NK_25519_ChaChaPoly_SHA256) key the MACs of either direction of the
+// authio.Conn (see authio.NewConnWithKeys). Handshake messages are prefixed
+// with their length as a big endian uint16.
+package noise
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/adrianosela/authio"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Pattern is a Noise handshake pattern
+type Pattern int
+
+const (
+	// PatternXX is the XX pattern, with mutual authentication
+	PatternXX Pattern = iota
+	// PatternNK is the NK pattern, with a known server and an anonymous client
+	PatternNK
+)
+
+// maxMessageLength is the max length of handshake messages, as per the spec
+const maxMessageLength = 65535
+
+var (
+	// ErrHandshakeFailed is returned (wrapped) when a handshake fails, e.g.
+	// because a message was tampered with or the peer has the wrong keys
+	ErrHandshakeFailed = errors.New("noise handshake failed")
+	// ErrUntrustedPeer is returned (wrapped) when the static key of the peer
+	// is rejected (see WithPeerStaticKey and WithPeerVerifier)
+	ErrUntrustedPeer = errors.New("untrusted peer static key")
+)
+
+// KeyPair is a Curve25519 key pair
+type KeyPair struct {
+	Private []byte
+	Public  []byte
+}
+
+// GenerateKeyPair generates a new random KeyPair
+func GenerateKeyPair() (KeyPair, error) {
+	private := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(private); err != nil {
+		return KeyPair{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return KeyPair{Private: private, Public: public}, nil
+}
+
+// Option represents a configuration option for a handshake
+type Option func(*config)
+
+type config struct {
+	pattern    Pattern
+	static     *KeyPair
+	peerStatic []byte
+	verifyPeer func(peerStatic []byte) error
+	prologue   []byte
+	timeout    time.Duration
+	connOpts   []authio.Option
+}
+
+// WithPattern sets the handshake pattern (default PatternXX), which both peers must use
+func WithPattern(pattern Pattern) Option {
+	return func(c *config) { c.pattern = pattern }
+}
+
+// WithStaticKey sets the static key pair of the local peer, which is required
+// for both peers with PatternXX, and for the server with PatternNK
+func WithStaticKey(static KeyPair) Option {
+	return func(c *config) { c.static = &static }
+}
+
+// WithPeerStaticKey sets the static public key of the remote peer, which is
+// required for the client with PatternNK, and otherwise pins the key the peer
+// must present
+func WithPeerStaticKey(public []byte) Option {
+	return func(c *config) { c.peerStatic = public }
+}
+
+// WithPeerVerifier sets a function which accepts (returning nil) or rejects
+// the static public key presented by the peer, e.g. against an allowlist
+func WithPeerVerifier(verify func(peerStatic []byte) error) Option {
+	return func(c *config) { c.verifyPeer = verify }
+}
+
+// WithPrologue sets data (e.g. a protocol version) both peers must agree on
+// for the handshake to succeed, without it being transmitted
+func WithPrologue(prologue []byte) Option {
+	return func(c *config) { c.prologue = prologue }
+}
+
+// WithHandshakeTimeout bounds the time the handshake may take, such that a
+// peer which stalls cannot block it forever. The deadline of the net.Conn is
+// set for the handshake and cleared afterwards. Zero (the default) means no
+// timeout, such that deadlines set on the net.Conn apply.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(c *config) { c.timeout = timeout }
+}
+
+// WithConnOptions sets options for the resulting authio.Conn. Options which
+// set the key or MessageAuthenticator must not be used.
+func WithConnOptions(opts ...authio.Option) Option {
+	return func(c *config) { c.connOpts = append(c.connOpts, opts...) }
+}
+
+// Conn is an authio.Conn established with a handshake
+type Conn struct {
+	*authio.Conn
+
+	peerStatic    []byte
+	handshakeHash []byte
+}
+
+// PeerStaticKey returns the static public key of the peer,
+// or nil for the client with PatternNK
+func (c *Conn) PeerStaticKey() []byte {
+	return c.peerStatic
+}
+
+// HandshakeHash returns the hash of the handshake, which uniquely
+// identifies the session, e.g. for channel binding
+func (c *Conn) HandshakeHash() []byte {
+	return c.handshakeHash
+}
+
+// Client performs the handshake as the client (i.e. initiator) over
+// the given net.Conn, returning a Conn keyed with the session keys
+func Client(conn net.Conn, opts ...Option) (*Conn, error) {
+	return handshake(conn, true, opts...)
+}
+
+// Server performs the handshake as the server (i.e. responder) over
+// the given net.Conn, returning a Conn keyed with the session keys
+func Server(conn net.Conn, opts ...Option) (*Conn, error) {
+	return handshake(conn, false, opts...)
+}
+
+func handshake(conn net.Conn, initiator bool, opts ...Option) (*Conn, error) {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	hs, err := newHandshakeState(c, initiator)
+	if err != nil {
+		return nil, err
+	}
+	if c.timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+			return nil, fmt.Errorf("failed to set handshake deadline: %w", err)
+		}
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	for i := range hs.messages {
+		if (i%2 == 0) == initiator {
+			msg, err := hs.writeMessage()
+			if err != nil {
+				return nil, err
+			}
+			if err := writeFrame(conn, msg); err != nil {
+				return nil, fmt.Errorf("failed to write handshake message: %w", err)
+			}
+			continue
+		}
+		msg, err := readFrame(conn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read handshake message: %w", err)
+		}
+		if err := hs.readMessage(msg); err != nil {
+			return nil, err
+		}
+		if err := c.checkPeer(hs.rs); err != nil {
+			return nil, err
+		}
+	}
+
+	k1, k2 := hs.split()
+	readKey, writeKey := k2, k1
+	if !initiator {
+		readKey, writeKey = k1, k2
+	}
+	return &Conn{
+		Conn:          authio.NewConnWithKeys(conn, readKey, writeKey, c.connOpts...),
+		peerStatic:    hs.rs,
+		handshakeHash: hs.h[:],
+	}, nil
+}
+
+// checkPeer verifies the static key of the peer, once received
+func (c *config) checkPeer(rs []byte) error {
+	if rs == nil || c.pattern == PatternNK {
+		return nil
+	}
+	if c.peerStatic != nil && subtle.ConstantTimeCompare(rs, c.peerStatic) != 1 {
+		return fmt.Errorf("%w: not the expected key", ErrUntrustedPeer)
+	}
+	if c.verifyPeer != nil {
+		if err := c.verifyPeer(rs); err != nil {
+			return fmt.Errorf("%w: %s", ErrUntrustedPeer, err)
+		}
+	}
+	return nil
+}
+
+func writeFrame(w io.Writer, msg []byte) error {
+	if len(msg) > maxMessageLength {
+		return fmt.Errorf("handshake message too large, got %d and expected at most %d bytes", len(msg), maxMessageLength)
+	}
+	_, err := w.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...))
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(r, length); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
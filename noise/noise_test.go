@@ -0,0 +1,185 @@
+package noise
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Handshake(t *testing.T) {
+	clientStatic, err := GenerateKeyPair()
+	assert.NoError(t, err)
+	serverStatic, err := GenerateKeyPair()
+	assert.NoError(t, err)
+	otherStatic, err := GenerateKeyPair()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		clientOpts  []Option
+		serverOpts  []Option
+		expectedErr error
+	}{
+		{
+			name:       "XX",
+			clientOpts: []Option{WithStaticKey(clientStatic), WithPeerStaticKey(serverStatic.Public)},
+			serverOpts: []Option{WithStaticKey(serverStatic), WithPeerVerifier(func(peer []byte) error { return nil })},
+		},
+		{
+			name:        "XX with untrusted server",
+			clientOpts:  []Option{WithStaticKey(clientStatic), WithPeerStaticKey(otherStatic.Public)},
+			serverOpts:  []Option{WithStaticKey(serverStatic), WithPeerVerifier(func(peer []byte) error { return nil })},
+			expectedErr: ErrUntrustedPeer,
+		},
+		{
+			name:        "XX with untrusted client",
+			clientOpts:  []Option{WithStaticKey(clientStatic), WithPeerStaticKey(serverStatic.Public)},
+			serverOpts:  []Option{WithStaticKey(serverStatic), WithPeerVerifier(func(peer []byte) error { return errors.New("not allowed") })},
+			expectedErr: ErrUntrustedPeer,
+		},
+		{
+			name:       "NK",
+			clientOpts: []Option{WithPattern(PatternNK), WithPeerStaticKey(serverStatic.Public)},
+			serverOpts: []Option{WithPattern(PatternNK), WithStaticKey(serverStatic)},
+		},
+		{
+			name:        "NK with the wrong server key",
+			clientOpts:  []Option{WithPattern(PatternNK), WithPeerStaticKey(otherStatic.Public)},
+			serverOpts:  []Option{WithPattern(PatternNK), WithStaticKey(serverStatic)},
+			expectedErr: ErrHandshakeFailed,
+		},
+		{
+			name:        "Mismatched prologues",
+			clientOpts:  []Option{WithPattern(PatternNK), WithPeerStaticKey(serverStatic.Public), WithPrologue([]byte("v1"))},
+			serverOpts:  []Option{WithPattern(PatternNK), WithStaticKey(serverStatic), WithPrologue([]byte("v2"))},
+			expectedErr: ErrHandshakeFailed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer a.Close()
+			defer b.Close()
+
+			type result struct {
+				conn *Conn
+				err  error
+			}
+			serverResult := make(chan result, 1)
+			go func() {
+				conn, err := Server(b, test.serverOpts...)
+				if err != nil {
+					b.Close() // unblock the client
+				}
+				serverResult <- result{conn: conn, err: err}
+			}()
+			client, clientErr := Client(a, test.clientOpts...)
+			if clientErr != nil {
+				a.Close() // unblock the server
+			}
+			server := <-serverResult
+
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(clientErr, test.expectedErr) || errors.Is(server.err, test.expectedErr),
+					"expected %v, got %v (client) and %v (server)", test.expectedErr, clientErr, server.err)
+				return
+			}
+			assert.NoError(t, clientErr)
+			assert.NoError(t, server.err)
+			assert.Equal(t, client.HandshakeHash(), server.conn.HandshakeHash())
+			assert.Equal(t, serverStatic.Public, client.PeerStaticKey())
+
+			// the session keys authenticate messages in either direction
+			go func() {
+				client.Write([]byte("ping"))
+			}()
+			buf := make([]byte, 4)
+			_, err := io.ReadFull(server.conn, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, "ping", string(buf))
+			go func() {
+				server.conn.Write([]byte("pong"))
+			}()
+			_, err = io.ReadFull(client, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, "pong", string(buf))
+		})
+	}
+}
+
+func Test_HandshakeMessages(t *testing.T) {
+	serverStatic, err := GenerateKeyPair()
+	assert.NoError(t, err)
+	clientStatic, err := GenerateKeyPair()
+	assert.NoError(t, err)
+	acceptAll := func([]byte) error { return nil }
+
+	// message sizes match the patterns (see section 7.5 of the spec)
+	tests := []struct {
+		name          string
+		pattern       Pattern
+		expectedSizes []int
+	}{
+		{name: "XX", pattern: PatternXX, expectedSizes: []int{32, 32 + 48 + 16, 48 + 16}},
+		{name: "NK", pattern: PatternNK, expectedSizes: []int{32 + 16, 32 + 16}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			initiatorConfig := &config{pattern: test.pattern, static: &clientStatic, peerStatic: serverStatic.Public}
+			responderConfig := &config{pattern: test.pattern, static: &serverStatic, verifyPeer: acceptAll}
+			initiator, err := newHandshakeState(initiatorConfig, true)
+			assert.NoError(t, err)
+			responder, err := newHandshakeState(responderConfig, false)
+			assert.NoError(t, err)
+
+			for i, expectedSize := range test.expectedSizes {
+				writer, reader := initiator, responder
+				if i%2 == 1 {
+					writer, reader = responder, initiator
+				}
+				msg, err := writer.writeMessage()
+				assert.NoError(t, err)
+				assert.Equal(t, expectedSize, len(msg))
+				assert.NoError(t, reader.readMessage(msg))
+			}
+			k1, k2 := initiator.split()
+			r1, r2 := responder.split()
+			assert.Equal(t, k1, r1)
+			assert.Equal(t, k2, r2)
+			assert.False(t, bytes.Equal(k1, k2))
+		})
+	}
+
+	// tampered messages fail
+	initiator, err := newHandshakeState(&config{pattern: PatternNK, peerStatic: serverStatic.Public}, true)
+	assert.NoError(t, err)
+	responder, err := newHandshakeState(&config{pattern: PatternNK, static: &serverStatic}, false)
+	assert.NoError(t, err)
+	msg, err := initiator.writeMessage()
+	assert.NoError(t, err)
+	msg[len(msg)-1] ^= 1
+	assert.True(t, errors.Is(responder.readMessage(msg), ErrHandshakeFailed))
+
+	_, err = Client(nil)
+	assert.Error(t, err, "XX requires a static key")
+}
+
+func Test_HandshakeTimeout(t *testing.T) {
+	serverStatic, err := GenerateKeyPair()
+	assert.NoError(t, err)
+
+	// a server which stalls
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	go io.Copy(io.Discard, b)
+
+	_, err = Client(a, WithPattern(PatternNK), WithPeerStaticKey(serverStatic.Public), WithHandshakeTimeout(50*time.Millisecond))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded), "expected %v, got %v", os.ErrDeadlineExceeded, err)
+}
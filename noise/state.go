@@ -0,0 +1,297 @@
+package noise
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// tokens of handshake patterns
+const (
+	tokenE  = "e"
+	tokenS  = "s"
+	tokenEE = "ee"
+	tokenES = "es"
+	tokenSE = "se"
+)
+
+// patterns are the message patterns of the supported handshake patterns
+// (see section 7 of the spec), the first message sent by the initiator
+var patterns = map[Pattern]struct {
+	name     string
+	messages [][]string
+}{
+	PatternXX: {
+		name:     "Noise_XX_25519_ChaChaPoly_SHA256",
+		messages: [][]string{{tokenE}, {tokenE, tokenEE, tokenS, tokenES}, {tokenS, tokenSE}},
+	},
+	PatternNK: {
+		name:     "Noise_NK_25519_ChaChaPoly_SHA256",
+		messages: [][]string{{tokenE, tokenES}, {tokenE, tokenEE}},
+	},
+}
+
+// cipherState is a CipherState (see section 5.1 of the spec)
+type cipherState struct {
+	k      [chacha20poly1305.KeySize]byte
+	hasKey bool
+	n      uint64
+}
+
+func (c *cipherState) encryptWithAd(ad, plaintext []byte) ([]byte, error) {
+	if !c.hasKey {
+		return plaintext, nil
+	}
+	aead, err := chacha20poly1305.New(c.k[:])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nil, c.nonce(), plaintext, ad)
+	c.n++
+	return ciphertext, nil
+}
+
+func (c *cipherState) decryptWithAd(ad, ciphertext []byte) ([]byte, error) {
+	if !c.hasKey {
+		return ciphertext, nil
+	}
+	aead, err := chacha20poly1305.New(c.k[:])
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, c.nonce(), ciphertext, ad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrHandshakeFailed, err)
+	}
+	c.n++
+	return plaintext, nil
+}
+
+// nonce encodes the counter as per the ChaChaPoly cipher functions
+func (c *cipherState) nonce() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], c.n)
+	return nonce
+}
+
+// symmetricState is a SymmetricState (see section 5.2 of the spec)
+type symmetricState struct {
+	cs cipherState
+	ck [sha256.Size]byte
+	h  [sha256.Size]byte
+}
+
+func (s *symmetricState) initialize(protocolName string) {
+	if len(protocolName) <= sha256.Size {
+		copy(s.h[:], protocolName)
+	} else {
+		s.h = sha256.Sum256([]byte(protocolName))
+	}
+	s.ck = s.h
+}
+
+func (s *symmetricState) mixKey(ikm []byte) {
+	var tempK []byte
+	s.ck, tempK = hkdf(s.ck[:], ikm)
+	s.cs = cipherState{hasKey: true}
+	copy(s.cs.k[:], tempK)
+}
+
+func (s *symmetricState) mixHash(data []byte) {
+	s.h = sha256.Sum256(append(s.h[:], data...))
+}
+
+func (s *symmetricState) encryptAndHash(plaintext []byte) ([]byte, error) {
+	ciphertext, err := s.cs.encryptWithAd(s.h[:], plaintext)
+	if err != nil {
+		return nil, err
+	}
+	s.mixHash(ciphertext)
+	return ciphertext, nil
+}
+
+func (s *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext, err := s.cs.decryptWithAd(s.h[:], ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	s.mixHash(ciphertext)
+	return plaintext, nil
+}
+
+// split returns the keys of the initiator and responder, respectively
+func (s *symmetricState) split() ([]byte, []byte) {
+	k1, k2 := hkdf(s.ck[:], nil)
+	return k1[:], k2
+}
+
+// hkdf is the HKDF function of the spec, with two outputs
+func hkdf(chainingKey, ikm []byte) ([sha256.Size]byte, []byte) {
+	tempKey := hmacSHA256(chainingKey, ikm)
+	var out1 [sha256.Size]byte
+	copy(out1[:], hmacSHA256(tempKey, []byte{1}))
+	out2 := hmacSHA256(tempKey, append(out1[:], 2))
+	return out1, out2
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// handshakeState is a HandshakeState (see section 5.3 of the spec)
+type handshakeState struct {
+	symmetricState
+
+	initiator bool
+	messages  [][]string
+	next      int // index of the next message
+
+	s  *KeyPair // local static key pair
+	e  *KeyPair // local ephemeral key pair
+	rs []byte   // remote static public key
+	re []byte   // remote ephemeral public key
+
+	// generateEphemeral generates ephemeral key pairs, which
+	// tests replace to reproduce the handshakes of test vectors
+	generateEphemeral func() (KeyPair, error)
+}
+
+func newHandshakeState(c *config, initiator bool) (*handshakeState, error) {
+	pattern, ok := patterns[c.pattern]
+	if !ok {
+		return nil, fmt.Errorf("unsupported handshake pattern %d", c.pattern)
+	}
+	hs := &handshakeState{initiator: initiator, messages: pattern.messages, s: c.static, generateEphemeral: GenerateKeyPair}
+	hs.initialize(pattern.name)
+	hs.mixHash(c.prologue)
+
+	switch {
+	case c.pattern == PatternXX && c.static == nil:
+		return nil, errors.New("a static key is required with the XX pattern")
+	case c.pattern == PatternXX && c.peerStatic == nil && c.verifyPeer == nil:
+		return nil, errors.New("the static key of the peer must be verified with the XX pattern")
+	case c.pattern == PatternNK && initiator && c.peerStatic == nil:
+		return nil, errors.New("the static key of the server is required with the NK pattern")
+	case c.pattern == PatternNK && !initiator && c.static == nil:
+		return nil, errors.New("a static key is required for the server with the NK pattern")
+	}
+
+	// the NK pattern has a pre-message with the static key of the responder
+	if c.pattern == PatternNK {
+		if initiator {
+			hs.rs = c.peerStatic
+			hs.mixHash(hs.rs)
+		} else {
+			hs.mixHash(hs.s.Public)
+		}
+	}
+	return hs, nil
+}
+
+// writeMessage returns the next message (with an empty payload)
+func (hs *handshakeState) writeMessage() ([]byte, error) {
+	msg := []byte{}
+	for _, token := range hs.messages[hs.next] {
+		switch token {
+		case tokenE:
+			e, err := hs.generateEphemeral()
+			if err != nil {
+				return nil, err
+			}
+			hs.e = &e
+			msg = append(msg, e.Public...)
+			hs.mixHash(e.Public)
+		case tokenS:
+			ciphertext, err := hs.encryptAndHash(hs.s.Public)
+			if err != nil {
+				return nil, err
+			}
+			msg = append(msg, ciphertext...)
+		default:
+			if err := hs.mixDH(token); err != nil {
+				return nil, err
+			}
+		}
+	}
+	payload, err := hs.encryptAndHash(nil)
+	if err != nil {
+		return nil, err
+	}
+	hs.next++
+	return append(msg, payload...), nil
+}
+
+// readMessage processes the next message, which must have an empty payload
+func (hs *handshakeState) readMessage(msg []byte) error {
+	for _, token := range hs.messages[hs.next] {
+		switch token {
+		case tokenE:
+			if len(msg) < curve25519.PointSize {
+				return fmt.Errorf("%w: message too short", ErrHandshakeFailed)
+			}
+			hs.re, msg = msg[:curve25519.PointSize], msg[curve25519.PointSize:]
+			hs.mixHash(hs.re)
+		case tokenS:
+			length := curve25519.PointSize
+			if hs.cs.hasKey {
+				length += chacha20poly1305.Overhead
+			}
+			if len(msg) < length {
+				return fmt.Errorf("%w: message too short", ErrHandshakeFailed)
+			}
+			rs, err := hs.decryptAndHash(msg[:length])
+			if err != nil {
+				return err
+			}
+			hs.rs, msg = rs, msg[length:]
+		default:
+			if err := hs.mixDH(token); err != nil {
+				return err
+			}
+		}
+	}
+	payload, err := hs.decryptAndHash(msg)
+	if err != nil {
+		return err
+	}
+	if len(payload) != 0 {
+		return fmt.Errorf("%w: unexpected payload", ErrHandshakeFailed)
+	}
+	hs.next++
+	return nil
+}
+
+// mixDH mixes the result of the DH of the given token into the key
+func (hs *handshakeState) mixDH(token string) error {
+	var private *KeyPair
+	var public []byte
+	switch token {
+	case tokenEE:
+		private, public = hs.e, hs.re
+	case tokenES:
+		if hs.initiator {
+			private, public = hs.e, hs.rs
+		} else {
+			private, public = hs.s, hs.re
+		}
+	case tokenSE:
+		if hs.initiator {
+			private, public = hs.s, hs.re
+		} else {
+			private, public = hs.e, hs.rs
+		}
+	}
+	shared, err := curve25519.X25519(private.Private, public)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrHandshakeFailed, err)
+	}
+	hs.mixKey(shared)
+	return nil
+}
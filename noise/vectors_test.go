@@ -0,0 +1,127 @@
+package noise
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"golang.org/x/crypto/curve25519"
+)
+
+// vector is a test vector in the format of the cacophony
+// and flynn/noise vectors, with hex encoded fields
+type vector map[string]string
+
+func (v vector) bytes(t *testing.T, field string) []byte {
+	b, err := hex.DecodeString(v[field])
+	assert.NoError(t, err)
+	return b
+}
+
+func (v vector) keyPair(t *testing.T, field string) KeyPair {
+	private := v.bytes(t, field)
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	assert.NoError(t, err)
+	return KeyPair{Private: private, Public: public}
+}
+
+// readVectors reads the vectors in the given file,
+// which are separated by empty lines
+func readVectors(t *testing.T, path string) []vector {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	vectors := []vector{}
+	current := vector{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case line == "":
+			if len(current) > 0 {
+				vectors = append(vectors, current)
+			}
+			current = vector{}
+		default:
+			field, value, _ := strings.Cut(line, "=")
+			current[field] = value
+		}
+	}
+	assert.NoError(t, scanner.Err())
+	if len(current) > 0 {
+		vectors = append(vectors, current)
+	}
+	return vectors
+}
+
+func Test_Vectors(t *testing.T) {
+	vectors := readVectors(t, "testdata/vectors.txt")
+	assert.Equal(t, 4, len(vectors))
+
+	acceptAll := func([]byte) error { return nil }
+	for _, v := range vectors {
+		name := v["handshake"]
+		if _, ok := v["prologue"]; ok {
+			name += " with prologue"
+		}
+		t.Run(name, func(t *testing.T) {
+			respStatic := v.keyPair(t, "resp_static")
+			initiatorConfig := &config{prologue: v.bytes(t, "prologue")}
+			responderConfig := &config{static: &respStatic, prologue: v.bytes(t, "prologue")}
+			switch v["handshake"] {
+			case patterns[PatternXX].name:
+				initStatic := v.keyPair(t, "init_static")
+				initiatorConfig.static, initiatorConfig.verifyPeer = &initStatic, acceptAll
+				responderConfig.verifyPeer = acceptAll
+			case patterns[PatternNK].name:
+				initiatorConfig.pattern, initiatorConfig.peerStatic = PatternNK, respStatic.Public
+				responderConfig.pattern = PatternNK
+			default:
+				t.Fatalf("unexpected handshake %s", v["handshake"])
+			}
+
+			initiator, err := newHandshakeState(initiatorConfig, true)
+			assert.NoError(t, err)
+			responder, err := newHandshakeState(responderConfig, false)
+			assert.NoError(t, err)
+			initEphemeral, respEphemeral := v.keyPair(t, "gen_init_ephemeral"), v.keyPair(t, "gen_resp_ephemeral")
+			initiator.generateEphemeral = func() (KeyPair, error) { return initEphemeral, nil }
+			responder.generateEphemeral = func() (KeyPair, error) { return respEphemeral, nil }
+
+			// the handshake messages match the vector byte for byte
+			for i := range initiator.messages {
+				writer, reader := initiator, responder
+				if i%2 == 1 {
+					writer, reader = responder, initiator
+				}
+				field := "msg_" + string(rune('0'+i))
+				assert.Equal(t, "", v[field+"_payload"])
+				msg, err := writer.writeMessage()
+				assert.NoError(t, err)
+				assert.Equal(t, v[field+"_ciphertext"], hex.EncodeToString(msg))
+				assert.NoError(t, reader.readMessage(msg))
+			}
+
+			// the split keys encrypt the transport messages of the vector, which
+			// alternate between them (the first of either key with a zero nonce)
+			k1, k2 := initiator.split()
+			for i := len(initiator.messages); i < len(initiator.messages)+2; i++ {
+				key := k1
+				if (i-len(initiator.messages))%2 == 1 {
+					key = k2
+				}
+				cs := cipherState{hasKey: true}
+				copy(cs.k[:], key)
+				field := "msg_" + string(rune('0'+i))
+				ciphertext, err := cs.encryptWithAd(nil, v.bytes(t, field+"_payload"))
+				assert.NoError(t, err)
+				assert.Equal(t, v[field+"_ciphertext"], hex.EncodeToString(ciphertext))
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+// Package objectstore signs objects as they are uploaded to object storage
+// (e.g. S3, GCS, Azure Blob Storage) and verifies them as they are downloaded,
+// such that their integrity does not depend on the checksums of the storage
+// provider. Objects are split in parts of a fixed size, each with its own MAC,
+// such that multipart uploads can sign their parts independently (and in
+// parallel), while downloads verify the whole object in a single pass. The
+// resulting tag (which binds the name of the object, its size, and the MACs
+// of its parts) is stored alongside the object, e.g. in a sidecar object or
+// in its metadata.
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// DefaultPartSize is the default size of the parts of objects,
+	// a common part size of multipart uploads
+	DefaultPartSize = 8 * 1024 * 1024
+
+	// DefaultSidecarExtension is the extension of sidecar objects holding
+	// tags, i.e. the tag of the object "backup.tar" is in "backup.tar.mac"
+	DefaultSidecarExtension = ".mac"
+
+	// MetadataKey is the conventional key of tags in object metadata
+	MetadataKey = "authio-tag"
+
+	// labels bound into part and object MACs
+	partLabel   = "authio object part"
+	objectLabel = "authio object"
+)
+
+var (
+	// ErrMACMismatch is returned (wrapped) when an object does not match its tag
+	ErrMACMismatch = authenticator.ErrMACMismatch
+	// ErrInvalidTag is returned (wrapped) when a tag cannot be parsed
+	ErrInvalidTag = errors.New("invalid tag")
+)
+
+// Option represents a configuration option for signing and verifying objects
+type Option func(*config)
+
+type config struct {
+	hashFn     func() hash.Hash
+	partSize   int64
+	sidecarExt string
+}
+
+func newConfig(opts ...Option) *config {
+	c := &config{
+		hashFn:     sha256.New,
+		partSize:   DefaultPartSize,
+		sidecarExt: DefaultSidecarExtension,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithHashFn sets the hash function used to compute MACs (default SHA-256)
+func WithHashFn(hashFn func() hash.Hash) Option {
+	return func(c *config) { c.hashFn = hashFn }
+}
+
+// WithPartSize sets the size of the parts objects are signed in, which must
+// match the part size of multipart uploads. It has no effect on verification,
+// which uses the part size in the tag.
+func WithPartSize(size int64) Option {
+	return func(c *config) { c.partSize = size }
+}
+
+// WithSidecarExtension sets the extension of sidecar objects (see Upload)
+func WithSidecarExtension(ext string) Option {
+	return func(c *config) { c.sidecarExt = ext }
+}
+
+// Signer computes the tag of an object written through it in a single pass
+// (e.g. through an io.MultiWriter or io.TeeReader along with the upload)
+type Signer struct {
+	name     string
+	key      []byte
+	hashFn   func() hash.Hash
+	partSize int64
+
+	object      hash.Hash // MAC over the MACs of the parts so far
+	part        hash.Hash // MAC of the current part
+	partWritten int64
+	parts       uint64
+	size        int64
+	mac         []byte // the MAC of the object, once done
+}
+
+// ensure Signer implements io.Writer at compile-time
+var _ io.Writer = (*Signer)(nil)
+
+// NewSigner returns a new Signer for the object with the given name, which
+// is bound into the tag such that objects cannot be swapped for one another.
+// Objects which are meant to be copied or renamed may use an empty name.
+func NewSigner(key []byte, name string, opts ...Option) *Signer {
+	c := newConfig(opts...)
+	return &Signer{
+		name:     name,
+		key:      key,
+		hashFn:   c.hashFn,
+		partSize: c.partSize,
+		object:   newObjectMAC(c.hashFn, key, name, c.partSize),
+	}
+}
+
+// Write adds the given data to the object
+func (s *Signer) Write(p []byte) (int, error) {
+	if s.mac != nil {
+		return 0, errors.New("write after the tag was computed")
+	}
+	if s.partSize <= 0 {
+		return 0, fmt.Errorf("invalid part size %d", s.partSize)
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if s.part == nil {
+			s.part = newPartMAC(s.hashFn, s.key, s.name, s.partSize, s.parts+1)
+		}
+		chunk := p
+		if remaining := s.partSize - s.partWritten; int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		s.part.Write(chunk)
+		s.partWritten += int64(len(chunk))
+		s.size += int64(len(chunk))
+		p = p[len(chunk):]
+		if s.partWritten == s.partSize {
+			s.endPart()
+		}
+	}
+	return n, nil
+}
+
+// endPart adds the MAC of the current part to the MAC of the object
+func (s *Signer) endPart() {
+	s.object.Write(s.part.Sum(nil))
+	s.parts++
+	s.part = nil
+	s.partWritten = 0
+}
+
+// Tag returns the tag of the object, after which no more data may be written
+func (s *Signer) Tag() string {
+	return encodeTag(s.partSize, s.sum())
+}
+
+func (s *Signer) sum() []byte {
+	if s.mac == nil {
+		if s.part != nil {
+			s.endPart()
+		}
+		s.mac = sumObjectMAC(s.object, s.size, s.parts)
+	}
+	return s.mac
+}
+
+// MultipartSigner computes the tag of an object from its parts, which may be
+// signed in any order and concurrently, e.g. along with a multipart upload.
+// Every part but the last must be of the part size (see WithPartSize).
+type MultipartSigner struct {
+	name     string
+	key      []byte
+	hashFn   func() hash.Hash
+	partSize int64
+
+	lock  sync.Mutex
+	parts map[int]signedPart
+}
+
+type signedPart struct {
+	mac  []byte
+	size int64
+}
+
+// NewMultipartSigner returns a new MultipartSigner for the object with the
+// given name (see NewSigner)
+func NewMultipartSigner(key []byte, name string, opts ...Option) *MultipartSigner {
+	c := newConfig(opts...)
+	return &MultipartSigner{
+		name:     name,
+		key:      key,
+		hashFn:   c.hashFn,
+		partSize: c.partSize,
+		parts:    make(map[int]signedPart),
+	}
+}
+
+// SignPart reads and signs the part with the given number (starting at 1, as
+// in S3 multipart uploads), returning its (base64 encoded) MAC. Signing a part
+// again (e.g. when its upload is retried) replaces it.
+func (s *MultipartSigner) SignPart(number int, part io.Reader) (string, error) {
+	if number < 1 {
+		return "", fmt.Errorf("invalid part number %d", number)
+	}
+	mac := newPartMAC(s.hashFn, s.key, s.name, s.partSize, uint64(number))
+	size, err := io.Copy(mac, io.LimitReader(part, s.partSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read part %d: %w", number, err)
+	}
+	if size == 0 || size > s.partSize {
+		return "", fmt.Errorf("part %d of %d bytes does not fit parts of %d bytes", number, size, s.partSize)
+	}
+
+	signed := signedPart{mac: mac.Sum(nil), size: size}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.parts[number] = signed
+	return base64.StdEncoding.EncodeToString(signed.mac), nil
+}
+
+// Tag returns the tag of the object, once all of its parts were signed
+func (s *MultipartSigner) Tag() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	object := newObjectMAC(s.hashFn, s.key, s.name, s.partSize)
+	size := int64(0)
+	for i := 1; i <= len(s.parts); i++ {
+		part, ok := s.parts[i]
+		if !ok {
+			return "", fmt.Errorf("part %d was not signed", i)
+		}
+		if i < len(s.parts) && part.size != s.partSize {
+			return "", fmt.Errorf("part %d of %d bytes is not the last part, but is not of %d bytes", i, part.size, s.partSize)
+		}
+		object.Write(part.mac)
+		size += part.size
+	}
+	return encodeTag(s.partSize, sumObjectMAC(object, size, uint64(len(s.parts)))), nil
+}
+
+// VerifyReader is a reader that verifies an object against its tag as it is
+// read. Note that data is returned before it is verified (which happens upon
+// reaching the end of the object): callers must read until io.EOF and discard
+// all data read if an error wrapping ErrMACMismatch is returned instead.
+type VerifyReader struct {
+	reader   io.Reader // underlying io.Reader to read from
+	signer   *Signer
+	expected []byte
+	err      error // why the tag is unusable, if it is
+	done     bool
+}
+
+// ensure VerifyReader implements io.Reader at compile-time
+var _ io.Reader = (*VerifyReader)(nil)
+
+// NewVerifyReader returns a new VerifyReader for the object with the given
+// name and tag. Of the options, only WithHashFn applies.
+func NewVerifyReader(reader io.Reader, key []byte, name, tag string, opts ...Option) *VerifyReader {
+	r := &VerifyReader{reader: reader}
+	partSize, mac, err := decodeTag(tag)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.signer = NewSigner(key, name, append(opts, WithPartSize(partSize))...)
+	r.expected = mac
+	return r
+}
+
+// Read reads data onto the given buffer
+func (r *VerifyReader) Read(b []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.done {
+		return 0, io.EOF
+	}
+	n, err := r.reader.Read(b)
+	r.signer.Write(b[:n])
+	if errors.Is(err, io.EOF) {
+		if !hmac.Equal(r.expected, r.signer.sum()) {
+			r.err = ErrMACMismatch
+			return n, r.err
+		}
+		r.done = true
+	}
+	return n, err
+}
+
+// Bucket is the subset of an object storage client needed by Upload and
+// Download, to be implemented by thin adapters over provider SDKs
+type Bucket interface {
+	// PutObject uploads an object with the contents read from body
+	PutObject(ctx context.Context, name string, body io.Reader) error
+	// GetObject downloads an object
+	GetObject(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// Upload uploads an object to the given bucket, signing it as it is uploaded,
+// and then uploads its tag in a sidecar object (see WithSidecarExtension)
+func Upload(ctx context.Context, bucket Bucket, name string, body io.Reader, key []byte, opts ...Option) error {
+	c := newConfig(opts...)
+	signer := NewSigner(key, name, opts...)
+	if err := bucket.PutObject(ctx, name, io.TeeReader(body, signer)); err != nil {
+		return err
+	}
+	if err := bucket.PutObject(ctx, name+c.sidecarExt, strings.NewReader(signer.Tag())); err != nil {
+		return fmt.Errorf("failed to upload tag: %w", err)
+	}
+	return nil
+}
+
+// Download downloads an object from the given bucket, returning a reader
+// which verifies it against the tag in its sidecar object (see VerifyReader)
+func Download(ctx context.Context, bucket Bucket, name string, key []byte, opts ...Option) (io.ReadCloser, error) {
+	c := newConfig(opts...)
+	sidecar, err := bucket.GetObject(ctx, name+c.sidecarExt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download tag: %w", err)
+	}
+	defer sidecar.Close()
+	tag, err := io.ReadAll(io.LimitReader(sidecar, 1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download tag: %w", err)
+	}
+
+	object, err := bucket.GetObject(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyReadCloser{
+		VerifyReader: NewVerifyReader(object, key, name, strings.TrimSpace(string(tag)), opts...),
+		closer:       object,
+	}, nil
+}
+
+type verifyReadCloser struct {
+	*VerifyReader
+	closer io.Closer
+}
+
+func (r *verifyReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+// encodeTag encodes a tag as "<part size>:<base64 MAC>"
+func encodeTag(partSize int64, mac []byte) string {
+	return strconv.FormatInt(partSize, 10) + ":" + base64.StdEncoding.EncodeToString(mac)
+}
+
+func decodeTag(tag string) (int64, []byte, error) {
+	size, encoded, ok := strings.Cut(tag, ":")
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: expected \"<part size>:<MAC>\"", ErrInvalidTag)
+	}
+	partSize, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || partSize <= 0 {
+		return 0, nil, fmt.Errorf("%w: invalid part size %q", ErrInvalidTag, size)
+	}
+	mac, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", ErrInvalidTag, err)
+	}
+	return partSize, mac, nil
+}
+
+// newPartMAC returns the MAC of a part, which binds the name of the object,
+// the part size, and the position of the part within the object
+func newPartMAC(hashFn func() hash.Hash, key []byte, name string, partSize int64, number uint64) hash.Hash {
+	mac := hmac.New(hashFn, key)
+	mac.Write([]byte(partLabel))
+	writeObjectFields(mac, name, partSize)
+	mac.Write(binary.BigEndian.AppendUint64(nil, number))
+	return mac
+}
+
+// newObjectMAC returns the MAC of an object, over the MACs of its parts
+// followed by its size and number of parts (see sumObjectMAC)
+func newObjectMAC(hashFn func() hash.Hash, key []byte, name string, partSize int64) hash.Hash {
+	mac := hmac.New(hashFn, key)
+	mac.Write([]byte(objectLabel))
+	writeObjectFields(mac, name, partSize)
+	return mac
+}
+
+func sumObjectMAC(object hash.Hash, size int64, parts uint64) []byte {
+	object.Write(binary.BigEndian.AppendUint64(nil, uint64(size)))
+	object.Write(binary.BigEndian.AppendUint64(nil, parts))
+	return object.Sum(nil)
+}
+
+func writeObjectFields(mac hash.Hash, name string, partSize int64) {
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(len(name))))
+	mac.Write([]byte(name))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(partSize)))
+}
@@ -0,0 +1,141 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_SignVerify(t *testing.T) {
+	mockKey := []byte("mock key")
+	data := bytes.Repeat([]byte("0123456789"), 10) // 100 bytes in parts of 16
+
+	signer := NewSigner(mockKey, "object", WithPartSize(16))
+	_, err := io.Copy(signer, bytes.NewReader(data))
+	assert.NoError(t, err)
+	tag := signer.Tag()
+	assert.True(t, strings.HasPrefix(tag, "16:"))
+	_, err = signer.Write(data)
+	assert.Error(t, err, "write after the tag was computed")
+
+	// parts signed out of order (and concurrently) result in the same tag
+	multipart := NewMultipartSigner(mockKey, "object", WithPartSize(16))
+	wg := sync.WaitGroup{}
+	for i := 6; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			end := 16 * (i + 1)
+			if end > len(data) {
+				end = len(data)
+			}
+			_, err := multipart.SignPart(i+1, bytes.NewReader(data[16*i:end]))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	multipartTag, err := multipart.Tag()
+	assert.NoError(t, err)
+	assert.Equal(t, tag, multipartTag)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		objectName  string
+		tag         string
+		expectedErr error
+	}{
+		{name: "Valid", data: data, objectName: "object", tag: tag},
+		{name: "Tampered", data: append([]byte("X"), data[1:]...), objectName: "object", tag: tag, expectedErr: ErrMACMismatch},
+		{name: "Truncated at a part boundary", data: data[:96], objectName: "object", tag: tag, expectedErr: ErrMACMismatch},
+		{name: "Other object", data: data, objectName: "other", tag: tag, expectedErr: ErrMACMismatch},
+		{name: "Other part size", data: data, objectName: "object", tag: "32" + tag[2:], expectedErr: ErrMACMismatch},
+		{name: "Invalid tag", data: data, objectName: "object", tag: "invalid", expectedErr: ErrInvalidTag},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			read, err := io.ReadAll(NewVerifyReader(bytes.NewReader(test.data), mockKey, test.objectName, test.tag))
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.data, read)
+		})
+	}
+}
+
+func Test_MultipartSigner(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	signer := NewMultipartSigner(mockKey, "object", WithPartSize(4))
+	_, err := signer.SignPart(0, strings.NewReader("abcd"))
+	assert.Error(t, err, "invalid part number")
+	_, err = signer.SignPart(1, strings.NewReader("abcde"))
+	assert.Error(t, err, "part too large")
+
+	_, err = signer.SignPart(2, strings.NewReader("ef"))
+	assert.NoError(t, err)
+	_, err = signer.Tag()
+	assert.Error(t, err, "missing part")
+
+	_, err = signer.SignPart(1, strings.NewReader("ab"))
+	assert.NoError(t, err)
+	_, err = signer.Tag()
+	assert.Error(t, err, "short part before the last")
+
+	// retried parts replace previous ones
+	_, err = signer.SignPart(1, strings.NewReader("abcd"))
+	assert.NoError(t, err)
+	tag, err := signer.Tag()
+	assert.NoError(t, err)
+	_, err = io.ReadAll(NewVerifyReader(strings.NewReader("abcdef"), mockKey, "object", tag))
+	assert.NoError(t, err)
+}
+
+// mockBucket is an in-memory Bucket
+type mockBucket map[string][]byte
+
+func (b mockBucket) PutObject(ctx context.Context, name string, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	b[name] = data
+	return err
+}
+
+func (b mockBucket) GetObject(ctx context.Context, name string) (io.ReadCloser, error) {
+	data, ok := b[name]
+	if !ok {
+		return nil, errors.New("no such object")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func Test_UploadDownload(t *testing.T) {
+	mockKey := []byte("mock key")
+	bucket := mockBucket{}
+
+	assert.NoError(t, Upload(context.Background(), bucket, "backup.tar", strings.NewReader("mock data"), mockKey))
+	assert.NotEmpty(t, bucket["backup.tar"+DefaultSidecarExtension])
+
+	object, err := Download(context.Background(), bucket, "backup.tar", mockKey)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(object)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock data", string(data))
+	assert.NoError(t, object.Close())
+
+	bucket["backup.tar"] = []byte("tampered data")
+	object, err = Download(context.Background(), bucket, "backup.tar", mockKey)
+	assert.NoError(t, err)
+	_, err = io.ReadAll(object)
+	assert.True(t, errors.Is(err, ErrMACMismatch))
+
+	_, err = Download(context.Background(), bucket, "missing", mockKey)
+	assert.Error(t, err)
+}
@@ -0,0 +1,743 @@
+package authio
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"time"
+
+	"github.com/adrianosela/authio/metrics"
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// DefaultMaxMessageSize is the default maximum size (in bytes, excluding MACs) of
+// messages. Without a limit, a single corrupt or malicious length field could make
+// a reader allocate an arbitrary amount of memory. Writers split larger writes into
+// several messages, and readers reject larger messages (see WithMaxMessageSize).
+const DefaultMaxMessageSize = 16 * 1024 * 1024
+
+// minTagSize is the minimum size (in bytes) of truncated MACs as per RFC 2104
+const minTagSize = 10
+
+// Option represents a configuration option for authio readers and writers
+type Option func(*config)
+
+type config struct {
+	authenticator      authenticator.MessageAuthenticator
+	keyProvider        KeyProvider
+	keyID              string
+	hashFn             func() hash.Hash
+	checksum           bool
+	algorithm          string
+	algorithms         []string
+	cborHeaders        bool
+	cborKeyID          string
+	frameCodec         authenticator.FrameCodec
+	formatDetection    bool
+	maxMessageSize     int
+	readFrameSize      int
+	delimited          bool
+	delimiter          byte
+	recordSize         int
+	paddingBlockSize   int
+	compression        string
+	tagSize            int
+	macEncoding        authenticator.MACEncoding
+	aad                []byte
+	noDirectionBinding bool
+	readTimeout        time.Duration
+	closeNotify        bool
+	heartbeatInterval  time.Duration
+	heartbeatTimeout   time.Duration
+	identity           string
+	failureLimiter     *FailureLimiter
+	readRateLimit      RateLimit
+	writeRateLimit     RateLimit
+	clock              Clock
+	writeTimeout       time.Duration
+	datagramTolerance  time.Duration
+	handshakeTimeout   time.Duration
+	minKeyLength       int
+	verifierPool       *VerifierPool
+	maxBufferedBytes   int
+	resync             bool
+	verifyState        *VerifyState
+	codec              Codec
+	frameErrorPolicy   FrameErrorPolicy
+	trailingDataPolicy TrailingDataPolicy
+	onBadFrame         func(err error)
+	oversizedPolicy    OversizedFramePolicy
+	onOversized        func(err error)
+	onResync           func(skipped int64, cause error)
+	policy             *Policy
+	allowlist          *Allowlist
+	controlHandlers    map[ControlType]func(payload []byte)
+	metrics            metrics.Metrics
+	logger             Logger
+}
+
+func newConfig(opts ...Option) *config {
+	c := &config{
+		hashFn:            sha256.New,
+		codec:             JSONCodec,
+		macEncoding:       authenticator.StdBase64,
+		maxMessageSize:    DefaultMaxMessageSize,
+		metrics:           metrics.Noop{},
+		logger:            nopLogger{},
+		policy:            getDefaultPolicy(),
+		clock:             SystemClock,
+		datagramTolerance: DefaultDatagramTolerance,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.metrics = withExpvar(c.metrics)
+	return c
+}
+
+// newAuthenticator returns a MessageAuthenticator for the configuration
+func (c *config) newAuthenticator(key []byte) authenticator.MessageAuthenticator {
+	if err := c.checkFraming(); err != nil {
+		return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+	}
+	if err := c.checkAllowlist(); err != nil {
+		return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+	}
+	if err := c.checkControlHandlers(); err != nil {
+		return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+	}
+	if c.formatDetection {
+		if err := c.checkPolicy(); err != nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+		}
+		if err := c.checkKey(key); err != nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+		}
+		return c.newDetectingAuthenticator(key)
+	}
+	if c.authenticator != nil {
+		return c.authenticator
+	}
+	if c.algorithm != "" {
+		return c.newRegisteredAuthenticator(key)
+	}
+	if c.checksum {
+		if c.policy != nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: fmt.Errorf("%w: checksums do not authenticate messages", ErrPolicyViolation)}
+		}
+		return authenticator.NewChecksumAuthenticator().
+			WithMACEncoding(c.macEncoding).
+			WithAssociatedData(c.aad).
+			WithMaxMessageSize(c.maxMessageSize)
+	}
+	if err := c.checkPolicy(); err != nil {
+		return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+	}
+	if c.frameCodec != nil {
+		if c.keyProvider != nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: errors.New("frame codecs do not support key providers")}
+		}
+		if err := c.checkKey(key); err != nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+		}
+		return c.newCodecAuthenticator(key)
+	}
+	if c.cborHeaders {
+		if c.keyProvider != nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: errors.New("CBOR headers do not support key providers")}
+		}
+		if err := c.checkKey(key); err != nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+		}
+		return c.newCBORAuthenticator(key)
+	}
+	if c.keyProvider != nil {
+		return &keyProviderAuthenticator{
+			provider:       c.keyProvider,
+			keyID:          c.keyID,
+			hashFn:         c.hashFn,
+			maxMessageSize: c.maxMessageSize,
+			tagSize:        c.tagSize,
+			encoding:       c.macEncoding,
+			headerLen:      c.headerLength(),
+			aad:            c.aad,
+			minKeyLength:   c.minKeyLength,
+		}
+	}
+	if err := c.checkKey(key); err != nil {
+		return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+	}
+	return authenticator.NewDefaultMessageAuthenticator(c.hashFn, key).
+		WithTagSize(c.tagSize).
+		WithMACEncoding(c.macEncoding).
+		WithAssociatedData(c.aad).
+		WithMaxMessageSize(c.maxMessageSize)
+}
+
+// newRegisteredAuthenticator returns a MessageAuthenticator for the
+// registered algorithm (see authenticator.Register) of the configuration
+func (c *config) newRegisteredAuthenticator(key []byte) authenticator.MessageAuthenticator {
+	alg, ok := authenticator.GetByName(c.algorithm)
+	if !ok {
+		return &failingAuthenticator{headerLen: c.headerLength(), err: fmt.Errorf("unknown algorithm %q", c.algorithm)}
+	}
+	if c.policy != nil {
+		if alg.HashFn == nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: fmt.Errorf("%w: algorithm %q is not HMAC based", ErrPolicyViolation, alg.Name)}
+		}
+		if err := c.policy.Check(alg.HashFn); err != nil {
+			return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+		}
+	}
+	if err := c.checkKey(key); err != nil {
+		return &failingAuthenticator{headerLen: c.headerLength(), err: err}
+	}
+	return alg.New(key)
+}
+
+func (c *config) newCBORAuthenticator(key []byte) *authenticator.CBORMessageAuthenticator {
+	a := authenticator.NewCBORMessageAuthenticator(c.hashFn, key)
+	if c.allowlist != nil {
+		a.WithAcceptedKeyIDs(c.allowlist.KeyIDs...)
+	}
+	return a.WithKeyID(c.cborKeyID).
+		WithTagSize(c.tagSize).
+		WithAssociatedData(c.aad).
+		WithMaxMessageSize(c.maxMessageSize).
+		WithClock(c.clock.Now)
+}
+
+func (c *config) newCodecAuthenticator(key []byte) *authenticator.CodecMessageAuthenticator {
+	return authenticator.NewCodecMessageAuthenticator(c.frameCodec, c.hashFn, key).
+		WithTagSize(c.tagSize).
+		WithAssociatedData(c.aad).
+		WithMaxMessageSize(c.maxMessageSize)
+}
+
+// defaultFraming returns whether frames have the default header format (see
+// authenticator.ParseFrameHeader) with the configuration, as opposed to e.g.
+// CBOR headers, frame codecs, or MessageAuthenticators set by the caller
+func (c *config) defaultFraming() bool {
+	return !c.formatDetection && c.authenticator == nil && c.algorithm == "" && c.frameCodec == nil && !c.cborHeaders
+}
+
+// headerLength returns the length of frame headers for the configuration
+func (c *config) headerLength() int {
+	if c.frameCodec != nil {
+		return c.newCodecAuthenticator(nil).GetMessageAuthenticationHeaderLength()
+	}
+	if c.cborHeaders {
+		return c.newCBORAuthenticator(nil).GetMessageAuthenticationHeaderLength()
+	}
+	if c.checksum {
+		return authenticator.HeaderLengthWithEncoding(authenticator.NewCRC32C().Size(), c.macEncoding)
+	}
+	if c.tagSize != 0 && c.tagSize < c.hashFn().Size() {
+		return authenticator.HeaderLengthWithEncoding(c.tagSize, c.macEncoding)
+	}
+	return authenticator.HeaderLengthWithEncoding(c.hashFn().Size(), c.macEncoding)
+}
+
+// checkPolicy checks the configuration against the Policy, if any,
+// and checks that the tag size is safe regardless
+func (c *config) checkPolicy() error {
+	hashSize := c.hashFn().Size()
+	if c.tagSize != 0 && (c.tagSize > hashSize || c.tagSize < minTagSize || c.tagSize < hashSize/2) {
+		return fmt.Errorf("invalid tag size %d, must be between max(%d, %d) and %d", c.tagSize, minTagSize, hashSize/2, hashSize)
+	}
+	if c.policy == nil {
+		return nil
+	}
+	if err := c.policy.Check(c.hashFn); err != nil {
+		return err
+	}
+	if c.tagSize != 0 {
+		return c.policy.CheckTagSize(c.tagSize)
+	}
+	return nil
+}
+
+// checkFraming checks the size of fixed size records, the
+// padding block size, and the compression algorithm, if set
+func (c *config) checkFraming() error {
+	if c.paddingBlockSize < 0 || c.paddingBlockSize > math.MaxUint16 {
+		return fmt.Errorf("invalid padding block size %d, must be at most %d", c.paddingBlockSize, math.MaxUint16)
+	}
+	if c.compression != "" {
+		if _, ok := getCompressorByName(c.compression); !ok {
+			return fmt.Errorf("unknown compression algorithm %q", c.compression)
+		}
+		if c.recordSize != 0 {
+			return errors.New("compression is not supported with fixed size records")
+		}
+	}
+	if c.recordSize == 0 {
+		return nil
+	}
+	if c.recordSize < minRecordSize {
+		return fmt.Errorf("invalid record size %d, must be at least %d", c.recordSize, minRecordSize)
+	}
+	if c.maxMessageSize > 0 && c.recordSize > c.maxMessageSize {
+		return fmt.Errorf("invalid record size %d, must be at most the max message size %d", c.recordSize, c.maxMessageSize)
+	}
+	return nil
+}
+
+// checkKey checks the given key if WithMinKeyLength is set
+func (c *config) checkKey(key []byte) error {
+	if c.minKeyLength <= 0 {
+		return nil
+	}
+	return CheckKey(key, c.minKeyLength)
+}
+
+// WithMessageAuthenticator sets the MessageAuthenticator used by stream readers
+// and writers, in which case the key, hash function, and max message size given
+// to them are ignored. This is mostly useful to inject fakes in tests (see the
+// protocol/authenticator/authenticatortest package).
+func WithMessageAuthenticator(a authenticator.MessageAuthenticator) Option {
+	return func(c *config) { c.authenticator = a }
+}
+
+// WithKeyProvider sets the KeyProvider stream readers and writers look up
+// the key with the given ID from on every message, in which case the key
+// given to them is ignored (and may be nil)
+func WithKeyProvider(provider KeyProvider, keyID string) Option {
+	return func(c *config) {
+		c.keyProvider = provider
+		c.keyID = keyID
+	}
+}
+
+// WithHashFn sets the hash function used to compute MACs (default SHA-256)
+func WithHashFn(hashFn func() hash.Hash) Option {
+	return func(c *config) { c.hashFn = hashFn }
+}
+
+// WithAlgorithm makes stream readers and writers use the MessageAuthenticator
+// registered (see authenticator.Register) with the given name, e.g. a third
+// party one, in which case the hash function, tag size, MAC encoding, associated
+// data, and max message size given to them are ignored. Every read and write
+// fails if no algorithm with the given name is registered. The peer must be
+// configured with the same algorithm, unless it is negotiated by a handshake
+// (see WithAlgorithms).
+func WithAlgorithm(name string) Option {
+	return func(c *config) { c.algorithm = name }
+}
+
+// WithAlgorithms sets the registered algorithms (see authenticator.Register)
+// which PSK handshakes (see NewPSKClientConn) negotiate: clients offer them in
+// order of preference, and servers pick the first one they accept, whose ID
+// is sent back to the client. The resulting Conn uses the picked algorithm
+// (as if configured WithAlgorithm), which AuthInfo reports. Either both or
+// neither peer must set it, and handshakes without a common algorithm fail.
+// It has no effect on anything else.
+func WithAlgorithms(names ...string) Option {
+	return func(c *config) { c.algorithms = names }
+}
+
+// WithChecksum makes stream readers and writers use (unkeyed) CRC-32C checksums
+// rather than MACs, with the same framing, in which case the key, hash function,
+// and tag size given to them are ignored. Note that this is NOT authentication:
+// anyone can forge checksums, which only detect accidental corruption (e.g. over
+// trusted links). It is rejected by every Policy (see WithPolicy).
+func WithChecksum() Option {
+	return func(c *config) { c.checksum = true }
+}
+
+// WithMACEncoding sets the text encoding of MACs in frame headers (default
+// authenticator.StdBase64), e.g. authenticator.Hex for frames which end up in
+// logs, or authenticator.URLBase64 for frames embedded in URLs. Both peers
+// must use the same encoding.
+func WithMACEncoding(encoding authenticator.MACEncoding) Option {
+	return func(c *config) { c.macEncoding = encoding }
+}
+
+// WithCBORHeaders makes stream readers and writers use extensible frame headers,
+// with fields (including the given key ID, which may be empty, and a sequence
+// number and timestamp) encoded as a CBOR map (see the authenticator package's
+// CBORMessageAuthenticator). Frames are not compatible with the default ones,
+// so both peers must use it. It cannot be used with WithKeyProvider,
+// WithVerifierPool, or WithResync, and the MAC encoding is ignored.
+func WithCBORHeaders(keyID string) Option {
+	return func(c *config) {
+		c.cborHeaders = true
+		c.cborKeyID = keyID
+	}
+}
+
+// WithFrameCodec makes stream readers and writers lay out frames with the given
+// FrameCodec (see the authenticator package's CodecMessageAuthenticator), e.g. to
+// match the framing of an existing protocol, while MACs are computed as usual
+// (with the hash function, tag size, and associated data configured). It cannot
+// be used with WithKeyProvider or extensions (e.g. WithCompression), and the
+// MAC encoding is ignored.
+func WithFrameCodec(codec authenticator.FrameCodec) Option {
+	return func(c *config) { c.frameCodec = codec }
+}
+
+// WithFormatDetection makes readers accept frames in either the default format
+// or with CBOR headers (see WithCBORHeaders), detecting the format of every
+// frame, while writers keep writing the configured format. This lets fleets
+// migrate between formats without a flag day: readers are upgraded first, then
+// writers. It cannot be used with WithMessageAuthenticator, WithAlgorithm,
+// WithChecksum, WithKeyProvider, WithVerifierPool, or WithResync, nor with
+// authenticator.Raw MAC encoding, whose MACs could be mistaken for CBOR headers.
+func WithFormatDetection() Option {
+	return func(c *config) { c.formatDetection = true }
+}
+
+// WithMaxMessageSize sets the maximum size (in bytes, excluding MACs) of
+// messages. Readers reject messages larger than this, and writers split
+// data larger than this into several messages (default DefaultMaxMessageSize).
+// Zero means no limit.
+func WithMaxMessageSize(size int) Option {
+	return func(c *config) { c.maxMessageSize = size }
+}
+
+// WithReadFrameSize makes AppendMACReaders read exactly the given number of
+// bytes (fewer only at the end of the input) of the underlying reader into every
+// frame, keeping frames which do not fit the buffer given to Read for the next
+// reads. Frames then do not depend on how the underlying reader delivers bytes
+// (e.g. TCP segmentation) nor on the size of the buffers given to Read. Sizes
+// larger than the max message size (see WithMaxMessageSize) are capped to it.
+// Zero (the default) means every frame holds what a single Read of the
+// underlying reader returns, up to what fits the buffer given to Read.
+func WithReadFrameSize(size int) Option {
+	return func(c *config) { c.readFrameSize = size }
+}
+
+// WithDelimiter makes AppendMACReaders and AppendMACWriters treat their input
+// as records ending with the given delimiter (e.g. '\n' for lines), producing
+// one frame per record, delimiter included, such that verified output is the
+// same as the input. Records larger than the max message size (see
+// WithMaxMessageSize) are split into several frames. AppendMACWriters keep a
+// partial record until the rest of it is written, and write it on Close. It
+// takes precedence over WithReadFrameSize.
+func WithDelimiter(delim byte) Option {
+	return func(c *config) {
+		c.delimited = true
+		c.delimiter = delim
+	}
+}
+
+// WithFixedRecords makes every frame carry exactly the given number of payload
+// bytes, e.g. for storage systems, or links which must not reveal the sizes of
+// messages: a (big endian uint32) length of the data in the record, the data,
+// and zeros up to the record size, all covered by the MAC. AppendMACWriters
+// and AppendMACReaders fill every record before writing it, padding the last
+// one on Close (or at the end of the input), and VerifyMACReaders and
+// VerifyMACWriters (which must also be configured with it) reject frames of
+// any other size. The size must be larger than 4 bytes and no larger than the
+// max message size, otherwise every read and write fails. It takes precedence
+// over WithDelimiter and WithReadFrameSize, and AppendMACWriters configured
+// with it do not support WriteBatch nor WriteWithExtensions.
+func WithFixedRecords(size int) Option {
+	return func(c *config) { c.recordSize = size }
+}
+
+// WithPadding makes writers (and AppendMACReaders) pad the payload of every
+// frame, i.e. the message and its extensions, to a multiple of the given block
+// size (at most 65535 bytes), such that observers cannot infer the exact length
+// of messages. The padding is an authenticator.ExtensionPadding extension in
+// the authenticated header, which readers drop, so readers need not be
+// configured with it. MessageAuthenticators must support extensions (see
+// authenticator.ExtensionFramer), otherwise every write fails. Zero (the
+// default) means no padding.
+func WithPadding(blockSize int) Option {
+	return func(c *config) { c.paddingBlockSize = blockSize }
+}
+
+// WithCompression makes writers (and AppendMACReaders) compress messages with
+// the Compressor registered with the given name (e.g. "gzip", see
+// RegisterCompressor) before computing their MACs, signaling the algorithm
+// with an authenticator.ExtensionCompression extension in the authenticated
+// header. Messages which do not shrink are sent uncompressed. Readers
+// decompress messages with any registered Compressor, rejecting those which
+// decompress to more than the max message size, so they need not be configured
+// with it (but must know the algorithm). Since there is no handshake, nothing
+// is negotiated: writers must only use algorithms their readers know. It
+// cannot be used with WithFixedRecords, and MessageAuthenticators must support
+// extensions (see authenticator.ExtensionFramer).
+func WithCompression(name string) Option {
+	return func(c *config) { c.compression = name }
+}
+
+// WithTagSize truncates MACs to the given size (in bytes, before encoding),
+// reducing the overhead per message (e.g. 16 bytes i.e. 128 bits, rather than
+// 32 with SHA-256). As per RFC 2104, the size must be no less than half the
+// size of the hash function nor less than 10 bytes, otherwise every read and
+// write fails. Both sides must use the same tag size. Zero means no truncation.
+func WithTagSize(size int) Option {
+	return func(c *config) { c.tagSize = size }
+}
+
+// WithAssociatedData binds the given associated data (e.g. a connection ID or
+// protocol version) into every MAC without transmitting it, such that messages
+// are only accepted by readers configured with the same associated data. This
+// prevents splicing messages from one context (e.g. connection) into another.
+func WithAssociatedData(aad []byte) Option {
+	return func(c *config) { c.aad = aad }
+}
+
+// WithoutDirectionBinding disables binding the direction of messages into
+// their MACs in Conns created with NewClientConn and NewServerConn (and their
+// stream counterparts), e.g. to talk to peers which use the deprecated NewConn
+// or NewStreamConn. It has no effect on anything else.
+func WithoutDirectionBinding() Option {
+	return func(c *config) { c.noDirectionBinding = true }
+}
+
+// WithReadTimeout sets a deadline on every Read of a Conn, such that a peer
+// which stalls (e.g. after sending the header of a message but not its body)
+// cannot block a reader forever. Since every Read reads at most one message,
+// this bounds the time to receive a message, including waiting for it to
+// start. A read which times out between messages can be retried, but one
+// which times out mid-frame fails the Conn for good, since the part of the
+// frame read is lost. It overrides deadlines set with SetReadDeadline and has
+// no effect on anything other than Conns. Zero (the default) means no timeout.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(c *config) { c.readTimeout = timeout }
+}
+
+// WithHandshakeTimeout bounds the time handshakes (see NewPSKClientConn and
+// NewSessionClientConn) may take, such that a peer which stalls cannot block
+// them forever. The deadline of the connection is set for the handshake and
+// cleared afterwards, overriding deadlines set with SetDeadline. Zero (the
+// default) means no timeout, such that deadlines set on the connection apply.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(c *config) { c.handshakeTimeout = timeout }
+}
+
+// WithWriteTimeout sets a deadline on every Write of a Conn, such that a peer
+// which stops reading cannot block a writer forever. It overrides deadlines
+// set with SetWriteDeadline and has no effect on anything other than Conns.
+// Zero (the default) means no timeout.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(c *config) { c.writeTimeout = timeout }
+}
+
+// WithCloseNotify makes writers send an authenticated close notification on
+// Close (and AppendMACReaders at the end of their input), and makes readers
+// require one: a stream ending without it results in ErrTruncatedStream rather
+// than io.EOF. This lets receivers tell graceful shutdown from truncation.
+// Readers without this option also return io.EOF on a close notification.
+func WithCloseNotify() Option {
+	return func(c *config) { c.closeNotify = true }
+}
+
+// WithHeartbeat makes a Conn send an authenticated ping every interval, which
+// peers answer with a pong, to measure round trip times (see Conn.RTT) and
+// check liveness: if nothing at all is received for longer than the timeout,
+// the Conn is closed. Pongs are only processed while the Conn is being read
+// from, and both peers must use authio versions which support control frames.
+// It has no effect on anything other than Conns. Zero timeout means no timeout.
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(c *config) {
+		c.heartbeatInterval = interval
+		c.heartbeatTimeout = timeout
+	}
+}
+
+// WithFailureLimiter makes a Conn report messages failing verification to the
+// given FailureLimiter (which may be shared by many Conns), and close itself once
+// its peer exceeds the limit. Listeners also drop connections of banned peers
+// as soon as they are accepted. It has no effect on anything else.
+func WithFailureLimiter(l *FailureLimiter) Option {
+	return func(c *config) { c.failureLimiter = l }
+}
+
+// WithRateLimit throttles the messages a Conn reads and writes to the given
+// RateLimits (either of which may be zero for no limit), e.g. to enforce
+// per-connection limits in tunnels and sidecars. Writes block until the limit
+// allows them, as do reads before returning what was read, such that a peer
+// writing faster than allowed is slowed down by the underlying transport's
+// flow control. Bursts of up to a second worth of either rate are allowed,
+// and control frames (e.g. heartbeats) are not limited. It has no effect on
+// anything but Conns.
+func WithRateLimit(read, write RateLimit) Option {
+	return func(c *config) {
+		c.readRateLimit = read
+		c.writeRateLimit = write
+	}
+}
+
+// WithDatagramTolerance sets how far in the past or future (as per the Clock,
+// see WithClock) the timestamps of datagrams read by PacketConns may be, which
+// must exceed the clock skew between peers plus the delay of the network.
+// Longer tolerances keep the replay windows of senders for longer. It has no
+// effect on anything but PacketConns. The default is DefaultDatagramTolerance.
+func WithDatagramTolerance(tolerance time.Duration) Option {
+	return func(c *config) { c.datagramTolerance = tolerance }
+}
+
+// WithClock sets the Clock the time is read from wherever it is recorded
+// (e.g. the timestamps of CBOR headers, see WithCBORHeaders, those of the
+// datagrams of PacketConns, and the creation time of Conns) rather than the
+// system clock. Deadlines and
+// heartbeats, which rely on the runtime's timers, always use the system clock.
+func WithClock(clock Clock) Option {
+	return func(c *config) { c.clock = clock }
+}
+
+// WithMinKeyLength enables strict key checking: keys shorter than the given
+// length (in bytes, see DefaultMinKeyLength) or well-known placeholder values
+// are rejected (see CheckKey). Since constructors do not return errors, every
+// read and write then fails with an error wrapping ErrWeakKey instead.
+func WithMinKeyLength(length int) Option {
+	return func(c *config) { c.minKeyLength = length }
+}
+
+// WithVerifierPool makes VerifyMACReaders (and Conns) read frames ahead and
+// verify their MACs on the given (shared) VerifierPool, rather than one at a
+// time while being read from. Messages are still returned in order. Since
+// frames are read ahead of the application, read deadlines (see
+// WithReadTimeout) apply even while the application is not reading.
+// MessageAuthenticators set WithMessageAuthenticator must use the default
+// frame format (see authenticator.ParseFrameHeader).
+func WithVerifierPool(pool *VerifierPool) Option {
+	return func(c *config) { c.verifierPool = pool }
+}
+
+// WithMaxBufferedBytes limits the frames VerifyMACReaders (and Conns) read
+// ahead WithVerifierPool to about the given number of bytes: once that many
+// are pending, no new frames are read from the underlying reader until the
+// application reads enough of them, pushing back on the sender. A single
+// frame is always read, however large. Without a VerifierPool, frames are
+// only read as the application reads, so at most one message is buffered.
+// Zero (the default) means only the number of frames read ahead is limited.
+func WithMaxBufferedBytes(n int) Option {
+	return func(c *config) { c.maxBufferedBytes = n }
+}
+
+// WithResync makes VerifyMACReaders (and Conns) skip over corrupted data
+// rather than failing: upon a frame failing verification, they scan forward
+// one byte at a time until a valid frame is found. The given callback (which
+// may be nil) is called with the number of bytes skipped and the error which
+// caused the scan. Since a corrupted length field may claim a message of up
+// to the max message size (see WithMaxMessageSize), the scan may need to read
+// that much before moving on. It is meant for lossy or occasionally corrupting
+// transports, and takes precedence over WithVerifierPool.
+func WithResync(onSkip func(skipped int64, cause error)) Option {
+	return func(c *config) {
+		c.resync = true
+		c.onResync = onSkip
+	}
+}
+
+// WithVerifyState makes VerifyMACReaders resume from the given state (see
+// VerifyMACReader.State), in which case their underlying reader must start at
+// the offset of the state in the stream (e.g. a file seeked to it, or a
+// download resumed with a range request). It has no effect on anything else.
+func WithVerifyState(state VerifyState) Option {
+	return func(c *config) { c.verifyState = &state }
+}
+
+// WithCodec sets the Codec Encoders and Decoders marshal values with
+// (default JSONCodec), e.g. GobCodec. It has no effect on anything else.
+func WithCodec(codec Codec) Option {
+	return func(c *config) { c.codec = codec }
+}
+
+// WithFrameErrorPolicy sets what VerifyMACReaders (and Conns) do upon frames
+// failing verification (see FrameErrorPolicy). With SkipAndReport, the given
+// callback (which may be nil) is called with the error of every frame dropped.
+func WithFrameErrorPolicy(policy FrameErrorPolicy, onBadFrame func(err error)) Option {
+	return func(c *config) {
+		c.frameErrorPolicy = policy
+		c.onBadFrame = onBadFrame
+	}
+}
+
+// WithOversizedFramePolicy sets what VerifyMACReaders (and Conns) do upon
+// frames larger than the max message size (see OversizedFramePolicy and
+// WithMaxMessageSize). With DrainOversizedFrame, the given callback (which may
+// be nil) is called with the error of every frame drained. It has no effect
+// WithVerifierPool or WithResync, which always abort.
+func WithOversizedFramePolicy(policy OversizedFramePolicy, onOversized func(err error)) Option {
+	return func(c *config) {
+		c.oversizedPolicy = policy
+		c.onOversized = onOversized
+	}
+}
+
+// WithTrailingDataPolicy sets what VerifyMACReaders (and Conns) do when the
+// underlying reader ends mid-frame (see TrailingDataPolicy). Note that with
+// WithCloseNotify, a stream ending without a close notification still fails
+// with ErrTruncatedStream.
+func WithTrailingDataPolicy(policy TrailingDataPolicy) Option {
+	return func(c *config) { c.trailingDataPolicy = policy }
+}
+
+// WithPolicy sets the Policy the configuration must comply with, overriding
+// the default set with SetDefaultPolicy. A nil Policy allows everything.
+func WithPolicy(p *Policy) Option {
+	return func(c *config) { c.policy = p }
+}
+
+// WithMetrics sets the metrics.Metrics implementation
+// message authentication events are recorded to
+func WithMetrics(m metrics.Metrics) Option {
+	return func(c *config) { c.metrics = m }
+}
+
+// WithLogger sets the Logger noteworthy events (e.g. messages
+// failing verification) are logged to. Note that *slog.Logger
+// implements Logger.
+func WithLogger(l Logger) Option {
+	return func(c *config) { c.logger = l }
+}
+
+// failingAuthenticator is a MessageAuthenticator which fails every
+// operation, used in place of one with an invalid configuration
+// (e.g. a weak key) since constructors do not return errors
+type failingAuthenticator struct {
+	headerLen int
+	err       error
+}
+
+// ensure failingAuthenticator implements CloseNotifier, ControlFramer, and ExtensionFramer at compile-time
+var (
+	_ authenticator.CloseNotifier   = (*failingAuthenticator)(nil)
+	_ authenticator.ControlFramer   = (*failingAuthenticator)(nil)
+	_ authenticator.ExtensionFramer = (*failingAuthenticator)(nil)
+)
+
+func (a *failingAuthenticator) GetMessageAuthenticationHeaderLength() int {
+	return a.headerLen
+}
+
+func (a *failingAuthenticator) GetMessageAuthenticationHeader([]byte) ([]byte, error) {
+	return nil, a.err
+}
+
+func (a *failingAuthenticator) ReadNext(io.Reader) ([]byte, error) {
+	return nil, a.err
+}
+
+func (a *failingAuthenticator) AuthenticateMessages([]byte) ([]byte, int, error) {
+	return nil, 0, a.err
+}
+
+func (a *failingAuthenticator) GetCloseNotifyHeader() ([]byte, error) {
+	return nil, a.err
+}
+
+func (a *failingAuthenticator) GetControlFrameHeader([]byte) ([]byte, error) {
+	return nil, a.err
+}
+
+func (a *failingAuthenticator) ReadNextFrame(io.Reader) ([]byte, bool, error) {
+	return nil, false, a.err
+}
+
+func (a *failingAuthenticator) GetMessageAuthenticationHeaderWithExtensions([]byte, []authenticator.Extension) ([]byte, error) {
+	return nil, a.err
+}
+
+func (a *failingAuthenticator) ReadNextFrameWithExtensions(io.Reader) ([]byte, []authenticator.Extension, bool, error) {
+	return nil, nil, false, a.err
+}
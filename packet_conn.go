@@ -0,0 +1,264 @@
+package authio
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adrianosela/authio/metrics"
+)
+
+var (
+	// ErrInvalidDatagram is returned by PacketConn.ReadFrom
+	// when a datagram received fails MAC verification
+	ErrInvalidDatagram = errors.New("invalid datagram")
+
+	// ErrReplayedDatagram is returned by PacketConn.ReadFrom when a
+	// datagram received has a valid MAC but was already received (or
+	// is too old to tell), or is a reflection of our own datagram
+	ErrReplayedDatagram = errors.New("replayed datagram")
+
+	// ErrStaleDatagram is returned by PacketConn.ReadFrom when a datagram
+	// received has a valid MAC but was sent (as per its timestamp) further
+	// in the past or future than tolerated (see WithDatagramTolerance)
+	ErrStaleDatagram = errors.New("stale datagram")
+)
+
+const (
+	// every PacketConn identifies itself with a random 64 bit sender
+	// ID followed by a 64 bit sequence number and a 64 bit timestamp
+	// (in nanoseconds since the Unix epoch) in every datagram
+	senderIDFieldSize  = 8
+	sequenceFieldSize  = 8
+	timestampFieldSize = 8
+
+	// DefaultDatagramTolerance is how far in the past or future the timestamps
+	// of datagrams read by PacketConns may be by default (see WithDatagramTolerance)
+	DefaultDatagramTolerance = time.Minute
+)
+
+// PacketConn is a net.PacketConn that prepends MACs to every datagram
+// written and verifies and strips MACs from every datagram read. Since
+// every datagram is exactly one message, no length header is needed.
+//
+// Every datagram carries (covered by the MAC) the sender's random ID, a
+// sequence number and a timestamp. Receivers reject datagrams whose timestamp
+// is off by more than a tolerance (see WithDatagramTolerance), and replayed
+// and reflected datagrams with a sliding window per sender. Since replays of
+// datagrams from senders not heard from for twice the tolerance would be
+// stale anyway, their windows are forgotten, such that senders which come
+// and go (e.g. restart with a new ID) do not grow the PacketConn forever.
+type PacketConn struct {
+	net.PacketConn // underlying net.PacketConn to read from and write to
+
+	hashFn    func() hash.Hash
+	macLen    int
+	aad       []byte
+	tolerance time.Duration
+	clock     Clock
+	configErr error
+	metrics   metrics.Metrics
+	logger    Logger
+
+	// the key is destroyed by Close while reads and writes may be pending
+	keyLock sync.RWMutex
+	key     []byte
+
+	senderID []byte
+	sequence uint64 // accessed atomically
+
+	windowsLock sync.Mutex
+	windows     map[[senderIDFieldSize]byte]*senderWindow
+	lastPruned  time.Time
+}
+
+// senderWindow is the replay window of a sender, along with
+// the (local) time a datagram was last accepted from it
+type senderWindow struct {
+	replayWindow
+	lastSeen time.Time
+}
+
+// ensure PacketConn implements net.PacketConn at compile-time
+var _ net.PacketConn = (*PacketConn)(nil)
+
+// NewPacketConn wraps a net.PacketConn in a PacketConn
+func NewPacketConn(conn net.PacketConn, key []byte, opts ...Option) *PacketConn {
+	config := newConfig(opts...)
+
+	macLen := config.hashFn().Size()
+	if config.tagSize != 0 {
+		macLen = config.tagSize
+	}
+
+	configErr := config.checkPolicy()
+	if configErr == nil {
+		configErr = config.checkKey(key)
+	}
+	if configErr == nil && config.datagramTolerance <= 0 {
+		configErr = fmt.Errorf("datagram tolerance must be positive, got %s", config.datagramTolerance)
+	}
+
+	senderID := make([]byte, senderIDFieldSize)
+	if _, err := rand.Read(senderID); err != nil {
+		// note: crypto/rand.Read() only fails if the system's
+		// secure random number generator is unavailable
+		panic(fmt.Sprintf("failed to generate sender ID: %s", err))
+	}
+
+	return &PacketConn{
+		PacketConn: conn,
+		hashFn:     config.hashFn,
+		key:        append([]byte{}, key...),
+		macLen:     macLen,
+		aad:        config.aad,
+		tolerance:  config.datagramTolerance,
+		clock:      config.clock,
+		configErr:  configErr,
+		metrics:    config.metrics,
+		logger:     config.logger,
+		senderID:   senderID,
+		windows:    make(map[[senderIDFieldSize]byte]*senderWindow),
+		lastPruned: config.clock.Now(),
+	}
+}
+
+// ReadFrom reads a datagram onto the given buffer (with MAC excluded).
+// Datagrams which fail verification result in ErrInvalidDatagram, stale
+// datagrams in ErrStaleDatagram, and replayed datagrams in ErrReplayedDatagram.
+// The caller may keep reading from the PacketConn after receiving any of them.
+func (c *PacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if c.configErr != nil {
+		return 0, nil, c.configErr
+	}
+	headerLen := c.macLen + senderIDFieldSize + sequenceFieldSize + timestampFieldSize
+	buf := make([]byte, len(p)+headerLen)
+
+	n, addr, err := c.PacketConn.ReadFrom(buf)
+	if err != nil {
+		return 0, addr, err
+	}
+	if n < headerLen {
+		c.metrics.VerificationFailed()
+		c.logger.Warn("received datagram too short to have header", "remote_addr", addr, "size", n)
+		return 0, addr, fmt.Errorf("%w: too short to have header, got %d and expected at least %d", ErrInvalidDatagram, n, headerLen)
+	}
+
+	mac := buf[:c.macLen]
+	authenticated := buf[c.macLen:n]
+
+	if !hmac.Equal(mac, c.computeMAC(authenticated)) {
+		c.metrics.VerificationFailed()
+		c.logger.Warn("received datagram with invalid MAC", "remote_addr", addr, "size", n)
+		return 0, addr, fmt.Errorf("%w: MAC mismatch", ErrInvalidDatagram)
+	}
+
+	var senderID [senderIDFieldSize]byte
+	copy(senderID[:], authenticated[:senderIDFieldSize])
+	seq := binary.BigEndian.Uint64(authenticated[senderIDFieldSize : senderIDFieldSize+sequenceFieldSize])
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64(authenticated[senderIDFieldSize+sequenceFieldSize:])))
+	msg := authenticated[senderIDFieldSize+sequenceFieldSize+timestampFieldSize:]
+
+	if hmac.Equal(senderID[:], c.senderID) {
+		c.metrics.VerificationFailed()
+		c.logger.Warn("received reflected datagram", "remote_addr", addr, "sequence", seq)
+		return 0, addr, fmt.Errorf("%w: datagram was sent by this PacketConn", ErrReplayedDatagram)
+	}
+	now := c.clock.Now()
+	if age := now.Sub(sent); age > c.tolerance || age < -c.tolerance {
+		c.metrics.VerificationFailed()
+		c.logger.Warn("received stale datagram", "remote_addr", addr, "sequence", seq, "age", age)
+		return 0, addr, fmt.Errorf("%w: sent %s ago and expected at most %s either way", ErrStaleDatagram, age, c.tolerance)
+	}
+	if !c.acceptSequence(senderID, seq, now) {
+		c.metrics.VerificationFailed()
+		c.logger.Warn("received replayed datagram", "remote_addr", addr, "sequence", seq)
+		return 0, addr, fmt.Errorf("%w: sequence number %d already received or too old", ErrReplayedDatagram, seq)
+	}
+	c.metrics.MessageVerified(len(msg))
+
+	return copy(p, msg), addr, nil
+}
+
+// WriteTo writes the contents of a buffer as a single datagram (with an included MAC)
+func (c *PacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if c.configErr != nil {
+		return 0, c.configErr
+	}
+	headerLen := senderIDFieldSize + sequenceFieldSize + timestampFieldSize
+	authenticated := make([]byte, headerLen, headerLen+len(p))
+	copy(authenticated, c.senderID)
+	binary.BigEndian.PutUint64(authenticated[senderIDFieldSize:], atomic.AddUint64(&c.sequence, 1))
+	binary.BigEndian.PutUint64(authenticated[senderIDFieldSize+sequenceFieldSize:], uint64(c.clock.Now().UnixNano()))
+	authenticated = append(authenticated, p...)
+
+	datagram := append(c.computeMAC(authenticated), authenticated...)
+	c.metrics.MessageSigned(len(p))
+
+	n, err := c.PacketConn.WriteTo(datagram, addr)
+	if err != nil {
+		return 0, err
+	}
+	if n < len(datagram) {
+		// datagrams are written whole or not at all, this should never happen
+		return 0, fmt.Errorf("short datagram write, wrote %d of %d bytes", n, len(datagram))
+	}
+	return len(p), nil
+}
+
+// Close destroys the key material held by the PacketConn
+// and closes the underlying net.PacketConn
+func (c *PacketConn) Close() error {
+	c.keyLock.Lock()
+	zeroize(c.key)
+	c.keyLock.Unlock()
+	return c.PacketConn.Close()
+}
+
+func (c *PacketConn) acceptSequence(senderID [senderIDFieldSize]byte, seq uint64, now time.Time) bool {
+	c.windowsLock.Lock()
+	defer c.windowsLock.Unlock()
+
+	// windows are pruned at most once per tolerance, rather
+	// than scanned for every datagram read
+	if now.Sub(c.lastPruned) > c.tolerance {
+		for id, window := range c.windows {
+			if now.Sub(window.lastSeen) > 2*c.tolerance {
+				delete(c.windows, id)
+			}
+		}
+		c.lastPruned = now
+	}
+
+	window, ok := c.windows[senderID]
+	if !ok {
+		window = &senderWindow{}
+		c.windows[senderID] = window
+	}
+	if !window.accept(seq) {
+		return false
+	}
+	window.lastSeen = now
+	return true
+}
+
+func (c *PacketConn) computeMAC(msg []byte) []byte {
+	c.keyLock.RLock()
+	defer c.keyLock.RUnlock()
+
+	computed := hmac.New(c.hashFn, c.key)
+	// note: hash.Write() never returns an error as per godoc (https://pkg.go.dev/hash#Hash)
+	if len(c.aad) > 0 {
+		computed.Write(binary.BigEndian.AppendUint64(nil, uint64(len(c.aad))))
+		computed.Write(c.aad)
+	}
+	computed.Write(msg)
+	return computed.Sum(nil)[:c.macLen]
+}
@@ -0,0 +1,180 @@
+package authio
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_PacketConn(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	rawServer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer rawServer.Close()
+
+	rawClient, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer rawClient.Close()
+
+	server := NewPacketConn(rawServer, mockKey)
+	client := NewPacketConn(rawClient, mockKey)
+
+	buf := make([]byte, 1024)
+
+	// authenticated datagram
+	n, err := client.WriteTo([]byte("mock data"), server.LocalAddr())
+	assert.NoError(t, err)
+	assert.Equal(t, len("mock data"), n)
+
+	n, addr, err := server.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock data", string(buf[:n]))
+	assert.Equal(t, client.LocalAddr().String(), addr.String())
+
+	// unauthenticated datagram
+	_, err = rawClient.WriteTo([]byte("mock data with no MAC at all"), server.LocalAddr())
+	assert.NoError(t, err)
+
+	_, _, err = server.ReadFrom(buf)
+	assert.True(t, errors.Is(err, ErrInvalidDatagram))
+
+	// datagram with MAC for a different key
+	_, err = NewPacketConn(rawClient, []byte("other key")).WriteTo([]byte("mock data"), server.LocalAddr())
+	assert.NoError(t, err)
+
+	_, _, err = server.ReadFrom(buf)
+	assert.True(t, errors.Is(err, ErrInvalidDatagram))
+}
+
+func Test_PacketConn_Replay(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	rawServer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer rawServer.Close()
+
+	rawClient, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer rawClient.Close()
+
+	server := NewPacketConn(rawServer, mockKey)
+	client := NewPacketConn(rawClient, mockKey)
+
+	buf := make([]byte, 1024)
+
+	// capture an authenticated datagram off the wire
+	_, err = client.WriteTo([]byte("mock data"), rawClient.LocalAddr())
+	assert.NoError(t, err)
+	n, _, err := rawClient.ReadFrom(buf)
+	assert.NoError(t, err)
+	captured := append([]byte{}, buf[:n]...)
+
+	// reflected back to its sender
+	_, err = rawServer.WriteTo(captured, client.LocalAddr())
+	assert.NoError(t, err)
+	_, _, err = client.ReadFrom(buf)
+	assert.True(t, errors.Is(err, ErrReplayedDatagram))
+
+	// first delivery to the server
+	_, err = rawClient.WriteTo(captured, server.LocalAddr())
+	assert.NoError(t, err)
+	n, _, err = server.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock data", string(buf[:n]))
+
+	// replayed to the server
+	_, err = rawClient.WriteTo(captured, server.LocalAddr())
+	assert.NoError(t, err)
+	_, _, err = server.ReadFrom(buf)
+	assert.True(t, errors.Is(err, ErrReplayedDatagram))
+}
+
+func Test_PacketConn_Freshness(t *testing.T) {
+	mockKey := []byte("mock key")
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name        string
+		skew        time.Duration // of the sender's clock
+		expectedErr error
+	}{
+		{name: "in sync", skew: 0, expectedErr: nil},
+		{name: "behind within tolerance", skew: -30 * time.Second, expectedErr: nil},
+		{name: "ahead within tolerance", skew: 30 * time.Second, expectedErr: nil},
+		{name: "behind beyond tolerance", skew: -2 * time.Minute, expectedErr: ErrStaleDatagram},
+		{name: "ahead beyond tolerance", skew: 2 * time.Minute, expectedErr: ErrStaleDatagram},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rawServer, err := net.ListenPacket("udp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			rawClient, err := net.ListenPacket("udp", "127.0.0.1:0")
+			assert.NoError(t, err)
+
+			server := NewPacketConn(rawServer, mockKey, WithClock(ClockFunc(func() time.Time { return now })))
+			defer server.Close()
+			client := NewPacketConn(rawClient, mockKey, WithClock(ClockFunc(func() time.Time { return now.Add(test.skew) })))
+			defer client.Close()
+
+			_, err = client.WriteTo([]byte("mock data"), server.LocalAddr())
+			assert.NoError(t, err)
+
+			n, _, err := server.ReadFrom(make([]byte, 1024))
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, len("mock data"), n)
+		})
+	}
+}
+
+func Test_PacketConn_PruneWindows(t *testing.T) {
+	mockKey := []byte("mock key")
+	now := time.Unix(1700000000, 0)
+	clock := WithClock(ClockFunc(func() time.Time { return now }))
+
+	rawServer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := NewPacketConn(rawServer, mockKey, clock, WithDatagramTolerance(time.Second))
+	defer server.Close()
+
+	rawClient, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer rawClient.Close()
+
+	buf := make([]byte, 1024)
+	send := func() {
+		// every PacketConn is a new sender, like a restarted peer
+		_, err := NewPacketConn(rawClient, mockKey, clock).WriteTo([]byte("mock data"), server.LocalAddr())
+		assert.NoError(t, err)
+		_, _, err = server.ReadFrom(buf)
+		assert.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		send()
+	}
+	assert.Equal(t, 10, len(server.windows))
+
+	// senders idle for twice the tolerance are forgotten
+	now = now.Add(3 * time.Second)
+	send()
+	assert.Equal(t, 1, len(server.windows))
+}
+
+func Test_PacketConn_InvalidTolerance(t *testing.T) {
+	rawConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	conn := NewPacketConn(rawConn, []byte("mock key"), WithDatagramTolerance(0))
+	defer conn.Close()
+
+	_, err = conn.WriteTo([]byte("mock data"), rawConn.LocalAddr())
+	assert.Error(t, err)
+}
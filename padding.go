@@ -0,0 +1,68 @@
+package authio
+
+import (
+	"fmt"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// padExtensions returns the given extensions followed by an ExtensionPadding
+// extension such that the payload of a frame with the given data (i.e. the
+// extension area and the data) is a multiple of the block size
+func padExtensions(extensions []authenticator.Extension, dataLen, blockSize int) []authenticator.Extension {
+	extensions = append(extensions[:len(extensions):len(extensions)], authenticator.PaddingExtension(0))
+	size := authenticator.ExtensionAreaLength(extensions) + dataLen
+	extensions[len(extensions)-1] = authenticator.PaddingExtension((blockSize - size%blockSize) % blockSize)
+	return extensions
+}
+
+// withoutPadding returns the given extensions without any ExtensionPadding extensions
+func withoutPadding(extensions []authenticator.Extension) []authenticator.Extension {
+	if _, ok := authenticator.FindExtension(extensions, authenticator.ExtensionPadding); !ok {
+		return extensions
+	}
+	unpadded := make([]authenticator.Extension, 0, len(extensions)-1)
+	for _, extension := range extensions {
+		if extension.Type != authenticator.ExtensionPadding {
+			unpadded = append(unpadded, extension)
+		}
+	}
+	return unpadded
+}
+
+// encodeMessage returns the header and payload of the frame of the given data
+// with the given extensions, if any, compressed with the given Compressor (see
+// WithCompression) if any, and padded to a multiple of the given block size
+// (see WithPadding) if positive
+func encodeMessage(a authenticator.MessageAuthenticator, c *Compressor, data []byte, extensions []authenticator.Extension, blockSize int) ([]byte, []byte, error) {
+	payload, extensions, err := compressMessage(c, data, extensions)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blockSize > 0 {
+		extensions = padExtensions(extensions, len(payload), blockSize)
+	}
+	if len(extensions) == 0 {
+		header, err := a.GetMessageAuthenticationHeader(payload)
+		return header, payload, err
+	}
+	framer, ok := a.(authenticator.ExtensionFramer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not support extensions", a)
+	}
+	header, err := framer.GetMessageAuthenticationHeaderWithExtensions(payload, extensions)
+	return header, payload, err
+}
+
+// writtenMessageBytes returns how many bytes of the given data made it
+// into the first n bytes written of its frame with the given header and
+// payload, i.e. none of compressed data unless the whole frame was written
+func writtenMessageBytes(n int, data, header, payload []byte) int {
+	if n >= len(header)+len(payload) {
+		return len(data)
+	}
+	if n <= len(header) || len(payload) != len(data) {
+		return 0
+	}
+	return n - len(header)
+}
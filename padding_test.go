@@ -0,0 +1,67 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithPadding(t *testing.T) {
+	mockKey := []byte("mock key")
+	blockSize := 64
+	headerLen := NewAppendMACWriter(nil, mockKey).authHeaderLen
+
+	for _, size := range []int{0, 1, 10, 58, 64, 100} {
+		message := bytes.Repeat([]byte("a"), size)
+
+		buf := &bytes.Buffer{}
+		_, err := NewAppendMACWriter(buf, mockKey, WithPadding(blockSize)).WriteWithExtensions(message, authenticator.KeyIDExtension("key-1"))
+		assert.Nil(t, err)
+		// observers only learn the number of blocks
+		assert.Equal(t, 0, (buf.Len()-headerLen)%blockSize)
+
+		got, extensions, err := NewVerifyMACReader(buf, mockKey).NextWithExtensions()
+		assert.Nil(t, err)
+		assert.Equal(t, message, got)
+		assert.Equal(t, []authenticator.Extension{authenticator.KeyIDExtension("key-1")}, extensions)
+	}
+}
+
+func Test_WithPaddingAppendMACReader(t *testing.T) {
+	mockKey := []byte("mock key")
+	input := "hello world"
+
+	// frames larger than the buffer given to Read are returned over several reads
+	reader := NewAppendMACReader(strings.NewReader(input), mockKey, WithPadding(256))
+	frames := &bytes.Buffer{}
+	buf := make([]byte, 128)
+	for {
+		n, err := reader.Read(buf)
+		frames.Write(buf[:n])
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.Nil(t, err)
+	}
+
+	out, err := io.ReadAll(NewVerifyMACReader(frames, mockKey))
+	assert.Nil(t, err)
+	assert.Equal(t, input, string(out))
+}
+
+func Test_WithPaddingUnsupported(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	for _, opts := range [][]Option{
+		{WithPadding(64), WithCBORHeaders("")},
+		{WithPadding(1 << 16)},
+	} {
+		_, err := NewAppendMACWriter(&bytes.Buffer{}, mockKey, opts...).Write([]byte("hello"))
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,70 @@
+package authio
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// MinSaltSize is the minimum size (in bytes) of salts for KeyFromPassphrase
+	MinSaltSize = 16
+)
+
+// PassphraseParams are the Argon2id parameters used by KeyFromPassphrase.
+// Zero fields are replaced by those of DefaultPassphraseParams.
+type PassphraseParams struct {
+	Time      uint32 // number of passes over the memory
+	Memory    uint32 // memory size in KiB
+	Threads   uint8  // degree of parallelism
+	KeyLength uint32 // length of the derived key in bytes
+}
+
+// DefaultPassphraseParams are the second recommended Argon2id
+// parameters of RFC 9106 (i.e. for memory constrained environments)
+var DefaultPassphraseParams = PassphraseParams{
+	Time:      3,
+	Memory:    64 * 1024,
+	Threads:   4,
+	KeyLength: 32,
+}
+
+// KeyFromPassphrase derives a key from a (human-memorable) passphrase
+// with Argon2id, which is much slower to brute force than a key made of
+// the passphrase itself. The salt must be at least MinSaltSize random
+// bytes (see NewSalt) and, like the parameters, must be the same when
+// deriving the key again.
+func KeyFromPassphrase(passphrase string, salt []byte, params PassphraseParams) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+	if len(salt) < MinSaltSize {
+		return nil, fmt.Errorf("salt too short, got %d bytes and expected at least %d", len(salt), MinSaltSize)
+	}
+
+	if params.Time == 0 {
+		params.Time = DefaultPassphraseParams.Time
+	}
+	if params.Memory == 0 {
+		params.Memory = DefaultPassphraseParams.Memory
+	}
+	if params.Threads == 0 {
+		params.Threads = DefaultPassphraseParams.Threads
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultPassphraseParams.KeyLength
+	}
+
+	return argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, params.KeyLength), nil
+}
+
+// NewSalt returns a new random salt of MinSaltSize bytes for KeyFromPassphrase
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, MinSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
@@ -0,0 +1,71 @@
+package authio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_KeyFromPassphrase(t *testing.T) {
+	mockSalt := bytes.Repeat([]byte{0x01}, MinSaltSize)
+	// keep tests fast, the defaults take a noticeable amount of memory and time
+	mockParams := PassphraseParams{Time: 1, Memory: 64, Threads: 1}
+
+	tests := []struct {
+		name              string
+		passphrase        string
+		salt              []byte
+		params            PassphraseParams
+		expectedKeyLength int
+		expectError       bool
+	}{
+		{
+			name:              "Default key length",
+			passphrase:        "correct horse battery staple",
+			salt:              mockSalt,
+			params:            mockParams,
+			expectedKeyLength: 32,
+		},
+		{
+			name:              "Custom key length",
+			passphrase:        "correct horse battery staple",
+			salt:              mockSalt,
+			params:            PassphraseParams{Time: 1, Memory: 64, Threads: 1, KeyLength: 64},
+			expectedKeyLength: 64,
+		},
+		{
+			name:        "Empty passphrase",
+			passphrase:  "",
+			salt:        mockSalt,
+			params:      mockParams,
+			expectError: true,
+		},
+		{
+			name:        "Short salt",
+			passphrase:  "correct horse battery staple",
+			salt:        mockSalt[:MinSaltSize-1],
+			params:      mockParams,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := KeyFromPassphrase(test.passphrase, test.salt, test.params)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedKeyLength, len(key))
+
+			again, err := KeyFromPassphrase(test.passphrase, test.salt, test.params)
+			assert.NoError(t, err)
+			assert.Equal(t, key, again, "derivation must be deterministic")
+
+			other, err := KeyFromPassphrase(test.passphrase+"!", test.salt, test.params)
+			assert.NoError(t, err)
+			assert.NotEqual(t, key, other)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package authio
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Pipe(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{
+			name: "Happy path",
+		},
+		{
+			name: "Close notification",
+			opts: []Option{WithCloseNotify()},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, writer := Pipe(mockKey, test.opts...)
+			defer reader.Close()
+
+			go func() {
+				writer.Write([]byte("hello"))
+				writer.Write([]byte("world"))
+				writer.Close()
+			}()
+
+			data, err := io.ReadAll(reader)
+			assert.Nil(t, err)
+			assert.Equal(t, "helloworld", string(data))
+		})
+	}
+}
+
+func Test_PipeReaderClose(t *testing.T) {
+	reader, writer := Pipe([]byte("mock key"))
+	reader.Close()
+
+	_, err := writer.Write([]byte("hello"))
+	assert.True(t, errors.Is(err, io.ErrClosedPipe))
+}
+
+func Test_ConnPipe(t *testing.T) {
+	client, server := ConnPipe([]byte("mock key"))
+	defer client.Close()
+	defer server.Close()
+
+	go client.Write([]byte("ping"))
+
+	buf := make([]byte, 64)
+	n, err := server.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "ping", string(buf[:n]))
+}
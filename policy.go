@@ -0,0 +1,104 @@
+package authio
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+
+	// registers the SHA-3 hash functions with the crypto package
+	_ "golang.org/x/crypto/sha3"
+)
+
+// ErrPolicyViolation is returned when a configuration does not comply with a Policy
+var ErrPolicyViolation = errors.New("policy violation")
+
+// Policy restricts the algorithms readers, writers, and connections may be
+// configured with. Since constructors do not return errors, every read and
+// write of a non-compliant one fails with an error wrapping ErrPolicyViolation.
+type Policy struct {
+	// AllowedHashes are the hash functions which may be used for MACs
+	AllowedHashes []crypto.Hash
+	// MinTagSize is the minimum size (in bytes) of MACs before encoding
+	MinTagSize int
+}
+
+// FIPSPolicy returns a Policy which only allows hash functions of FIPS 180-4
+// and FIPS 202 (i.e. not legacy Keccak) and MACs of at least 128 bits. It is
+// stricter than those standards: SHA-1, SHA-224, and SHA-512/224 are left out
+// since they offer less than 128 bits of collision resistance (and NIST is
+// phasing SHA-1 out). Every call returns a new Policy, which the caller may
+// modify without affecting any other.
+func FIPSPolicy() *Policy {
+	return &Policy{
+		AllowedHashes: []crypto.Hash{
+			crypto.SHA256,
+			crypto.SHA384,
+			crypto.SHA512,
+			crypto.SHA512_256,
+			crypto.SHA3_256,
+			crypto.SHA3_384,
+			crypto.SHA3_512,
+		},
+		MinTagSize: 16,
+	}
+}
+
+var (
+	defaultPolicyLock sync.RWMutex
+	defaultPolicy     *Policy
+)
+
+// SetDefaultPolicy sets the Policy applied to all readers, writers, and
+// connections created afterwards, unless overridden with WithPolicy. This
+// allows enforcing a policy centrally (e.g. in main) rather than at every
+// call site. A nil Policy (the default) allows everything.
+func SetDefaultPolicy(p *Policy) {
+	defaultPolicyLock.Lock()
+	defer defaultPolicyLock.Unlock()
+	defaultPolicy = p
+}
+
+func getDefaultPolicy() *Policy {
+	defaultPolicyLock.RLock()
+	defer defaultPolicyLock.RUnlock()
+	return defaultPolicy
+}
+
+// policyProbe is hashed to tell hash functions apart, since hash.Hash
+// implementations (e.g. SHA3-256 and legacy Keccak-256) may share types
+var policyProbe = []byte("authio policy probe")
+
+// Check returns an error wrapping ErrPolicyViolation if
+// the given hash function does not comply with the Policy
+func (p *Policy) Check(hashFn func() hash.Hash) error {
+	h := hashFn()
+	if err := p.CheckTagSize(h.Size()); err != nil {
+		return err
+	}
+
+	h.Write(policyProbe)
+	sum := h.Sum(nil)
+	for _, allowed := range p.AllowedHashes {
+		if !allowed.Available() {
+			continue
+		}
+		candidate := allowed.New()
+		candidate.Write(policyProbe)
+		if bytes.Equal(sum, candidate.Sum(nil)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: hash function is not allowed", ErrPolicyViolation)
+}
+
+// CheckTagSize returns an error wrapping ErrPolicyViolation if the
+// given MAC size (in bytes) does not comply with the Policy
+func (p *Policy) CheckTagSize(size int) error {
+	if size < p.MinTagSize {
+		return fmt.Errorf("%w: MAC size %d is smaller than the minimum %d", ErrPolicyViolation, size, p.MinTagSize)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package authio
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"net"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"golang.org/x/crypto/sha3"
+)
+
+func Test_PolicyCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		hashFn      func() hash.Hash
+		expectError bool
+	}{
+		{name: "SHA-256", hashFn: sha256.New},
+		{name: "SHA-512", hashFn: sha512.New},
+		{name: "SHA3-256", hashFn: sha3.New256},
+		{name: "SHA-224", hashFn: sha256.New224, expectError: true},
+		{name: "SHA-512/224", hashFn: sha512.New512_224, expectError: true},
+		{name: "SHA-1", hashFn: sha1.New, expectError: true},
+		{name: "MD5", hashFn: md5.New, expectError: true},
+		{name: "Legacy Keccak-256", hashFn: sha3.NewLegacyKeccak256, expectError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := FIPSPolicy().Check(test.hashFn)
+			if test.expectError {
+				assert.True(t, errors.Is(err, ErrPolicyViolation))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_FIPSPolicyCopy(t *testing.T) {
+	modified := FIPSPolicy()
+	modified.AllowedHashes[0] = crypto.SHA1
+	modified.MinTagSize = 0
+
+	assert.NoError(t, modified.Check(sha1.New))
+	assert.True(t, errors.Is(FIPSPolicy().Check(sha1.New), ErrPolicyViolation))
+	assert.NoError(t, FIPSPolicy().Check(sha256.New))
+}
+
+func Test_DefaultPolicy(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	SetDefaultPolicy(FIPSPolicy())
+	defer SetDefaultPolicy(nil)
+
+	_, err := NewAppendMACWriter(&bytes.Buffer{}, mockKey).Write([]byte("hello"))
+	assert.NoError(t, err)
+
+	_, err = NewAppendMACWriter(&bytes.Buffer{}, mockKey, WithHashFn(sha1.New)).Write([]byte("hello"))
+	assert.True(t, errors.Is(err, ErrPolicyViolation))
+
+	_, err = NewPacketConn(nil, mockKey, WithHashFn(sha1.New)).WriteTo([]byte("hello"), &net.UDPAddr{})
+	assert.True(t, errors.Is(err, ErrPolicyViolation))
+
+	// the default can be overridden per instance
+	_, err = NewAppendMACWriter(&bytes.Buffer{}, mockKey, WithHashFn(sha1.New), WithPolicy(nil)).Write([]byte("hello"))
+	assert.NoError(t, err)
+}
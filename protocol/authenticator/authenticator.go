@@ -1,6 +1,22 @@
 package authenticator
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+// ErrCloseNotify is returned by ReadNext upon reading
+// a valid close notification (see CloseNotifier)
+var ErrCloseNotify = errors.New("close notification received")
+
+// ErrTruncatedMessage is returned (wrapped) by ReadNext (and the like) when
+// the reader ends in the middle of a frame, i.e. after some (but not all) of
+// its bytes. Readers ending at a frame boundary return io.EOF instead.
+var ErrTruncatedMessage = errors.New("stream ended mid-frame")
+
+// ErrNotAllowed is returned (wrapped) when a frame was authenticated with
+// an algorithm or key ID its reader does not accept
+var ErrNotAllowed = errors.New("algorithm or key ID not allowed")
 
 // MessageAuthenticator represents a message authentication service
 type MessageAuthenticator interface {
@@ -9,3 +25,54 @@ type MessageAuthenticator interface {
 	ReadNext(io.Reader) ([]byte, error)
 	AuthenticateMessages([]byte) ([]byte, int, error)
 }
+
+// AADMessageAuthenticator is a MessageAuthenticator which can bind associated
+// data (e.g. a connection ID, direction, or protocol version) into its MACs
+// without transmitting it, such that frames are rejected in any other context
+type AADMessageAuthenticator interface {
+	MessageAuthenticator
+	GetMessageAuthenticationHeaderWithAAD(data []byte, aad []byte) ([]byte, error)
+	ReadNextWithAAD(r io.Reader, aad []byte) ([]byte, error)
+}
+
+// CloseNotifier is a MessageAuthenticator which can produce close notifications,
+// i.e. authenticated frames (without a message) signaling the intentional end of
+// a stream, such that receivers can tell graceful shutdown from truncation
+type CloseNotifier interface {
+	MessageAuthenticator
+	GetCloseNotifyHeader() ([]byte, error)
+}
+
+// ControlFramer is a MessageAuthenticator which can produce and read control
+// frames, i.e. authenticated frames which carry protocol (e.g. heartbeat)
+// messages rather than application data. ReadNext rejects control frames.
+type ControlFramer interface {
+	MessageAuthenticator
+	GetControlFrameHeader(payload []byte) ([]byte, error)
+	ReadNextFrame(r io.Reader) (payload []byte, control bool, err error)
+}
+
+// ExtensionFramer is a MessageAuthenticator which can produce and read frames
+// with an extension area (see Extension), i.e. type-length-value fields which
+// are covered by the MAC. ReadNext and ReadNextFrame skip over extensions.
+type ExtensionFramer interface {
+	MessageAuthenticator
+	// GetMessageAuthenticationHeaderWithExtensions returns the header for
+	// the given data, followed by the extension area (i.e. everything
+	// which precedes the data in the frame)
+	GetMessageAuthenticationHeaderWithExtensions(data []byte, extensions []Extension) ([]byte, error)
+	ReadNextFrameWithExtensions(r io.Reader) (payload []byte, extensions []Extension, control bool, err error)
+}
+
+// SequenceTracker is a MessageAuthenticator which tracks the sequence numbers
+// of the frames it reads (e.g. to reject replayed frames), such that readers
+// interrupted mid-stream can resume with the same progress
+type SequenceTracker interface {
+	MessageAuthenticator
+	// ReadSequence returns the sequence number of the last frame read,
+	// and whether any frame was read at all
+	ReadSequence() (seq uint64, ok bool)
+	// ResumeReadSequence makes the MessageAuthenticator behave as if the frame
+	// with the given sequence number was the last one read
+	ResumeReadSequence(seq uint64)
+}
@@ -0,0 +1,163 @@
+package authenticatortest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+const (
+	// DefaultMAC is the fixed MAC in every header produced by a new Authenticator
+	DefaultMAC = "fake-mac"
+
+	// the message length is transmitted as a binary
+	// encoded 64 bit unsigned integer (8 bytes)
+	lengthHeaderFieldSize = 8
+)
+
+// Method names recorded in Calls
+const (
+	MethodGetMessageAuthenticationHeaderLength = "GetMessageAuthenticationHeaderLength"
+	MethodGetMessageAuthenticationHeader       = "GetMessageAuthenticationHeader"
+	MethodReadNext                             = "ReadNext"
+	MethodAuthenticateMessages                 = "AuthenticateMessages"
+)
+
+// Call is a recorded call to a method of an Authenticator
+type Call struct {
+	Method string
+	Data   []byte // the data given to GetMessageAuthenticationHeader or AuthenticateMessages
+}
+
+// Authenticator is a deterministic fake authenticator.MessageAuthenticator
+// for tests. Headers consist of a fixed MAC followed by the frame length
+// (the same layout as the real implementation), so frames are easy to
+// craft by hand (see Frame) and no real cryptography is involved. All
+// calls are recorded, and failures can be injected per method.
+type Authenticator struct {
+	// MAC is the fixed MAC in every header, and the only MAC accepted
+	MAC []byte
+
+	// if set, these errors are returned by the respective methods
+	GetMessageAuthenticationHeaderErr error
+	ReadNextErr                       error
+	AuthenticateMessagesErr           error
+
+	lock  sync.Mutex
+	calls []Call
+}
+
+// ensure Authenticator implements authenticator.MessageAuthenticator at compile-time
+var _ authenticator.MessageAuthenticator = (*Authenticator)(nil)
+
+// New returns a new Authenticator using DefaultMAC
+func New() *Authenticator {
+	return &Authenticator{MAC: []byte(DefaultMAC)}
+}
+
+// Calls returns all calls recorded so far
+func (a *Authenticator) Calls() []Call {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return append([]Call{}, a.calls...)
+}
+
+// Frame returns a frame (header and message) for the given message
+// which the Authenticator accepts. It is not recorded as a call.
+func (a *Authenticator) Frame(msg []byte) []byte {
+	return append(a.header(msg), msg...)
+}
+
+// GetMessageAuthenticationHeaderLength returns the length of the fixed MAC plus the length field
+func (a *Authenticator) GetMessageAuthenticationHeaderLength() int {
+	a.record(MethodGetMessageAuthenticationHeaderLength, nil)
+	return a.headerLen()
+}
+
+// GetMessageAuthenticationHeader returns a header with the fixed MAC for the given data
+func (a *Authenticator) GetMessageAuthenticationHeader(data []byte) ([]byte, error) {
+	a.record(MethodGetMessageAuthenticationHeader, data)
+	if a.GetMessageAuthenticationHeaderErr != nil {
+		return nil, a.GetMessageAuthenticationHeaderErr
+	}
+	return a.header(data), nil
+}
+
+// ReadNext reads a single frame, checking that it carries the fixed MAC
+func (a *Authenticator) ReadNext(r io.Reader) ([]byte, error) {
+	a.record(MethodReadNext, nil)
+	if a.ReadNextErr != nil {
+		return nil, a.ReadNextErr
+	}
+	return a.readNext(r)
+}
+
+// AuthenticateMessages processes one or more frames in a given byte slice
+func (a *Authenticator) AuthenticateMessages(data []byte) ([]byte, int, error) {
+	a.record(MethodAuthenticateMessages, data)
+	if a.AuthenticateMessagesErr != nil {
+		return nil, 0, a.AuthenticateMessagesErr
+	}
+
+	processed := []byte{}
+	nMessages := 0
+
+	reader := bytes.NewReader(data)
+	for reader.Len() > 0 {
+		msg, err := a.readNext(reader)
+		if err != nil {
+			return processed, nMessages, err
+		}
+		processed = append(processed, msg...)
+		nMessages++
+	}
+	return processed, nMessages, nil
+}
+
+func (a *Authenticator) readNext(r io.Reader) ([]byte, error) {
+	header := make([]byte, a.headerLen())
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	mac := header[:len(a.MAC)]
+	if !bytes.Equal(mac, a.MAC) {
+		return nil, fmt.Errorf("MAC mismatch: got %q, expected %q", mac, a.MAC)
+	}
+
+	size := binary.BigEndian.Uint64(header[len(a.MAC):])
+	if size < uint64(len(header)) {
+		return nil, fmt.Errorf("message length in header smaller than header")
+	}
+
+	msg := make([]byte, size-uint64(len(header)))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	return msg, nil
+}
+
+func (a *Authenticator) header(data []byte) []byte {
+	header := make([]byte, a.headerLen())
+	copy(header, a.MAC)
+	binary.BigEndian.PutUint64(header[len(a.MAC):], uint64(len(header)+len(data)))
+	return header
+}
+
+func (a *Authenticator) headerLen() int {
+	return len(a.MAC) + lengthHeaderFieldSize
+}
+
+func (a *Authenticator) record(method string, data []byte) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.calls = append(a.calls, Call{Method: method, Data: append([]byte(nil), data...)})
+}
@@ -0,0 +1,95 @@
+package authenticatortest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Authenticator(t *testing.T) {
+	mockErr := errors.New("mock error")
+
+	tests := []struct {
+		name          string
+		configure     func(*Authenticator)
+		data          []byte
+		expectedMsgs  [][]byte
+		expectedCalls []string
+		expectError   bool
+	}{
+		{
+			name:          "Round trip",
+			configure:     func(*Authenticator) {},
+			data:          append(New().Frame([]byte("hello")), New().Frame([]byte("world"))...),
+			expectedMsgs:  [][]byte{[]byte("hello"), []byte("world")},
+			expectedCalls: []string{MethodReadNext, MethodReadNext, MethodReadNext},
+		},
+		{
+			name:          "Wrong MAC",
+			configure:     func(a *Authenticator) { a.MAC = []byte("fake-max") },
+			data:          New().Frame([]byte("hello")),
+			expectedCalls: []string{MethodReadNext},
+			expectError:   true,
+		},
+		{
+			name:          "Injected failure",
+			configure:     func(a *Authenticator) { a.ReadNextErr = mockErr },
+			data:          New().Frame([]byte("hello")),
+			expectedCalls: []string{MethodReadNext},
+			expectError:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := New()
+			test.configure(a)
+
+			r := bytes.NewReader(test.data)
+			msgs := [][]byte{}
+			var err error
+			for {
+				var msg []byte
+				if msg, err = a.ReadNext(r); err != nil {
+					break
+				}
+				msgs = append(msgs, msg)
+			}
+			if test.expectError {
+				assert.NotEqual(t, io.EOF, err)
+			} else {
+				assert.Equal(t, io.EOF, err)
+				assert.Equal(t, test.expectedMsgs, msgs)
+			}
+
+			methods := []string{}
+			for _, call := range a.Calls() {
+				methods = append(methods, call.Method)
+			}
+			assert.Equal(t, test.expectedCalls, methods)
+		})
+	}
+}
+
+func Test_AuthenticateMessages(t *testing.T) {
+	a := New()
+
+	header, err := a.GetMessageAuthenticationHeader([]byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, a.Frame([]byte("hello")), append(header, []byte("hello")...))
+
+	msgs, n, err := a.AuthenticateMessages(append(a.Frame([]byte("hello ")), a.Frame([]byte("world"))...))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte("hello world"), msgs)
+
+	a.AuthenticateMessagesErr = errors.New("mock error")
+	_, _, err = a.AuthenticateMessages(a.Frame([]byte("hello")))
+	assert.Error(t, err)
+
+	calls := a.Calls()
+	assert.Equal(t, 3, len(calls))
+	assert.Equal(t, Call{Method: MethodGetMessageAuthenticationHeader, Data: []byte("hello")}, calls[0])
+}
@@ -0,0 +1,162 @@
+package authenticator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// a minimal CBOR (RFC 8949) encoder and decoder, supporting just
+// what is needed for frame headers: maps with unsigned integer keys
+// and unsigned integer or text string values. Other items (e.g. in
+// extensions added by newer writers) are skipped over when decoding.
+
+const (
+	cborMajorUint   = 0
+	cborMajorBytes  = 2
+	cborMajorText   = 3
+	cborMajorArray  = 4
+	cborMajorMap    = 5
+	cborMajorTag    = 6
+	cborMajorSimple = 7
+
+	// maximum nesting depth of items skipped over
+	cborMaxDepth = 16
+)
+
+var errCBORTruncated = errors.New("truncated CBOR item")
+
+// cborAppendHead appends the head of a CBOR item with the minimal encoding of its argument
+func cborAppendHead(b []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(b, major<<5|byte(arg))
+	case arg <= 0xff:
+		return append(b, major<<5|24, byte(arg))
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major<<5|25), uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major<<5|26), uint32(arg))
+	default:
+		return cborAppendUint64(b, arg)
+	}
+}
+
+// cborAppendUint64 appends an unsigned integer with a fixed size (8 byte)
+// encoding, such that headers have the same size regardless of values
+func cborAppendUint64(b []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, cborMajorUint<<5|27), v)
+}
+
+// cborAppendText appends a text string
+func cborAppendText(b []byte, s string) []byte {
+	return append(cborAppendHead(b, cborMajorText, uint64(len(s))), s...)
+}
+
+// cborDecoder decodes CBOR items from a byte slice
+type cborDecoder struct {
+	data []byte
+	off  int
+}
+
+// head decodes the head of the next item
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if d.off >= len(d.data) {
+		return 0, 0, errCBORTruncated
+	}
+	ib := d.data[d.off]
+	d.off++
+	major, info := ib>>5, ib&0x1f
+
+	size := 0
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("unsupported CBOR additional information %d", info)
+	}
+	if len(d.data)-d.off < size {
+		return 0, 0, errCBORTruncated
+	}
+	arg := uint64(0)
+	for _, b := range d.data[d.off : d.off+size] {
+		arg = arg<<8 | uint64(b)
+	}
+	d.off += size
+	return major, arg, nil
+}
+
+// uint decodes an unsigned integer
+func (d *cborDecoder) uint() (uint64, error) {
+	major, arg, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	if major != cborMajorUint {
+		return 0, fmt.Errorf("expected CBOR unsigned integer, got major type %d", major)
+	}
+	return arg, nil
+}
+
+// text decodes a text string
+func (d *cborDecoder) text() (string, error) {
+	major, arg, err := d.head()
+	if err != nil {
+		return "", err
+	}
+	if major != cborMajorText {
+		return "", fmt.Errorf("expected CBOR text string, got major type %d", major)
+	}
+	if uint64(len(d.data)-d.off) < arg {
+		return "", errCBORTruncated
+	}
+	s := string(d.data[d.off : d.off+int(arg)])
+	d.off += int(arg)
+	return s, nil
+}
+
+// skip skips over the next item, returning its encoding
+func (d *cborDecoder) skip(depth int) ([]byte, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New("CBOR item nested too deeply")
+	}
+	start := d.off
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborMajorBytes, cborMajorText:
+		if uint64(len(d.data)-d.off) < arg {
+			return nil, errCBORTruncated
+		}
+		d.off += int(arg)
+	case cborMajorArray, cborMajorMap:
+		items := arg
+		if major == cborMajorMap {
+			items *= 2
+		}
+		// every item is at least one byte
+		if uint64(len(d.data)-d.off) < items {
+			return nil, errCBORTruncated
+		}
+		for i := uint64(0); i < items; i++ {
+			if _, err := d.skip(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+	case cborMajorTag:
+		if _, err := d.skip(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return d.data[start:d.off], nil
+}
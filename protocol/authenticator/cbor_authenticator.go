@@ -0,0 +1,490 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+)
+
+// keys of the fields of CBOR frame headers. Any other keys are extensions,
+// which readers not knowing them skip over (but still authenticate).
+const (
+	cborKeyType      = 1
+	cborKeyLength    = 2
+	cborKeyKeyID     = 3
+	cborKeySequence  = 4
+	cborKeyTimestamp = 5
+)
+
+// types of frames with CBOR headers
+const (
+	cborFrameData        = 0
+	cborFrameCloseNotify = 1
+	cborFrameControl     = 2
+)
+
+// cborMapLengthFieldSize is the size of the field preceding
+// the CBOR map with its length, a big endian uint16
+const cborMapLengthFieldSize = 2
+
+// CBORHeader is the (verified) header of a frame with a CBOR encoded header
+type CBORHeader struct {
+	// Length is the length of the message (excluding the header)
+	Length uint64
+	// KeyID is the ID of the key the frame was authenticated with, if any
+	KeyID string
+	// Sequence is the sequence number of the frame in its stream
+	Sequence uint64
+	// Timestamp is the time at which the frame was authenticated
+	Timestamp time.Time
+	// Extensions are the (CBOR encoded) values of unknown fields, by key
+	Extensions map[uint64][]byte
+
+	frameType uint64
+}
+
+// Control returns whether the frame is a control frame (see ControlFramer)
+func (h CBORHeader) Control() bool {
+	return h.frameType == cborFrameControl
+}
+
+// CloseNotify returns whether the frame is a close notification (see CloseNotifier)
+func (h CBORHeader) CloseNotify() bool {
+	return h.frameType == cborFrameCloseNotify
+}
+
+// CBORDetectionSize is the number of bytes at the start of a
+// frame HasCBORHeader needs to tell whether it has a CBOR header
+const CBORDetectionSize = cborMapLengthFieldSize + 1
+
+// HasCBORHeader returns whether the frame starting with the given bytes (at
+// least CBORDetectionSize of them) has a CBOR header: such frames start with
+// the (uint16) length of the CBOR map followed by the head of the map, whereas
+// frames of a DefaultMessageAuthenticator start with the text encoded MAC,
+// which can never have the major type of a map
+func HasCBORHeader(start []byte) bool {
+	return len(start) >= CBORDetectionSize && start[cborMapLengthFieldSize]>>5 == cborMajorMap
+}
+
+// ParseCBORHeader parses the (CBOR map of) header fields of a frame with a
+// CBOR header, i.e. the bytes following its length, without verifying it
+func ParseCBORHeader(fields []byte) (CBORHeader, error) {
+	return decodeCBORFields(fields)
+}
+
+// CBORMessageAuthenticator is an HMAC based MessageAuthenticator with an
+// extensible frame header: a (big endian uint16) length, a CBOR map of header
+// fields (see CBORHeader), the (raw) MAC, and finally the message. The MAC
+// covers everything else (and any associated data). Readers skip over fields
+// they do not know, such that new fields can be added without breaking them.
+//
+// Sequence numbers must increase within a stream: frames which are replayed
+// or reordered are rejected. Frames are not compatible with those of a
+// DefaultMessageAuthenticator, so both peers must opt in.
+type CBORMessageAuthenticator struct {
+	hashFn         func() hash.Hash
+	key            []byte
+	keyID          string
+	acceptedKeyIDs []string // if not empty, the only key IDs read frames may have
+	tagSize        int
+	aad            []byte
+	maxMessageSize int
+	now            func() time.Time // the timestamps of frames are read from
+
+	// guards key and destroyed, such that Destroy
+	// waits for any operations in progress
+	lock      sync.RWMutex
+	destroyed bool
+
+	// guards sequence numbers
+	seqLock     sync.Mutex
+	sendSeq     uint64
+	recvSeq     uint64
+	receivedAny bool
+}
+
+// ensure CBORMessageAuthenticator implements CloseNotifier, ControlFramer, and SequenceTracker at compile-time
+var (
+	_ CloseNotifier   = (*CBORMessageAuthenticator)(nil)
+	_ ControlFramer   = (*CBORMessageAuthenticator)(nil)
+	_ SequenceTracker = (*CBORMessageAuthenticator)(nil)
+)
+
+// NewCBORMessageAuthenticator returns a newly initialized CBORMessageAuthenticator.
+// The key is copied, such that Destroy does not modify the caller's key.
+func NewCBORMessageAuthenticator(hashFn func() hash.Hash, key []byte) *CBORMessageAuthenticator {
+	return &CBORMessageAuthenticator{
+		hashFn: hashFn,
+		key:    append([]byte{}, key...),
+		now:    time.Now,
+	}
+}
+
+// WithKeyID sets the key ID included in the headers of frames written
+// by a CBORMessageAuthenticator and returns it
+func (a *CBORMessageAuthenticator) WithKeyID(keyID string) *CBORMessageAuthenticator {
+	a.keyID = keyID
+	return a
+}
+
+// WithAcceptedKeyIDs restricts the key IDs of the headers of frames read by a
+// CBORMessageAuthenticator to the given ones, rejecting (authentic) frames with
+// any other key ID with an error wrapping ErrNotAllowed, and returns it. No key
+// IDs means any key ID is accepted.
+func (a *CBORMessageAuthenticator) WithAcceptedKeyIDs(keyIDs ...string) *CBORMessageAuthenticator {
+	a.acceptedKeyIDs = keyIDs
+	return a
+}
+
+// WithTagSize truncates MACs on a CBORMessageAuthenticator to the given size
+// in bytes and returns it (see DefaultMessageAuthenticator.WithTagSize)
+func (a *CBORMessageAuthenticator) WithTagSize(size int) *CBORMessageAuthenticator {
+	if size >= a.hashFn().Size() {
+		size = 0
+	}
+	a.tagSize = size
+	return a
+}
+
+// WithAssociatedData sets the associated data bound into every
+// MAC on a CBORMessageAuthenticator and returns it
+func (a *CBORMessageAuthenticator) WithAssociatedData(aad []byte) *CBORMessageAuthenticator {
+	a.aad = aad
+	return a
+}
+
+// WithMaxMessageSize sets the maximum size (in bytes, excluding the header) of
+// messages accepted on a CBORMessageAuthenticator and returns it. Zero means no limit.
+func (a *CBORMessageAuthenticator) WithMaxMessageSize(size int) *CBORMessageAuthenticator {
+	a.maxMessageSize = size
+	return a
+}
+
+// WithClock sets the function the timestamps of frames written by a
+// CBORMessageAuthenticator are read from (default time.Now) and returns it
+func (a *CBORMessageAuthenticator) WithClock(now func() time.Time) *CBORMessageAuthenticator {
+	a.now = now
+	return a
+}
+
+// Destroy overwrites the CBORMessageAuthenticator's copy of the key with
+// zeros, after which all of its operations fail with ErrDestroyed
+func (a *CBORMessageAuthenticator) Destroy() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for i := range a.key {
+		a.key[i] = 0
+	}
+	a.key = nil
+	a.destroyed = true
+}
+
+// GetMessageAuthenticationHeaderLength returns the length (in bytes) of headers
+// produced by the CBORMessageAuthenticator. Since headers are extensible, those
+// produced by other (e.g. newer) writers may be longer.
+func (a *CBORMessageAuthenticator) GetMessageAuthenticationHeaderLength() int {
+	return cborMapLengthFieldSize + len(a.encodeFields(cborFrameData, 0, 0, time.Time{})) + a.macSize()
+}
+
+// GetMessageAuthenticationHeader returns the header for a message
+func (a *CBORMessageAuthenticator) GetMessageAuthenticationHeader(data []byte) ([]byte, error) {
+	return a.encodeHeader(cborFrameData, data)
+}
+
+// GetCloseNotifyHeader returns a close notification (see CloseNotifier)
+func (a *CBORMessageAuthenticator) GetCloseNotifyHeader() ([]byte, error) {
+	return a.encodeHeader(cborFrameCloseNotify, nil)
+}
+
+// GetControlFrameHeader returns the header for a control frame (see ControlFramer)
+func (a *CBORMessageAuthenticator) GetControlFrameHeader(payload []byte) ([]byte, error) {
+	return a.encodeHeader(cborFrameControl, payload)
+}
+
+// ReadNext reads and verifies a single message. It returns
+// ErrCloseNotify upon reading a valid close notification.
+func (a *CBORMessageAuthenticator) ReadNext(r io.Reader) ([]byte, error) {
+	msg, header, err := a.ReadNextWithHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if header.frameType == cborFrameControl {
+		return nil, fmt.Errorf("unexpected control frame")
+	}
+	return msg, nil
+}
+
+// ReadNextFrame reads and verifies a single frame, which
+// is either a message or (if control is true) a control frame
+func (a *CBORMessageAuthenticator) ReadNextFrame(r io.Reader) ([]byte, bool, error) {
+	msg, header, err := a.ReadNextWithHeader(r)
+	if err != nil {
+		return nil, false, err
+	}
+	return msg, header.frameType == cborFrameControl, nil
+}
+
+// AuthenticateMessages processes one or more frames in a given byte slice. It returns
+// the raw messages processed successfully and the number of messages processed.
+func (a *CBORMessageAuthenticator) AuthenticateMessages(data []byte) ([]byte, int, error) {
+	processed := []byte{}
+	reader := bytes.NewReader(data)
+	nMessages := 0
+
+	for reader.Len() > 0 {
+		msg, err := a.ReadNext(reader)
+		if err != nil {
+			return processed, nMessages, fmt.Errorf("failed decoding header: %w", err)
+		}
+		processed = append(processed, msg...)
+		nMessages++
+	}
+	return processed, nMessages, nil
+}
+
+// ReadNextWithHeader reads and verifies a single frame, returning its message
+// and header. It returns ErrCloseNotify upon reading a valid close notification.
+func (a *CBORMessageAuthenticator) ReadNextWithHeader(r io.Reader) ([]byte, CBORHeader, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, CBORHeader{}, ErrDestroyed
+	}
+
+	mapLen := make([]byte, cborMapLengthFieldSize)
+	if _, err := io.ReadFull(r, mapLen); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, CBORHeader{}, io.EOF
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, CBORHeader{}, fmt.Errorf("%w: read data too short to have valid header", ErrTruncatedMessage)
+		}
+		return nil, CBORHeader{}, fmt.Errorf("failed to read message header: %w", err)
+	}
+	fields := make([]byte, binary.BigEndian.Uint16(mapLen))
+	if _, err := io.ReadFull(r, fields); err != nil {
+		return nil, CBORHeader{}, a.readError(err)
+	}
+
+	header, err := decodeCBORFields(fields)
+	if err != nil {
+		return nil, CBORHeader{}, fmt.Errorf("invalid header: %w", err)
+	}
+	if header.Length > maxFrameLength {
+		return nil, CBORHeader{}, fmt.Errorf("%w: message length in header larger than the max frame length, got %d and expected at most %d", ErrInvalidLength, header.Length, maxFrameLength)
+	}
+	if a.maxMessageSize > 0 && header.Length > uint64(a.maxMessageSize) {
+		// the MAC follows the header fields
+		return nil, CBORHeader{}, &MessageTooLargeError{Size: header.Length, Max: a.maxMessageSize, Remaining: uint64(a.macSize()) + header.Length}
+	}
+
+	mac := make([]byte, a.macSize())
+	if _, err := io.ReadFull(r, mac); err != nil {
+		return nil, CBORHeader{}, a.readError(err)
+	}
+	msg, err := readPayload(r, header.Length)
+	if err != nil {
+		return nil, CBORHeader{}, a.readError(err)
+	}
+
+	sum, err := computeMAC(a.hashFn, a.tagSize, Raw, a.key, a.aad, append(mapLen, fields...), msg)
+	if err != nil {
+		return nil, CBORHeader{}, err
+	}
+	if !hmac.Equal(mac, []byte(sum)) {
+		return nil, CBORHeader{}, ErrMACMismatch
+	}
+
+	if err := a.checkKeyID(header.KeyID); err != nil {
+		return nil, CBORHeader{}, err
+	}
+	if err := a.checkSequence(header.Sequence); err != nil {
+		return nil, CBORHeader{}, err
+	}
+	if header.frameType == cborFrameCloseNotify {
+		return nil, header, ErrCloseNotify
+	}
+	return msg, header, nil
+}
+
+func (a *CBORMessageAuthenticator) readError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: read message too short, does not match message size from header", ErrTruncatedMessage)
+	}
+	return fmt.Errorf("failed to read message: %w", err)
+}
+
+// checkKeyID checks the key ID of a verified frame against the accepted key IDs
+func (a *CBORMessageAuthenticator) checkKeyID(keyID string) error {
+	if len(a.acceptedKeyIDs) == 0 {
+		return nil
+	}
+	for _, accepted := range a.acceptedKeyIDs {
+		if keyID == accepted {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: key ID %q", ErrNotAllowed, keyID)
+}
+
+// checkSequence checks that a verified sequence number is greater than that
+// of the previous frame read, i.e. that frames are not replayed or reordered
+func (a *CBORMessageAuthenticator) checkSequence(seq uint64) error {
+	a.seqLock.Lock()
+	defer a.seqLock.Unlock()
+
+	if a.receivedAny && seq <= a.recvSeq {
+		return fmt.Errorf("replayed or reordered frame, got sequence number %d after %d", seq, a.recvSeq)
+	}
+	a.recvSeq, a.receivedAny = seq, true
+	return nil
+}
+
+// ReadSequence returns the sequence number of the last frame read,
+// and whether any frame was read at all
+func (a *CBORMessageAuthenticator) ReadSequence() (uint64, bool) {
+	a.seqLock.Lock()
+	defer a.seqLock.Unlock()
+	return a.recvSeq, a.receivedAny
+}
+
+// WriteSequence returns the sequence number of the last frame
+// written, and whether any frame was written at all
+func (a *CBORMessageAuthenticator) WriteSequence() (uint64, bool) {
+	a.seqLock.Lock()
+	defer a.seqLock.Unlock()
+	if a.sendSeq == 0 {
+		return 0, false
+	}
+	return a.sendSeq - 1, true
+}
+
+// ResumeReadSequence makes the CBORMessageAuthenticator reject frames with
+// sequence numbers up to the given one, as if it was the last one read
+func (a *CBORMessageAuthenticator) ResumeReadSequence(seq uint64) {
+	a.seqLock.Lock()
+	defer a.seqLock.Unlock()
+	a.recvSeq, a.receivedAny = seq, true
+}
+
+func (a *CBORMessageAuthenticator) encodeHeader(frameType uint64, data []byte) ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+
+	a.seqLock.Lock()
+	seq := a.sendSeq
+	a.sendSeq++
+	a.seqLock.Unlock()
+
+	fields := a.encodeFields(frameType, uint64(len(data)), seq, a.now())
+	header := binary.BigEndian.AppendUint16(nil, uint16(len(fields)))
+	header = append(header, fields...)
+
+	sum, err := computeMAC(a.hashFn, a.tagSize, Raw, a.key, a.aad, header, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sum...), nil
+}
+
+// encodeFields encodes the header fields as a CBOR map. Integers have fixed
+// size encodings, such that headers of a given writer have a fixed length.
+func (a *CBORMessageAuthenticator) encodeFields(frameType, length, seq uint64, ts time.Time) []byte {
+	entries := uint64(4)
+	if a.keyID != "" {
+		entries++
+	}
+	fields := cborAppendHead(nil, cborMajorMap, entries)
+	fields = cborAppendUint64(cborAppendHead(fields, cborMajorUint, cborKeyType), frameType)
+	fields = cborAppendUint64(cborAppendHead(fields, cborMajorUint, cborKeyLength), length)
+	if a.keyID != "" {
+		fields = cborAppendText(cborAppendHead(fields, cborMajorUint, cborKeyKeyID), a.keyID)
+	}
+	fields = cborAppendUint64(cborAppendHead(fields, cborMajorUint, cborKeySequence), seq)
+	fields = cborAppendUint64(cborAppendHead(fields, cborMajorUint, cborKeyTimestamp), uint64(ts.UnixNano()))
+	return fields
+}
+
+// macSize returns the size of (raw) MACs
+func (a *CBORMessageAuthenticator) macSize() int {
+	if a.tagSize > 0 {
+		return a.tagSize
+	}
+	return a.hashFn().Size()
+}
+
+// decodeCBORFields decodes the header fields from a CBOR map
+func decodeCBORFields(fields []byte) (CBORHeader, error) {
+	d := &cborDecoder{data: fields}
+	major, entries, err := d.head()
+	if err != nil {
+		return CBORHeader{}, err
+	}
+	if major != cborMajorMap {
+		return CBORHeader{}, fmt.Errorf("expected CBOR map, got major type %d", major)
+	}
+
+	header := CBORHeader{}
+	seen := map[uint64]bool{}
+	for i := uint64(0); i < entries; i++ {
+		key, err := d.uint()
+		if err != nil {
+			return CBORHeader{}, fmt.Errorf("invalid key: %w", err)
+		}
+		if seen[key] {
+			return CBORHeader{}, fmt.Errorf("duplicate key %d", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case cborKeyType:
+			header.frameType, err = d.uint()
+		case cborKeyLength:
+			header.Length, err = d.uint()
+		case cborKeyKeyID:
+			header.KeyID, err = d.text()
+		case cborKeySequence:
+			header.Sequence, err = d.uint()
+		case cborKeyTimestamp:
+			var ts uint64
+			ts, err = d.uint()
+			header.Timestamp = time.Unix(0, int64(ts))
+		default:
+			var value []byte
+			if value, err = d.skip(0); err == nil {
+				if header.Extensions == nil {
+					header.Extensions = map[uint64][]byte{}
+				}
+				header.Extensions[key] = value
+			}
+		}
+		if err != nil {
+			return CBORHeader{}, fmt.Errorf("invalid value for key %d: %w", key, err)
+		}
+	}
+	if d.off != len(fields) {
+		return CBORHeader{}, fmt.Errorf("%d trailing bytes after CBOR map", len(fields)-d.off)
+	}
+	for _, key := range []uint64{cborKeyType, cborKeyLength, cborKeySequence} {
+		if !seen[key] {
+			return CBORHeader{}, fmt.Errorf("missing required key %d", key)
+		}
+	}
+	if header.frameType > cborFrameControl {
+		return CBORHeader{}, fmt.Errorf("unknown frame type %d", header.frameType)
+	}
+	if header.frameType == cborFrameCloseNotify && header.Length != 0 {
+		return CBORHeader{}, fmt.Errorf("close notification with a message")
+	}
+	return header, nil
+}
@@ -0,0 +1,166 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_CBORMessageAuthenticator(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	writer := NewCBORMessageAuthenticator(sha256.New, mockKey).WithKeyID("key-1")
+	reader := NewCBORMessageAuthenticator(sha256.New, mockKey)
+
+	buf := &bytes.Buffer{}
+	for _, msg := range []string{"first", "second"} {
+		header, err := writer.GetMessageAuthenticationHeader([]byte(msg))
+		assert.Nil(t, err)
+		assert.Equal(t, writer.GetMessageAuthenticationHeaderLength(), len(header))
+		buf.Write(append(header, msg...))
+	}
+	closeNotify, err := writer.GetCloseNotifyHeader()
+	assert.Nil(t, err)
+	buf.Write(closeNotify)
+
+	for seq, expected := range []string{"first", "second"} {
+		msg, header, err := reader.ReadNextWithHeader(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(msg))
+		assert.Equal(t, uint64(len(expected)), header.Length)
+		assert.Equal(t, "key-1", header.KeyID)
+		assert.Equal(t, uint64(seq), header.Sequence)
+		assert.False(t, header.Timestamp.IsZero())
+	}
+	_, err = reader.ReadNext(buf)
+	assert.True(t, errors.Is(err, ErrCloseNotify))
+	_, err = reader.ReadNext(buf)
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func Test_CBORMessageAuthenticatorRejects(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name        string
+		frames      func(w *CBORMessageAuthenticator) []byte
+		expectError error
+	}{
+		{
+			name: "Tampered message",
+			frames: func(w *CBORMessageAuthenticator) []byte {
+				frame := mustCBORFrame(t, w, "message")
+				frame[len(frame)-1] ^= 0x01
+				return frame
+			},
+			expectError: ErrMACMismatch,
+		},
+		{
+			name: "Wrong key",
+			frames: func(*CBORMessageAuthenticator) []byte {
+				return mustCBORFrame(t, NewCBORMessageAuthenticator(sha256.New, []byte("wrong key")), "message")
+			},
+			expectError: ErrMACMismatch,
+		},
+		{
+			name: "Replayed frame",
+			frames: func(w *CBORMessageAuthenticator) []byte {
+				frame := mustCBORFrame(t, w, "message")
+				return append(frame, frame...)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewCBORMessageAuthenticator(sha256.New, mockKey)
+			data := bytes.NewReader(test.frames(NewCBORMessageAuthenticator(sha256.New, mockKey)))
+
+			var err error
+			for err == nil {
+				_, err = reader.ReadNext(data)
+			}
+			assert.False(t, errors.Is(err, io.EOF))
+			if test.expectError != nil {
+				assert.True(t, errors.Is(err, test.expectError))
+			}
+		})
+	}
+}
+
+func Test_CBORMessageAuthenticatorExtensions(t *testing.T) {
+	mockKey := []byte("mock key")
+	msg := []byte("message")
+
+	// a header from a (future) writer with an extension, whose value is
+	// an array of an integer and a text string, under key 100
+	fields := cborAppendHead(nil, cborMajorMap, 4)
+	fields = cborAppendUint64(cborAppendHead(fields, cborMajorUint, cborKeyType), cborFrameData)
+	fields = cborAppendUint64(cborAppendHead(fields, cborMajorUint, cborKeyLength), uint64(len(msg)))
+	fields = cborAppendUint64(cborAppendHead(fields, cborMajorUint, cborKeySequence), 0)
+	extension := cborAppendText(cborAppendHead(cborAppendHead(nil, cborMajorArray, 2), cborMajorUint, 7), "value")
+	fields = append(cborAppendHead(fields, cborMajorUint, 100), extension...)
+
+	header := binary.BigEndian.AppendUint16(nil, uint16(len(fields)))
+	header = append(header, fields...)
+	sum, err := computeMAC(sha256.New, 0, Raw, mockKey, nil, header, msg)
+	assert.Nil(t, err)
+	frame := append(append(header, sum...), msg...)
+
+	got, parsed, err := NewCBORMessageAuthenticator(sha256.New, mockKey).ReadNextWithHeader(bytes.NewReader(frame))
+	assert.Nil(t, err)
+	assert.Equal(t, msg, got)
+	assert.Equal(t, map[uint64][]byte{100: extension}, parsed.Extensions)
+}
+
+func mustCBORFrame(t *testing.T, a *CBORMessageAuthenticator, msg string) []byte {
+	header, err := a.GetMessageAuthenticationHeader([]byte(msg))
+	assert.Nil(t, err)
+	return append(header, msg...)
+}
+
+func Test_ParseCBORHeader(t *testing.T) {
+	a := NewCBORMessageAuthenticator(sha256.New, []byte("mock key")).WithKeyID("key-1")
+	data, err := a.GetMessageAuthenticationHeader([]byte("hello"))
+	assert.Nil(t, err)
+	control, err := a.GetControlFrameHeader([]byte("ping"))
+	assert.Nil(t, err)
+	closeNotify, err := a.GetCloseNotifyHeader()
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name        string
+		frame       []byte
+		length      uint64
+		sequence    uint64
+		control     bool
+		closeNotify bool
+	}{
+		{name: "Data frame", frame: data, length: 5, sequence: 0},
+		{name: "Control frame", frame: control, length: 4, sequence: 1, control: true},
+		{name: "Close notification", frame: closeNotify, sequence: 2, closeNotify: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.True(t, HasCBORHeader(test.frame))
+
+			mapLen := binary.BigEndian.Uint16(test.frame)
+			header, err := ParseCBORHeader(test.frame[cborMapLengthFieldSize : cborMapLengthFieldSize+int(mapLen)])
+			assert.Nil(t, err)
+			assert.Equal(t, test.length, header.Length)
+			assert.Equal(t, "key-1", header.KeyID)
+			assert.Equal(t, test.sequence, header.Sequence)
+			assert.Equal(t, test.control, header.Control())
+			assert.Equal(t, test.closeNotify, header.CloseNotify())
+		})
+	}
+
+	standard, err := NewDefaultMessageAuthenticator(sha256.New, []byte("mock key")).GetMessageAuthenticationHeader([]byte("hello"))
+	assert.Nil(t, err)
+	assert.False(t, HasCBORHeader(standard))
+	assert.False(t, HasCBORHeader(data[:CBORDetectionSize-1]))
+}
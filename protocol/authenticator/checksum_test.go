@@ -0,0 +1,57 @@
+package authenticator
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_ChecksumAuthenticator(t *testing.T) {
+	tests := []struct {
+		name        string
+		tamper      func([]byte)
+		expectError error
+	}{
+		{
+			name:   "Happy path",
+			tamper: func([]byte) {},
+		},
+		{
+			name:        "Corrupted message",
+			tamper:      func(b []byte) { b[len(b)-1] ^= 0x01 },
+			expectError: ErrMACMismatch,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := NewChecksumAuthenticator()
+			assert.Equal(t, lengthHeaderFieldSize+8, a.GetMessageAuthenticationHeaderLength())
+
+			msg := []byte("mock message")
+			header, err := a.GetMessageAuthenticationHeader(msg)
+			assert.Nil(t, err)
+
+			frame := append(header, msg...)
+			test.tamper(frame)
+
+			got, err := a.ReadNext(bytes.NewReader(frame))
+			if test.expectError != nil {
+				assert.True(t, errors.Is(err, test.expectError))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, msg, got)
+		})
+	}
+}
+
+func Test_NewCRC32C(t *testing.T) {
+	h := NewCRC32C()
+	h.Write([]byte("123456789"))
+	// check value of CRC-32C as per RFC 3720
+	assert.Equal(t, crc32.Checksum([]byte("123456789"), crc32.MakeTable(crc32.Castagnoli)), uint32(0xe3069283))
+	assert.Equal(t, []byte{0xe3, 0x06, 0x92, 0x83}, h.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package authenticator
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// MACEncoding is the text encoding of MACs in frame headers. Note that
+// encoding/base64.Encoding implements MACEncoding.
+type MACEncoding interface {
+	// EncodedLen returns the length of the encoding of n bytes
+	EncodedLen(n int) int
+	// EncodeToString returns the encoding of the given bytes
+	EncodeToString(src []byte) string
+}
+
+var (
+	// StdBase64 is standard (padded) base64 as per RFC 4648, the default.
+	// It never produces special characters such as newlines, such that
+	// e.g. bufio.Reader.ReadString('\n') is safe on authenticated streams.
+	StdBase64 MACEncoding = base64.StdEncoding
+
+	// URLBase64 is unpadded URL-safe base64 as per RFC 4648, for frames
+	// embedded in URLs or file names
+	URLBase64 MACEncoding = base64.RawURLEncoding
+
+	// Hex is lowercase hexadecimal, e.g. for logs and JSON
+	Hex MACEncoding = hexEncoding{}
+
+	// Raw leaves MACs unencoded, for the smallest headers. Note that raw
+	// MACs may contain any bytes, including newlines.
+	Raw MACEncoding = rawEncoding{}
+)
+
+type hexEncoding struct{}
+
+func (hexEncoding) EncodedLen(n int) int { return hex.EncodedLen(n) }
+
+func (hexEncoding) EncodeToString(src []byte) string { return hex.EncodeToString(src) }
+
+func (hexEncoding) DecodeString(s string) ([]byte, error) { return hex.DecodeString(s) }
+
+type rawEncoding struct{}
+
+func (rawEncoding) EncodedLen(n int) int { return n }
+
+func (rawEncoding) EncodeToString(src []byte) string { return string(src) }
+
+func (rawEncoding) DecodeString(s string) ([]byte, error) { return []byte(s), nil }
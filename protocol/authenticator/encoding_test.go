@@ -0,0 +1,74 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithMACEncoding(t *testing.T) {
+	mockKey := []byte("mock key")
+	msg := []byte("mock message")
+
+	tests := []struct {
+		name            string
+		encoding        MACEncoding
+		tagSize         int
+		expectHeaderLen int
+	}{
+		{
+			name:            "Standard base64",
+			encoding:        StdBase64,
+			expectHeaderLen: lengthHeaderFieldSize + 44,
+		},
+		{
+			name:            "URL-safe base64",
+			encoding:        URLBase64,
+			expectHeaderLen: lengthHeaderFieldSize + 43, // unpadded
+		},
+		{
+			name:            "Hex",
+			encoding:        Hex,
+			expectHeaderLen: lengthHeaderFieldSize + 64,
+		},
+		{
+			name:            "Hex with tag size",
+			encoding:        Hex,
+			tagSize:         16,
+			expectHeaderLen: lengthHeaderFieldSize + 32,
+		},
+		{
+			name:            "Raw",
+			encoding:        Raw,
+			expectHeaderLen: lengthHeaderFieldSize + 32,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := NewDefaultMessageAuthenticator(sha256.New, mockKey).
+				WithTagSize(test.tagSize).
+				WithMACEncoding(test.encoding)
+			assert.Equal(t, test.expectHeaderLen, a.GetMessageAuthenticationHeaderLength())
+
+			header, err := a.GetMessageAuthenticationHeader(msg)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectHeaderLen, len(header))
+
+			got, err := a.ReadNext(bytes.NewReader(append(header, msg...)))
+			assert.Nil(t, err)
+			assert.Equal(t, msg, got)
+		})
+	}
+}
+
+func Test_HexEncoding(t *testing.T) {
+	a := NewDefaultMessageAuthenticator(sha256.New, []byte("mock key")).WithMACEncoding(Hex)
+	header, err := a.GetMessageAuthenticationHeader([]byte("mock message"))
+	assert.Nil(t, err)
+
+	_, err = hex.DecodeString(string(header[:len(header)-lengthHeaderFieldSize]))
+	assert.Nil(t, err)
+}
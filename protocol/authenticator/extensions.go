@@ -0,0 +1,156 @@
+package authenticator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// extensionsFlag is set in the length field of frames with an extension area
+// (see ExtensionFramer), no frame can be long enough for the length to have it set
+const extensionsFlag = uint64(1) << 62
+
+// extensionAreaLengthFieldSize is the size of the field preceding the extension
+// area with its length, and of the type and length fields of every extension
+const extensionAreaLengthFieldSize = 2
+
+// registered extension types. Types from ExtensionTypeCustom on are free for
+// applications to use.
+const (
+	// ExtensionKeyID is the ID of the key a frame was authenticated with
+	ExtensionKeyID uint16 = 1
+	// ExtensionSequence is the (big endian uint64) sequence number of a frame
+	ExtensionSequence uint16 = 2
+	// ExtensionTimestamp is the (big endian, nanoseconds since the Unix
+	// epoch, uint64) time at which a frame was authenticated
+	ExtensionTimestamp uint16 = 3
+	// ExtensionCompression is the (single byte) ID of the algorithm the
+	// message of a frame is compressed with
+	ExtensionCompression uint16 = 4
+	// ExtensionAlgorithm is the (big endian uint16) ID of the registered
+	// Algorithm (see Register) a frame was authenticated with, which only
+	// applications write (see AlgorithmExtension)
+	ExtensionAlgorithm uint16 = 5
+	// ExtensionPadding is (zero valued) padding, hiding the exact length
+	// of the message of a frame, which readers may ignore
+	ExtensionPadding uint16 = 6
+	// ExtensionStreamID is the (big endian uint32) ID of the multiplexed
+	// stream a frame belongs to (see the mux package)
+	ExtensionStreamID uint16 = 7
+	// ExtensionStreamFlags is the (single byte) kind of a frame of a
+	// multiplexed stream, e.g. opening or closing it (see the mux package)
+	ExtensionStreamFlags uint16 = 8
+
+	// ExtensionTypeCustom is the first type free for applications to use
+	ExtensionTypeCustom uint16 = 0x8000
+)
+
+// Extension is a type-length-value field in the extension area of a frame.
+// Extensions are covered by the MAC of their frame.
+type Extension struct {
+	Type  uint16
+	Value []byte
+}
+
+// KeyIDExtension returns an ExtensionKeyID Extension
+func KeyIDExtension(keyID string) Extension {
+	return Extension{Type: ExtensionKeyID, Value: []byte(keyID)}
+}
+
+// SequenceExtension returns an ExtensionSequence Extension
+func SequenceExtension(seq uint64) Extension {
+	return Extension{Type: ExtensionSequence, Value: binary.BigEndian.AppendUint64(nil, seq)}
+}
+
+// TimestampExtension returns an ExtensionTimestamp Extension
+func TimestampExtension(t time.Time) Extension {
+	return Extension{Type: ExtensionTimestamp, Value: binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))}
+}
+
+// CompressionExtension returns an ExtensionCompression Extension
+func CompressionExtension(algorithm byte) Extension {
+	return Extension{Type: ExtensionCompression, Value: []byte{algorithm}}
+}
+
+// PaddingExtension returns an ExtensionPadding Extension of the given length
+func PaddingExtension(length int) Extension {
+	return Extension{Type: ExtensionPadding, Value: make([]byte, length)}
+}
+
+// ExtensionAreaLength returns the length (in bytes) of the extension
+// area of a frame with the given extensions, its length field included
+func ExtensionAreaLength(extensions []Extension) int {
+	length := extensionAreaLengthFieldSize
+	for _, extension := range extensions {
+		length += 2*extensionAreaLengthFieldSize + len(extension.Value)
+	}
+	return length
+}
+
+// FindExtension returns the value of the first Extension of the given type
+func FindExtension(extensions []Extension, extensionType uint16) ([]byte, bool) {
+	for _, extension := range extensions {
+		if extension.Type == extensionType {
+			return extension.Value, true
+		}
+	}
+	return nil, false
+}
+
+// encodeExtensions encodes an extension area, i.e. its (big endian uint16)
+// length followed by every extension's type, length, and value
+func encodeExtensions(extensions []Extension) ([]byte, error) {
+	area := make([]byte, extensionAreaLengthFieldSize)
+	for _, extension := range extensions {
+		if len(extension.Value) > math.MaxUint16 {
+			return nil, fmt.Errorf("extension %d too large, got %d bytes", extension.Type, len(extension.Value))
+		}
+		area = binary.BigEndian.AppendUint16(area, extension.Type)
+		area = binary.BigEndian.AppendUint16(area, uint16(len(extension.Value)))
+		area = append(area, extension.Value...)
+	}
+	if len(area)-extensionAreaLengthFieldSize > math.MaxUint16 {
+		return nil, fmt.Errorf("extension area too large, got %d bytes", len(area))
+	}
+	binary.BigEndian.PutUint16(area, uint16(len(area)-extensionAreaLengthFieldSize))
+	return area, nil
+}
+
+// ParseExtensions splits the payload of a frame with an extension area (see
+// FrameInfo) into its extensions and the message which follows them, without
+// verifying the frame, e.g. to inspect frames without the key
+func ParseExtensions(payload []byte) ([]Extension, []byte, error) {
+	return decodeExtensions(payload)
+}
+
+// decodeExtensions splits the payload of a frame into its
+// extensions and the message which follows them
+func decodeExtensions(payload []byte) ([]Extension, []byte, error) {
+	if len(payload) < extensionAreaLengthFieldSize {
+		return nil, nil, errors.New("payload too short to have extension area")
+	}
+	areaLen := int(binary.BigEndian.Uint16(payload))
+	payload = payload[extensionAreaLengthFieldSize:]
+	if len(payload) < areaLen {
+		return nil, nil, fmt.Errorf("%w: extension area longer than payload", ErrInvalidLength)
+	}
+	area, msg := payload[:areaLen], payload[areaLen:]
+
+	extensions := []Extension{}
+	for len(area) > 0 {
+		if len(area) < 2*extensionAreaLengthFieldSize {
+			return nil, nil, fmt.Errorf("%w: truncated extension", ErrInvalidLength)
+		}
+		extensionType := binary.BigEndian.Uint16(area)
+		valueLen := int(binary.BigEndian.Uint16(area[extensionAreaLengthFieldSize:]))
+		area = area[2*extensionAreaLengthFieldSize:]
+		if len(area) < valueLen {
+			return nil, nil, fmt.Errorf("%w: truncated extension %d", ErrInvalidLength, extensionType)
+		}
+		extensions = append(extensions, Extension{Type: extensionType, Value: area[:valueLen]})
+		area = area[valueLen:]
+	}
+	return extensions, msg, nil
+}
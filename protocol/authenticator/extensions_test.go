@@ -0,0 +1,112 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Extensions(t *testing.T) {
+	mockKey := []byte("mock key")
+	msg := []byte("mock message")
+	now := time.Unix(0, time.Now().UnixNano())
+
+	tests := []struct {
+		name        string
+		extensions  []Extension
+		tamper      func(frame []byte, headerLen int)
+		expectError error
+	}{
+		{
+			name: "Registered extensions",
+			extensions: []Extension{
+				KeyIDExtension("key-1"),
+				SequenceExtension(42),
+				TimestampExtension(now),
+				CompressionExtension(1),
+			},
+		},
+		{
+			name:       "Custom extension",
+			extensions: []Extension{{Type: ExtensionTypeCustom, Value: []byte("custom")}},
+		},
+		{
+			name:       "No extensions",
+			extensions: []Extension{},
+		},
+		{
+			name:        "Tampered extension",
+			extensions:  []Extension{KeyIDExtension("key-1")},
+			tamper:      func(frame []byte, headerLen int) { frame[headerLen+2+4] ^= 0x01 },
+			expectError: ErrMACMismatch,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := NewDefaultMessageAuthenticator(sha256.New, mockKey)
+			header, err := a.GetMessageAuthenticationHeaderWithExtensions(msg, test.extensions)
+			assert.Nil(t, err)
+			assert.Equal(t, a.GetMessageAuthenticationHeaderLength()+ExtensionAreaLength(test.extensions), len(header))
+
+			frame := append(header, msg...)
+			info, err := ParseFrameHeader(frame[:a.GetMessageAuthenticationHeaderLength()])
+			assert.Nil(t, err)
+			assert.True(t, info.Extensions)
+			assert.Equal(t, uint64(len(frame)), info.Length)
+
+			if test.tamper != nil {
+				test.tamper(frame, a.GetMessageAuthenticationHeaderLength())
+			}
+
+			got, extensions, control, err := a.ReadNextFrameWithExtensions(bytes.NewReader(frame))
+			if test.expectError != nil {
+				assert.True(t, errors.Is(err, test.expectError))
+				return
+			}
+			assert.Nil(t, err)
+			assert.False(t, control)
+			assert.Equal(t, msg, got)
+			assert.Equal(t, test.extensions, extensions)
+
+			// readers not interested in extensions skip over them
+			got, err = a.ReadNext(bytes.NewReader(frame))
+			assert.Nil(t, err)
+			assert.Equal(t, msg, got)
+		})
+	}
+}
+
+func Test_FindExtension(t *testing.T) {
+	extensions := []Extension{KeyIDExtension("key-1"), SequenceExtension(1)}
+
+	value, ok := FindExtension(extensions, ExtensionKeyID)
+	assert.True(t, ok)
+	assert.Equal(t, "key-1", string(value))
+
+	_, ok = FindExtension(extensions, ExtensionTimestamp)
+	assert.False(t, ok)
+}
+
+func Test_ParseExtensions(t *testing.T) {
+	a := NewDefaultMessageAuthenticator(sha256.New, []byte("mock key"))
+	extensions := []Extension{KeyIDExtension("key-1"), PaddingExtension(3)}
+	header, err := a.GetMessageAuthenticationHeaderWithExtensions([]byte("hello"), extensions)
+	assert.Nil(t, err)
+
+	headerLen := a.GetMessageAuthenticationHeaderLength()
+	info, err := ParseFrameHeader(header[:headerLen])
+	assert.Nil(t, err)
+	assert.True(t, info.Extensions)
+
+	parsed, msg, err := ParseExtensions(append(header[headerLen:], "hello"...))
+	assert.Nil(t, err)
+	assert.Equal(t, extensions, parsed)
+	assert.Equal(t, "hello", string(msg))
+
+	_, _, err = ParseExtensions(header[headerLen : len(header)-1])
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+}
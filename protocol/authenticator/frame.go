@@ -0,0 +1,153 @@
+package authenticator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+)
+
+// ErrInvalidLength is returned (wrapped) for frames whose length field cannot
+// be right, i.e. smaller than the header, larger than the data the frame is
+// decoded from, or larger than the max frame length (see maxFrameLength)
+var ErrInvalidLength = errors.New("invalid frame length")
+
+// MessageTooLargeError is returned by ReadNext (and the like) for frames whose
+// message is larger than the max message size, as soon as their header is read
+// (i.e. before the rest of the frame), such that readers can either give up on
+// the stream or discard the rest of the frame and carry on. Use errors.As to
+// access it. Note that the frame is not verified, so its size cannot be trusted.
+type MessageTooLargeError struct {
+	// Size is the size of the message, according to the frame header
+	Size uint64
+	// Max is the max message size
+	Max int
+	// Remaining is the number of bytes of the frame left unread
+	Remaining uint64
+}
+
+// Error returns the error message
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("message too large, got %d and expected at most %d", e.Size, e.Max)
+}
+
+const (
+	// maxFrameLength is the max length of a frame (with any header format),
+	// such that lengths always fit in an int regardless of the platform
+	maxFrameLength = math.MaxInt32
+
+	// payloadChunkSize is the size of the chunks in which payloads
+	// larger than it are read (and allocated), see readPayload
+	payloadChunkSize = 64 * 1024
+)
+
+// FrameInfo is the information in the header of a frame (i.e. a message
+// and its header). Note that none of it can be trusted until the MAC over
+// the frame has been verified.
+type FrameInfo struct {
+	// MAC is the (base64 encoded) MAC of the frame
+	MAC []byte
+	// Length is the length of the frame, header included
+	Length uint64
+	// PayloadLength is the length of the message in the frame
+	PayloadLength uint64
+	// CloseNotify is whether the frame is a close notification (see
+	// CloseNotifier), which is encoded as a frame with a zero length
+	CloseNotify bool
+	// Control is whether the frame is a control frame (see ControlFramer),
+	// which is encoded with the most significant bit of the length set
+	Control bool
+	// Extensions is whether the payload starts with an extension area (see
+	// ExtensionFramer), which is encoded with the second most significant
+	// bit of the length set
+	Extensions bool
+}
+
+// controlFrameFlag is set in the length field of control frames, no
+// data frame can be long enough for the length to have it set
+const controlFrameFlag = uint64(1) << 63
+
+// HeaderLength returns the length (in bytes) of frame
+// headers for frames authenticated with the given hash
+func HeaderLength(hashFn func() hash.Hash) int {
+	return computeHeaderLengthWithHash(hashFn)
+}
+
+// HeaderLengthWithTagSize returns the length (in bytes) of frame headers for
+// frames authenticated with MACs truncated to the given tag size (see WithTagSize)
+func HeaderLengthWithTagSize(tagSize int) int {
+	return computeHeaderLength(tagSize)
+}
+
+// HeaderLengthWithEncoding returns the length (in bytes) of frame headers for
+// frames authenticated with MACs of the given size (i.e. that of the hash, or
+// the tag size) encoded with the given MACEncoding (see WithMACEncoding)
+func HeaderLengthWithEncoding(macSize int, encoding MACEncoding) int {
+	return computeHeaderLengthWithEncoding(macSize, encoding)
+}
+
+// ParseFrameHeader parses a frame header without verifying it. Since the
+// length of the MAC depends on the hash function used, the given bytes must
+// be exactly one header (see HeaderLength), without any message bytes.
+func ParseFrameHeader(header []byte) (FrameInfo, error) {
+	headerLen := len(header)
+	if headerLen <= lengthHeaderFieldSize {
+		return FrameInfo{}, fmt.Errorf("header too short, got %d and expected more than %d", headerLen, lengthHeaderFieldSize)
+	}
+
+	length := binary.BigEndian.Uint64(header[headerLen-lengthHeaderFieldSize:])
+	control := length&controlFrameFlag != 0
+	extensions := length&extensionsFlag != 0
+	length &^= controlFrameFlag | extensionsFlag
+	if length == 0 && !control && !extensions {
+		return FrameInfo{
+			MAC:         header[:headerLen-lengthHeaderFieldSize],
+			CloseNotify: true,
+		}, nil
+	}
+	if length < uint64(headerLen) {
+		return FrameInfo{}, fmt.Errorf("%w: message length in header smaller than header, got %d and expected at least %d", ErrInvalidLength, length, headerLen)
+	}
+	if length > maxFrameLength {
+		return FrameInfo{}, fmt.Errorf("%w: message length in header larger than the max frame length, got %d and expected at most %d", ErrInvalidLength, length, maxFrameLength)
+	}
+
+	return FrameInfo{
+		MAC:           header[:headerLen-lengthHeaderFieldSize],
+		Length:        length,
+		PayloadLength: length - uint64(headerLen),
+		Control:       control,
+		Extensions:    extensions,
+	}, nil
+}
+
+// readPayload reads a payload of the given length, allocating memory as it is
+// read (rather than all of it up front) for payloads larger than a chunk, such
+// that lengths claimed by frames cannot make readers allocate (much) more
+// memory than was actually sent. Like io.ReadFull, it returns io.EOF if no
+// bytes were read, and io.ErrUnexpectedEOF if only some were.
+func readPayload(r io.Reader, n uint64) ([]byte, error) {
+	if n <= payloadChunkSize {
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, payloadChunkSize))
+	read, err := buf.ReadFrom(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if read == 0 {
+		return nil, io.EOF
+	}
+	if uint64(read) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,309 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+)
+
+// FrameHeader is the header of a frame as encoded and decoded by a FrameCodec
+type FrameHeader struct {
+	// MAC is the (raw, i.e. not encoded) MAC of the frame
+	MAC []byte
+	// PayloadLength is the length of the message (or control frame payload)
+	PayloadLength uint64
+	// Control is whether the frame is a control frame (see ControlFramer)
+	Control bool
+	// CloseNotify is whether the frame is a close notification (see
+	// CloseNotifier), which has no payload
+	CloseNotify bool
+}
+
+// FrameCodec encodes and decodes the headers of frames, i.e. their wire format,
+// separately from how MACs are computed (see CodecMessageAuthenticator), such
+// that frames can match the framing of another (e.g. legacy) protocol. Headers
+// must have a fixed length for MACs of a given size. Since MACs only cover the
+// fields of a FrameHeader (and the payload), codecs must not carry anything
+// else readers act upon.
+type FrameCodec interface {
+	// HeaderLength returns the length of headers with MACs of the given size
+	HeaderLength(macSize int) int
+	// EncodeHeader encodes a header
+	EncodeHeader(header FrameHeader) ([]byte, error)
+	// DecodeHeader decodes a header of HeaderLength(macSize) bytes
+	DecodeHeader(header []byte, macSize int) (FrameHeader, error)
+}
+
+// defaultFrameCodec is the FrameCodec of the default frame format
+type defaultFrameCodec struct {
+	encoding MACDecoder
+}
+
+// NewDefaultFrameCodec returns a FrameCodec of the default frame format (see
+// DefaultMessageAuthenticator) with MACs in the given encoding, e.g. StdBase64
+// or Hex. Frames are interchangeable with those of DefaultMessageAuthenticators
+// without extensions.
+func NewDefaultFrameCodec(encoding MACDecoder) FrameCodec {
+	return defaultFrameCodec{encoding: encoding}
+}
+
+func (c defaultFrameCodec) HeaderLength(macSize int) int {
+	return computeHeaderLengthWithEncoding(macSize, c.encoding)
+}
+
+func (c defaultFrameCodec) EncodeHeader(header FrameHeader) ([]byte, error) {
+	length := frameLengthField(c.HeaderLength(len(header.MAC)), header)
+	return binary.BigEndian.AppendUint64([]byte(c.encoding.EncodeToString(header.MAC)), length), nil
+}
+
+func (c defaultFrameCodec) DecodeHeader(header []byte, macSize int) (FrameHeader, error) {
+	info, err := ParseFrameHeader(header)
+	if err != nil {
+		return FrameHeader{}, err
+	}
+	if info.Extensions {
+		return FrameHeader{}, errors.New("extensions are not supported")
+	}
+	mac, err := c.encoding.DecodeString(string(info.MAC))
+	if err != nil {
+		return FrameHeader{}, fmt.Errorf("invalid MAC encoding: %w", err)
+	}
+	return FrameHeader{
+		MAC:           mac,
+		PayloadLength: info.PayloadLength,
+		Control:       info.Control,
+		CloseNotify:   info.CloseNotify,
+	}, nil
+}
+
+// frameLengthField returns the length field of the default frame format
+// for a header, which is also what MACs cover regardless of the FrameCodec
+func frameLengthField(headerLen int, header FrameHeader) uint64 {
+	if header.CloseNotify {
+		return 0
+	}
+	length := uint64(headerLen) + header.PayloadLength
+	if header.Control {
+		length |= controlFrameFlag
+	}
+	return length
+}
+
+// CodecMessageAuthenticator is an HMAC based MessageAuthenticator whose frames
+// are laid out by a FrameCodec. MACs cover the associated data (if any), the
+// length field of the default frame format (i.e. the length of the frame and
+// whether it is a control frame or a close notification), and the payload.
+type CodecMessageAuthenticator struct {
+	codec          FrameCodec
+	hashFn         func() hash.Hash
+	key            []byte
+	tagSize        int
+	aad            []byte
+	maxMessageSize int
+
+	// guards key and destroyed, such that Destroy
+	// waits for any operations in progress
+	lock      sync.RWMutex
+	destroyed bool
+}
+
+// ensure CodecMessageAuthenticator implements CloseNotifier and ControlFramer at compile-time
+var (
+	_ CloseNotifier = (*CodecMessageAuthenticator)(nil)
+	_ ControlFramer = (*CodecMessageAuthenticator)(nil)
+)
+
+// NewCodecMessageAuthenticator returns a newly initialized CodecMessageAuthenticator.
+// The key is copied, such that Destroy does not modify the caller's key.
+func NewCodecMessageAuthenticator(codec FrameCodec, hashFn func() hash.Hash, key []byte) *CodecMessageAuthenticator {
+	return &CodecMessageAuthenticator{
+		codec:  codec,
+		hashFn: hashFn,
+		key:    append([]byte{}, key...),
+	}
+}
+
+// WithTagSize truncates MACs on a CodecMessageAuthenticator to the given size
+// in bytes and returns it (see DefaultMessageAuthenticator.WithTagSize)
+func (a *CodecMessageAuthenticator) WithTagSize(size int) *CodecMessageAuthenticator {
+	if size >= a.hashFn().Size() {
+		size = 0
+	}
+	a.tagSize = size
+	return a
+}
+
+// WithAssociatedData sets the associated data bound into every
+// MAC on a CodecMessageAuthenticator and returns it
+func (a *CodecMessageAuthenticator) WithAssociatedData(aad []byte) *CodecMessageAuthenticator {
+	a.aad = aad
+	return a
+}
+
+// WithMaxMessageSize sets the maximum size (in bytes, excluding the header) of
+// messages accepted on a CodecMessageAuthenticator and returns it. Zero means no limit.
+func (a *CodecMessageAuthenticator) WithMaxMessageSize(size int) *CodecMessageAuthenticator {
+	a.maxMessageSize = size
+	return a
+}
+
+// Destroy overwrites the CodecMessageAuthenticator's copy of the key with
+// zeros, after which all of its operations fail with ErrDestroyed
+func (a *CodecMessageAuthenticator) Destroy() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for i := range a.key {
+		a.key[i] = 0
+	}
+	a.key = nil
+	a.destroyed = true
+}
+
+// macSize returns the size of MACs
+func (a *CodecMessageAuthenticator) macSize() int {
+	if a.tagSize > 0 {
+		return a.tagSize
+	}
+	return a.hashFn().Size()
+}
+
+// GetMessageAuthenticationHeaderLength returns the length
+// (in bytes) of headers produced by the MessageAuthenticator
+func (a *CodecMessageAuthenticator) GetMessageAuthenticationHeaderLength() int {
+	return a.codec.HeaderLength(a.macSize())
+}
+
+// GetMessageAuthenticationHeader returns a header produced for the given data
+func (a *CodecMessageAuthenticator) GetMessageAuthenticationHeader(data []byte) ([]byte, error) {
+	return a.header(FrameHeader{PayloadLength: uint64(len(data))}, data)
+}
+
+// GetControlFrameHeader returns a header produced for the given control frame payload
+func (a *CodecMessageAuthenticator) GetControlFrameHeader(payload []byte) ([]byte, error) {
+	return a.header(FrameHeader{PayloadLength: uint64(len(payload)), Control: true}, payload)
+}
+
+// GetCloseNotifyHeader returns a close notification
+func (a *CodecMessageAuthenticator) GetCloseNotifyHeader() ([]byte, error) {
+	return a.header(FrameHeader{CloseNotify: true}, nil)
+}
+
+func (a *CodecMessageAuthenticator) header(header FrameHeader, payload []byte) ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+	mac, err := a.mac(header, payload)
+	if err != nil {
+		return nil, err
+	}
+	header.MAC = mac
+	encoded, err := a.codec.EncodeHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode header: %w", err)
+	}
+	return encoded, nil
+}
+
+// mac returns the MAC of a frame, the key lock must be held
+func (a *CodecMessageAuthenticator) mac(header FrameHeader, payload []byte) ([]byte, error) {
+	length := binary.BigEndian.AppendUint64(nil, frameLengthField(a.GetMessageAuthenticationHeaderLength(), header))
+	sum, err := computeMAC(a.hashFn, a.tagSize, Raw, a.key, a.aad, length, payload)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(sum), nil
+}
+
+// AuthenticateMessages processes one or more frames in a given byte slice. It returns
+// the raw messages processed successfully and the number of messages processed.
+func (a *CodecMessageAuthenticator) AuthenticateMessages(data []byte) ([]byte, int, error) {
+	processed := []byte{}
+	reader := bytes.NewReader(data)
+	nMessages := 0
+
+	for reader.Len() > 0 {
+		msg, err := a.ReadNext(reader)
+		if err != nil {
+			return processed, nMessages, fmt.Errorf("failed decoding header: %w", err)
+		}
+		processed = append(processed, msg...)
+		nMessages++
+	}
+	return processed, nMessages, nil
+}
+
+// ReadNext reads and verifies a single message. It returns
+// ErrCloseNotify upon reading a valid close notification.
+func (a *CodecMessageAuthenticator) ReadNext(r io.Reader) ([]byte, error) {
+	msg, control, err := a.ReadNextFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	if control {
+		return nil, fmt.Errorf("unexpected control frame")
+	}
+	return msg, nil
+}
+
+// ReadNextFrame reads and verifies a single frame, which
+// is either a message or (if control is true) a control frame
+func (a *CodecMessageAuthenticator) ReadNextFrame(r io.Reader) ([]byte, bool, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, false, ErrDestroyed
+	}
+
+	raw := make([]byte, a.GetMessageAuthenticationHeaderLength())
+	if _, err := io.ReadFull(r, raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, false, io.EOF
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, false, fmt.Errorf("%w: read data too short to have valid header", ErrTruncatedMessage)
+		}
+		return nil, false, fmt.Errorf("failed to read message header: %w", err)
+	}
+	header, err := a.codec.DecodeHeader(raw, a.macSize())
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid header: %w", err)
+	}
+	if header.CloseNotify && header.PayloadLength != 0 {
+		return nil, false, fmt.Errorf("%w: close notification with a payload", ErrInvalidLength)
+	}
+	if header.PayloadLength > maxFrameLength {
+		return nil, false, fmt.Errorf("%w: message length in header larger than the max frame length, got %d and expected at most %d", ErrInvalidLength, header.PayloadLength, maxFrameLength)
+	}
+	if a.maxMessageSize > 0 && header.PayloadLength > uint64(a.maxMessageSize) {
+		return nil, false, &MessageTooLargeError{Size: header.PayloadLength, Max: a.maxMessageSize, Remaining: header.PayloadLength}
+	}
+
+	msg, err := readPayload(r, header.PayloadLength)
+	if err != nil {
+		// the header was read, so the frame is truncated even if none of the message was
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, false, fmt.Errorf("%w: read message too short, does not match message size from header", ErrTruncatedMessage)
+		}
+		return nil, false, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	sum, err := a.mac(header, msg)
+	if err != nil {
+		return nil, false, err
+	}
+	if !hmac.Equal(header.MAC, sum) {
+		return nil, false, ErrMACMismatch
+	}
+	if header.CloseNotify {
+		return nil, false, ErrCloseNotify
+	}
+	return msg, header.Control, nil
+}
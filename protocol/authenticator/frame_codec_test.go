@@ -0,0 +1,138 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+// legacyFrameCodec is a FrameCodec of a made up legacy framing: a type byte,
+// a (big endian uint32) payload length, and the raw MAC
+type legacyFrameCodec struct{}
+
+const (
+	legacyTypeData = iota
+	legacyTypeControl
+	legacyTypeClose
+)
+
+func (legacyFrameCodec) HeaderLength(macSize int) int { return 1 + 4 + macSize }
+
+func (legacyFrameCodec) EncodeHeader(header FrameHeader) ([]byte, error) {
+	frameType := byte(legacyTypeData)
+	if header.Control {
+		frameType = legacyTypeControl
+	}
+	if header.CloseNotify {
+		frameType = legacyTypeClose
+	}
+	encoded := binary.BigEndian.AppendUint32([]byte{frameType}, uint32(header.PayloadLength))
+	return append(encoded, header.MAC...), nil
+}
+
+func (legacyFrameCodec) DecodeHeader(header []byte, macSize int) (FrameHeader, error) {
+	if header[0] > legacyTypeClose {
+		return FrameHeader{}, fmt.Errorf("unknown frame type %d", header[0])
+	}
+	return FrameHeader{
+		MAC:           header[5:],
+		PayloadLength: uint64(binary.BigEndian.Uint32(header[1:5])),
+		Control:       header[0] == legacyTypeControl,
+		CloseNotify:   header[0] == legacyTypeClose,
+	}, nil
+}
+
+func Test_CodecMessageAuthenticator(t *testing.T) {
+	mockKey := []byte("mock key")
+	a := NewCodecMessageAuthenticator(legacyFrameCodec{}, sha256.New, mockKey).WithTagSize(16)
+	assert.Equal(t, 1+4+16, a.GetMessageAuthenticationHeaderLength())
+
+	frame := func(header []byte, err error, payload string) []byte {
+		assert.NoError(t, err)
+		assert.Equal(t, a.GetMessageAuthenticationHeaderLength(), len(header))
+		return append(header, payload...)
+	}
+	header, err := a.GetMessageAuthenticationHeader([]byte("hello"))
+	message := frame(header, err, "hello")
+	header, err = a.GetControlFrameHeader([]byte("ping"))
+	control := frame(header, err, "ping")
+	header, err = a.GetCloseNotifyHeader()
+	closeNotify := frame(header, err, "")
+
+	stream := bytes.NewReader(bytes.Join([][]byte{message, control, closeNotify}, nil))
+	msg, isControl, err := a.ReadNextFrame(stream)
+	assert.NoError(t, err)
+	assert.False(t, isControl)
+	assert.Equal(t, "hello", string(msg))
+	msg, isControl, err = a.ReadNextFrame(stream)
+	assert.NoError(t, err)
+	assert.True(t, isControl)
+	assert.Equal(t, "ping", string(msg))
+	_, _, err = a.ReadNextFrame(stream)
+	assert.True(t, errors.Is(err, ErrCloseNotify))
+	_, _, err = a.ReadNextFrame(stream)
+	assert.True(t, errors.Is(err, io.EOF))
+
+	// every header field is covered by the MAC
+	for _, tampered := range [][]byte{
+		append([]byte{legacyTypeControl}, message[1:]...),
+		append([]byte{legacyTypeData}, control[1:]...),
+		append(append([]byte{}, message[:len(message)-1]...), 'x'),
+	} {
+		_, err := a.ReadNext(bytes.NewReader(tampered))
+		assert.True(t, errors.Is(err, ErrMACMismatch), "expected %v, got %v", ErrMACMismatch, err)
+	}
+
+	// frames are bound to the key and associated data
+	_, err = NewCodecMessageAuthenticator(legacyFrameCodec{}, sha256.New, []byte("other key")).WithTagSize(16).ReadNext(bytes.NewReader(message))
+	assert.True(t, errors.Is(err, ErrMACMismatch))
+	_, err = NewCodecMessageAuthenticator(legacyFrameCodec{}, sha256.New, mockKey).WithTagSize(16).WithAssociatedData([]byte("aad")).ReadNext(bytes.NewReader(message))
+	assert.True(t, errors.Is(err, ErrMACMismatch))
+
+	_, err = a.ReadNext(bytes.NewReader(message[:3]))
+	assert.True(t, errors.Is(err, ErrTruncatedMessage))
+	_, err = a.WithMaxMessageSize(4).ReadNext(bytes.NewReader(message))
+	assert.Error(t, err)
+
+	a.Destroy()
+	_, err = a.GetMessageAuthenticationHeader([]byte("hello"))
+	assert.True(t, errors.Is(err, ErrDestroyed))
+}
+
+func Test_DefaultFrameCodec(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	for _, encoding := range []MACDecoder{StdBase64.(MACDecoder), Hex.(MACDecoder), Raw.(MACDecoder)} {
+		codec := NewCodecMessageAuthenticator(NewDefaultFrameCodec(encoding), sha256.New, mockKey)
+		standard := NewDefaultMessageAuthenticator(sha256.New, mockKey).WithMACEncoding(encoding)
+		assert.Equal(t, standard.GetMessageAuthenticationHeaderLength(), codec.GetMessageAuthenticationHeaderLength())
+
+		// frames are interchangeable with those of DefaultMessageAuthenticators
+		for _, pair := range [][2]MessageAuthenticator{{codec, standard}, {standard, codec}} {
+			header, err := pair[0].GetMessageAuthenticationHeader([]byte("hello"))
+			assert.NoError(t, err)
+			controlHeader, err := pair[0].(ControlFramer).GetControlFrameHeader([]byte("ping"))
+			assert.NoError(t, err)
+			closeNotify, err := pair[0].(CloseNotifier).GetCloseNotifyHeader()
+			assert.NoError(t, err)
+
+			stream := bytes.NewReader(bytes.Join([][]byte{header, []byte("hello"), controlHeader, []byte("ping"), closeNotify}, nil))
+			msg, control, err := pair[1].(ControlFramer).ReadNextFrame(stream)
+			assert.NoError(t, err)
+			assert.False(t, control)
+			assert.Equal(t, "hello", string(msg))
+			msg, control, err = pair[1].(ControlFramer).ReadNextFrame(stream)
+			assert.NoError(t, err)
+			assert.True(t, control)
+			assert.Equal(t, "ping", string(msg))
+			_, _, err = pair[1].(ControlFramer).ReadNextFrame(stream)
+			assert.True(t, errors.Is(err, ErrCloseNotify))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package authenticator
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_ParseFrameHeader(t *testing.T) {
+	mockRawMsgAndSizeMAC := []byte("ayfkWUgjU14GmJSb+O5QP3IU7ZepnQ52KwV2s7iBX8Q=")
+	mockHeaderLen := HeaderLength(sha256.New)
+
+	tests := []struct {
+		name         string
+		header       []byte
+		expectedInfo FrameInfo
+		expectError  bool
+	}{
+		{
+			name:   "Valid header",
+			header: append(mockRawMsgAndSizeMAC, []byte{0, 0, 0, 0, 0, 0, 0, byte(mockHeaderLen + 9)}...),
+			expectedInfo: FrameInfo{
+				MAC:           mockRawMsgAndSizeMAC,
+				Length:        uint64(mockHeaderLen + 9),
+				PayloadLength: 9,
+			},
+		},
+		{
+			name:   "Empty message",
+			header: append(mockRawMsgAndSizeMAC, []byte{0, 0, 0, 0, 0, 0, 0, byte(mockHeaderLen)}...),
+			expectedInfo: FrameInfo{
+				MAC:           mockRawMsgAndSizeMAC,
+				Length:        uint64(mockHeaderLen),
+				PayloadLength: 0,
+			},
+		},
+		{
+			name:   "Close notification",
+			header: append(mockRawMsgAndSizeMAC, []byte{0, 0, 0, 0, 0, 0, 0, 0}...),
+			expectedInfo: FrameInfo{
+				MAC:         mockRawMsgAndSizeMAC,
+				CloseNotify: true,
+			},
+		},
+		{
+			name:        "Length smaller than header",
+			header:      append(mockRawMsgAndSizeMAC, []byte{0, 0, 0, 0, 0, 0, 0, 1}...),
+			expectError: true,
+		},
+		{
+			name:        "Too short",
+			header:      []byte{0, 0, 0, 0, 0, 0, 0, 1},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ParseFrameHeader(test.header)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedInfo, info)
+		})
+	}
+}
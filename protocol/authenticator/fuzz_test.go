@@ -0,0 +1,99 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+const (
+	fuzzMaxMessageSize = 1024
+)
+
+var fuzzKey = []byte("fuzz key")
+
+// fuzzSeeds returns a valid frame, a frame with a tampered
+// MAC, and frames with lengths smaller than the header and
+// larger than the data (or the max message size)
+func fuzzSeeds(f *testing.F) [][]byte {
+	a := NewDefaultMessageAuthenticator(sha256.New, fuzzKey)
+
+	header, err := a.GetMessageAuthenticationHeader([]byte("hello"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	valid := append(header, []byte("hello")...)
+
+	tampered := append([]byte{}, valid...)
+	tampered[0] ^= 0xff
+
+	withLength := func(length uint64) []byte {
+		frame := append([]byte{}, valid...)
+		binary.BigEndian.PutUint64(frame[a.headerLen-lengthHeaderFieldSize:], length)
+		return frame
+	}
+
+	return [][]byte{
+		{},
+		valid,
+		append(valid, valid...),
+		tampered,
+		withLength(0),
+		withLength(uint64(a.headerLen - 1)),
+		withLength(uint64(len(valid) + 1)),
+		withLength(uint64(a.headerLen + fuzzMaxMessageSize + 1)),
+		withLength(^uint64(0)),
+	}
+}
+
+func FuzzReadNext(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		a := NewDefaultMessageAuthenticator(sha256.New, fuzzKey).WithMaxMessageSize(fuzzMaxMessageSize)
+
+		msg, err := a.ReadNext(bytes.NewReader(data))
+		if err != nil {
+			if msg != nil {
+				t.Fatalf("got message %q along with error %s", msg, err)
+			}
+			if len(data) == 0 && !errors.Is(err, io.EOF) {
+				t.Fatalf("expected io.EOF for empty data, got %s", err)
+			}
+			return
+		}
+		if len(msg) > fuzzMaxMessageSize {
+			t.Fatalf("got message of size %d larger than max %d", len(msg), fuzzMaxMessageSize)
+		}
+		if len(msg)+a.headerLen > len(data) {
+			t.Fatalf("got message of size %d from only %d bytes", len(msg), len(data))
+		}
+	})
+}
+
+func FuzzAuthenticateMessages(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		a := NewDefaultMessageAuthenticator(sha256.New, fuzzKey).WithMaxMessageSize(fuzzMaxMessageSize)
+
+		processed, n, err := a.AuthenticateMessages(data)
+		if len(processed)+n*a.headerLen > len(data) {
+			t.Fatalf("got %d messages of %d bytes from only %d bytes", n, len(processed), len(data))
+		}
+		if err == nil {
+			// every frame verified by AuthenticateMessages is verified by ReadNext too
+			reader := bytes.NewReader(data)
+			for reader.Len() > 0 {
+				if _, _, _, err := a.ReadNextFrameWithExtensions(reader); err != nil && !errors.Is(err, ErrCloseNotify) {
+					t.Fatalf("AuthenticateMessages accepted a frame ReadNext rejects: %s", err)
+				}
+			}
+		}
+	})
+}
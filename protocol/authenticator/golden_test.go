@@ -0,0 +1,182 @@
+package authenticator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+// the golden files guard the wire format of every algorithm and frame format
+// against accidental changes, regenerate them (only upon intentional changes
+// of the wire format) with: go test ./protocol/authenticator -update
+var goldenDir = filepath.Join("testdata", "golden")
+
+var (
+	goldenKey      = []byte("golden key, never use for anything else")
+	goldenAAD      = []byte("golden associated data")
+	goldenTime     = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	goldenMessages = [][]byte{
+		{},
+		[]byte("hello world\n"),
+		bytes.Repeat([]byte{0x00, 0x01, 0xfe, 0xff}, 64),
+	}
+	goldenExtensions = []Extension{KeyIDExtension("key-1"), TimestampExtension(goldenTime)}
+	goldenControl    = []byte("\x01ping")
+)
+
+// goldenCase is an algorithm and frame format combination
+type goldenCase struct {
+	name       string
+	new        func() MessageAuthenticator
+	extensions bool // whether messages carry goldenExtensions
+}
+
+func goldenCases() []goldenCase {
+	cases := []goldenCase{}
+	for _, alg := range Algorithms() {
+		if alg.ID >= AlgorithmIDCustom {
+			// registered by other tests
+			continue
+		}
+		alg := alg
+		switch {
+		case alg.ID == AlgorithmPoly1305:
+			// nonces must be deterministic
+			cases = append(cases, goldenCase{name: alg.Name, new: func() MessageAuthenticator {
+				return NewOneTimeMACAuthenticator(goldenKey, NewRandomNonceSource(goldenNonces()))
+			}})
+		case alg.ID == AlgorithmCBORHMACSHA256:
+			// timestamps must be deterministic
+			cases = append(cases, goldenCase{name: alg.Name, new: func() MessageAuthenticator {
+				return NewCBORMessageAuthenticator(alg.HashFn, goldenKey).WithClock(func() time.Time { return goldenTime })
+			}})
+		case alg.HashFn == nil:
+			cases = append(cases, goldenCase{name: alg.Name, new: func() MessageAuthenticator { return alg.New(goldenKey) }})
+		default:
+			hashFn := alg.HashFn
+			cases = append(cases,
+				goldenCase{name: alg.Name, new: func() MessageAuthenticator { return alg.New(goldenKey) }},
+				goldenCase{name: alg.Name + "-hex", new: func() MessageAuthenticator {
+					return NewDefaultMessageAuthenticator(hashFn, goldenKey).WithMACEncoding(Hex)
+				}},
+				goldenCase{name: alg.Name + "-raw", new: func() MessageAuthenticator {
+					return NewDefaultMessageAuthenticator(hashFn, goldenKey).WithMACEncoding(Raw)
+				}},
+				goldenCase{name: alg.Name + "-tag16", new: func() MessageAuthenticator {
+					return NewDefaultMessageAuthenticator(hashFn, goldenKey).WithTagSize(16)
+				}},
+				goldenCase{name: alg.Name + "-aad", new: func() MessageAuthenticator {
+					return NewDefaultMessageAuthenticator(hashFn, goldenKey).WithAssociatedData(goldenAAD)
+				}},
+				goldenCase{name: alg.Name + "-extensions", extensions: true, new: func() MessageAuthenticator {
+					return NewDefaultMessageAuthenticator(hashFn, goldenKey)
+				}},
+				goldenCase{name: alg.Name + "-cbor", new: func() MessageAuthenticator {
+					return NewCBORMessageAuthenticator(hashFn, goldenKey).WithKeyID("key-1").WithClock(func() time.Time { return goldenTime })
+				}},
+				goldenCase{name: alg.Name + "-codec", new: func() MessageAuthenticator {
+					return NewCodecMessageAuthenticator(NewDefaultFrameCodec(Hex.(MACDecoder)), hashFn, goldenKey)
+				}},
+			)
+		}
+	}
+	return cases
+}
+
+// goldenNonces returns a deterministic source of nonces
+func goldenNonces() io.Reader {
+	nonces := make([]byte, 1024)
+	for i := range nonces {
+		nonces[i] = byte(i)
+	}
+	return bytes.NewReader(nonces)
+}
+
+// goldenStream returns a stream of a control frame (if supported), the
+// golden messages, and a close notification (if supported)
+func goldenStream(t *testing.T, test goldenCase) []byte {
+	a := test.new()
+	stream := []byte{}
+	if framer, ok := a.(ControlFramer); ok {
+		header, err := framer.GetControlFrameHeader(goldenControl)
+		assert.NoError(t, err)
+		stream = append(append(stream, header...), goldenControl...)
+	}
+	for _, message := range goldenMessages {
+		var header []byte
+		var err error
+		if test.extensions {
+			header, err = a.(ExtensionFramer).GetMessageAuthenticationHeaderWithExtensions(message, goldenExtensions)
+		} else {
+			header, err = a.GetMessageAuthenticationHeader(message)
+		}
+		assert.NoError(t, err)
+		stream = append(append(stream, header...), message...)
+	}
+	if notifier, ok := a.(CloseNotifier); ok {
+		header, err := notifier.GetCloseNotifyHeader()
+		assert.NoError(t, err)
+		stream = append(stream, header...)
+	}
+	return stream
+}
+
+func Test_GoldenFiles(t *testing.T) {
+	cases := goldenCases()
+	if *update {
+		assert.NoError(t, os.RemoveAll(goldenDir))
+		assert.NoError(t, os.MkdirAll(goldenDir, 0755))
+		for _, test := range cases {
+			assert.NoError(t, os.WriteFile(filepath.Join(goldenDir, test.name+".golden"), goldenStream(t, test), 0644))
+		}
+	}
+
+	// every golden file is covered, such that removed cases are noticed
+	files, err := filepath.Glob(filepath.Join(goldenDir, "*.golden"))
+	assert.NoError(t, err)
+	assert.Equal(t, len(cases), len(files))
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			golden, err := os.ReadFile(filepath.Join(goldenDir, test.name+".golden"))
+			if err != nil {
+				t.Fatalf("failed to read golden file (regenerate with -update): %s", err)
+			}
+			assert.Equal(t, golden, goldenStream(t, test), "wire format changed")
+
+			// the golden frames read back as the golden messages
+			a := test.new()
+			r := bytes.NewReader(golden)
+			messages := [][]byte{}
+			for {
+				var message []byte
+				var err error
+				control := false
+				if framer, ok := a.(ControlFramer); ok {
+					message, control, err = framer.ReadNextFrame(r)
+				} else {
+					message, err = a.ReadNext(r)
+				}
+				if err != nil {
+					assert.True(t, errors.Is(err, io.EOF) || errors.Is(err, ErrCloseNotify))
+					break
+				}
+				if control {
+					assert.Equal(t, goldenControl, message)
+					continue
+				}
+				messages = append(messages, message)
+			}
+			assert.Equal(t, len(goldenMessages), len(messages))
+			for i := range messages {
+				assert.Equal(t, goldenMessages[i], messages[i])
+			}
+		})
+	}
+}
@@ -1,25 +1,46 @@
 package authenticator
 
 import (
+	"bytes"
 	"crypto/hmac"
-	"encoding/base64"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
 	"math"
+	"sync"
 )
 
+// ErrDestroyed is returned by a DefaultMessageAuthenticator after Destroy
+var ErrDestroyed = errors.New("key material destroyed")
+
+// ErrMACMismatch is returned (wrapped) when the MAC of a frame is invalid
+var ErrMACMismatch = errors.New("MAC mismatch")
+
 // DefaultMessageAuthenticator is an HMAC based MessageAuthenticator
 type DefaultMessageAuthenticator struct {
-	hashFn    func() hash.Hash
-	key       []byte
-	headerLen int
+	hashFn         func() hash.Hash
+	key            []byte
+	headerLen      int
+	maxMessageSize int
+	tagSize        int
+	encoding       MACEncoding
+	aad            []byte
+
+	// guards key and destroyed, such that Destroy
+	// waits for any operations in progress
+	lock      sync.RWMutex
+	destroyed bool
 }
 
-// ensure MessageAuthenticator implements MessageAuthenticator at compile-time
-var _ MessageAuthenticator = (*DefaultMessageAuthenticator)(nil)
+// ensure DefaultMessageAuthenticator implements AADMessageAuthenticator, CloseNotifier, ControlFramer, and ExtensionFramer at compile-time
+var (
+	_ AADMessageAuthenticator = (*DefaultMessageAuthenticator)(nil)
+	_ CloseNotifier           = (*DefaultMessageAuthenticator)(nil)
+	_ ControlFramer           = (*DefaultMessageAuthenticator)(nil)
+	_ ExtensionFramer         = (*DefaultMessageAuthenticator)(nil)
+)
 
 const (
 	// the message length is transmitted as a binary
@@ -27,13 +48,15 @@ const (
 	lengthHeaderFieldSize = 8
 )
 
-// NewDefaultMessageAuthenticator returns a newly initialized DefaultMessageAuthenticator
+// NewDefaultMessageAuthenticator returns a newly initialized DefaultMessageAuthenticator.
+// The key is copied, such that Destroy does not modify the caller's key.
 func NewDefaultMessageAuthenticator(hashFn func() hash.Hash, key []byte) *DefaultMessageAuthenticator {
 	return &DefaultMessageAuthenticator{
-		hashFn: hashFn,
-		key:    key,
+		hashFn:   hashFn,
+		key:      append([]byte{}, key...),
+		encoding: StdBase64,
 
-		// header length changes only if the hashFn changes
+		// header length changes only if the hashFn, tag size, or encoding changes
 		headerLen: computeHeaderLengthWithHash(hashFn),
 	}
 }
@@ -41,10 +64,69 @@ func NewDefaultMessageAuthenticator(hashFn func() hash.Hash, key []byte) *Defaul
 // WithHashFn modifies the hash function and hash length on a DefaultMessageAuthenticator and returns it
 func (a *DefaultMessageAuthenticator) WithHashFn(hashFn func() hash.Hash) *DefaultMessageAuthenticator {
 	a.hashFn = hashFn
-	a.headerLen = computeHeaderLengthWithHash(hashFn)
+	a.tagSize = 0
+	a.headerLen = computeHeaderLengthWithEncoding(hashFn().Size(), a.encoding)
+	return a
+}
+
+// WithTagSize truncates MACs (before encoding) on a DefaultMessageAuthenticator to
+// the given size in bytes and returns it. Zero, or a size no smaller than that of
+// the hash function, means no truncation. Note that RFC 2104 recommends tags of
+// no less than half the size of the hash function, and no less than 80 bits.
+func (a *DefaultMessageAuthenticator) WithTagSize(size int) *DefaultMessageAuthenticator {
+	if size >= a.hashFn().Size() {
+		size = 0
+	}
+	a.tagSize = size
+	a.headerLen = computeHeaderLengthWithEncoding(a.macSize(), a.encoding)
+	return a
+}
+
+// WithMACEncoding sets the text encoding of MACs (default StdBase64)
+// on a DefaultMessageAuthenticator and returns it
+func (a *DefaultMessageAuthenticator) WithMACEncoding(encoding MACEncoding) *DefaultMessageAuthenticator {
+	a.encoding = encoding
+	a.headerLen = computeHeaderLengthWithEncoding(a.macSize(), encoding)
+	return a
+}
+
+// macSize returns the size of MACs before encoding
+func (a *DefaultMessageAuthenticator) macSize() int {
+	if a.tagSize > 0 {
+		return a.tagSize
+	}
+	return a.hashFn().Size()
+}
+
+// WithMaxMessageSize sets the maximum size (in bytes, excluding the header) of messages
+// accepted by ReadNext on a DefaultMessageAuthenticator and returns it. Zero means no limit.
+func (a *DefaultMessageAuthenticator) WithMaxMessageSize(size int) *DefaultMessageAuthenticator {
+	a.maxMessageSize = size
+	return a
+}
+
+// WithAssociatedData sets the associated data bound into every MAC computed or
+// verified by GetMessageAuthenticationHeader, ReadNext, and AuthenticateMessages
+// on a DefaultMessageAuthenticator and returns it
+func (a *DefaultMessageAuthenticator) WithAssociatedData(aad []byte) *DefaultMessageAuthenticator {
+	a.aad = aad
 	return a
 }
 
+// Destroy overwrites the DefaultMessageAuthenticator's copy of the key with
+// zeros, after which all of its operations fail with ErrDestroyed. It waits
+// for operations in progress (e.g. a ReadNext blocked on its reader) to return.
+func (a *DefaultMessageAuthenticator) Destroy() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for i := range a.key {
+		a.key[i] = 0
+	}
+	a.key = nil
+	a.destroyed = true
+}
+
 // GetMessageAuthenticationHeaderLength returns the length
 // (in bytes) of headers produced by the MessageAuthenticator
 func (a *DefaultMessageAuthenticator) GetMessageAuthenticationHeaderLength() int {
@@ -53,153 +135,308 @@ func (a *DefaultMessageAuthenticator) GetMessageAuthenticationHeaderLength() int
 
 // GetMessageAuthenticationHeader returns a header produced for the given data
 func (a *DefaultMessageAuthenticator) GetMessageAuthenticationHeader(data []byte) ([]byte, error) {
-	return encodeHeader(a.hashFn, a.headerLen, a.key, data)
+	return a.GetMessageAuthenticationHeaderWithAAD(data, a.aad)
 }
 
-// AuthenticateMessages processes one or more messages (each with a header) in a given byte slice.
-// It returns the successfully processed raw messages successfully and the number of messages processed.
+// GetMessageAuthenticationHeaderWithAAD returns a header produced for the given
+// data, with the MAC also covering the given associated data (which is not sent)
+func (a *DefaultMessageAuthenticator) GetMessageAuthenticationHeaderWithAAD(data []byte, aad []byte) ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+	return encodeHeader(a.hashFn, a.tagSize, a.encoding, a.headerLen, a.key, aad, data)
+}
+
+// GetControlFrameHeader returns a header produced for the given control frame payload
+func (a *DefaultMessageAuthenticator) GetControlFrameHeader(payload []byte) ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+	return encodeFrameHeader(a.hashFn, a.tagSize, a.encoding, a.headerLen, a.key, a.aad, payload, controlFrameFlag)
+}
+
+// GetMessageAuthenticationHeaderWithExtensions returns a header produced for
+// the given data followed by an extension area with the given extensions
+func (a *DefaultMessageAuthenticator) GetMessageAuthenticationHeaderWithExtensions(data []byte, extensions []Extension) ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+	area, err := encodeExtensions(extensions)
+	if err != nil {
+		return nil, err
+	}
+	header, err := encodeFrameHeader(a.hashFn, a.tagSize, a.encoding, a.headerLen, a.key, a.aad, append(area, data...), extensionsFlag)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, area...), nil
+}
+
+// GetCloseNotifyHeader returns a close notification, which is a
+// header with a zero length (and so without a message). Since the
+// length of every other frame includes its header, the two can
+// never be confused.
+func (a *DefaultMessageAuthenticator) GetCloseNotifyHeader() ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+	rawSize := make([]byte, lengthHeaderFieldSize)
+	sum, err := computeMAC(a.hashFn, a.tagSize, a.encoding, a.key, a.aad, rawSize, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(sum), rawSize...), nil
+}
+
+// AuthenticateMessages processes one or more frames in a given byte slice, verifying
+// them exactly as ReadNext does (e.g. stripping extension areas, and enforcing the
+// max message size). It returns the messages processed successfully and the number
+// of messages processed. Control frames are skipped, and a close notification must
+// be the last frame.
 func (a *DefaultMessageAuthenticator) AuthenticateMessages(data []byte) ([]byte, int, error) {
+	a.lock.RLock()
+	destroyed := a.destroyed
+	a.lock.RUnlock()
+	if destroyed {
+		return nil, 0, ErrDestroyed
+	}
+
 	processed := []byte{}
-	notProcessed := data
+	reader := bytes.NewReader(data)
 	nMessages := 0
 
-	for len(notProcessed) > 0 {
-		message, leftOver, err := decodeHeader(a.hashFn, a.headerLen, a.key, notProcessed)
+	for reader.Len() > 0 {
+		msg, _, control, err := a.readFrame(reader, a.aad)
+		if errors.Is(err, ErrCloseNotify) {
+			if reader.Len() > 0 {
+				return processed, nMessages, fmt.Errorf("%d bytes after close notification", reader.Len())
+			}
+			break
+		}
 		if err != nil {
-			return processed, nMessages, fmt.Errorf("failed decoding header: %s", err)
+			return processed, nMessages, fmt.Errorf("failed decoding header: %w", err)
+		}
+		if control {
+			continue
 		}
-		processed = append(processed, message...)
-		notProcessed = leftOver
+		processed = append(processed, msg...)
 		nMessages++
 	}
-
 	return processed, nMessages, nil
 }
 
 // ReadNext reads and verifies HMAC on a single messages
 func (a *DefaultMessageAuthenticator) ReadNext(r io.Reader) ([]byte, error) {
+	return a.ReadNextWithAAD(r, a.aad)
+}
+
+// ReadNextWithAAD reads and verifies HMAC on a single message, with
+// the MAC expected to also cover the given associated data. It returns
+// ErrCloseNotify upon reading a valid close notification.
+func (a *DefaultMessageAuthenticator) ReadNextWithAAD(r io.Reader, aad []byte) ([]byte, error) {
+	msg, _, control, err := a.readFrame(r, aad)
+	if err != nil {
+		return nil, err
+	}
+	if control {
+		return nil, fmt.Errorf("unexpected control frame")
+	}
+	return msg, nil
+}
+
+// ReadNextFrame reads and verifies HMAC on a single frame, which
+// is either a message or (if control is true) a control frame
+func (a *DefaultMessageAuthenticator) ReadNextFrame(r io.Reader) ([]byte, bool, error) {
+	msg, _, control, err := a.readFrame(r, a.aad)
+	return msg, control, err
+}
+
+// ReadNextFrameWithExtensions reads and verifies HMAC on a single frame, which
+// is either a message or (if control is true) a control frame, and returns the
+// extensions in it (if any)
+func (a *DefaultMessageAuthenticator) ReadNextFrameWithExtensions(r io.Reader) ([]byte, []Extension, bool, error) {
+	return a.readFrame(r, a.aad)
+}
+
+func (a *DefaultMessageAuthenticator) readFrame(r io.Reader, aad []byte) ([]byte, []Extension, bool, error) {
+	msg, info, err := a.readRawFrame(r, aad)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !info.Extensions {
+		return msg, nil, info.Control, nil
+	}
+	extensions, msg, err := decodeExtensions(msg)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("invalid extension area: %w", err)
+	}
+	return msg, extensions, info.Control, nil
+}
+
+// readRawFrame reads and verifies a single frame, returning its
+// whole payload (i.e. including any extension area)
+func (a *DefaultMessageAuthenticator) readRawFrame(r io.Reader, aad []byte) ([]byte, FrameInfo, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, FrameInfo{}, ErrDestroyed
+	}
 	header := make([]byte, a.headerLen)
 
 	// read header
 	if _, err := io.ReadFull(r, header); err != nil {
 		if errors.Is(err, io.EOF) {
-			return nil, io.EOF
+			return nil, FrameInfo{}, io.EOF
 		}
 		if errors.Is(err, io.ErrUnexpectedEOF) {
-			return nil, fmt.Errorf("read data too short to have valid header")
+			return nil, FrameInfo{}, fmt.Errorf("%w: read data too short to have valid header", ErrTruncatedMessage)
 		}
-		return nil, fmt.Errorf("failed to read message header: %s", err)
+		return nil, FrameInfo{}, fmt.Errorf("failed to read message header: %w", err)
 	}
 
-	mac := header[:a.headerLen-lengthHeaderFieldSize]
-	rawSize := header[a.headerLen-lengthHeaderFieldSize:]
-	size := binary.BigEndian.Uint64(rawSize)
-
-	msg := make([]byte, size-uint64(a.headerLen)) // we already read the header
-	// read msg
-	if _, err := io.ReadFull(r, msg); err != nil {
-		if errors.Is(err, io.EOF) {
-			return nil, io.EOF
+	info, err := ParseFrameHeader(header)
+	if err != nil {
+		return nil, FrameInfo{}, err
+	}
+	if info.CloseNotify {
+		sum, err := computeMAC(a.hashFn, a.tagSize, a.encoding, a.key, aad, header[a.headerLen-lengthHeaderFieldSize:], nil)
+		if err != nil {
+			return nil, FrameInfo{}, err
 		}
-		if errors.Is(err, io.ErrUnexpectedEOF) {
-			return nil, fmt.Errorf("read message too short, does not match message size from header")
+		if !hmac.Equal(info.MAC, []byte(sum)) {
+			return nil, FrameInfo{}, fmt.Errorf("%w on close notification", ErrMACMismatch)
 		}
-		return nil, fmt.Errorf("failed to read message: %s", err)
+		return nil, FrameInfo{}, ErrCloseNotify
+	}
+	if a.maxMessageSize > 0 && info.PayloadLength > uint64(a.maxMessageSize) {
+		return nil, FrameInfo{}, &MessageTooLargeError{Size: info.PayloadLength, Max: a.maxMessageSize, Remaining: info.PayloadLength}
 	}
 
-	// compute mac for message
-	computed := hmac.New(a.hashFn, a.key)
-	if _, err := computed.Write(append(rawSize, msg...)); err != nil {
-		// note: hash.Write() never returns an error as per godoc
-		// (https://pkg.go.dev/hash#Hash) but we check it regardless
-		return nil, err
+	mac := info.MAC
+	rawSize := header[a.headerLen-lengthHeaderFieldSize:]
+
+	// read msg (we already read the header)
+	msg, err := readPayload(r, info.PayloadLength)
+	if err != nil {
+		// the header was read, so the frame is truncated even if none of the message was
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, FrameInfo{}, fmt.Errorf("%w: read message too short, does not match message size from header", ErrTruncatedMessage)
+		}
+		return nil, FrameInfo{}, fmt.Errorf("failed to read message: %w", err)
 	}
 
-	// received MAC is base64 to avoid special character (e.g. '\n') bytes in hash
-	sum := base64.StdEncoding.EncodeToString(computed.Sum(nil))
+	sum, err := computeMAC(a.hashFn, a.tagSize, a.encoding, a.key, aad, rawSize, msg)
+	if err != nil {
+		return nil, FrameInfo{}, err
+	}
 
-	// compare received vs computed MAC
-	if string(mac) != sum {
-		return nil, fmt.Errorf("MAC mismatch: is %s - need %s", sum, mac)
+	// compare received vs computed MAC in constant time, and never
+	// reveal the computed MAC (i.e. a valid MAC for the message)
+	if !hmac.Equal(mac, []byte(sum)) {
+		return nil, FrameInfo{}, ErrMACMismatch
 	}
 
-	return msg, nil
+	return msg, info, nil
 }
 
 func computeHeaderLengthWithHash(hashFn func() hash.Hash) int {
+	return computeHeaderLength(hashFn().Size())
+}
+
+func computeHeaderLengthWithEncoding(macSize int, encoding MACEncoding) int {
+	return lengthHeaderFieldSize + encoding.EncodedLen(macSize)
+}
+
+func computeHeaderLength(tagSize int) int {
 	// MACs are base64 encoded hashes produced by h(). In b64, each
 	// character is used to represent 6 bits (log2(64) = 6), So 4
 	// chars are used to represent 4 * 6 = 24 bits = 3 bytes. So we
 	// need 4*(n/3) chars to represent n bytes. This result is also
 	// rounded up to the nearest multiple of 4.
-	macSize := int(math.Ceil(float64(hashFn().Size())/3) * 4)
+	macSize := int(math.Ceil(float64(tagSize)/3) * 4)
 	return lengthHeaderFieldSize + macSize
 }
 
-func encodeHeader(
+// computeMAC returns the encoded (see MACEncoding) MAC of a message and its encoded
+// length, truncated to the given tag size (if non-zero) before encoding.
+// Associated data (if any) is covered by the MAC too, prefixed with its
+// length. Since a frame's length always exceeds that of the associated
+// data, MAC inputs with and without associated data never collide.
+func computeMAC(
 	hashFn func() hash.Hash,
-	headerLen int,
+	tagSize int,
+	encoding MACEncoding,
 	key []byte,
-	data []byte,
-) ([]byte, error) {
-	// binary encode message length -- taking into acount header and data.
-	encodedMessageLength := make([]byte, lengthHeaderFieldSize)
-	binary.BigEndian.PutUint64(encodedMessageLength, uint64(headerLen+len(data)))
+	aad []byte,
+	rawSize []byte,
+	msg []byte,
+) (string, error) {
+	input := []byte{}
+	if len(aad) > 0 {
+		input = binary.BigEndian.AppendUint64(input, uint64(len(aad)))
+		input = append(input, aad...)
+	}
+	input = append(append(input, rawSize...), msg...)
 
-	// compute HMAC for message
-	computed := hmac.New(hashFn, key)
-	if _, err := computed.Write(append(encodedMessageLength, data...)); err != nil {
+	// a nil key means a plain (unkeyed) checksum (see NewChecksumAuthenticator)
+	computed := hashFn()
+	if key != nil {
+		computed = hmac.New(hashFn, key)
+	}
+	if _, err := computed.Write(input); err != nil {
 		// note: hash.Write() never returns an error as per godoc
 		// (https://pkg.go.dev/hash#Hash) but we check it regardless
-		return nil, err
+		return "", err
+	}
+	sum := computed.Sum(nil)
+	if tagSize > 0 && tagSize < len(sum) {
+		sum = sum[:tagSize]
 	}
-	// base64 to avoid special character (e.g. '\n') bytes in hash, without
-	// this, certain functions i.e. bufio(authedReader).ReadString('\n')
+	// base64 (by default) to avoid special character (e.g. '\n') bytes in hash,
+	// without this, certain functions i.e. bufio(authedReader).ReadString('\n')
 	// will stop reading at the special character and cause reading to fail.
-	sum := base64.StdEncoding.EncodeToString(computed.Sum(nil))
-
-	// return all header bytes appended
-	return append([]byte(sum), encodedMessageLength...), nil
+	return encoding.EncodeToString(sum), nil
 }
 
-func decodeHeader(
+func encodeHeader(
 	hashFn func() hash.Hash,
+	tagSize int,
+	encoding MACEncoding,
 	headerLen int,
 	key []byte,
+	aad []byte,
 	data []byte,
-) ([]byte, []byte, error) {
-	actualDataLen := len(data)
-	if actualDataLen < headerLen {
-		return nil, data, fmt.Errorf("data too small to have header, got %d and expected at least %d", actualDataLen, headerLen)
-	}
-
-	header := data[:headerLen]
-	mac := header[:headerLen-lengthHeaderFieldSize]
-	rawSize := header[headerLen-lengthHeaderFieldSize:]
-
-	size := binary.BigEndian.Uint64(rawSize)
-	if uint64(actualDataLen) < size {
-		return nil, data, fmt.Errorf("data smaller than message length reported in header, got %d and expected at least %d", actualDataLen, size)
-	}
-
-	msg := data[headerLen:size] // message starts after header and ends after 'size' bytes
-	rest := data[size:]         // rest is everything after 'size' bytes
-
-	// compute mac for message
-	computed := hmac.New(hashFn, key)
-	if _, err := computed.Write(append(rawSize, msg...)); err != nil {
-		// note: hash.Write() never returns an error as per godoc
-		// (https://pkg.go.dev/hash#Hash) but we check it regardless
-		return nil, data, err
-	}
+) ([]byte, error) {
+	return encodeFrameHeader(hashFn, tagSize, encoding, headerLen, key, aad, data, 0)
+}
 
-	// received MAC is base64 to avoid special character (e.g. '\n') bytes in hash
-	sum := base64.StdEncoding.EncodeToString(computed.Sum(nil))
+func encodeFrameHeader(
+	hashFn func() hash.Hash,
+	tagSize int,
+	encoding MACEncoding,
+	headerLen int,
+	key []byte,
+	aad []byte,
+	data []byte,
+	flags uint64,
+) ([]byte, error) {
+	// binary encode message length -- taking into acount header and data.
+	encodedMessageLength := make([]byte, lengthHeaderFieldSize)
+	binary.BigEndian.PutUint64(encodedMessageLength, uint64(headerLen+len(data))|flags)
 
-	// compare received vs computed MAC
-	if string(mac) != sum {
-		return nil, data, fmt.Errorf("MAC mismatch: is %s - need %s", sum, mac)
+	sum, err := computeMAC(hashFn, tagSize, encoding, key, aad, encodedMessageLength, data)
+	if err != nil {
+		return nil, err
 	}
 
-	return msg, rest, nil
+	// return all header bytes appended
+	return append([]byte(sum), encodedMessageLength...), nil
 }
@@ -1,10 +1,12 @@
 package authenticator
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/binary"
+	"errors"
 	"hash"
 	"testing"
 
@@ -107,7 +109,7 @@ func Test_encodeHeader(t *testing.T) {
 		headerLen := computeHeaderLengthWithHash(test.hashFn)
 
 		t.Run(test.name, func(t *testing.T) {
-			header, err := encodeHeader(test.hashFn, headerLen, test.key, test.data)
+			header, err := encodeHeader(test.hashFn, 0, StdBase64, headerLen, test.key, nil, test.data)
 			assert.NoError(t, err)
 			// FIXME: not checking actual hash, just length
 			assert.Equal(t, uint64(headerLen+len(test.data)), binary.BigEndian.Uint64(header[headerLen-lengthHeaderFieldSize:]))
@@ -170,6 +172,91 @@ func Test_AuthenticateMessages(t *testing.T) {
 	}
 }
 
+func Test_AuthenticateMessagesMatchesReadNext(t *testing.T) {
+	mockKey := []byte("mock key")
+	a := NewDefaultMessageAuthenticator(sha256.New, mockKey).WithMaxMessageSize(64)
+
+	extended, err := a.GetMessageAuthenticationHeaderWithExtensions([]byte("extended"), []Extension{KeyIDExtension("key-1"), PaddingExtension(4)})
+	assert.NoError(t, err)
+	control, err := a.GetControlFrameHeader([]byte("ping"))
+	assert.NoError(t, err)
+	plain, err := a.GetMessageAuthenticationHeader([]byte("plain"))
+	assert.NoError(t, err)
+	closeNotify, err := a.GetCloseNotifyHeader()
+	assert.NoError(t, err)
+	tooLarge := bytes.Repeat([]byte("x"), 65)
+	tooLargeHeader, err := a.GetMessageAuthenticationHeader(tooLarge)
+	assert.NoError(t, err)
+
+	stream := append(append([]byte{}, extended...), "extended"...)
+	stream = append(append(stream, control...), "ping"...)
+	stream = append(append(stream, plain...), "plain"...)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		expectedMsg   string
+		expectedCount int
+		expectError   bool
+	}{
+		{
+			name:          "Extensions and control frame",
+			data:          stream,
+			expectedMsg:   "extendedplain",
+			expectedCount: 2,
+		},
+		{
+			name:          "Close notification",
+			data:          append(append([]byte{}, stream...), closeNotify...),
+			expectedMsg:   "extendedplain",
+			expectedCount: 2,
+		},
+		{
+			name:        "Data after close notification",
+			data:        append(append(append([]byte{}, stream...), closeNotify...), stream...),
+			expectError: true,
+		},
+		{
+			name:        "Message larger than the max message size",
+			data:        append(tooLargeHeader, tooLarge...),
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			processed, n, err := a.AuthenticateMessages(test.data)
+			if test.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedMsg, string(processed))
+				assert.Equal(t, test.expectedCount, n)
+			}
+
+			// reading frame by frame yields the same messages
+			read, count := []byte{}, 0
+			reader := bytes.NewReader(test.data)
+			for reader.Len() > 0 {
+				msg, _, control, err := a.ReadNextFrameWithExtensions(reader)
+				if errors.Is(err, ErrCloseNotify) {
+					break
+				}
+				if err != nil {
+					assert.True(t, test.expectError, "unexpected error %v", err)
+					return
+				}
+				if !control {
+					read, count = append(read, msg...), count+1
+				}
+			}
+			if !test.expectError {
+				assert.Equal(t, test.expectedMsg, string(read))
+				assert.Equal(t, test.expectedCount, count)
+			}
+		})
+	}
+}
+
 func Test_GetMessageAuthenticationHeader(t *testing.T) {
 	mockKey := []byte("mock key")
 	mockRawMsg := []byte("mock data")
@@ -219,3 +306,29 @@ func Test_GetMessageAuthenticationHeader(t *testing.T) {
 		})
 	}
 }
+
+func Test_MACMismatchError(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name          string
+		authenticator MessageAuthenticator
+	}{
+		{name: "Default", authenticator: NewDefaultMessageAuthenticator(sha256.New, mockKey)},
+		{name: "CBOR", authenticator: NewCBORMessageAuthenticator(sha256.New, mockKey)},
+		{name: "Codec", authenticator: NewCodecMessageAuthenticator(NewDefaultFrameCodec(Hex.(MACDecoder)), sha256.New, mockKey)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header, err := test.authenticator.GetMessageAuthenticationHeader([]byte("mock data"))
+			assert.NoError(t, err)
+			tampered := append(header, []byte("mock datA")...)
+
+			// the error must not reveal the computed MAC, which
+			// would be a valid MAC for the tampered message
+			_, err = test.authenticator.ReadNext(bytes.NewReader(tampered))
+			assert.True(t, errors.Is(err, ErrMACMismatch))
+			assert.Equal(t, ErrMACMismatch.Error(), err.Error())
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+// lengthHeader returns a header of the default format with the given length
+// field (and a bogus MAC), since lengths are checked before MACs
+func lengthHeader(length uint64) []byte {
+	header := bytes.Repeat([]byte("A"), HeaderLength(sha256.New)-lengthHeaderFieldSize)
+	return binary.BigEndian.AppendUint64(header, length)
+}
+
+func Test_MaliciousLengths(t *testing.T) {
+	mockKey := []byte("mock key")
+	headerLen := uint64(HeaderLength(sha256.New))
+
+	tests := []struct {
+		name        string
+		data        []byte
+		expectedErr error
+	}{
+		{
+			name:        "Length smaller than header",
+			data:        lengthHeader(headerLen - 1),
+			expectedErr: ErrInvalidLength,
+		},
+		{
+			name:        "Length of one",
+			data:        lengthHeader(1),
+			expectedErr: ErrInvalidLength,
+		},
+		{
+			name:        "Length larger than the max frame length",
+			data:        lengthHeader(maxFrameLength + 1),
+			expectedErr: ErrInvalidLength,
+		},
+		{
+			name:        "Largest length without flags",
+			data:        lengthHeader(extensionsFlag - 1),
+			expectedErr: ErrInvalidLength,
+		},
+		{
+			name:        "Largest length",
+			data:        lengthHeader(math.MaxUint64),
+			expectedErr: ErrInvalidLength,
+		},
+		{
+			name: "Length larger than the data",
+			data: append(lengthHeader(headerLen+maxFrameLength/2), "short"...),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// without a max message size, only the length checks stand
+			// between crafted lengths and panics (or huge allocations)
+			a := NewDefaultMessageAuthenticator(sha256.New, mockKey)
+
+			_, err := a.ReadNext(bytes.NewReader(test.data))
+			assert.Error(t, err)
+			assert.False(t, errors.Is(err, io.EOF))
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+			}
+
+			_, _, _, err = a.ReadNextFrameWithExtensions(bytes.NewReader(test.data))
+			assert.Error(t, err)
+
+			// AuthenticateMessages fails the same way
+			_, _, err = a.AuthenticateMessages(test.data)
+			expectedErr := test.expectedErr
+			if expectedErr == nil {
+				expectedErr = ErrTruncatedMessage
+			}
+			assert.True(t, errors.Is(err, expectedErr), "expected %v, got %v", expectedErr, err)
+		})
+	}
+}
+
+func Test_MaliciousCBORLengths(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name        string
+		length      uint64
+		expectedErr error
+	}{
+		{name: "Length larger than the max frame length", length: maxFrameLength + 1, expectedErr: ErrInvalidLength},
+		{name: "Largest length", length: math.MaxUint64, expectedErr: ErrInvalidLength},
+		{name: "Length larger than the data", length: maxFrameLength / 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := NewCBORMessageAuthenticator(sha256.New, mockKey)
+			fields := a.encodeFields(cborFrameData, test.length, 0, time.Unix(0, 0))
+			frame := binary.BigEndian.AppendUint16(nil, uint16(len(fields)))
+			frame = append(frame, fields...)
+			frame = append(frame, make([]byte, a.macSize())...)
+			frame = append(frame, "short"...)
+
+			_, err := a.ReadNext(bytes.NewReader(frame))
+			assert.Error(t, err)
+			assert.False(t, errors.Is(err, io.EOF))
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr), "expected %v, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func Test_MaliciousExtensionLengths(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{name: "Area longer than payload", payload: []byte{0xff, 0xff, 0, 1}},
+		{name: "Truncated extension header", payload: []byte{0, 2, 0, 1}},
+		{name: "Value longer than area", payload: []byte{0, 4, 0, 1, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := decodeExtensions(test.payload)
+			assert.True(t, errors.Is(err, ErrInvalidLength), "expected %v, got %v", ErrInvalidLength, err)
+		})
+	}
+}
+
+func Test_readPayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		length      uint64
+		expectedErr error
+	}{
+		{name: "Small payload", data: []byte("payload"), length: 7},
+		{name: "Large payload", data: bytes.Repeat([]byte("x"), 3*payloadChunkSize+1), length: 3*payloadChunkSize + 1},
+		{name: "Empty payload", data: nil, length: 0},
+		{name: "No data", data: nil, length: 2 * payloadChunkSize, expectedErr: io.EOF},
+		{name: "Short data", data: []byte("short"), length: 2 * payloadChunkSize, expectedErr: io.ErrUnexpectedEOF},
+		{name: "Short data for small payload", data: []byte("short"), length: 7, expectedErr: io.ErrUnexpectedEOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := readPayload(bytes.NewReader(test.data), test.length)
+			if test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, len(test.data), len(payload))
+		})
+	}
+}
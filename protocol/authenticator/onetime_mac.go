@@ -0,0 +1,415 @@
+package authenticator
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/poly1305"
+)
+
+// NonceSize is the size (in bytes) of the nonces of OneTimeMACAuthenticators
+const NonceSize = chacha20.NonceSize
+
+// oneTimeMACKeyLabel is the label of the derivation of the key
+// one-time MAC keys are derived from (see NewOneTimeMACAuthenticator)
+const oneTimeMACKeyLabel = "authio one-time MAC key"
+
+// ErrNoncesExhausted is returned (wrapped) by a NonceSource which
+// cannot produce another nonce without repeating one
+var ErrNoncesExhausted = errors.New("nonces exhausted")
+
+// NonceSource produces the (NonceSize byte) nonces of a OneTimeMACAuthenticator.
+// Nonces must never repeat for the same key: anyone who sees two frames with
+// the same nonce can forge frames. NonceSources must be safe for concurrent use.
+type NonceSource interface {
+	Nonce() ([]byte, error)
+}
+
+// counterNonceSource is a NonceSource of a random prefix and a counter
+type counterNonceSource struct {
+	prefix  [NonceSize - 8]byte
+	counter uint64 // accessed atomically
+}
+
+// NewCounterNonceSource returns a NonceSource of nonces made of a random 32 bit
+// prefix followed by a 64 bit counter, such that nonces of the same source never
+// repeat. The prefix only tells apart sources sharing a key: with more than a
+// few thousand of them, use NewRandomNonceSource instead.
+func NewCounterNonceSource() (NonceSource, error) {
+	s := &counterNonceSource{}
+	if _, err := io.ReadFull(rand.Reader, s.prefix[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+	return s, nil
+}
+
+// Nonce returns the next nonce
+func (s *counterNonceSource) Nonce() ([]byte, error) {
+	counter := atomic.AddUint64(&s.counter, 1)
+	if counter == math.MaxUint64 {
+		// stay exhausted, rather than wrapping around
+		atomic.StoreUint64(&s.counter, math.MaxUint64-1)
+		return nil, fmt.Errorf("%w: counter wrapped around", ErrNoncesExhausted)
+	}
+	return binary.BigEndian.AppendUint64(append([]byte(nil), s.prefix[:]...), counter), nil
+}
+
+// randomNonceSource is a NonceSource of random nonces
+type randomNonceSource struct {
+	rand io.Reader
+	lock sync.Mutex // guards rand, which need not be safe for concurrent use
+}
+
+// NewRandomNonceSource returns a NonceSource of random nonces read from the
+// given reader (or crypto/rand if nil). Random nonces need no coordination
+// between sources sharing a key, but repeat with a probability which is no
+// longer negligible after 2^32 nonces: keys must be rotated before then.
+func NewRandomNonceSource(random io.Reader) NonceSource {
+	if random == nil {
+		random = rand.Reader
+	}
+	return &randomNonceSource{rand: random}
+}
+
+// Nonce returns a new random nonce
+func (s *randomNonceSource) Nonce() ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(s.rand, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// MACDecoder is a MACEncoding which can also decode MACs. It is required by
+// authenticators which carry more than a MAC in the MAC field of headers (see
+// OneTimeMACAuthenticator). All the built-in MACEncodings implement it.
+type MACDecoder interface {
+	MACEncoding
+	// DecodeString returns the bytes of the given encoding
+	DecodeString(s string) ([]byte, error)
+}
+
+// OneTimeMACAuthenticator is a Poly1305 based MessageAuthenticator. Poly1305
+// is a one-time MAC: each of its keys must authenticate a single message. So
+// every frame carries a nonce (from a NonceSource) from which its one-time key
+// is derived (as with ChaCha20-Poly1305, i.e. the first ChaCha20 block of the
+// nonce, as per RFC 8439), followed by the tag, in the MAC field of headers.
+// The framing is otherwise that of a DefaultMessageAuthenticator, but the two
+// are not compatible, so both peers must opt in.
+type OneTimeMACAuthenticator struct {
+	key            []byte // derived from the caller's key, see NewOneTimeMACAuthenticator
+	nonces         NonceSource
+	encoding       MACDecoder
+	headerLen      int
+	maxMessageSize int
+	aad            []byte
+
+	// guards key and destroyed, such that Destroy
+	// waits for any operations in progress
+	lock      sync.RWMutex
+	destroyed bool
+}
+
+// ensure OneTimeMACAuthenticator implements CloseNotifier, ControlFramer, and ExtensionFramer at compile-time
+var (
+	_ CloseNotifier   = (*OneTimeMACAuthenticator)(nil)
+	_ ControlFramer   = (*OneTimeMACAuthenticator)(nil)
+	_ ExtensionFramer = (*OneTimeMACAuthenticator)(nil)
+)
+
+// NewOneTimeMACAuthenticator returns a new OneTimeMACAuthenticator producing
+// nonces with the given NonceSource. Since ChaCha20 keys are 32 bytes, keys of
+// any length are used to derive the (HMAC-SHA256) key of the authenticator.
+func NewOneTimeMACAuthenticator(key []byte, nonces NonceSource) *OneTimeMACAuthenticator {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(oneTimeMACKeyLabel))
+	return &OneTimeMACAuthenticator{
+		key:       mac.Sum(nil),
+		nonces:    nonces,
+		encoding:  StdBase64.(MACDecoder),
+		headerLen: computeHeaderLengthWithEncoding(NonceSize+poly1305.TagSize, StdBase64),
+	}
+}
+
+// WithMACEncoding sets the text encoding of nonces and tags (default StdBase64)
+// on a OneTimeMACAuthenticator and returns it
+func (a *OneTimeMACAuthenticator) WithMACEncoding(encoding MACDecoder) *OneTimeMACAuthenticator {
+	a.encoding = encoding
+	a.headerLen = computeHeaderLengthWithEncoding(NonceSize+poly1305.TagSize, encoding)
+	return a
+}
+
+// WithMaxMessageSize sets the maximum size (in bytes, excluding the header) of messages
+// accepted by ReadNext on a OneTimeMACAuthenticator and returns it. Zero means no limit.
+func (a *OneTimeMACAuthenticator) WithMaxMessageSize(size int) *OneTimeMACAuthenticator {
+	a.maxMessageSize = size
+	return a
+}
+
+// WithAssociatedData sets the associated data bound into every MAC computed
+// or verified on a OneTimeMACAuthenticator and returns it
+func (a *OneTimeMACAuthenticator) WithAssociatedData(aad []byte) *OneTimeMACAuthenticator {
+	a.aad = aad
+	return a
+}
+
+// Destroy overwrites the OneTimeMACAuthenticator's key with zeros, after
+// which all of its operations fail with ErrDestroyed
+func (a *OneTimeMACAuthenticator) Destroy() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for i := range a.key {
+		a.key[i] = 0
+	}
+	a.key = nil
+	a.destroyed = true
+}
+
+// GetMessageAuthenticationHeaderLength returns the length
+// (in bytes) of headers produced by the MessageAuthenticator
+func (a *OneTimeMACAuthenticator) GetMessageAuthenticationHeaderLength() int {
+	return a.headerLen
+}
+
+// GetMessageAuthenticationHeader returns a header produced for the given data
+func (a *OneTimeMACAuthenticator) GetMessageAuthenticationHeader(data []byte) ([]byte, error) {
+	return a.frameHeader(data, 0)
+}
+
+// GetControlFrameHeader returns a header produced for the given control frame payload
+func (a *OneTimeMACAuthenticator) GetControlFrameHeader(payload []byte) ([]byte, error) {
+	return a.frameHeader(payload, controlFrameFlag)
+}
+
+// GetMessageAuthenticationHeaderWithExtensions returns a header produced for
+// the given data followed by an extension area with the given extensions
+func (a *OneTimeMACAuthenticator) GetMessageAuthenticationHeaderWithExtensions(data []byte, extensions []Extension) ([]byte, error) {
+	area, err := encodeExtensions(extensions)
+	if err != nil {
+		return nil, err
+	}
+	header, err := a.frameHeader(append(area, data...), extensionsFlag)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, area...), nil
+}
+
+// GetCloseNotifyHeader returns a close notification, i.e. a header with
+// a zero length (see DefaultMessageAuthenticator.GetCloseNotifyHeader)
+func (a *OneTimeMACAuthenticator) GetCloseNotifyHeader() ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+	rawSize := make([]byte, lengthHeaderFieldSize)
+	sum, err := a.sign(rawSize, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(sum, rawSize...), nil
+}
+
+// frameHeader returns the header of a frame with the given payload and flags
+func (a *OneTimeMACAuthenticator) frameHeader(payload []byte, flags uint64) ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+	rawSize := binary.BigEndian.AppendUint64(nil, uint64(a.headerLen+len(payload))|flags)
+	sum, err := a.sign(rawSize, payload)
+	if err != nil {
+		return nil, err
+	}
+	return append(sum, rawSize...), nil
+}
+
+// AuthenticateMessages processes one or more messages (each with a header) in a given byte slice.
+// It returns the successfully processed raw messages successfully and the number of messages processed.
+func (a *OneTimeMACAuthenticator) AuthenticateMessages(data []byte) ([]byte, int, error) {
+	processed := []byte{}
+	nMessages := 0
+
+	for len(data) > 0 {
+		if len(data) < a.headerLen {
+			return processed, nMessages, fmt.Errorf("failed decoding header: data too small to have header, got %d and expected at least %d", len(data), a.headerLen)
+		}
+		info, err := ParseFrameHeader(data[:a.headerLen])
+		if err != nil {
+			return processed, nMessages, fmt.Errorf("failed decoding header: %w", err)
+		}
+		if info.CloseNotify || info.Control || info.Extensions {
+			return processed, nMessages, fmt.Errorf("failed decoding header: unexpected frame type")
+		}
+		if uint64(len(data)) < info.Length {
+			return processed, nMessages, fmt.Errorf("failed decoding header: %w: data smaller than message length reported in header, got %d and expected at least %d", ErrInvalidLength, len(data), info.Length)
+		}
+		msg, err := a.verifyFrame(data[:a.headerLen], info, data[a.headerLen:info.Length])
+		if err != nil {
+			return processed, nMessages, fmt.Errorf("failed decoding header: %w", err)
+		}
+		processed = append(processed, msg...)
+		data = data[info.Length:]
+		nMessages++
+	}
+	return processed, nMessages, nil
+}
+
+// ReadNext reads and verifies a single message. It returns
+// ErrCloseNotify upon reading a valid close notification.
+func (a *OneTimeMACAuthenticator) ReadNext(r io.Reader) ([]byte, error) {
+	msg, _, control, err := a.ReadNextFrameWithExtensions(r)
+	if err != nil {
+		return nil, err
+	}
+	if control {
+		return nil, fmt.Errorf("unexpected control frame")
+	}
+	return msg, nil
+}
+
+// ReadNextFrame reads and verifies a single frame, which is
+// either a message or (if control is true) a control frame
+func (a *OneTimeMACAuthenticator) ReadNextFrame(r io.Reader) ([]byte, bool, error) {
+	msg, _, control, err := a.ReadNextFrameWithExtensions(r)
+	return msg, control, err
+}
+
+// ReadNextFrameWithExtensions reads and verifies a single frame, which is
+// either a message or (if control is true) a control frame, and returns the
+// extensions in it (if any)
+func (a *OneTimeMACAuthenticator) ReadNextFrameWithExtensions(r io.Reader) ([]byte, []Extension, bool, error) {
+	msg, info, err := a.readRawFrame(r)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !info.Extensions {
+		return msg, nil, info.Control, nil
+	}
+	extensions, msg, err := decodeExtensions(msg)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("invalid extension area: %w", err)
+	}
+	return msg, extensions, info.Control, nil
+}
+
+// readRawFrame reads and verifies a single frame, returning its
+// whole payload (i.e. including any extension area)
+func (a *OneTimeMACAuthenticator) readRawFrame(r io.Reader) ([]byte, FrameInfo, error) {
+	header := make([]byte, a.headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, FrameInfo{}, io.EOF
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, FrameInfo{}, fmt.Errorf("%w: read data too short to have valid header", ErrTruncatedMessage)
+		}
+		return nil, FrameInfo{}, fmt.Errorf("failed to read message header: %w", err)
+	}
+
+	info, err := ParseFrameHeader(header)
+	if err != nil {
+		return nil, FrameInfo{}, err
+	}
+	if info.CloseNotify {
+		if _, err := a.verifyFrame(header, info, nil); err != nil {
+			return nil, FrameInfo{}, fmt.Errorf("%w on close notification", err)
+		}
+		return nil, FrameInfo{}, ErrCloseNotify
+	}
+	if a.maxMessageSize > 0 && info.PayloadLength > uint64(a.maxMessageSize) {
+		return nil, FrameInfo{}, &MessageTooLargeError{Size: info.PayloadLength, Max: a.maxMessageSize, Remaining: info.PayloadLength}
+	}
+
+	msg, err := readPayload(r, info.PayloadLength)
+	if err != nil {
+		// the header was read, so the frame is truncated even if none of the message was
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, FrameInfo{}, fmt.Errorf("%w: read message too short, does not match message size from header", ErrTruncatedMessage)
+		}
+		return nil, FrameInfo{}, fmt.Errorf("failed to read message: %w", err)
+	}
+	if _, err := a.verifyFrame(header, info, msg); err != nil {
+		return nil, FrameInfo{}, err
+	}
+	return msg, info, nil
+}
+
+// verifyFrame verifies the MAC field of the given header over its length
+// field and the given payload, and returns the payload
+func (a *OneTimeMACAuthenticator) verifyFrame(header []byte, info FrameInfo, payload []byte) ([]byte, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.destroyed {
+		return nil, ErrDestroyed
+	}
+	field, err := a.encoding.DecodeString(string(info.MAC))
+	if err != nil || len(field) != NonceSize+poly1305.TagSize {
+		return nil, fmt.Errorf("%w: malformed nonce and tag", ErrMACMismatch)
+	}
+	nonce, tag := field[:NonceSize], field[NonceSize:]
+	expected, err := a.tag(nonce, header[a.headerLen-lengthHeaderFieldSize:], payload)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(tag, expected) != 1 {
+		return nil, ErrMACMismatch
+	}
+	return payload, nil
+}
+
+// sign returns the MAC field of a header (i.e. a new nonce and the tag of the
+// frame under the one-time key of the nonce), encoded. Callers hold the lock.
+func (a *OneTimeMACAuthenticator) sign(rawSize []byte, payload []byte) ([]byte, error) {
+	if a.nonces == nil {
+		return nil, errors.New("no nonce source")
+	}
+	nonce, err := a.nonces.Nonce()
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != NonceSize {
+		return nil, fmt.Errorf("invalid nonce size %d, expected %d", len(nonce), NonceSize)
+	}
+	tag, err := a.tag(nonce, rawSize, payload)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(a.encoding.EncodeToString(append(nonce, tag...))), nil
+}
+
+// tag returns the Poly1305 tag of a frame under the one-time key of the given
+// nonce. As with computeMAC, associated data (if any) is covered too.
+func (a *OneTimeMACAuthenticator) tag(nonce, rawSize, payload []byte) ([]byte, error) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(a.key, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive one-time key: %w", err)
+	}
+	var oneTimeKey [32]byte
+	cipher.XORKeyStream(oneTimeKey[:], oneTimeKey[:])
+	defer func() { oneTimeKey = [32]byte{} }()
+
+	mac := poly1305.New(&oneTimeKey)
+	if len(a.aad) > 0 {
+		mac.Write(binary.BigEndian.AppendUint64(nil, uint64(len(a.aad))))
+		mac.Write(a.aad)
+	}
+	mac.Write(rawSize)
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
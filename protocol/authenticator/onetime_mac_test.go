@@ -0,0 +1,168 @@
+package authenticator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+// fixedNonceSource is a NonceSource which (unsafely) repeats the same nonce
+type fixedNonceSource []byte
+
+func (s fixedNonceSource) Nonce() ([]byte, error) { return s, nil }
+
+func Test_OneTimeMACAuthenticator(t *testing.T) {
+	mockKey := []byte("mock key")
+	msg := []byte("mock message")
+	counter, err := NewCounterNonceSource()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		nonces NonceSource
+		modify func(a *OneTimeMACAuthenticator) *OneTimeMACAuthenticator
+	}{
+		{name: "Counter nonces", nonces: counter},
+		{name: "Random nonces", nonces: NewRandomNonceSource(nil)},
+		{name: "Hex encoding", nonces: counter, modify: func(a *OneTimeMACAuthenticator) *OneTimeMACAuthenticator {
+			return a.WithMACEncoding(Hex.(MACDecoder))
+		}},
+		{name: "Raw encoding", nonces: counter, modify: func(a *OneTimeMACAuthenticator) *OneTimeMACAuthenticator {
+			return a.WithMACEncoding(Raw.(MACDecoder))
+		}},
+		{name: "Associated data", nonces: counter, modify: func(a *OneTimeMACAuthenticator) *OneTimeMACAuthenticator {
+			return a.WithAssociatedData([]byte("mock aad"))
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newAuthenticator := func() *OneTimeMACAuthenticator {
+				a := NewOneTimeMACAuthenticator(mockKey, test.nonces)
+				if test.modify != nil {
+					a = test.modify(a)
+				}
+				return a
+			}
+			a := newAuthenticator()
+
+			// the same message is authenticated with a different nonce every time
+			first, err := a.GetMessageAuthenticationHeader(msg)
+			assert.NoError(t, err)
+			second, err := a.GetMessageAuthenticationHeader(msg)
+			assert.NoError(t, err)
+			assert.Equal(t, a.GetMessageAuthenticationHeaderLength(), len(first))
+			assert.NotEqual(t, first, second)
+
+			closeNotify, err := a.GetCloseNotifyHeader()
+			assert.NoError(t, err)
+			control, err := a.GetControlFrameHeader([]byte("ping"))
+			assert.NoError(t, err)
+			extended, err := a.GetMessageAuthenticationHeaderWithExtensions(msg, []Extension{SequenceExtension(7)})
+			assert.NoError(t, err)
+
+			stream := bytes.NewBuffer(nil)
+			stream.Write(append(first, msg...))
+			stream.Write(append(second, msg...))
+			stream.Write(append(control, "ping"...))
+			stream.Write(append(extended, msg...))
+			stream.Write(closeNotify)
+
+			reader := newAuthenticator()
+			got, err := reader.ReadNext(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, msg, got)
+			got, err = reader.ReadNext(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, msg, got)
+			got, isControl, err := reader.ReadNextFrame(stream)
+			assert.NoError(t, err)
+			assert.True(t, isControl)
+			assert.Equal(t, []byte("ping"), got)
+			got, extensions, _, err := reader.ReadNextFrameWithExtensions(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, msg, got)
+			assert.Equal(t, []Extension{SequenceExtension(7)}, extensions)
+			_, err = reader.ReadNext(stream)
+			assert.Equal(t, ErrCloseNotify, err)
+			_, err = reader.ReadNext(stream)
+			assert.Equal(t, io.EOF, err)
+
+			processed, n, err := reader.AuthenticateMessages(append(append(first, msg...), append(second, msg...)...))
+			assert.NoError(t, err)
+			assert.Equal(t, 2, n)
+			assert.Equal(t, append(append([]byte{}, msg...), msg...), processed)
+		})
+	}
+}
+
+func Test_OneTimeMACAuthenticatorTampering(t *testing.T) {
+	mockKey := []byte("mock key")
+	msg := []byte("mock message")
+	a := NewOneTimeMACAuthenticator(mockKey, NewRandomNonceSource(nil)).WithMACEncoding(Raw.(MACDecoder))
+	header, err := a.GetMessageAuthenticationHeader(msg)
+	assert.NoError(t, err)
+	frame := append(header, msg...)
+
+	tests := []struct {
+		name   string
+		modify func(frame []byte)
+	}{
+		{name: "Tampered nonce", modify: func(frame []byte) { frame[0] ^= 1 }},
+		{name: "Tampered tag", modify: func(frame []byte) { frame[NonceSize] ^= 1 }},
+		{name: "Tampered message", modify: func(frame []byte) { frame[len(frame)-1] ^= 1 }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tampered := append([]byte(nil), frame...)
+			test.modify(tampered)
+			_, err := a.ReadNext(bytes.NewReader(tampered))
+			assert.True(t, errors.Is(err, ErrMACMismatch), "expected %v, got %v", ErrMACMismatch, err)
+		})
+	}
+
+	// frames are bound to the key, and to associated data
+	_, err = NewOneTimeMACAuthenticator([]byte("other key"), nil).WithMACEncoding(Raw.(MACDecoder)).ReadNext(bytes.NewReader(frame))
+	assert.True(t, errors.Is(err, ErrMACMismatch))
+	_, err = NewOneTimeMACAuthenticator(mockKey, nil).WithMACEncoding(Raw.(MACDecoder)).WithAssociatedData([]byte("aad")).ReadNext(bytes.NewReader(frame))
+	assert.True(t, errors.Is(err, ErrMACMismatch))
+
+	// frames fail once the key is destroyed
+	a.Destroy()
+	_, err = a.GetMessageAuthenticationHeader(msg)
+	assert.Equal(t, ErrDestroyed, err)
+}
+
+func Test_NonceSources(t *testing.T) {
+	counter, err := NewCounterNonceSource()
+	assert.NoError(t, err)
+	first, err := counter.Nonce()
+	assert.NoError(t, err)
+	second, err := counter.Nonce()
+	assert.NoError(t, err)
+	assert.Equal(t, NonceSize, len(first))
+	assert.Equal(t, first[:4], second[:4])
+	assert.NotEqual(t, first, second)
+
+	// counters do not wrap around
+	counter.(*counterNonceSource).counter = math.MaxUint64 - 1
+	_, err = counter.Nonce()
+	assert.True(t, errors.Is(err, ErrNoncesExhausted))
+	_, err = counter.Nonce()
+	assert.True(t, errors.Is(err, ErrNoncesExhausted))
+
+	// random nonces come from the given reader
+	random := NewRandomNonceSource(bytes.NewReader(bytes.Repeat([]byte{1}, NonceSize)))
+	nonce, err := random.Nonce()
+	assert.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{1}, NonceSize), nonce)
+	_, err = random.Nonce()
+	assert.Error(t, err)
+
+	// nonces of the wrong size are rejected
+	_, err = NewOneTimeMACAuthenticator([]byte("mock key"), fixedNonceSource("short")).GetMessageAuthenticationHeader(nil)
+	assert.Error(t, err)
+}
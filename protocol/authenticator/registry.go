@@ -0,0 +1,153 @@
+package authenticator
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// IDs of the built-in algorithms. IDs from AlgorithmIDCustom on are free for
+// third parties to use.
+const (
+	AlgorithmHMACSHA256     uint16 = 1
+	AlgorithmHMACSHA384     uint16 = 2
+	AlgorithmHMACSHA512     uint16 = 3
+	AlgorithmHMACSHA3_256   uint16 = 4
+	AlgorithmHMACSHA3_384   uint16 = 5
+	AlgorithmHMACSHA3_512   uint16 = 6
+	AlgorithmCRC32C         uint16 = 7
+	AlgorithmCBORHMACSHA256 uint16 = 8
+	AlgorithmPoly1305       uint16 = 9
+
+	// AlgorithmIDCustom is the first ID free for third parties to use
+	AlgorithmIDCustom uint16 = 0x8000
+)
+
+// Factory returns a new MessageAuthenticator for the given key
+type Factory func(key []byte) MessageAuthenticator
+
+// Algorithm is a registered MessageAuthenticator, identified by
+// a stable numeric ID (e.g. for handshakes) and a stable name (e.g.
+// for configuration files and flags)
+type Algorithm struct {
+	ID   uint16
+	Name string
+	New  Factory
+	// HashFn is the hash function of HMAC based algorithms (and nil
+	// otherwise), such that they can be checked against policies
+	HashFn func() hash.Hash
+}
+
+var (
+	registryLock sync.RWMutex
+	registryByID = map[uint16]Algorithm{}
+	registryName = map[string]Algorithm{}
+)
+
+func init() {
+	for _, alg := range []Algorithm{
+		hmacAlgorithm(AlgorithmHMACSHA256, "hmac-sha256", sha256.New),
+		hmacAlgorithm(AlgorithmHMACSHA384, "hmac-sha384", sha512.New384),
+		hmacAlgorithm(AlgorithmHMACSHA512, "hmac-sha512", sha512.New),
+		hmacAlgorithm(AlgorithmHMACSHA3_256, "hmac-sha3-256", sha3.New256),
+		hmacAlgorithm(AlgorithmHMACSHA3_384, "hmac-sha3-384", sha3.New384),
+		hmacAlgorithm(AlgorithmHMACSHA3_512, "hmac-sha3-512", sha3.New512),
+		{
+			ID:   AlgorithmCRC32C,
+			Name: "crc32c",
+			New:  func([]byte) MessageAuthenticator { return NewChecksumAuthenticator() },
+		},
+		{
+			ID:     AlgorithmCBORHMACSHA256,
+			Name:   "cbor-hmac-sha256",
+			New:    func(key []byte) MessageAuthenticator { return NewCBORMessageAuthenticator(sha256.New, key) },
+			HashFn: sha256.New,
+		},
+		{
+			ID:   AlgorithmPoly1305,
+			Name: "poly1305",
+			New: func(key []byte) MessageAuthenticator {
+				return NewOneTimeMACAuthenticator(key, NewRandomNonceSource(nil))
+			},
+		},
+	} {
+		if err := Register(alg); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func hmacAlgorithm(id uint16, name string, hashFn func() hash.Hash) Algorithm {
+	return Algorithm{
+		ID:   id,
+		Name: name,
+		New: func(key []byte) MessageAuthenticator {
+			return NewDefaultMessageAuthenticator(hashFn, key)
+		},
+		HashFn: hashFn,
+	}
+}
+
+// Register registers an Algorithm, such that it can be looked up with Get
+// and GetByName. It fails if its ID or name is already registered.
+func Register(alg Algorithm) error {
+	if alg.Name == "" || alg.New == nil {
+		return fmt.Errorf("algorithm %d must have a name and a factory", alg.ID)
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if existing, ok := registryByID[alg.ID]; ok {
+		return fmt.Errorf("algorithm ID %d already registered (as %q)", alg.ID, existing.Name)
+	}
+	if existing, ok := registryName[alg.Name]; ok {
+		return fmt.Errorf("algorithm name %q already registered (with ID %d)", alg.Name, existing.ID)
+	}
+	registryByID[alg.ID] = alg
+	registryName[alg.Name] = alg
+	return nil
+}
+
+// Get returns the registered Algorithm with the given ID
+func Get(id uint16) (Algorithm, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	alg, ok := registryByID[id]
+	return alg, ok
+}
+
+// GetByName returns the registered Algorithm with the given name
+func GetByName(name string) (Algorithm, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	alg, ok := registryName[name]
+	return alg, ok
+}
+
+// Algorithms returns all registered Algorithms, ordered by ID
+func Algorithms() []Algorithm {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	algs := make([]Algorithm, 0, len(registryByID))
+	for _, alg := range registryByID {
+		algs = append(algs, alg)
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i].ID < algs[j].ID })
+	return algs
+}
+
+// AlgorithmExtension returns an ExtensionAlgorithm Extension, with which
+// applications may label the frames they write. Readers do not select the
+// algorithm by it (the frame format depends on the algorithm, which is
+// configured by name or negotiated by a handshake), but they may check it,
+// e.g. against an allowlist.
+func AlgorithmExtension(id uint16) Extension {
+	return Extension{Type: ExtensionAlgorithm, Value: binary.BigEndian.AppendUint16(nil, id)}
+}
@@ -0,0 +1,66 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Registry(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        uint16
+		algorithm string
+	}{
+		{name: "HMAC-SHA256", id: AlgorithmHMACSHA256, algorithm: "hmac-sha256"},
+		{name: "HMAC-SHA3-512", id: AlgorithmHMACSHA3_512, algorithm: "hmac-sha3-512"},
+		{name: "CRC-32C", id: AlgorithmCRC32C, algorithm: "crc32c"},
+		{name: "CBOR HMAC-SHA256", id: AlgorithmCBORHMACSHA256, algorithm: "cbor-hmac-sha256"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			byID, ok := Get(test.id)
+			assert.True(t, ok)
+			assert.Equal(t, test.algorithm, byID.Name)
+
+			byName, ok := GetByName(test.algorithm)
+			assert.True(t, ok)
+			assert.Equal(t, test.id, byName.ID)
+
+			// frames round trip between instances of the algorithm
+			msg := []byte("mock message")
+			header, err := byID.New([]byte("mock key")).GetMessageAuthenticationHeader(msg)
+			assert.Nil(t, err)
+			got, err := byName.New([]byte("mock key")).ReadNext(bytes.NewReader(append(header, msg...)))
+			assert.Nil(t, err)
+			assert.Equal(t, msg, got)
+		})
+	}
+}
+
+func Test_Register(t *testing.T) {
+	custom := Algorithm{
+		ID:   AlgorithmIDCustom + 1,
+		Name: "test-custom",
+		New: func(key []byte) MessageAuthenticator {
+			return NewDefaultMessageAuthenticator(sha256.New, key).WithTagSize(16)
+		},
+	}
+	assert.Nil(t, Register(custom))
+
+	_, ok := GetByName("test-custom")
+	assert.True(t, ok)
+
+	// IDs and names must be unique
+	assert.NotNil(t, Register(custom))
+	assert.NotNil(t, Register(Algorithm{ID: AlgorithmHMACSHA256, Name: "other", New: custom.New}))
+	assert.NotNil(t, Register(Algorithm{ID: AlgorithmIDCustom + 2, Name: "hmac-sha256", New: custom.New}))
+	assert.NotNil(t, Register(Algorithm{ID: AlgorithmIDCustom + 3, Name: "no-factory"}))
+
+	algs := Algorithms()
+	for i := 1; i < len(algs); i++ {
+		assert.True(t, algs[i-1].ID < algs[i].ID)
+	}
+}
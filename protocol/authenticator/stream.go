@@ -0,0 +1,61 @@
+package authenticator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamStats is a summary of the messages processed by AuthenticateStream
+type StreamStats struct {
+	// Frames is the number of messages verified (excluding control frames)
+	Frames int64
+	// Bytes is the number of message bytes written
+	Bytes int64
+}
+
+// AuthenticateStream is the streaming counterpart of AuthenticateMessages: it
+// verifies the frames read from r one at a time and writes their messages to
+// w, until the end of r (which must be at a frame boundary) or a close
+// notification. Control frames are skipped, as are the extensions of frames.
+// Note that the messages of frames read before an invalid one have already
+// been written to w when it returns an error, and that:
+//
+//   - only one frame is held in memory at a time, so memory use is bounded by
+//     the max message size of a (e.g. DefaultMessageAuthenticator.WithMaxMessageSize) rather than by
+//     the size of the input. With a max message size of zero (no limit, the
+//     default), a single frame may claim (and be buffered for) any length, so
+//     untrusted input must only be passed along with a max message size.
+//   - messages are written as they were framed: AuthenticateStream does not
+//     interpret extensions, so the messages of compressed frames (see
+//     ExtensionCompression) are written still compressed. Use authio readers
+//     (e.g. authio.NewVerifyMACReader) to decompress them.
+func AuthenticateStream(a MessageAuthenticator, r io.Reader, w io.Writer) (StreamStats, error) {
+	framer, isControlFramer := a.(ControlFramer)
+
+	stats := StreamStats{}
+	for index := 0; ; index++ {
+		var msg []byte
+		var control bool
+		var err error
+		if isControlFramer {
+			msg, control, err = framer.ReadNextFrame(r)
+		} else {
+			msg, err = a.ReadNext(r)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, ErrCloseNotify) {
+				return stats, nil
+			}
+			return stats, fmt.Errorf("failed to authenticate frame %d: %w", index, err)
+		}
+		if control {
+			continue
+		}
+		if _, err := w.Write(msg); err != nil {
+			return stats, fmt.Errorf("failed to write message of frame %d: %w", index, err)
+		}
+		stats.Frames++
+		stats.Bytes += int64(len(msg))
+	}
+}
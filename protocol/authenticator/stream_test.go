@@ -0,0 +1,123 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_AuthenticateStream(t *testing.T) {
+	a := NewDefaultMessageAuthenticator(sha256.New, []byte("mock key"))
+	frame := func(message string) []byte {
+		header, err := a.GetMessageAuthenticationHeader([]byte(message))
+		assert.NoError(t, err)
+		return append(header, message...)
+	}
+	control, err := a.GetControlFrameHeader([]byte("ping"))
+	assert.NoError(t, err)
+	control = append(control, "ping"...)
+	closeNotify, err := a.GetCloseNotifyHeader()
+	assert.NoError(t, err)
+	withExtensions := func(message string, extensions ...Extension) []byte {
+		header, err := a.GetMessageAuthenticationHeaderWithExtensions([]byte(message), extensions)
+		assert.NoError(t, err)
+		return append(header, message...)
+	}
+
+	tests := []struct {
+		name          string
+		stream        []byte
+		expectedOut   string
+		expectedStats StreamStats
+		expectedErr   error
+	}{
+		{
+			name: "Empty stream",
+		},
+		{
+			name:          "Messages",
+			stream:        bytes.Join([][]byte{frame("hello "), frame(""), frame("world")}, nil),
+			expectedOut:   "hello world",
+			expectedStats: StreamStats{Frames: 3, Bytes: 11},
+		},
+		{
+			name:          "Control frames are skipped",
+			stream:        bytes.Join([][]byte{control, frame("hello"), control}, nil),
+			expectedOut:   "hello",
+			expectedStats: StreamStats{Frames: 1, Bytes: 5},
+		},
+		{
+			name:          "Stops at close notification",
+			stream:        bytes.Join([][]byte{frame("hello"), closeNotify, frame("world")}, nil),
+			expectedOut:   "hello",
+			expectedStats: StreamStats{Frames: 1, Bytes: 5},
+		},
+		{
+			name:          "Extensions are skipped",
+			stream:        bytes.Join([][]byte{withExtensions("hello ", KeyIDExtension("mock key ID")), frame("world")}, nil),
+			expectedOut:   "hello world",
+			expectedStats: StreamStats{Frames: 2, Bytes: 11},
+		},
+		{
+			// the message is not decompressed
+			name:          "Compressed message is written as-is",
+			stream:        withExtensions("compressed", CompressionExtension(1)),
+			expectedOut:   "compressed",
+			expectedStats: StreamStats{Frames: 1, Bytes: 10},
+		},
+		{
+			name:          "Tampered message",
+			stream:        bytes.Join([][]byte{frame("hello"), bytes.Replace(frame("world"), []byte("world"), []byte("World"), 1)}, nil),
+			expectedOut:   "hello",
+			expectedStats: StreamStats{Frames: 1, Bytes: 5},
+			expectedErr:   ErrMACMismatch,
+		},
+		{
+			name:          "Truncated final frame",
+			stream:        bytes.Join([][]byte{frame("hello"), frame("world")[:len(frame("world"))-1]}, nil),
+			expectedOut:   "hello",
+			expectedStats: StreamStats{Frames: 1, Bytes: 5},
+			expectedErr:   ErrTruncatedMessage,
+		},
+		{
+			name:          "Truncated message",
+			stream:        bytes.Join([][]byte{frame("hello"), frame("world")[:10]}, nil),
+			expectedOut:   "hello",
+			expectedStats: StreamStats{Frames: 1, Bytes: 5},
+			expectedErr:   ErrTruncatedMessage,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			stats, err := AuthenticateStream(a, bytes.NewReader(test.stream), out)
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedOut, out.String())
+			assert.Equal(t, test.expectedStats, stats)
+		})
+	}
+}
+
+func Test_AuthenticateStreamMaxMessageSize(t *testing.T) {
+	a := NewDefaultMessageAuthenticator(sha256.New, []byte("mock key")).WithMaxMessageSize(5)
+	stream := &bytes.Buffer{}
+	for _, message := range []string{"hello", "world!"} {
+		header, err := a.GetMessageAuthenticationHeader([]byte(message))
+		assert.NoError(t, err)
+		stream.Write(append(header, message...))
+	}
+
+	out := &bytes.Buffer{}
+	stats, err := AuthenticateStream(a, stream, out)
+	var tooLarge *MessageTooLargeError
+	assert.True(t, errors.As(err, &tooLarge), "expected %T, got %v", tooLarge, err)
+	assert.Equal(t, "hello", out.String())
+	assert.Equal(t, StreamStats{Frames: 1, Bytes: 5}, stats)
+}
@@ -0,0 +1,144 @@
+package authenticator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"golang.org/x/crypto/sha3"
+)
+
+// the test vectors are published for non-Go implementations of the wire
+// format, regenerate them with: go test ./protocol/authenticator -update
+var update = flag.Bool("update", false, "regenerate test vectors")
+
+var testVectorsPath = filepath.Join("testdata", "vectors.json")
+
+// testVectorHashes are the hash functions covered by the test vectors
+var testVectorHashes = map[string]func() hash.Hash{
+	"sha256":   sha256.New,
+	"sha384":   sha512.New384,
+	"sha512":   sha512.New,
+	"sha3-256": sha3.New256,
+	"sha3-384": sha3.New384,
+	"sha3-512": sha3.New512,
+}
+
+// testVector is a single frame, all byte fields are hex encoded
+type testVector struct {
+	Name      string `json:"name"`
+	Hash      string `json:"hash"`
+	Key       string `json:"key"`
+	Plaintext string `json:"plaintext"`
+	Frame     string `json:"frame"`
+}
+
+func generateTestVectors(t *testing.T) []testVector {
+	keys := map[string][]byte{
+		"ascii key":  []byte("mysupersecretpassword"),
+		"binary key": bytes.Repeat([]byte{0x00, 0xff}, 16),
+	}
+	plaintexts := map[string][]byte{
+		"empty": {},
+		"ascii": []byte("hello world\n"),
+		"binary": func() []byte {
+			b := make([]byte, 256)
+			for i := range b {
+				b[i] = byte(i)
+			}
+			return b
+		}(),
+	}
+
+	vectors := []testVector{}
+	for _, hashName := range sortedKeys(testVectorHashes) {
+		for _, keyName := range sortedKeys(keys) {
+			for _, plaintextName := range sortedKeys(plaintexts) {
+				a := NewDefaultMessageAuthenticator(testVectorHashes[hashName], keys[keyName])
+				header, err := a.GetMessageAuthenticationHeader(plaintexts[plaintextName])
+				assert.NoError(t, err)
+
+				vectors = append(vectors, testVector{
+					Name:      hashName + ", " + keyName + ", " + plaintextName + " plaintext",
+					Hash:      hashName,
+					Key:       hex.EncodeToString(keys[keyName]),
+					Plaintext: hex.EncodeToString(plaintexts[plaintextName]),
+					Frame:     hex.EncodeToString(append(header, plaintexts[plaintextName]...)),
+				})
+			}
+		}
+	}
+	return vectors
+}
+
+// loadTestVectors loads the published test vectors, decoding their byte fields
+func loadTestVectors(t *testing.T) []testVector {
+	data, err := os.ReadFile(testVectorsPath)
+	if err != nil {
+		t.Fatalf("failed to read test vectors: %s", err)
+	}
+	vectors := []testVector{}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("failed to decode test vectors: %s", err)
+	}
+	return vectors
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("failed to decode hex %q: %s", s, err)
+	}
+	return b
+}
+
+func Test_TestVectors(t *testing.T) {
+	if *update {
+		data, err := json.MarshalIndent(generateTestVectors(t), "", "  ")
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(testVectorsPath, append(data, '\n'), 0644))
+	}
+
+	vectors := loadTestVectors(t)
+	assert.Equal(t, len(generateTestVectors(t)), len(vectors))
+
+	for _, vector := range vectors {
+		t.Run(vector.Name, func(t *testing.T) {
+			hashFn, ok := testVectorHashes[vector.Hash]
+			if !ok {
+				t.Fatalf("unknown hash %q", vector.Hash)
+			}
+			key := mustDecodeHex(t, vector.Key)
+			plaintext := mustDecodeHex(t, vector.Plaintext)
+			frame := mustDecodeHex(t, vector.Frame)
+
+			a := NewDefaultMessageAuthenticator(hashFn, key)
+
+			header, err := a.GetMessageAuthenticationHeader(plaintext)
+			assert.NoError(t, err)
+			assert.Equal(t, frame, append(header, plaintext...))
+
+			msg, err := a.ReadNext(bytes.NewReader(frame))
+			assert.NoError(t, err)
+			assert.Equal(t, plaintext, msg)
+		})
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,147 @@
+package authio
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// PSKNonceSize is the size of the nonces exchanged by PSK handshakes
+	PSKNonceSize = 32
+
+	// pskSessionKeyInfo is the HKDF info session keys are derived with
+	pskSessionKeyInfo = "authio psk session keys"
+
+	// pskConfirmationLabel is the label of the key confirmation
+	// each peer sends once it derived the session keys
+	pskConfirmationLabel = "authio psk key confirmation"
+
+	// pskSessionKeySize is the size of every session key
+	pskSessionKeySize = 32
+)
+
+// ErrPSKHandshakeFailed is returned (wrapped) by NewPSKClientConn and
+// NewPSKServerConn when the peer does not know the pre-shared key (or
+// runs another handshake, or another version of it)
+var ErrPSKHandshakeFailed = errors.New("PSK handshake failed")
+
+// DerivePSKSessionKeys derives the session keys of either direction of a
+// connection from a pre-shared key and the nonces exchanged by its peers,
+// with HKDF-SHA256 (with the nonces as salt). Both peers derive the same
+// keys, which are unique to the connection as long as either nonce is.
+func DerivePSKSessionKeys(psk, clientNonce, serverNonce []byte) (clientToServer, serverToClient []byte, err error) {
+	salt := append(append([]byte{}, clientNonce...), serverNonce...)
+	keys := make([]byte, 2*pskSessionKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, psk, salt, []byte(pskSessionKeyInfo)), keys); err != nil {
+		return nil, nil, fmt.Errorf("failed to derive session keys: %w", err)
+	}
+	return keys[:pskSessionKeySize], keys[pskSessionKeySize:], nil
+}
+
+// NewPSKClientConn performs a handshake over the client side of a connection,
+// and wraps it in a Conn keyed with per-connection session keys derived from
+// the pre-shared key and nonces exchanged by the peers (see
+// DerivePSKSessionKeys), rather than with the pre-shared key itself, such
+// that captured traffic of one connection reveals nothing about the others.
+// The peer must use NewPSKServerConn. Deadlines set on the connection apply
+// to the handshake, unless configured WithHandshakeTimeout.
+func NewPSKClientConn(conn net.Conn, psk []byte, opts ...Option) (*Conn, error) {
+	config := newConfig(opts...)
+	if err := config.checkKey(psk); err != nil {
+		return nil, err
+	}
+	return runHandshake(conn, config.handshakeTimeout, func() (*Conn, error) {
+		return pskClientHandshake(conn, psk, opts...)
+	})
+}
+
+// NewPSKServerConn performs a handshake over the server side of a connection,
+// and wraps it in a Conn keyed with per-connection session keys derived from
+// the pre-shared key (see NewPSKClientConn)
+func NewPSKServerConn(conn net.Conn, psk []byte, opts ...Option) (*Conn, error) {
+	config := newConfig(opts...)
+	if err := config.checkKey(psk); err != nil {
+		return nil, err
+	}
+	return runHandshake(conn, config.handshakeTimeout, func() (*Conn, error) {
+		return pskServerHandshake(conn, psk, opts...)
+	})
+}
+
+func pskClientHandshake(conn net.Conn, psk []byte, opts ...Option) (*Conn, error) {
+	hello, err := pskHandshakeProtocol.exchangeNonces(conn, true, newConfig(opts...).algorithms)
+	if err != nil {
+		return nil, err
+	}
+	clientToServer, serverToClient, err := DerivePSKSessionKeys(psk, hello.clientNonce, hello.serverNonce)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePSKConfirmation(conn, clientToServer, hello); err != nil {
+		return nil, err
+	}
+	if err := readPSKConfirmation(conn, serverToClient, hello); err != nil {
+		return nil, err
+	}
+	return NewConnWithKeys(conn, serverToClient, clientToServer, hello.options(opts)...), nil
+}
+
+func pskServerHandshake(conn net.Conn, psk []byte, opts ...Option) (*Conn, error) {
+	hello, err := pskHandshakeProtocol.exchangeNonces(conn, false, newConfig(opts...).algorithms)
+	if err != nil {
+		return nil, err
+	}
+	clientToServer, serverToClient, err := DerivePSKSessionKeys(psk, hello.clientNonce, hello.serverNonce)
+	if err != nil {
+		return nil, err
+	}
+	// the client confirms first, such that servers confirm nothing to
+	// clients which do not know the pre-shared key
+	if err := readPSKConfirmation(conn, clientToServer, hello); err != nil {
+		return nil, err
+	}
+	if err := writePSKConfirmation(conn, serverToClient, hello); err != nil {
+		return nil, err
+	}
+	return NewConnWithKeys(conn, clientToServer, serverToClient, hello.options(opts)...), nil
+}
+
+// writePSKConfirmation writes a confirmation of the session key of the
+// direction written (i.e. a MAC of the nonces and negotiated algorithm under
+// it), such that peers with different pre-shared keys (or whose algorithm
+// offers were tampered with) fail the handshake rather than the first message
+func writePSKConfirmation(conn net.Conn, writeKey []byte, hello handshakeHello) error {
+	if _, err := conn.Write(pskConfirmation(writeKey, hello)); err != nil {
+		return fmt.Errorf("failed to write key confirmation: %w", err)
+	}
+	return nil
+}
+
+// readPSKConfirmation reads and verifies the peer's confirmation of
+// the session key of the direction read (see writePSKConfirmation)
+func readPSKConfirmation(conn net.Conn, readKey []byte, hello handshakeHello) error {
+	confirmation := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(conn, confirmation); err != nil {
+		return fmt.Errorf("failed to read key confirmation: %w", err)
+	}
+	if !hmac.Equal(confirmation, pskConfirmation(readKey, hello)) {
+		return fmt.Errorf("%w: peer does not know the pre-shared key", ErrPSKHandshakeFailed)
+	}
+	return nil
+}
+
+// pskConfirmation returns the key confirmation of the given session key
+func pskConfirmation(key []byte, hello handshakeHello) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(pskConfirmationLabel))
+	mac.Write(hello.clientNonce)
+	mac.Write(hello.serverNonce)
+	mac.Write(hello.negotiation)
+	return mac.Sum(nil)
+}
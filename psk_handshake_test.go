@@ -0,0 +1,201 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+// pskHandshake performs a PSK handshake over a pipe, closing either end
+// of it once its side of the handshake fails
+func pskHandshake(clientPSK, serverPSK []byte, opts ...[]Option) (client, server *Conn, clientErr, serverErr error) {
+	clientConn, serverConn := net.Pipe()
+	return pskHandshakeOver(clientConn, serverConn, clientPSK, serverPSK, opts...)
+}
+
+// pskHandshakeOver performs a PSK handshake over the given connections,
+// with the given client and server options (if any), respectively
+func pskHandshakeOver(clientConn, serverConn net.Conn, clientPSK, serverPSK []byte, opts ...[]Option) (client, server *Conn, clientErr, serverErr error) {
+	var clientOpts, serverOpts []Option
+	if len(opts) == 2 {
+		clientOpts, serverOpts = opts[0], opts[1]
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if server, serverErr = NewPSKServerConn(serverConn, serverPSK, serverOpts...); serverErr != nil {
+			serverConn.Close()
+		}
+	}()
+	if client, clientErr = NewPSKClientConn(clientConn, clientPSK, clientOpts...); clientErr != nil {
+		clientConn.Close()
+	}
+	<-done
+	return client, server, clientErr, serverErr
+}
+
+func Test_PSKHandshake(t *testing.T) {
+	psk := []byte("mock pre-shared key")
+
+	client, server, clientErr, serverErr := pskHandshake(psk, psk)
+	assert.NoError(t, clientErr)
+	assert.NoError(t, serverErr)
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_, err := client.Write([]byte("hello"))
+		assert.NoError(t, err)
+	}()
+	buf := make([]byte, 16)
+	n, err := server.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+
+	go func() {
+		_, err := server.Write([]byte("world"))
+		assert.NoError(t, err)
+	}()
+	n, err = client.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+
+	// a peer without the pre-shared key fails the handshake
+	_, _, clientErr, serverErr = pskHandshake([]byte("other pre-shared key"), psk)
+	assert.Error(t, clientErr)
+	assert.True(t, errors.Is(serverErr, ErrPSKHandshakeFailed), "expected %v, got %v", ErrPSKHandshakeFailed, serverErr)
+
+	// keys below the minimum key length are rejected before the handshake
+	_, err = NewPSKClientConn(nil, []byte("short"), WithMinKeyLength(32))
+	assert.Error(t, err)
+}
+
+func Test_DerivePSKSessionKeys(t *testing.T) {
+	psk := []byte("mock pre-shared key")
+	clientNonce := bytes.Repeat([]byte{1}, PSKNonceSize)
+	serverNonce := bytes.Repeat([]byte{2}, PSKNonceSize)
+
+	clientToServer, serverToClient, err := DerivePSKSessionKeys(psk, clientNonce, serverNonce)
+	assert.NoError(t, err)
+	assert.Equal(t, pskSessionKeySize, len(clientToServer))
+	assert.NotEqual(t, clientToServer, serverToClient)
+	assert.NotEqual(t, psk, clientToServer)
+
+	// keys are deterministic, but unique to the nonces
+	again, _, err := DerivePSKSessionKeys(psk, clientNonce, serverNonce)
+	assert.NoError(t, err)
+	assert.Equal(t, clientToServer, again)
+	other, _, err := DerivePSKSessionKeys(psk, clientNonce, bytes.Repeat([]byte{3}, PSKNonceSize))
+	assert.NoError(t, err)
+	assert.NotEqual(t, clientToServer, other)
+	swapped, _, err := DerivePSKSessionKeys(psk, serverNonce, clientNonce)
+	assert.NoError(t, err)
+	assert.NotEqual(t, clientToServer, swapped)
+}
+
+func Test_PSKHandshakeAlgorithms(t *testing.T) {
+	psk := []byte("mock pre-shared key")
+
+	tests := []struct {
+		name              string
+		clientAlgorithms  []string
+		serverAlgorithms  []string
+		expectedAlgorithm string
+		expectError       bool
+	}{
+		{
+			name:              "Not negotiated",
+			expectedAlgorithm: "HMAC-SHA-256",
+		},
+		{
+			name:              "Client preference",
+			clientAlgorithms:  []string{"hmac-sha384", "hmac-sha256"},
+			serverAlgorithms:  []string{"hmac-sha256", "hmac-sha384"},
+			expectedAlgorithm: "hmac-sha384",
+		},
+		{
+			name:              "First accepted",
+			clientAlgorithms:  []string{"hmac-sha512", "hmac-sha3-256"},
+			serverAlgorithms:  []string{"hmac-sha3-256"},
+			expectedAlgorithm: "hmac-sha3-256",
+		},
+		{
+			name:             "No common algorithm",
+			clientAlgorithms: []string{"hmac-sha512"},
+			serverAlgorithms: []string{"hmac-sha256"},
+			expectError:      true,
+		},
+		{
+			name:             "Server does not negotiate",
+			clientAlgorithms: []string{"hmac-sha256"},
+			expectError:      true,
+		},
+		{
+			name:             "Client does not negotiate",
+			serverAlgorithms: []string{"hmac-sha256"},
+			expectError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, server, clientErr, serverErr := pskHandshake(psk, psk, []Option{WithAlgorithms(test.clientAlgorithms...)}, []Option{WithAlgorithms(test.serverAlgorithms...)})
+			if test.expectError {
+				assert.Error(t, clientErr)
+				assert.True(t, errors.Is(serverErr, ErrPSKHandshakeFailed), "expected %v, got %v", ErrPSKHandshakeFailed, serverErr)
+				return
+			}
+			assert.NoError(t, clientErr)
+			assert.NoError(t, serverErr)
+			defer client.Close()
+			defer server.Close()
+			assert.Equal(t, test.expectedAlgorithm, client.AuthInfo().Algorithm)
+			assert.Equal(t, test.expectedAlgorithm, server.AuthInfo().Algorithm)
+
+			go func() {
+				_, err := client.Write([]byte("hello"))
+				assert.NoError(t, err)
+			}()
+			buf := make([]byte, 16)
+			n, err := server.Read(buf)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(buf[:n]))
+		})
+	}
+
+	// unknown algorithms are rejected before the handshake
+	_, err := NewPSKClientConn(nil, psk, WithAlgorithms("unknown"))
+	assert.Error(t, err)
+}
+
+func Test_PSKHandshakeAlgorithmDowngrade(t *testing.T) {
+	psk := []byte("mock pre-shared key")
+	clientConn, clientSide := net.Pipe()
+	serverSide, serverConn := net.Pipe()
+
+	// a man in the middle swaps the algorithms offered by the
+	// client, such that the server picks the less preferred one
+	go func() {
+		defer clientSide.Close()
+		defer serverSide.Close()
+		hello := make([]byte, handshakeHeaderSize+PSKNonceSize+5)
+		if _, err := io.ReadFull(clientSide, hello); err != nil {
+			return
+		}
+		offer := hello[handshakeHeaderSize+PSKNonceSize+1:]
+		copy(offer, append(offer[2:4:4], offer[0:2]...))
+		if _, err := serverSide.Write(hello); err != nil {
+			return
+		}
+		go io.Copy(serverSide, clientSide)
+		io.Copy(clientSide, serverSide)
+	}()
+
+	algorithms := []Option{WithAlgorithms("hmac-sha384", "hmac-sha256")}
+	_, _, clientErr, serverErr := pskHandshakeOver(clientConn, serverConn, psk, psk, algorithms, algorithms)
+	assert.Error(t, clientErr)
+	assert.True(t, errors.Is(serverErr, ErrPSKHandshakeFailed), "expected %v, got %v", ErrPSKHandshakeFailed, serverErr)
+}
@@ -0,0 +1,105 @@
+package authio
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimit is the max rate of messages in one direction of a Conn (see
+// WithRateLimit). Either rate may be zero, which means no limit.
+type RateLimit struct {
+	// BytesPerSecond is the max rate of message bytes (excluding headers)
+	BytesPerSecond int
+	// FramesPerSecond is the max rate of messages
+	FramesPerSecond int
+}
+
+// rateLimiter throttles one direction of a Conn to a RateLimit, with
+// token buckets which hold up to one second worth of either rate (i.e.
+// bursts of up to a second are allowed). It is safe for concurrent use.
+type rateLimiter struct {
+	lock   sync.Mutex
+	bytes  *tokenBucket
+	frames *tokenBucket
+}
+
+// newRateLimiter returns a rateLimiter for the given
+// RateLimit, or nil if the RateLimit has no limits
+func newRateLimiter(limit RateLimit, clock Clock) *rateLimiter {
+	if limit.BytesPerSecond <= 0 && limit.FramesPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		bytes:  newTokenBucket(limit.BytesPerSecond, clock),
+		frames: newTokenBucket(limit.FramesPerSecond, clock),
+	}
+}
+
+// wait takes the given number of frames and bytes from the buckets, and
+// blocks until the rate limit allows them (or until done is closed, upon
+// which it returns net.ErrClosed). Frames and bytes are always taken, such
+// that messages larger than the buckets only take longer to be allowed.
+func (l *rateLimiter) wait(frames, bytes int, done <-chan struct{}) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	delay := l.bytes.take(bytes)
+	if d := l.frames.take(frames); d > delay {
+		delay = d
+	}
+	l.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-done:
+		return net.ErrClosed
+	}
+}
+
+// tokenBucket is a token bucket, refilled at a constant rate up to one
+// second worth of tokens, whose balance goes negative upon taking more
+// tokens than available (i.e. takers wait until the debt is paid off)
+type tokenBucket struct {
+	rate   float64 // per second
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+// newTokenBucket returns a full tokenBucket refilled at the
+// given rate per second, or nil if the rate is not positive
+func newTokenBucket(rate int, clock Clock) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{rate: float64(rate), tokens: float64(rate), last: clock.Now(), clock: clock}
+}
+
+// take takes n tokens and returns how long to wait until the balance is
+// no longer negative. Taking from a nil tokenBucket never has to wait.
+func (b *tokenBucket) take(n int) time.Duration {
+	if b == nil {
+		return 0
+	}
+	now := b.clock.Now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,120 @@
+package authio
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_TokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	clock := ClockFunc(func() time.Time { return now })
+
+	tests := []struct {
+		name          string
+		elapsed       time.Duration
+		take          int
+		expectedDelay time.Duration
+	}{
+		{name: "Full bucket", take: 100},
+		{name: "Empty bucket", take: 50, expectedDelay: 500 * time.Millisecond},
+		{name: "Debt paid off", elapsed: 500 * time.Millisecond},
+		{name: "Partially refilled", elapsed: 250 * time.Millisecond, take: 50, expectedDelay: 250 * time.Millisecond},
+		{name: "Refilled up to a second worth", elapsed: time.Hour, take: 150, expectedDelay: 500 * time.Millisecond},
+	}
+	// the bucket carries over from one test to the next
+	bucket := newTokenBucket(100, clock)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now = now.Add(test.elapsed)
+			assert.Equal(t, test.expectedDelay, bucket.take(test.take))
+		})
+	}
+
+	assert.Nil(t, newTokenBucket(0, clock))
+	assert.Equal(t, time.Duration(0), (*tokenBucket)(nil).take(1000))
+}
+
+func Test_ConnRateLimit(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name     string
+		read     RateLimit
+		write    RateLimit
+		messages int
+		minDelay time.Duration
+	}{
+		{
+			name:     "No limit",
+			messages: 50,
+		},
+		{
+			name:     "Write frames per second",
+			write:    RateLimit{FramesPerSecond: 40},
+			messages: 50,
+			minDelay: 200 * time.Millisecond,
+		},
+		{
+			name:     "Write bytes per second",
+			write:    RateLimit{BytesPerSecond: 400},
+			messages: 50,
+			minDelay: 200 * time.Millisecond,
+		},
+		{
+			name:     "Read frames per second",
+			read:     RateLimit{FramesPerSecond: 40},
+			messages: 50,
+			minDelay: 200 * time.Millisecond,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			client := NewClientConn(a, mockKey, WithRateLimit(RateLimit{}, test.write))
+			server := NewServerConn(b, mockKey, WithRateLimit(test.read, RateLimit{}))
+			defer client.Close()
+			defer server.Close()
+
+			start := time.Now()
+			written := make(chan struct{})
+			go func() {
+				defer close(written)
+				for i := 0; i < test.messages; i++ {
+					_, err := client.Write([]byte("10 bytes!!"))
+					assert.NoError(t, err)
+				}
+			}()
+			buf := make([]byte, 10)
+			for i := 0; i < test.messages; i++ {
+				_, err := io.ReadFull(server, buf)
+				assert.NoError(t, err)
+			}
+			<-written
+			elapsed := time.Since(start)
+			assert.True(t, elapsed >= test.minDelay, "expected at least %s, took %s", test.minDelay, elapsed)
+			if test.minDelay == 0 {
+				assert.True(t, elapsed < 100*time.Millisecond, "expected no delay, took %s", elapsed)
+			}
+		})
+	}
+}
+
+func Test_ConnRateLimitClose(t *testing.T) {
+	a, b := net.Pipe()
+	client := NewClientConn(a, []byte("mock key"), WithRateLimit(RateLimit{}, RateLimit{BytesPerSecond: 5}))
+	server := NewServerConn(b, []byte("mock key"))
+	defer server.Close()
+	go io.Copy(io.Discard, server)
+
+	// the first write takes the whole bucket
+	_, err := client.Write([]byte("hello"))
+	assert.NoError(t, err)
+	time.AfterFunc(50*time.Millisecond, func() { client.Close() })
+	_, err = client.Write([]byte("world"))
+	assert.True(t, errors.Is(err, net.ErrClosed))
+}
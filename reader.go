@@ -13,6 +13,6 @@ type Reader struct {
 var _ io.Reader = (*Reader)(nil)
 
 // NewReader returns a default Reader implementation
-func NewReader(reader io.Reader, key []byte) *Reader {
-	return &Reader{NewVerifyMACReader(reader, key)}
+func NewReader(reader io.Reader, key []byte, opts ...Option) *Reader {
+	return &Reader{NewVerifyMACReader(reader, key, opts...)}
 }
@@ -0,0 +1,47 @@
+package authio
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// recordLengthFieldSize is the size of the field at the start of fixed size
+// records (see WithFixedRecords) with the length of their data, a big endian
+// uint32, which is followed by the data and then zeros up to the record size
+const recordLengthFieldSize = 4
+
+// minRecordSize is the minimum size of fixed size records, which
+// must have room for at least one byte of data after the length
+const minRecordSize = recordLengthFieldSize + 1
+
+// recordCapacity returns the number of data bytes fitting in records of the given size
+func recordCapacity(size int) int {
+	return size - recordLengthFieldSize
+}
+
+// encodeRecord encodes the given data (no more than the
+// record's capacity) as a record of the given size
+func encodeRecord(size int, data []byte) []byte {
+	record := make([]byte, size)
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	copy(record[recordLengthFieldSize:], data)
+	return record
+}
+
+// decodeRecord returns the data in a record of the given size
+func decodeRecord(size int, record []byte) ([]byte, error) {
+	if len(record) != size {
+		return nil, fmt.Errorf("invalid record size, got %d and expected %d", len(record), size)
+	}
+	length := binary.BigEndian.Uint32(record)
+	if uint64(length) > uint64(recordCapacity(size)) {
+		return nil, fmt.Errorf("invalid record data length, got %d and expected at most %d", length, recordCapacity(size))
+	}
+	data := record[recordLengthFieldSize : recordLengthFieldSize+int(length)]
+	for _, b := range record[recordLengthFieldSize+int(length):] {
+		if b != 0 {
+			return nil, fmt.Errorf("invalid record padding")
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,80 @@
+package authio
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithFixedRecords(t *testing.T) {
+	mockKey := []byte("mock key")
+	recordSize := 8
+	frameSize := recordSize + NewAppendMACWriter(nil, mockKey).authHeaderLen
+
+	tests := []struct {
+		name   string
+		input  string
+		frames int
+	}{
+		{name: "Single partial record", input: "hi", frames: 1},
+		{name: "Exactly one record", input: "1234", frames: 1},
+		{name: "Several records", input: "hello world, hello", frames: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer := NewAppendMACWriter(buf, mockKey, WithFixedRecords(recordSize))
+			for _, c := range test.input {
+				_, err := writer.Write([]byte(string(c)))
+				assert.Nil(t, err)
+			}
+			assert.Nil(t, writer.Close())
+			assert.Equal(t, test.frames*frameSize, buf.Len())
+
+			// AppendMACReaders produce the same frames
+			frames, err := io.ReadAll(NewAppendMACReader(strings.NewReader(test.input), mockKey, WithFixedRecords(recordSize)))
+			assert.Nil(t, err)
+			assert.Equal(t, buf.Bytes(), frames)
+
+			out, err := io.ReadAll(NewVerifyMACReader(buf, mockKey, WithFixedRecords(recordSize)))
+			assert.Nil(t, err)
+			assert.Equal(t, test.input, string(out))
+
+			verified := &bytes.Buffer{}
+			verifier := NewVerifyMACWriter(verified, mockKey, WithFixedRecords(recordSize))
+			_, err = verifier.Write(frames)
+			assert.Nil(t, err)
+			assert.Equal(t, test.input, verified.String())
+		})
+	}
+}
+
+func Test_WithFixedRecordsInvalid(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	// frames which are not records of the configured size are rejected
+	buf := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(buf, mockKey).Write([]byte("hello"))
+	assert.Nil(t, err)
+	_, err = NewVerifyMACReader(buf, mockKey, WithFixedRecords(8)).Next()
+	assert.NotNil(t, err)
+
+	// records must fit at least one byte and the max message size
+	for _, opts := range [][]Option{
+		{WithFixedRecords(4)},
+		{WithFixedRecords(64), WithMaxMessageSize(32)},
+	} {
+		writer := NewAppendMACWriter(&bytes.Buffer{}, mockKey, opts...)
+		_, err = writer.Write([]byte("hello"))
+		if err == nil {
+			err = writer.Close()
+		}
+		assert.NotNil(t, err)
+	}
+	_, err = NewAppendMACWriter(&bytes.Buffer{}, mockKey, WithFixedRecords(8)).WriteBatch([][]byte{[]byte("hello")})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,45 @@
+package authio
+
+// replayWindowSize is the number of most recent sequence
+// numbers for which duplicates are detected (bits in bitmap)
+const replayWindowSize = 64
+
+// replayWindow is a sliding window over received sequence numbers as
+// described in RFC 4303 (section 3.4.3). Sequence numbers newer than
+// the highest seen move the window forwards, sequence numbers within
+// the window are accepted at-most once, and older ones are rejected.
+type replayWindow struct {
+	highest uint64
+	bitmap  uint64 // bit i is set if (highest - i) was seen
+}
+
+// accept returns whether the given sequence number is fresh, and
+// if so, marks it as seen. It must only be called for messages
+// whose MAC was already verified.
+func (w *replayWindow) accept(seq uint64) bool {
+	if seq == 0 {
+		return false // sequence numbers start at 1
+	}
+
+	if seq > w.highest {
+		shift := seq - w.highest
+		if shift >= replayWindowSize {
+			w.bitmap = 1
+		} else {
+			w.bitmap = (w.bitmap << shift) | 1
+		}
+		w.highest = seq
+		return true
+	}
+
+	diff := w.highest - seq
+	if diff >= replayWindowSize {
+		return false // too old to tell
+	}
+	bit := uint64(1) << diff
+	if w.bitmap&bit != 0 {
+		return false // replayed
+	}
+	w.bitmap |= bit
+	return true
+}
@@ -0,0 +1,54 @@
+package authio
+
+import (
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_replayWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		seqs     []uint64
+		expected []bool
+	}{
+		{
+			name:     "Zero sequence number",
+			seqs:     []uint64{0},
+			expected: []bool{false},
+		},
+		{
+			name:     "In order",
+			seqs:     []uint64{1, 2, 3},
+			expected: []bool{true, true, true},
+		},
+		{
+			name:     "Duplicates",
+			seqs:     []uint64{1, 1, 2, 1, 2},
+			expected: []bool{true, false, true, false, false},
+		},
+		{
+			name:     "Out of order within window",
+			seqs:     []uint64{5, 3, 4, 1, 2, 3},
+			expected: []bool{true, true, true, true, true, false},
+		},
+		{
+			name:     "Too old",
+			seqs:     []uint64{1, replayWindowSize + 1, 2, 1},
+			expected: []bool{true, true, true, false},
+		},
+		{
+			name:     "Large jump",
+			seqs:     []uint64{1, 1000, 999, 1000, 936},
+			expected: []bool{true, true, true, false, false},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			window := &replayWindow{}
+			for i, seq := range test.seqs {
+				assert.Equal(t, test.expected[i], window.accept(seq), "sequence number %d (index %d)", seq, i)
+			}
+		})
+	}
+}
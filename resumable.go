@@ -0,0 +1,250 @@
+package authio
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// verifyStateVersion is the version of the encoding of VerifyStates
+const verifyStateVersion = byte(1)
+
+// VerifyState is the progress of a VerifyMACReader through a stream (see
+// VerifyMACReader.State), with which a new VerifyMACReader can resume reading
+// the stream where the previous one left off (see WithVerifyState), e.g. after
+// an interrupted transfer, without reprocessing already verified data. Since
+// it holds verified data, it must be stored somewhere it cannot be tampered with.
+type VerifyState struct {
+	// Offset is the number of bytes of the stream read, i.e. the
+	// offset in the stream at which the next frame starts
+	Offset int64
+	// Messages is the number of messages verified so far
+	Messages uint64
+	// Pending is the part of the last message verified which was
+	// not returned yet, i.e. which is returned first on resumption
+	Pending []byte
+	// Sequence is the sequence number of the last frame read, if
+	// HasSequence (see authenticator.SequenceTracker)
+	Sequence    uint64
+	HasSequence bool
+	// Closed is whether a close notification was read
+	Closed bool
+}
+
+// ensure VerifyState implements encoding.BinaryMarshaler
+// and encoding.BinaryUnmarshaler at compile-time
+var (
+	_ encoding.BinaryMarshaler   = VerifyState{}
+	_ encoding.BinaryUnmarshaler = (*VerifyState)(nil)
+)
+
+// MarshalBinary encodes the VerifyState, e.g. to persist it
+func (s VerifyState) MarshalBinary() ([]byte, error) {
+	b := []byte{verifyStateVersion, boolByte(s.HasSequence), boolByte(s.Closed)}
+	b = binary.BigEndian.AppendUint64(b, uint64(s.Offset))
+	b = binary.BigEndian.AppendUint64(b, s.Messages)
+	b = binary.BigEndian.AppendUint64(b, s.Sequence)
+	return append(b, s.Pending...), nil
+}
+
+// UnmarshalBinary decodes a VerifyState encoded with MarshalBinary
+func (s *VerifyState) UnmarshalBinary(b []byte) error {
+	if len(b) < 3+3*8 || b[0] != verifyStateVersion {
+		return errors.New("invalid verify state")
+	}
+	*s = VerifyState{
+		HasSequence: b[1] == 1,
+		Closed:      b[2] == 1,
+		Offset:      int64(binary.BigEndian.Uint64(b[3:])),
+		Messages:    binary.BigEndian.Uint64(b[11:]),
+		Sequence:    binary.BigEndian.Uint64(b[19:]),
+		Pending:     append([]byte{}, b[27:]...),
+	}
+	return nil
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// State returns the progress of the VerifyMACReader through the stream, with
+// which a new VerifyMACReader can resume reading it (see WithVerifyState). It
+// fails if configured WithVerifierPool or WithResync, which read ahead of (or
+// skip over) the data returned.
+func (r *VerifyMACReader) State() (VerifyState, error) {
+	if r.pool != nil || r.resync != nil {
+		return VerifyState{}, errors.New("state is not available with verifier pools nor resynchronization")
+	}
+	state := VerifyState{
+		Offset:   r.offset,
+		Messages: r.messages,
+		Pending:  append([]byte{}, r.readReadyBytes...),
+		Closed:   r.closed,
+	}
+	if tracker, ok := r.authenticator.(authenticator.SequenceTracker); ok {
+		state.Sequence, state.HasSequence = tracker.ReadSequence()
+	}
+	return state, nil
+}
+
+// resume restores the given progress of the VerifyMACReader
+func (r *VerifyMACReader) resume(state VerifyState) {
+	r.offset = state.Offset
+	r.messages = state.Messages
+	r.frames = state.Messages
+	r.closed = state.Closed
+	r.setReadReady(append([]byte{}, state.Pending...))
+	if tracker, ok := r.authenticator.(authenticator.SequenceTracker); ok && state.HasSequence {
+		tracker.ResumeReadSequence(state.Sequence)
+	}
+}
+
+// offsetReader counts the bytes read from the
+// underlying reader of a VerifyMACReader
+type offsetReader struct {
+	r *VerifyMACReader
+}
+
+func (o offsetReader) Read(b []byte) (int, error) {
+	n, err := o.r.reader.Read(b)
+	o.r.offset += int64(n)
+	return n, err
+}
+
+// State returns the progress of the DetachedVerifyReader (the number of bytes
+// read and the state of the running MAC), with which a new DetachedVerifyReader
+// can resume reading the rest of the data (see ResumeDetachedVerifyReader). Since
+// the state of the MAC is derived from the key, it must be kept as secret.
+func (r *DetachedVerifyReader) State() ([]byte, error) {
+	return r.hash.(*resumableHMAC).MarshalBinary()
+}
+
+// ResumeDetachedVerifyReader returns a new DetachedVerifyReader which resumes
+// from the given state (see DetachedVerifyReader.State), reading the rest of
+// the data (after the bytes read before the state was exported) from the
+// given reader
+func ResumeDetachedVerifyReader(reader io.Reader, mac string, key []byte, state []byte) (*DetachedVerifyReader, error) {
+	r := NewDetachedVerifyReader(reader, mac, key)
+	if err := r.hash.(*resumableHMAC).UnmarshalBinary(state); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Offset returns the number of bytes read through the DetachedVerifyReader,
+// including those read before its state was exported, if it was resumed
+func (r *DetachedVerifyReader) Offset() int64 {
+	return r.hash.(*resumableHMAC).written
+}
+
+// resumableHMAC is an HMAC (as per RFC 2104) whose state can be exported and
+// imported, which is not the case of crypto/hmac, by exporting the state of
+// the inner hash function (which must implement encoding.BinaryMarshaler)
+type resumableHMAC struct {
+	hashFn  func() hash.Hash
+	inner   hash.Hash
+	opad    []byte // the key xor'ed with the outer pad
+	ipad    []byte // the key xor'ed with the inner pad
+	context []byte // written after the inner pad, e.g. for domain separation
+	written int64  // bytes written, excluding the inner pad and context
+}
+
+// ensure resumableHMAC implements hash.Hash at compile-time
+var _ hash.Hash = (*resumableHMAC)(nil)
+
+func newResumableHMAC(hashFn func() hash.Hash, key []byte) *resumableHMAC {
+	h := &resumableHMAC{hashFn: hashFn, inner: hashFn()}
+	blockSize := h.inner.BlockSize()
+	if len(key) > blockSize {
+		key = hashSum(hashFn, key)
+	}
+	h.ipad = make([]byte, blockSize)
+	h.opad = make([]byte, blockSize)
+	copy(h.ipad, key)
+	copy(h.opad, key)
+	for i := range h.ipad {
+		h.ipad[i] ^= 0x36
+		h.opad[i] ^= 0x5c
+	}
+	h.inner.Write(h.ipad)
+	return h
+}
+
+// detachedMACContext precedes the data covered by detached MACs, such that
+// they are never valid as the MACs of frames (which cover the length of the
+// frame followed by the message) over the same bytes, nor the other way around
+const detachedMACContext = "authio detached v1\x00"
+
+func newDetachedHMAC(key []byte) *resumableHMAC {
+	h := newResumableHMAC(sha256.New, key)
+	h.context = []byte(detachedMACContext)
+	h.inner.Write(h.context)
+	return h
+}
+
+func hashSum(hashFn func() hash.Hash, data []byte) []byte {
+	h := hashFn()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (h *resumableHMAC) Write(p []byte) (int, error) {
+	h.written += int64(len(p))
+	return h.inner.Write(p)
+}
+
+func (h *resumableHMAC) Sum(b []byte) []byte {
+	outer := h.hashFn()
+	outer.Write(h.opad)
+	outer.Write(h.inner.Sum(nil))
+	return outer.Sum(b)
+}
+
+func (h *resumableHMAC) Reset() {
+	h.inner.Reset()
+	h.inner.Write(h.ipad)
+	h.inner.Write(h.context)
+	h.written = 0
+}
+
+func (h *resumableHMAC) Size() int { return h.inner.Size() }
+
+func (h *resumableHMAC) BlockSize() int { return h.inner.BlockSize() }
+
+// MarshalBinary encodes the number of bytes written and the state of the inner hash
+func (h *resumableHMAC) MarshalBinary() ([]byte, error) {
+	marshaler, ok := h.inner.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("state of %T cannot be exported", h.inner)
+	}
+	state, err := marshaler.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export hash state: %w", err)
+	}
+	return append(binary.BigEndian.AppendUint64(nil, uint64(h.written)), state...), nil
+}
+
+// UnmarshalBinary decodes a state encoded with MarshalBinary
+func (h *resumableHMAC) UnmarshalBinary(b []byte) error {
+	unmarshaler, ok := h.inner.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("state of %T cannot be imported", h.inner)
+	}
+	if len(b) < 8 {
+		return errors.New("invalid hash state")
+	}
+	if err := unmarshaler.UnmarshalBinary(b[8:]); err != nil {
+		return fmt.Errorf("failed to import hash state: %w", err)
+	}
+	h.written = int64(binary.BigEndian.Uint64(b))
+	return nil
+}
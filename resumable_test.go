@@ -0,0 +1,141 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_VerifyMACReaderResume(t *testing.T) {
+	mockKey := []byte("mock key")
+	messages := []string{"first message", "second message", "third message"}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Default format"},
+		{name: "CBOR headers", opts: []Option{WithCBORHeaders("key")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := &bytes.Buffer{}
+			w := NewAppendMACWriter(stream, mockKey, test.opts...)
+			for _, message := range messages {
+				_, err := w.Write([]byte(message))
+				assert.NoError(t, err)
+			}
+			data := stream.Bytes()
+
+			// read the first message and part of the second
+			r := NewVerifyMACReader(bytes.NewReader(data), mockKey, test.opts...)
+			message, err := r.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, messages[0], string(message))
+			buf := make([]byte, 6)
+			_, err = r.Read(buf)
+			assert.NoError(t, err)
+			assert.Equal(t, "second", string(buf))
+
+			state, err := r.State()
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(2), state.Messages)
+			encoded, err := state.MarshalBinary()
+			assert.NoError(t, err)
+
+			// resume from the offset of the state
+			var decoded VerifyState
+			assert.NoError(t, decoded.UnmarshalBinary(encoded))
+			assert.Equal(t, state, decoded)
+			resumed := NewVerifyMACReader(bytes.NewReader(data[decoded.Offset:]), mockKey, append(test.opts, WithVerifyState(decoded))...)
+			rest, err := io.ReadAll(resumed)
+			assert.NoError(t, err)
+			assert.Equal(t, " message"+messages[2], string(rest))
+
+			// the rest of the stream is still verified
+			tampered := append([]byte{}, data[decoded.Offset:]...)
+			tampered[len(tampered)-1] ^= 1
+			_, err = io.ReadAll(NewVerifyMACReader(bytes.NewReader(tampered), mockKey, append(test.opts, WithVerifyState(decoded))...))
+			assert.Error(t, err)
+		})
+	}
+
+	// sequence numbers continue from the state, such that
+	// frames before it cannot be replayed after resuming
+	stream := &bytes.Buffer{}
+	w := NewAppendMACWriter(stream, mockKey, WithCBORHeaders("key"))
+	for _, message := range messages {
+		_, err := w.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+	r := NewVerifyMACReader(bytes.NewReader(stream.Bytes()), mockKey, WithCBORHeaders("key"))
+	_, err := r.Next()
+	assert.NoError(t, err)
+	state, err := r.State()
+	assert.NoError(t, err)
+	assert.True(t, state.HasSequence)
+	_, err = NewVerifyMACReader(bytes.NewReader(stream.Bytes()), mockKey, WithCBORHeaders("key"), WithVerifyState(state)).Next()
+	assert.Error(t, err)
+
+	_, err = NewVerifyMACReader(bytes.NewReader(nil), mockKey, WithResync(nil)).State()
+	assert.Error(t, err)
+}
+
+func Test_DetachedVerifyReaderResume(t *testing.T) {
+	mockKey := []byte("mock key")
+	data := bytes.Repeat([]byte("some data to be verified "), 100)
+
+	signer := NewDetachedSignReader(bytes.NewReader(data), mockKey)
+	_, err := io.Copy(io.Discard, signer)
+	assert.NoError(t, err)
+	mac := signer.MAC()
+
+	// interrupt the transfer midway
+	r := NewDetachedVerifyReader(bytes.NewReader(data[:1000]), mac, mockKey)
+	_, err = io.Copy(io.Discard, r)
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch))
+	state, err := r.State()
+	assert.NoError(t, err)
+
+	resumed, err := ResumeDetachedVerifyReader(bytes.NewReader(data[1000:]), mac, mockKey, state)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), resumed.Offset())
+	_, err = io.Copy(io.Discard, resumed)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), resumed.Offset())
+
+	_, err = ResumeDetachedVerifyReader(bytes.NewReader(data[1000:]), mac, mockKey, state[:4])
+	assert.Error(t, err)
+}
+
+func Test_ResumableHMAC(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{name: "Short key", key: []byte("mock key")},
+		{name: "Block size key", key: bytes.Repeat([]byte("k"), sha256.BlockSize)},
+		{name: "Long key", key: bytes.Repeat([]byte("k"), 2*sha256.BlockSize)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expected := hmac.New(sha256.New, test.key)
+			expected.Write([]byte("mock data"))
+
+			h := newResumableHMAC(sha256.New, test.key)
+			h.Write([]byte("mock "))
+			state, err := h.MarshalBinary()
+			assert.NoError(t, err)
+			resumed := newResumableHMAC(sha256.New, test.key)
+			assert.NoError(t, resumed.UnmarshalBinary(state))
+			resumed.Write([]byte("data"))
+			assert.Equal(t, expected.Sum(nil), resumed.Sum(nil))
+		})
+	}
+}
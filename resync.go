@@ -0,0 +1,130 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// resyncReader reads frames for a VerifyMACReader configured WithResync,
+// scanning forward (one byte at a time) past corrupted data until it finds
+// a valid frame. Since frames have no sync marker, a frame is considered
+// found once its MAC is valid.
+type resyncReader struct {
+	r      *VerifyMACReader
+	buf    []byte // read but not yet consumed
+	eof    bool
+	onSkip func(skipped int64, cause error)
+
+	// frameOffset is the offset in the stream of the frame last read (or
+	// which failed to be read), past any data skipped over before it
+	frameOffset int64
+}
+
+// fill reads until at least n bytes are buffered, returning false if
+// the underlying reader ends before that
+func (s *resyncReader) fill(n int) (bool, error) {
+	for len(s.buf) < n && !s.eof {
+		chunk := make([]byte, n-len(s.buf))
+		m, err := s.r.reader.Read(chunk)
+		s.buf = append(s.buf, chunk[:m]...)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return false, err
+			}
+			s.eof = true
+		}
+	}
+	return len(s.buf) >= n, nil
+}
+
+// next reads the next valid frame
+func (s *resyncReader) next() (frame, error) {
+	skipped := int64(0)
+	var cause error
+
+	for {
+		f, size, err := s.try()
+		s.frameOffset = s.r.offset
+		if err == nil || errors.Is(err, authenticator.ErrCloseNotify) {
+			s.buf = s.buf[size:]
+			s.r.offset += int64(size)
+			if skipped > 0 {
+				s.skipped(skipped, cause)
+			}
+			return f, err
+		}
+		if errors.Is(err, io.EOF) {
+			// whatever is left can never be a valid frame
+			skipped += int64(len(s.buf))
+			s.r.offset += int64(len(s.buf))
+			s.buf = nil
+			if cause == nil {
+				cause = io.ErrUnexpectedEOF
+			}
+			if skipped > 0 {
+				s.skipped(skipped, cause)
+			}
+			return frame{}, io.EOF
+		}
+		if size < 0 {
+			// failed to read from the underlying reader (not a corrupted frame)
+			return frame{}, err
+		}
+		if cause == nil {
+			cause = err
+		}
+		s.buf = s.buf[1:]
+		s.r.offset++
+		skipped++
+	}
+}
+
+// try reads and verifies a frame at the start of the buffer, returning its
+// size, or a negative size if the error is not due to a corrupted frame
+func (s *resyncReader) try() (frame, int, error) {
+	headerLen := s.r.authHeaderLen
+	ok, err := s.fill(headerLen)
+	if err != nil {
+		return frame{}, -1, err
+	}
+	if !ok {
+		return frame{}, 0, io.EOF
+	}
+
+	info, err := authenticator.ParseFrameHeader(s.buf[:headerLen])
+	if err != nil {
+		return frame{}, 0, err
+	}
+	size := headerLen + int(info.PayloadLength)
+	if info.CloseNotify {
+		size = headerLen
+	} else if s.r.maxMessageLen > 0 && info.PayloadLength > uint64(s.r.maxMessageLen) {
+		return frame{}, 0, errors.New("message too large")
+	}
+
+	if ok, err = s.fill(size); err != nil {
+		return frame{}, -1, err
+	}
+	if !ok {
+		// the frame is truncated, but its header may be corrupted instead
+		return frame{}, 0, errors.New("truncated frame")
+	}
+
+	f, err := s.r.readFrame(bytes.NewReader(s.buf[:size]))
+	if errors.Is(err, authenticator.ErrDestroyed) {
+		return frame{}, -1, err
+	}
+	return f, size, err
+}
+
+// skipped reports bytes skipped over to find a valid frame
+func (s *resyncReader) skipped(n int64, cause error) {
+	s.r.metrics.VerificationFailed()
+	s.r.logger.Warn("skipped corrupted data", "skipped_bytes", n, "error", cause)
+	if s.onSkip != nil {
+		s.onSkip(n, cause)
+	}
+}
@@ -0,0 +1,91 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Resync(t *testing.T) {
+	mockKey := []byte("mock key")
+	frameLen := authenticator.HeaderLength(sha256.New) + len("message 1")
+
+	tests := []struct {
+		name          string
+		corrupt       func(frames []byte) []byte
+		expected      []string
+		expectSkipped []int64
+	}{
+		{
+			name:     "No corruption",
+			corrupt:  func(b []byte) []byte { return b },
+			expected: []string{"message 0", "message 1", "message 2"},
+		},
+		{
+			name: "Corrupted message",
+			corrupt: func(b []byte) []byte {
+				b[frameLen+frameLen-1] ^= 0xff
+				return b
+			},
+			expected:      []string{"message 0", "message 2"},
+			expectSkipped: []int64{int64(frameLen)},
+		},
+		{
+			name: "Corrupted length field",
+			corrupt: func(b []byte) []byte {
+				b[frameLen+frameLen-len("message 1")-1] ^= 0xff
+				return b
+			},
+			expected:      []string{"message 0", "message 2"},
+			expectSkipped: []int64{int64(frameLen)},
+		},
+		{
+			name: "Garbage between messages",
+			corrupt: func(b []byte) []byte {
+				return append(append(append([]byte{}, b[:frameLen]...), "garbage"...), b[frameLen:]...)
+			},
+			expected:      []string{"message 0", "message 1", "message 2"},
+			expectSkipped: []int64{int64(len("garbage"))},
+		},
+		{
+			name:          "Trailing garbage",
+			corrupt:       func(b []byte) []byte { return append(b, "garbage"...) },
+			expected:      []string{"message 0", "message 1", "message 2"},
+			expectSkipped: []int64{int64(len("garbage"))},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer := NewAppendMACWriter(buf, mockKey)
+			for _, message := range []string{"message 0", "message 1", "message 2"} {
+				_, err := writer.Write([]byte(message))
+				assert.Nil(t, err)
+			}
+
+			skipped := []int64{}
+			onSkip := func(n int64, cause error) {
+				assert.NotNil(t, cause)
+				skipped = append(skipped, n)
+			}
+			reader := NewVerifyMACReader(bytes.NewReader(test.corrupt(buf.Bytes())), mockKey, WithResync(onSkip))
+
+			for _, expected := range test.expected {
+				message, err := reader.Next()
+				assert.Nil(t, err)
+				assert.Equal(t, expected, string(message))
+			}
+			_, err := reader.Next()
+			assert.True(t, errors.Is(err, io.EOF))
+			if test.expectSkipped == nil {
+				test.expectSkipped = []int64{}
+			}
+			assert.Equal(t, test.expectSkipped, skipped)
+		})
+	}
+}
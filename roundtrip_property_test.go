@@ -0,0 +1,177 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+// roundTripFormats are the frame formats round trips are checked with
+var roundTripFormats = [][]Option{
+	{},
+	{WithCBORHeaders("key-1")},
+	{WithMACEncoding(authenticator.Hex), WithTagSize(16)},
+	{WithChecksum()},
+}
+
+// roundTripCase is an arbitrary round trip of messages through a writer and
+// a reader: the sizes of messages, the max message size (i.e. how messages
+// are split into frames), the chunks in which the stream is read, and the
+// size of read buffers are all random
+type roundTripCase struct {
+	Messages       [][]byte
+	MaxMessageSize int // zero means the default
+	ChunkSize      int // max bytes returned per read of the underlying reader
+	BufferSize     int
+	Format         int // index in roundTripFormats
+}
+
+// Generate returns a random roundTripCase (see quick.Generator)
+func (roundTripCase) Generate(rand *rand.Rand, size int) reflect.Value {
+	c := roundTripCase{
+		Messages:   make([][]byte, rand.Intn(10)),
+		ChunkSize:  1 + rand.Intn(300),
+		BufferSize: 1 + rand.Intn(300),
+		Format:     rand.Intn(len(roundTripFormats)),
+	}
+	if rand.Intn(2) == 0 {
+		c.MaxMessageSize = 1 + rand.Intn(512)
+	}
+	for i := range c.Messages {
+		switch rand.Intn(3) {
+		case 0:
+			c.Messages[i] = []byte{}
+		case 1:
+			c.Messages[i] = make([]byte, rand.Intn(64))
+		default:
+			c.Messages[i] = make([]byte, rand.Intn(4096))
+		}
+		rand.Read(c.Messages[i])
+	}
+	return reflect.ValueOf(c)
+}
+
+func (c roundTripCase) opts() []Option {
+	opts := append([]Option{}, roundTripFormats[c.Format]...)
+	if c.MaxMessageSize > 0 {
+		opts = append(opts, WithMaxMessageSize(c.MaxMessageSize))
+	}
+	return opts
+}
+
+// frames returns the messages as written, i.e. split in frames of up to
+// the max message size (messages are never split by default)
+func (c roundTripCase) frames() [][]byte {
+	frames := [][]byte{}
+	for _, message := range c.Messages {
+		if c.MaxMessageSize == 0 || len(message) <= c.MaxMessageSize {
+			frames = append(frames, message)
+			continue
+		}
+		for len(message) > 0 {
+			size := len(message)
+			if size > c.MaxMessageSize {
+				size = c.MaxMessageSize
+			}
+			frames = append(frames, message[:size])
+			message = message[size:]
+		}
+	}
+	return frames
+}
+
+// sign returns the stream of frames written for the messages
+func (c roundTripCase) sign(key []byte) ([]byte, error) {
+	signed := &bytes.Buffer{}
+	w := NewAppendMACWriter(signed, key, c.opts()...)
+	for _, message := range c.Messages {
+		if _, err := w.Write(message); err != nil {
+			return nil, err
+		}
+	}
+	return signed.Bytes(), nil
+}
+
+// chunkedReader returns at most size bytes per read
+type chunkedReader struct {
+	r    io.Reader
+	size int
+}
+
+func (c chunkedReader) Read(b []byte) (int, error) {
+	if len(b) > c.size {
+		b = b[:c.size]
+	}
+	return c.r.Read(b)
+}
+
+func Test_RoundTripProperties(t *testing.T) {
+	mockKey := []byte("mock key")
+	config := &quick.Config{MaxCount: 300}
+
+	// reads reproduce the exact byte stream written
+	// regardless of chunking and read buffer sizes
+	stream := func(c roundTripCase) bool {
+		signed, err := c.sign(mockKey)
+		if err != nil {
+			return false
+		}
+		r := NewVerifyMACReader(chunkedReader{r: bytes.NewReader(signed), size: c.ChunkSize}, mockKey, c.opts()...)
+		read := []byte{}
+		buf := make([]byte, c.BufferSize)
+		for {
+			n, err := r.Read(buf)
+			read = append(read, buf[:n]...)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return false
+			}
+		}
+		return bytes.Equal(bytes.Join(c.Messages, nil), read) && r.frames == uint64(len(c.frames()))
+	}
+	assert.NoError(t, quick.Check(stream, config))
+
+	// every frame written is read back as a message of its own
+	frames := func(c roundTripCase) bool {
+		signed, err := c.sign(mockKey)
+		if err != nil {
+			return false
+		}
+		r := NewVerifyMACReader(chunkedReader{r: bytes.NewReader(signed), size: c.ChunkSize}, mockKey, c.opts()...)
+		for _, expected := range c.frames() {
+			message, err := r.Next()
+			if err != nil || !bytes.Equal(expected, message) {
+				return false
+			}
+		}
+		_, err = r.Next()
+		return errors.Is(err, io.EOF) && r.messages == uint64(len(c.frames()))
+	}
+	assert.NoError(t, quick.Check(frames, config))
+
+	// any single bit flipped anywhere in the stream fails verification
+	tampered := func(c roundTripCase, position uint32, bit uint8) bool {
+		signed, err := c.sign(mockKey)
+		if err != nil || len(signed) == 0 || c.Format == 3 {
+			// checksums only detect corruption, which is tested elsewhere
+			return true
+		}
+		signed[int(position)%len(signed)] ^= 1 << (bit % 8)
+		r := NewVerifyMACReader(bytes.NewReader(signed), mockKey, c.opts()...)
+		for {
+			if _, err := r.Next(); err != nil {
+				return !errors.Is(err, io.EOF)
+			}
+		}
+	}
+	assert.NoError(t, quick.Check(tampered, config))
+}
@@ -0,0 +1,81 @@
+package authio
+
+import (
+	"errors"
+	"net"
+)
+
+const (
+	// SessionNonceSize is the size of the nonces exchanged by session
+	// handshakes, session IDs are made of the nonces of both peers
+	SessionNonceSize = 16
+
+	// sessionLabel prefixes the session ID in the associated data of
+	// session Conns, ahead of any set with WithAssociatedData
+	sessionLabel = "authio session"
+)
+
+// ErrSessionHandshakeFailed is returned (wrapped) by NewSessionClientConn and
+// NewSessionServerConn when the peer runs another handshake (or another
+// version of it)
+var ErrSessionHandshakeFailed = errors.New("session handshake failed")
+
+// NewSessionClientConn exchanges random nonces with the server side of a
+// connection, and wraps it in a Conn (like NewClientConn) which binds the
+// resulting session ID into the MAC of every message, such that messages
+// captured from one connection cannot be spliced into another one using the
+// same key. Since both peers contribute to the session ID, neither can whole
+// connections be replayed. The peer must use NewSessionServerConn. Deadlines
+// set on the connection apply to the handshake, unless configured
+// WithHandshakeTimeout. Conns keyed with per-connection session keys
+// (e.g. NewPSKClientConn) need no session ID.
+func NewSessionClientConn(conn net.Conn, key []byte, opts ...Option) (*Conn, error) {
+	return runHandshake(conn, newConfig(opts...).handshakeTimeout, func() (*Conn, error) {
+		return sessionClientHandshake(conn, key, opts...)
+	})
+}
+
+// NewSessionServerConn exchanges random nonces with the client side of a
+// connection, and wraps it in a Conn (like NewServerConn) which binds the
+// resulting session ID into the MAC of every message (see NewSessionClientConn)
+func NewSessionServerConn(conn net.Conn, key []byte, opts ...Option) (*Conn, error) {
+	return runHandshake(conn, newConfig(opts...).handshakeTimeout, func() (*Conn, error) {
+		return sessionServerHandshake(conn, key, opts...)
+	})
+}
+
+func sessionClientHandshake(conn net.Conn, key []byte, opts ...Option) (*Conn, error) {
+	hello, err := sessionHandshakeProtocol.exchangeNonces(conn, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	sessionID := append(hello.clientNonce, hello.serverNonce...)
+	c := NewClientConn(conn, key, withSessionID(sessionID, opts)...)
+	c.sessionID = sessionID
+	return c, nil
+}
+
+func sessionServerHandshake(conn net.Conn, key []byte, opts ...Option) (*Conn, error) {
+	hello, err := sessionHandshakeProtocol.exchangeNonces(conn, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	sessionID := append(hello.clientNonce, hello.serverNonce...)
+	c := NewServerConn(conn, key, withSessionID(sessionID, opts)...)
+	c.sessionID = sessionID
+	return c, nil
+}
+
+// SessionID returns the session ID bound into the MACs of the messages of
+// the Conn (see NewSessionClientConn), if any
+func (c *Conn) SessionID() []byte {
+	return append([]byte(nil), c.sessionID...)
+}
+
+// withSessionID returns the given options followed by one binding the
+// session ID (ahead of any other associated data) into every MAC
+func withSessionID(sessionID []byte, opts []Option) []Option {
+	aad := append([]byte(sessionLabel), sessionID...)
+	aad = append(aad, newConfig(opts...).aad...)
+	return append(append([]Option{}, opts...), WithAssociatedData(aad))
+}
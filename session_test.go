@@ -0,0 +1,75 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+// sessionHandshake performs a session handshake over a pipe, returning the
+// Conns and the client side of the pipe (to write spliced frames to)
+func sessionHandshake(t *testing.T, key []byte) (client, server *Conn, raw net.Conn) {
+	clientConn, serverConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var err error
+		server, err = NewSessionServerConn(serverConn, key)
+		assert.NoError(t, err)
+	}()
+	client, err := NewSessionClientConn(clientConn, key)
+	assert.NoError(t, err)
+	<-done
+	return client, server, clientConn
+}
+
+func Test_SessionConn(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	client, server, _ := sessionHandshake(t, mockKey)
+	defer client.Close()
+	defer server.Close()
+	assert.Equal(t, 2*SessionNonceSize, len(client.SessionID()))
+	assert.Equal(t, client.SessionID(), server.SessionID())
+
+	go func() {
+		_, err := client.Write([]byte("hello"))
+		assert.NoError(t, err)
+	}()
+	buf := make([]byte, 16)
+	n, err := server.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+
+	// every connection has its own session ID
+	otherClient, otherServer, otherRaw := sessionHandshake(t, mockKey)
+	defer otherClient.Close()
+	defer otherServer.Close()
+	assert.NotEqual(t, client.SessionID(), otherClient.SessionID())
+
+	// frames of one connection cannot be spliced into another one
+	spliced := &bytes.Buffer{}
+	aad := directionalAAD(directionClientToServer, append([]byte(sessionLabel), client.SessionID()...))
+	_, err = NewAppendMACWriter(spliced, mockKey, WithAssociatedData(aad)).Write([]byte("spliced"))
+	assert.NoError(t, err)
+	go func() {
+		_, err := otherRaw.Write(spliced.Bytes())
+		assert.NoError(t, err)
+	}()
+	_, err = otherServer.Read(buf)
+	assert.True(t, errors.Is(err, authenticator.ErrMACMismatch), "expected %v, got %v", authenticator.ErrMACMismatch, err)
+}
+
+func Test_SessionHandshakeVersion(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		_, _ = clientConn.Write(append([]byte{sessionHandshakeID, sessionHandshakeProtocol.version + 1}, make([]byte, SessionNonceSize)...))
+	}()
+	_, err := NewSessionServerConn(serverConn, []byte("mock key"))
+	assert.True(t, errors.Is(err, ErrSessionHandshakeFailed))
+}
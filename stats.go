@@ -0,0 +1,75 @@
+package authio
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/adrianosela/authio/metrics"
+)
+
+// ConnStats is a snapshot of the statistics of a Conn
+type ConnStats struct {
+	// BytesIn and BytesOut are the sizes of the verified and signed
+	// messages respectively, excluding MAC headers and control frames
+	BytesIn  uint64
+	BytesOut uint64
+
+	// FramesIn and FramesOut are the numbers of verified
+	// and signed messages respectively
+	FramesIn  uint64
+	FramesOut uint64
+
+	// VerificationFailures is the number of messages which failed verification
+	VerificationFailures uint64
+
+	// LastRekey is the time the current key of the Conn came into use.
+	// Conns are not rekeyed, so this is the time the Conn was created.
+	LastRekey time.Time
+
+	// KeyID is the ID of the key set with WithKeyProvider, if any
+	KeyID string
+}
+
+// connStats is a metrics.Metrics implementation which
+// counts the events of a Conn for Conn.Stats
+type connStats struct {
+	bytesIn              uint64 // accessed atomically
+	bytesOut             uint64 // accessed atomically
+	framesIn             uint64 // accessed atomically
+	framesOut            uint64 // accessed atomically
+	verificationFailures uint64 // accessed atomically
+}
+
+// ensure connStats implements metrics.Metrics at compile-time
+var _ metrics.Metrics = (*connStats)(nil)
+
+// MessageSigned counts a message written
+func (s *connStats) MessageSigned(size int) {
+	atomic.AddUint64(&s.framesOut, 1)
+	atomic.AddUint64(&s.bytesOut, uint64(size))
+}
+
+// MessageVerified counts a message read
+func (s *connStats) MessageVerified(size int) {
+	atomic.AddUint64(&s.framesIn, 1)
+	atomic.AddUint64(&s.bytesIn, uint64(size))
+}
+
+// VerificationFailed counts a message which failed verification
+func (s *connStats) VerificationFailed() {
+	atomic.AddUint64(&s.verificationFailures, 1)
+}
+
+// Stats returns a snapshot of the statistics of the Conn. It is
+// safe to call concurrently with reads and writes.
+func (c *Conn) Stats() ConnStats {
+	return ConnStats{
+		BytesIn:              atomic.LoadUint64(&c.stats.bytesIn),
+		BytesOut:             atomic.LoadUint64(&c.stats.bytesOut),
+		FramesIn:             atomic.LoadUint64(&c.stats.framesIn),
+		FramesOut:            atomic.LoadUint64(&c.stats.framesOut),
+		VerificationFailures: atomic.LoadUint64(&c.stats.verificationFailures),
+		LastRekey:            c.created,
+		KeyID:                c.keyID,
+	}
+}
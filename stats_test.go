@@ -0,0 +1,49 @@
+package authio
+
+import (
+	"net"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_ConnStats(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	client := NewClientConn(a, mockKey, WithKeyProvider(StaticKey(mockKey), "mock-key-id"))
+	server := NewServerConn(b, mockKey)
+
+	go func() {
+		client.Write([]byte("hello"))
+		client.Write([]byte("world!"))
+	}()
+
+	buf := make([]byte, 64)
+	for i := 0; i < 2; i++ {
+		_, err := server.Read(buf)
+		assert.Nil(t, err)
+	}
+
+	clientStats := client.Stats()
+	assert.Equal(t, uint64(11), clientStats.BytesOut)
+	assert.Equal(t, uint64(2), clientStats.FramesOut)
+	assert.Equal(t, uint64(0), clientStats.FramesIn)
+	assert.Equal(t, "mock-key-id", clientStats.KeyID)
+	assert.False(t, clientStats.LastRekey.IsZero())
+
+	serverStats := server.Stats()
+	assert.Equal(t, uint64(11), serverStats.BytesIn)
+	assert.Equal(t, uint64(2), serverStats.FramesIn)
+	assert.Equal(t, uint64(0), serverStats.VerificationFailures)
+	assert.Equal(t, "", serverStats.KeyID)
+
+	// a message from the wrong direction fails verification
+	go NewServerConn(a, mockKey).Write([]byte("reflected"))
+	_, err := server.Read(buf)
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(1), server.Stats().VerificationFailures)
+}
@@ -0,0 +1,102 @@
+package authio
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// errDeadlinesNotSupported is returned when setting deadlines on
+// Conns over streams which do not support them
+var errDeadlinesNotSupported = errors.New("deadlines are not supported by the underlying stream")
+
+// NewClientStreamConn wraps the client side of a stream which is not a net.Conn
+// (e.g. an ssh.Channel, or a stream of a multiplexer) in a Conn which binds the
+// direction of every message into its MAC (see NewClientConn). Deadlines (and
+// thereby read and write timeouts) are only supported if the stream supports
+// them, and the addresses of the Conn are those of the stream if it has any.
+func NewClientStreamConn(stream io.ReadWriteCloser, key []byte, opts ...Option) *Conn {
+	return NewClientConn(&streamConn{stream: stream}, key, opts...)
+}
+
+// NewServerStreamConn wraps the server side of a stream which is not a net.Conn
+// in a Conn which binds the direction of every message into its MAC (see
+// NewClientStreamConn and NewServerConn)
+func NewServerStreamConn(stream io.ReadWriteCloser, key []byte, opts ...Option) *Conn {
+	return NewServerConn(&streamConn{stream: stream}, key, opts...)
+}
+
+// NewStreamConn wraps a stream which is not a net.Conn in a Conn, without
+// direction binding (see NewClientStreamConn).
+//
+// Deprecated: like NewConn, it lets a peer reflect messages back. Use
+// NewClientStreamConn or NewServerStreamConn instead, with
+// WithoutDirectionBinding to opt out of direction binding explicitly.
+func NewStreamConn(stream io.ReadWriteCloser, key []byte, opts ...Option) *Conn {
+	return newSymmetricConn(&streamConn{stream: stream}, key, opts...)
+}
+
+// streamConn adapts an io.ReadWriteCloser to a net.Conn
+type streamConn struct {
+	stream io.ReadWriteCloser
+}
+
+// ensure streamConn implements net.Conn at compile-time
+var _ net.Conn = (*streamConn)(nil)
+
+func (s *streamConn) Read(b []byte) (int, error) { return s.stream.Read(b) }
+
+func (s *streamConn) Write(b []byte) (int, error) { return s.stream.Write(b) }
+
+func (s *streamConn) Close() error { return s.stream.Close() }
+
+// CloseWrite half-closes the stream, if it supports it (e.g. ssh.Channel)
+func (s *streamConn) CloseWrite() error {
+	if cw, ok := s.stream.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("half-close is not supported by the underlying stream")
+}
+
+func (s *streamConn) LocalAddr() net.Addr {
+	if a, ok := s.stream.(interface{ LocalAddr() net.Addr }); ok {
+		return a.LocalAddr()
+	}
+	return streamAddr{}
+}
+
+func (s *streamConn) RemoteAddr() net.Addr {
+	if a, ok := s.stream.(interface{ RemoteAddr() net.Addr }); ok {
+		return a.RemoteAddr()
+	}
+	return streamAddr{}
+}
+
+func (s *streamConn) SetDeadline(t time.Time) error {
+	if d, ok := s.stream.(interface{ SetDeadline(time.Time) error }); ok {
+		return d.SetDeadline(t)
+	}
+	return errDeadlinesNotSupported
+}
+
+func (s *streamConn) SetReadDeadline(t time.Time) error {
+	if d, ok := s.stream.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	return errDeadlinesNotSupported
+}
+
+func (s *streamConn) SetWriteDeadline(t time.Time) error {
+	if d, ok := s.stream.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return errDeadlinesNotSupported
+}
+
+// streamAddr is the net.Addr of streams without addresses
+type streamAddr struct{}
+
+func (streamAddr) Network() string { return "stream" }
+
+func (streamAddr) String() string { return "stream" }
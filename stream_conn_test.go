@@ -0,0 +1,139 @@
+package authio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+// mockStream is an io.ReadWriteCloser which is not a net.Conn, which
+// can be half-closed like an ssh.Channel
+type mockStream struct {
+	io.Reader
+	w *io.PipeWriter
+}
+
+func (s *mockStream) Write(b []byte) (int, error) { return s.w.Write(b) }
+
+func (s *mockStream) Close() error { return s.w.Close() }
+
+func (s *mockStream) CloseWrite() error { return s.w.Close() }
+
+func mockStreamPair() (*mockStream, *mockStream) {
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+	return &mockStream{Reader: ar, w: bw}, &mockStream{Reader: br, w: aw}
+}
+
+func Test_StreamConn(t *testing.T) {
+	mockKey := []byte("mock key")
+	a, b := mockStreamPair()
+	client, server := NewClientStreamConn(a, mockKey), NewServerStreamConn(b, mockKey)
+
+	assert.Equal(t, "stream", client.RemoteAddr().String())
+	assert.True(t, errors.Is(client.SetDeadline(time.Now()), errDeadlinesNotSupported))
+
+	go func() {
+		client.Write([]byte("mock message"))
+		client.CloseWrite()
+	}()
+	data, err := io.ReadAll(server)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock message", string(data))
+
+	// the other direction is still open after a half-close
+	go server.Write([]byte("mock reply"))
+	buf := make([]byte, len("mock reply"))
+	_, err = io.ReadFull(client, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock reply", string(buf))
+}
+
+// Test_ConnMultiplexerAssumptions tests what stream multiplexers (e.g. smux,
+// yamux) wrapping a Conn rely on: that concurrent writes are never interleaved
+// (every Write is a single message written at once), and that reads of any
+// size return the byte stream in order
+func Test_ConnMultiplexerAssumptions(t *testing.T) {
+	client, server := ConnPipe([]byte("mock key"))
+	defer client.Close()
+	defer server.Close()
+
+	const writers, writes = 8, 50
+	go func() {
+		wg := sync.WaitGroup{}
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < writes; j++ {
+					n, err := client.Write([]byte(fmt.Sprintf("writer %d write %03d;", i, j)))
+					assert.NoError(t, err)
+					assert.Equal(t, len(fmt.Sprintf("writer %d write %03d;", i, j)), n)
+				}
+			}(i)
+		}
+		wg.Wait()
+	}()
+
+	// every message is a whole write
+	next := make([]int, writers)
+	for k := 0; k < writers*writes; k++ {
+		message, _, err := server.NextWithExtensions()
+		assert.NoError(t, err)
+		var i, j int
+		_, err = fmt.Sscanf(string(message), "writer %d write %03d;", &i, &j)
+		assert.NoError(t, err)
+		assert.Equal(t, next[i], j, "writes of a writer are in order")
+		next[i]++
+	}
+
+	// reads smaller than messages return them in order
+	go client.Write([]byte("0123456789"))
+	buf := make([]byte, 3)
+	data := []byte{}
+	for len(data) < 10 {
+		n, err := server.Read(buf)
+		assert.NoError(t, err)
+		data = append(data, buf[:n]...)
+	}
+	assert.Equal(t, "0123456789", string(data))
+}
+
+func Test_ConnCloseWrite(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	mockKey := []byte("mock key")
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		server := NewServerConn(conn, mockKey, WithCloseNotify())
+		defer server.Close()
+		io.Copy(server, server) // echo until the client is done writing
+		server.CloseWrite()
+	}()
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	client := NewClientConn(raw, mockKey, WithCloseNotify())
+	defer client.Close()
+
+	_, err = client.Write([]byte("mock message"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.CloseWrite())
+	_, err = client.Write([]byte("after close"))
+	assert.Error(t, err)
+
+	data, err := io.ReadAll(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock message", string(data))
+}
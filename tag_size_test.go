@@ -0,0 +1,81 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WithTagSize(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name              string
+		tagSize           int
+		expectedHeaderLen int
+		expectError       bool
+	}{
+		{
+			name:              "No truncation",
+			tagSize:           0,
+			expectedHeaderLen: authenticator.HeaderLength(sha256.New),
+		},
+		{
+			name:              "128 bit tags",
+			tagSize:           16,
+			expectedHeaderLen: 8 + 24,
+		},
+		{
+			name:        "Less than half the hash size",
+			tagSize:     15,
+			expectError: true,
+		},
+		{
+			name:        "Larger than the hash size",
+			tagSize:     33,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			_, err := NewAppendMACWriter(buf, mockKey, WithTagSize(test.tagSize)).Write([]byte("hello"))
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedHeaderLen+len("hello"), buf.Len())
+
+			msg, err := NewVerifyMACReader(bytes.NewReader(buf.Bytes()), mockKey, WithTagSize(test.tagSize)).Next()
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(msg))
+		})
+	}
+}
+
+func Test_PacketConnWithTagSize(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	a, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	b, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	sender := NewPacketConn(a, mockKey, WithTagSize(16))
+	receiver := NewPacketConn(b, mockKey, WithTagSize(16))
+	defer sender.Close()
+	defer receiver.Close()
+
+	_, err = sender.WriteTo([]byte("hello"), b.LocalAddr())
+	assert.NoError(t, err)
+
+	buf := make([]byte, 64)
+	n, _, err := receiver.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+}
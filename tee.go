@@ -0,0 +1,63 @@
+package authio
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// MessageReader reads verified messages one at a time, e.g. a
+// VerifyMACReader, a Reader, or a Conn
+type MessageReader interface {
+	NextWithExtensions() ([]byte, []authenticator.Extension, error)
+}
+
+// ensure the readers of the package implement MessageReader at compile-time
+var (
+	_ MessageReader = (*VerifyMACReader)(nil)
+	_ MessageReader = (*Conn)(nil)
+)
+
+// teeReader is the io.Reader returned by TeeReader
+type teeReader struct {
+	reader  MessageReader
+	writer  io.Writer
+	pending []byte // rest of the last message, already written to writer
+}
+
+// TeeReader returns an io.Reader which reads the verified messages of the
+// given reader, and writes every one of them to w (as a single write) before
+// returning any of its bytes, such that w only ever receives verified
+// plaintext, at message boundaries (e.g. to fan it out with io.MultiWriter).
+// Errors writing to w are returned as read errors.
+func TeeReader(reader MessageReader, w io.Writer) io.Reader {
+	return &teeReader{reader: reader, writer: w}
+}
+
+// Read reads data onto the given buffer
+func (t *teeReader) Read(b []byte) (int, error) {
+	if len(t.pending) == 0 {
+		message, _, err := t.reader.NextWithExtensions()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := t.writer.Write(message); err != nil {
+			return 0, fmt.Errorf("failed to write message to tee writer: %w", err)
+		}
+		t.pending = message
+	}
+	n := copy(b, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// MultiWriter returns a Writer which writes every authenticated message to
+// all of the given writers, like io.MultiWriter, such that its MAC is computed
+// once regardless of the number of destinations. As with io.MultiWriter, a
+// write stops at the first writer which fails, after which the destinations
+// may no longer be at a message boundary. Use NewWriter with io.MultiWriter
+// for a Writer with options.
+func MultiWriter(key []byte, writers ...io.Writer) *Writer {
+	return NewWriter(io.MultiWriter(writers...), key)
+}
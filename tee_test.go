@@ -0,0 +1,115 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+// errMockWrite is the error of failing test writers
+var errMockWrite = errors.New("mock write error")
+
+// failingWriter is an io.Writer which always fails
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errMockWrite }
+
+// recordingWriter is an io.Writer which records every write
+type recordingWriter struct {
+	writes []string
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.writes = append(w.writes, string(b))
+	return len(b), nil
+}
+
+func Test_TeeReader(t *testing.T) {
+	mockKey := []byte("mock key")
+	signed := bytes.NewBuffer(nil)
+	writer := NewWriter(signed, mockKey)
+	writer.Write([]byte("first message"))
+	writer.Write([]byte("second message"))
+	tampered := append([]byte(nil), signed.Bytes()...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name           string
+		stream         []byte
+		tee            io.Writer
+		expectedRead   string
+		expectedWrites []string
+		expectError    bool
+	}{
+		{
+			name:           "Valid stream",
+			stream:         signed.Bytes(),
+			expectedRead:   "first messagesecond message",
+			expectedWrites: []string{"first message", "second message"},
+		},
+		{
+			name:           "Tampered message is not written",
+			stream:         tampered,
+			expectedRead:   "first message",
+			expectedWrites: []string{"first message"},
+			expectError:    true,
+		},
+		{
+			name:        "Failing tee writer",
+			stream:      signed.Bytes(),
+			tee:         failingWriter{},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writes := &recordingWriter{}
+			tee := test.tee
+			if tee == nil {
+				tee = writes
+			}
+			reader := TeeReader(NewReader(bytes.NewReader(test.stream), mockKey), tee)
+
+			// reads smaller than messages write whole messages to the tee
+			read := bytes.NewBuffer(nil)
+			buf := make([]byte, 5)
+			var err error
+			for {
+				var n int
+				n, err = reader.Read(buf)
+				read.Write(buf[:n])
+				if err != nil {
+					break
+				}
+			}
+			if test.expectError {
+				assert.False(t, errors.Is(err, io.EOF))
+			} else {
+				assert.True(t, errors.Is(err, io.EOF))
+			}
+			assert.Equal(t, test.expectedRead, read.String())
+			assert.Equal(t, test.expectedWrites, writes.writes)
+		})
+	}
+}
+
+func Test_MultiWriter(t *testing.T) {
+	mockKey := []byte("mock key")
+	a, b := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	expected := bytes.NewBuffer(nil)
+
+	_, err := MultiWriter(mockKey, a, b).Write([]byte("mock message"))
+	assert.NoError(t, err)
+	_, err = NewWriter(expected, mockKey).Write([]byte("mock message"))
+	assert.NoError(t, err)
+
+	// every destination receives the same frame
+	assert.Equal(t, expected.Bytes(), a.Bytes())
+	assert.Equal(t, expected.Bytes(), b.Bytes())
+
+	_, err = MultiWriter(mockKey, a, failingWriter{}).Write([]byte("mock message"))
+	assert.Error(t, err)
+}
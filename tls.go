@@ -0,0 +1,55 @@
+package authio
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+const (
+	// TLSExporterLabel is the label (see RFC 5705 and RFC 8446) keys
+	// are exported from TLS sessions with (see ExportTLSKeys)
+	TLSExporterLabel = "EXPORTER-authio"
+
+	// tlsExportedKeySize is the size of every key exported from TLS sessions
+	tlsExportedKeySize = 32
+)
+
+// ExportTLSKeys derives the keys of either direction of an authio session
+// bound to the given TLS session, through its keying material exporter (see
+// tls.ConnectionState.ExportKeyingMaterial), performing the TLS handshake
+// first if it was not done yet. Both peers derive the same keys, which are
+// unique to the TLS session. Note that with TLS 1.2, exporting keys requires
+// the extended master secret extension (RFC 7627).
+func ExportTLSKeys(conn *tls.Conn) (clientToServer, serverToClient []byte, err error) {
+	if err := conn.Handshake(); err != nil {
+		return nil, nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	state := conn.ConnectionState()
+	material, err := state.ExportKeyingMaterial(TLSExporterLabel, nil, 2*tlsExportedKeySize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to export keying material: %w", err)
+	}
+	return material[:tlsExportedKeySize], material[tlsExportedKeySize:], nil
+}
+
+// NewTLSClientConn wraps the client side of a TLS connection in a Conn keyed
+// with keys exported from the TLS session (see ExportTLSKeys), which adds
+// per-message authentication at the application layer, bound to the TLS
+// session, without a pre-shared key. The peer must use NewTLSServerConn.
+func NewTLSClientConn(conn *tls.Conn, opts ...Option) (*Conn, error) {
+	clientToServer, serverToClient, err := ExportTLSKeys(conn)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnWithKeys(conn, serverToClient, clientToServer, opts...), nil
+}
+
+// NewTLSServerConn wraps the server side of a TLS connection in a Conn keyed
+// with keys exported from the TLS session (see NewTLSClientConn)
+func NewTLSServerConn(conn *tls.Conn, opts ...Option) (*Conn, error) {
+	clientToServer, serverToClient, err := ExportTLSKeys(conn)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnWithKeys(conn, clientToServer, serverToClient, opts...), nil
+}
@@ -0,0 +1,106 @@
+package authio
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+// mockTLSConfigs returns the configs of a TLS client and server with a self-signed certificate
+func mockTLSConfigs(t *testing.T) (client *tls.Config, server *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mock"},
+		DNSNames:     []string{"mock"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{RootCAs: pool, ServerName: "mock"},
+		&tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+			// TLS 1.3 servers write session tickets at the end of the
+			// handshake, which blocks over net.Pipe until the client reads
+			SessionTicketsDisabled: true,
+		}
+}
+
+func Test_TLSConn(t *testing.T) {
+	clientConfig, serverConfig := mockTLSConfigs(t)
+
+	a, b := net.Pipe()
+	type result struct {
+		conn *Conn
+		err  error
+	}
+	serverResult := make(chan result, 1)
+	go func() {
+		conn, err := NewTLSServerConn(tls.Server(b, serverConfig))
+		serverResult <- result{conn: conn, err: err}
+	}()
+	client, err := NewTLSClientConn(tls.Client(a, clientConfig))
+	assert.NoError(t, err)
+	server := <-serverResult
+	assert.NoError(t, server.err)
+	defer client.Close()
+	defer server.conn.Close()
+
+	go client.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(server.conn, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	go server.conn.Write([]byte("pong"))
+	_, err = io.ReadFull(client, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "pong", string(buf))
+}
+
+func Test_ExportTLSKeys(t *testing.T) {
+	clientConfig, serverConfig := mockTLSConfigs(t)
+
+	// keys are unique to every TLS session
+	sessions := [][]byte{}
+	for i := 0; i < 2; i++ {
+		a, b := net.Pipe()
+		serverKeys := make(chan []byte, 1)
+		go func() {
+			c2s, s2c, err := ExportTLSKeys(tls.Server(b, serverConfig))
+			assert.NoError(t, err)
+			serverKeys <- append(c2s, s2c...)
+		}()
+		c2s, s2c, err := ExportTLSKeys(tls.Client(a, clientConfig))
+		assert.NoError(t, err)
+		assert.NotEqual(t, c2s, s2c)
+		assert.Equal(t, append(c2s, s2c...), <-serverKeys)
+		sessions = append(sessions, c2s)
+		a.Close()
+		b.Close()
+	}
+	assert.NotEqual(t, sessions[0], sessions[1])
+
+	// failed handshakes fail
+	a, b := net.Pipe()
+	b.Close()
+	_, _, err := ExportTLSKeys(tls.Client(a, clientConfig))
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+package authio
+
+import (
+	"encoding/hex"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// traceDumpLen is the max number of payload bytes of
+// every frame included (hex encoded) in trace logs
+const traceDumpLen = 32
+
+// Tracer is a debugging decorator of an io.ReadWriter (e.g. the net.Conn under
+// a Conn, or a Conn of a peer to debug), which logs the header fields of every
+// frame read or written through it, and a truncated hex dump of its payload.
+// It does not know (and never logs) any key, nor does it verify any frame, so
+// what it logs must not be trusted. Frames are parsed as framed by default,
+// with the header length of the given options (e.g. WithHashFn, WithTagSize,
+// WithMACEncoding), and not at all with CBOR headers. Logging can be turned on
+// and off at any time, without losing track of frame boundaries.
+type Tracer struct {
+	rw      io.ReadWriter
+	logger  Logger
+	enabled atomic.Bool
+	read    *frameTracer
+	written *frameTracer
+}
+
+// ensure Tracer implements io.ReadWriter at compile-time
+var _ io.ReadWriter = (*Tracer)(nil)
+
+// Trace wraps an io.ReadWriter in an (enabled) Tracer logging to the given logger
+func Trace(rw io.ReadWriter, logger Logger, opts ...Option) *Tracer {
+	headerLen := newConfig(opts...).headerLength()
+	t := &Tracer{rw: rw, logger: logger}
+	t.read = &frameTracer{tracer: t, direction: "read", headerLen: headerLen}
+	t.written = &frameTracer{tracer: t, direction: "write", headerLen: headerLen}
+	t.enabled.Store(true)
+	return t
+}
+
+// SetEnabled turns logging on or off
+func (t *Tracer) SetEnabled(enabled bool) {
+	t.enabled.Store(enabled)
+}
+
+// Enabled returns whether logging is on
+func (t *Tracer) Enabled() bool {
+	return t.enabled.Load()
+}
+
+// Read reads from the underlying io.ReadWriter, tracing the frames read
+func (t *Tracer) Read(b []byte) (int, error) {
+	n, err := t.rw.Read(b)
+	t.read.trace(b[:n])
+	return n, err
+}
+
+// Write writes to the underlying io.ReadWriter, tracing the frames written
+func (t *Tracer) Write(b []byte) (int, error) {
+	n, err := t.rw.Write(b)
+	t.written.trace(b[:n])
+	return n, err
+}
+
+// Close closes the underlying io.ReadWriter, if it is an io.Closer
+func (t *Tracer) Close() error {
+	if c, ok := t.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// frameTracer tracks the frames of one direction of a Tracer
+type frameTracer struct {
+	sync.Mutex
+	tracer    *Tracer
+	direction string
+	headerLen int
+	frames    int64
+	header    []byte // header of the current frame, until complete
+	info      authenticator.FrameInfo
+	remaining uint64 // payload bytes of the current frame not yet seen
+	dump      []byte // first payload bytes of the current frame
+	lost      bool   // whether a header failed to parse, after which frames are unknown
+}
+
+func (f *frameTracer) trace(b []byte) {
+	f.Lock()
+	defer f.Unlock()
+
+	for len(b) > 0 && !f.lost {
+		if len(f.header) < f.headerLen {
+			n := minInt(f.headerLen-len(f.header), len(b))
+			f.header = append(f.header, b[:n]...)
+			b = b[n:]
+			if len(f.header) < f.headerLen {
+				return
+			}
+			info, err := authenticator.ParseFrameHeader(f.header)
+			if err != nil {
+				f.lost = true
+				f.log("failed to parse frame header, no longer tracing frames", "error", err)
+				return
+			}
+			f.info = info
+			f.remaining = info.PayloadLength
+		}
+
+		n := uint64(len(b))
+		if n > f.remaining {
+			n = f.remaining
+		}
+		if missing := traceDumpLen - len(f.dump); missing > 0 {
+			f.dump = append(f.dump, b[:minInt(missing, int(n))]...)
+		}
+		b = b[n:]
+		f.remaining -= n
+		if f.remaining == 0 {
+			f.logFrame()
+			f.header = f.header[:0]
+			f.dump = f.dump[:0]
+		}
+	}
+}
+
+func (f *frameTracer) logFrame() {
+	dump := hex.EncodeToString(f.dump)
+	if f.info.PayloadLength > uint64(len(f.dump)) {
+		dump += "..."
+	}
+	f.log("frame",
+		"frame", f.frames,
+		"length", f.info.Length,
+		"payload_length", f.info.PayloadLength,
+		"close_notify", f.info.CloseNotify,
+		"control", f.info.Control,
+		"extensions", f.info.Extensions,
+		"mac", string(f.info.MAC),
+		"payload", dump,
+	)
+	f.frames++
+}
+
+func (f *frameTracer) log(msg string, args ...any) {
+	if !f.tracer.Enabled() {
+		return
+	}
+	f.tracer.logger.Info(msg, append([]any{"direction", f.direction}, args...)...)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
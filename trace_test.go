@@ -0,0 +1,119 @@
+package authio
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+// mockLogger is a Logger which records every log line
+type mockLogger struct {
+	lines []map[string]any
+}
+
+func (l *mockLogger) Info(msg string, args ...any) {
+	line := map[string]any{"msg": msg}
+	for i := 0; i+1 < len(args); i += 2 {
+		line[args[i].(string)] = args[i+1]
+	}
+	l.lines = append(l.lines, line)
+}
+
+func (l *mockLogger) Warn(msg string, args ...any) { l.Info(msg, args...) }
+
+func Test_Trace(t *testing.T) {
+	mockKey := []byte("mock key")
+	long := bytes.Repeat([]byte("x"), 100)
+
+	tests := []struct {
+		name             string
+		opts             []Option
+		messages         [][]byte
+		writeSize        int // size of the writes of the stream to the Tracer, all at once if zero
+		expectedPayloads []int
+		expectedDumps    []string
+	}{
+		{
+			name:             "Single write",
+			messages:         [][]byte{[]byte("first"), []byte("second")},
+			expectedPayloads: []int{5, 6},
+			expectedDumps:    []string{hex.EncodeToString([]byte("first")), hex.EncodeToString([]byte("second"))},
+		},
+		{
+			name:             "Byte by byte",
+			messages:         [][]byte{[]byte("first"), long},
+			writeSize:        1,
+			expectedPayloads: []int{5, 100},
+			expectedDumps:    []string{hex.EncodeToString([]byte("first")), hex.EncodeToString(long[:traceDumpLen]) + "..."},
+		},
+		{
+			name:             "Other hash",
+			opts:             []Option{WithHashFn(sha512.New)},
+			messages:         [][]byte{[]byte("first")},
+			writeSize:        7,
+			expectedPayloads: []int{5},
+			expectedDumps:    []string{hex.EncodeToString([]byte("first"))},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := bytes.NewBuffer(nil)
+			writer := NewWriter(stream, mockKey, test.opts...)
+			for _, message := range test.messages {
+				_, err := writer.Write(message)
+				assert.NoError(t, err)
+			}
+
+			logger := &mockLogger{}
+			tracer := Trace(bytes.NewBuffer(nil), logger, test.opts...)
+			frames := stream.Bytes()
+			size := test.writeSize
+			if size == 0 {
+				size = len(frames)
+			}
+			for len(frames) > 0 {
+				n := minInt(size, len(frames))
+				_, err := tracer.Write(frames[:n])
+				assert.NoError(t, err)
+				frames = frames[n:]
+			}
+
+			assert.Equal(t, len(test.expectedPayloads), len(logger.lines))
+			for i, line := range logger.lines {
+				assert.Equal(t, "write", line["direction"])
+				assert.Equal(t, int64(i), line["frame"])
+				assert.Equal(t, uint64(test.expectedPayloads[i]), line["payload_length"])
+				assert.Equal(t, test.expectedDumps[i], line["payload"])
+			}
+		})
+	}
+}
+
+func Test_TraceToggle(t *testing.T) {
+	mockKey := []byte("mock key")
+	stream := bytes.NewBuffer(nil)
+	writer := NewWriter(stream, mockKey)
+	for _, message := range []string{"first", "second", "third"} {
+		_, err := writer.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+
+	logger := &mockLogger{}
+	tracer := Trace(stream, logger)
+	reader := NewReader(tracer, mockKey)
+
+	// frames read while disabled are not logged, but still tracked
+	for i, enabled := range []bool{true, false, true} {
+		tracer.SetEnabled(enabled)
+		assert.Equal(t, enabled, tracer.Enabled())
+		_, err := reader.Next()
+		assert.NoError(t, err, "message %d", i)
+	}
+	assert.Equal(t, 2, len(logger.lines))
+	assert.Equal(t, "read", logger.lines[1]["direction"])
+	assert.Equal(t, int64(2), logger.lines[1]["frame"])
+	assert.Equal(t, hex.EncodeToString([]byte("third")), logger.lines[1]["payload"])
+}
@@ -0,0 +1,22 @@
+module github.com/adrianosela/authio/tracing/otel
+
+go 1.19
+
+require (
+	github.com/adrianosela/authio v0.0.0
+	github.com/autarch/testify v1.2.2
+	go.opentelemetry.io/otel v1.11.1
+	go.opentelemetry.io/otel/sdk v1.11.1
+	go.opentelemetry.io/otel/trace v1.11.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.4.0 // indirect
+	golang.org/x/sys v0.3.0 // indirect
+)
+
+replace github.com/adrianosela/authio => ../..
@@ -0,0 +1,76 @@
+package otel
+
+import (
+	"context"
+
+	"github.com/adrianosela/authio/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// EventVerificationFailed is the name of the span event
+	// recorded when a message fails MAC verification
+	EventVerificationFailed = "authio.verification_failed"
+	// EventMessageSigned is the name of the span event recorded
+	// when a MAC is computed for a message (only with WithFrameEvents)
+	EventMessageSigned = "authio.message_signed"
+	// EventMessageVerified is the name of the span event recorded
+	// when a message's MAC is verified (only with WithFrameEvents)
+	EventMessageVerified = "authio.message_verified"
+
+	attributeMessageSize = attribute.Key("authio.message.size")
+)
+
+// Tracer is a metrics.Metrics implementation that records message
+// authentication events as events on an OpenTelemetry span. By default
+// only verification failures are recorded, not every single message.
+type Tracer struct {
+	span        trace.Span
+	frameEvents bool
+}
+
+// ensure Tracer implements metrics.Metrics at compile-time
+var _ metrics.Metrics = (*Tracer)(nil)
+
+// Option represents a configuration option for a Tracer
+type Option func(*Tracer)
+
+// WithFrameEvents enables recording an event for every message signed
+// or verified. This can be very noisy on long-lived connections.
+func WithFrameEvents() Option {
+	return func(t *Tracer) { t.frameEvents = true }
+}
+
+// New returns a Tracer recording events on the given span
+func New(span trace.Span, opts ...Option) *Tracer {
+	t := &Tracer{span: span}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// FromContext returns a Tracer recording events on the span in the given context
+func FromContext(ctx context.Context, opts ...Option) *Tracer {
+	return New(trace.SpanFromContext(ctx), opts...)
+}
+
+// MessageSigned records an event for a signed message if frame events are enabled
+func (t *Tracer) MessageSigned(size int) {
+	if t.frameEvents {
+		t.span.AddEvent(EventMessageSigned, trace.WithAttributes(attributeMessageSize.Int(size)))
+	}
+}
+
+// MessageVerified records an event for a verified message if frame events are enabled
+func (t *Tracer) MessageVerified(size int) {
+	if t.frameEvents {
+		t.span.AddEvent(EventMessageVerified, trace.WithAttributes(attributeMessageSize.Int(size)))
+	}
+}
+
+// VerificationFailed records a verification failure event
+func (t *Tracer) VerificationFailed() {
+	t.span.AddEvent(EventVerificationFailed)
+}
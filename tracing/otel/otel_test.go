@@ -0,0 +1,66 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio"
+	"github.com/autarch/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_Tracer(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name           string
+		opts           []Option
+		expectedEvents []string
+	}{
+		{
+			name:           "Failures only",
+			opts:           nil,
+			expectedEvents: []string{EventVerificationFailed},
+		},
+		{
+			name:           "With frame events",
+			opts:           []Option{WithFrameEvents()},
+			expectedEvents: []string{EventMessageSigned, EventMessageVerified, EventVerificationFailed},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			ctx, span := provider.Tracer("test").Start(context.Background(), "mock span")
+			tracer := FromContext(ctx, test.opts...)
+
+			buf := &bytes.Buffer{}
+			_, err := authio.NewWriter(buf, mockKey, authio.WithMetrics(tracer)).Write([]byte("mock data"))
+			assert.NoError(t, err)
+
+			corrupted := append([]byte{}, buf.Bytes()...)
+			corrupted[len(corrupted)-1] ^= 0xff
+
+			_, err = io.ReadAll(authio.NewReader(buf, mockKey, authio.WithMetrics(tracer)))
+			assert.NoError(t, err)
+			_, err = io.ReadAll(authio.NewReader(bytes.NewReader(corrupted), mockKey, authio.WithMetrics(tracer)))
+			assert.Error(t, err)
+
+			span.End()
+
+			spans := recorder.Ended()
+			assert.Equal(t, 1, len(spans))
+
+			events := []string{}
+			for _, event := range spans[0].Events() {
+				events = append(events, event.Name)
+			}
+			assert.Equal(t, test.expectedEvents, events)
+		})
+	}
+}
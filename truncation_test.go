@@ -0,0 +1,73 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_Truncation(t *testing.T) {
+	mockKey := []byte("mock key")
+	pool := NewVerifierPool(2)
+	defer pool.Close()
+
+	formats := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Default"},
+		{name: "CBOR headers", opts: []Option{WithCBORHeaders("")}},
+		{name: "Extensions", opts: []Option{WithPadding(16)}},
+		{name: "Verifier pool", opts: []Option{WithVerifierPool(pool)}},
+		{name: "Format detection", opts: []Option{WithFormatDetection()}},
+	}
+	for _, format := range formats {
+		signed := bytes.NewBuffer(nil)
+		writer := NewWriter(signed, mockKey, format.opts...)
+		boundaries := map[int]int{0: 0} // offsets of frame boundaries, to messages before them
+		for i, message := range []string{"first", "second"} {
+			_, err := writer.Write([]byte(message))
+			assert.NoError(t, err)
+			boundaries[signed.Len()] = i + 1
+		}
+		stream := signed.Bytes()
+
+		for _, policy := range []TrailingDataPolicy{FailOnTrailingData, IgnoreTrailingData} {
+			// every truncation point: the start or middle of a header
+			// or message, or the boundary between frames
+			for end := 0; end <= len(stream); end++ {
+				opts := append([]Option{WithTrailingDataPolicy(policy)}, format.opts...)
+				reader := NewVerifyMACReader(bytes.NewReader(stream[:end]), mockKey, opts...)
+
+				messages := 0
+				var err error
+				for {
+					if _, err = reader.Next(); err != nil {
+						break
+					}
+					messages++
+				}
+
+				expectedMessages, atBoundary := boundaries[end]
+				if !atBoundary {
+					// the last frame before the truncation point
+					for offset, n := range boundaries {
+						if offset < end && n > expectedMessages {
+							expectedMessages = n
+						}
+					}
+				}
+				assert.Equal(t, expectedMessages, messages, "%s: messages before truncation at %d", format.name, end)
+				if atBoundary || policy == IgnoreTrailingData {
+					assert.Equal(t, io.EOF, err, "%s: truncation at %d", format.name, end)
+				} else {
+					assert.True(t, errors.Is(err, authenticator.ErrTruncatedMessage), "%s: truncation at %d: got %v", format.name, end, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+)
+
+// verifierPoolReadAhead is the maximum number of frames read ahead (and being
+// verified) per VerifyMACReader using a VerifierPool
+const verifierPoolReadAhead = 16
+
+// ErrVerifierPoolClosed is returned by reads which need a closed VerifierPool
+var ErrVerifierPoolClosed = errors.New("verifier pool closed")
+
+// VerifierPool is a pool of workers which verifies the MACs of frames read by
+// VerifyMACReaders (and Conns) created WithVerifierPool. Sharing a single pool
+// across many connections spreads MAC verification across all cores, while
+// every connection still returns its messages in order.
+type VerifierPool struct {
+	jobs      chan func()
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewVerifierPool returns a VerifierPool with the given number of
+// workers, or GOMAXPROCS workers if the given number is not positive
+func NewVerifierPool(workers int) *VerifierPool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p := &VerifierPool{
+		jobs: make(chan func()),
+		done: make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *VerifierPool) work() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case job := <-p.jobs:
+			job()
+		}
+	}
+}
+
+// submit runs the given job on a worker, returning false
+// (without running it) if the pool is closed
+func (p *VerifierPool) submit(job func()) bool {
+	select {
+	case <-p.done:
+		return false
+	case p.jobs <- job:
+		return true
+	}
+}
+
+// Close stops the workers of the VerifierPool, after which reads of
+// VerifyMACReaders using it fail with ErrVerifierPoolClosed
+func (p *VerifierPool) Close() {
+	p.closeOnce.Do(func() { close(p.done) })
+}
+
+// verifyResult is the result of verifying a single frame
+type verifyResult struct {
+	frame frame
+	size  int // size of the frame read
+	err   error
+}
+
+// verifyPipeline reads frames ahead of a VerifyMACReader and
+// verifies them on a VerifierPool, keeping them in order
+type verifyPipeline struct {
+	results chan chan verifyResult
+	done    chan struct{}
+	stop    sync.Once
+
+	// maxBuffered, if positive, is the maximum number of bytes read ahead
+	maxBuffered int
+	lock        sync.Mutex
+	space       *sync.Cond // signaled as buffered bytes are consumed
+	buffered    int
+	stopped     bool
+}
+
+// startPipeline starts reading frames ahead of the VerifyMACReader
+func (r *VerifyMACReader) startPipeline() *verifyPipeline {
+	p := &verifyPipeline{
+		results:     make(chan chan verifyResult, verifierPoolReadAhead),
+		done:        make(chan struct{}),
+		maxBuffered: r.maxBuffered,
+	}
+	p.space = sync.NewCond(&p.lock)
+	go r.readAhead(p)
+	return p
+}
+
+func (r *VerifyMACReader) readAhead(p *verifyPipeline) {
+	defer close(p.results)
+
+	for {
+		result := make(chan verifyResult, 1)
+		// blocks while verifierPoolReadAhead frames are pending
+		select {
+		case <-p.done:
+			return
+		case p.results <- result:
+		}
+		// blocks while maxBuffered bytes are pending
+		if !p.waitForSpace() {
+			result <- verifyResult{err: io.EOF}
+			return
+		}
+
+		raw, last, err := r.readRawFrame()
+		if err != nil {
+			result <- verifyResult{err: err}
+			return
+		}
+		p.add(len(raw))
+		if !r.pool.submit(func() { result <- r.verifyFrame(raw) }) {
+			result <- verifyResult{err: ErrVerifierPoolClosed}
+			return
+		}
+		if last {
+			return
+		}
+	}
+}
+
+// readRawFrame reads a single frame without verifying it, returning
+// whether it is (claims to be) a close notification, after which
+// there is nothing more to read
+func (r *VerifyMACReader) readRawFrame() ([]byte, bool, error) {
+	header := make([]byte, r.authHeaderLen)
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, false, io.EOF
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, false, fmt.Errorf("%w: read data too short to have valid header", authenticator.ErrTruncatedMessage)
+		}
+		return nil, false, fmt.Errorf("failed to read message header: %w", err)
+	}
+
+	info, err := authenticator.ParseFrameHeader(header)
+	if err != nil {
+		return nil, false, err
+	}
+	if info.CloseNotify {
+		return header, true, nil
+	}
+	if r.maxMessageLen > 0 && info.PayloadLength > uint64(r.maxMessageLen) {
+		return nil, false, fmt.Errorf("message too large, got %d and expected at most %d", info.PayloadLength, r.maxMessageLen)
+	}
+
+	raw := make([]byte, info.Length)
+	copy(raw, header)
+	if _, err := io.ReadFull(r.reader, raw[len(header):]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, false, fmt.Errorf("%w: read message too short, does not match message size from header", authenticator.ErrTruncatedMessage)
+		}
+		return nil, false, fmt.Errorf("failed to read message: %w", err)
+	}
+	return raw, false, nil
+}
+
+// verifyFrame verifies a single frame read with readRawFrame
+func (r *VerifyMACReader) verifyFrame(raw []byte) verifyResult {
+	f, err := r.readFrame(bytes.NewReader(raw))
+	return verifyResult{frame: f, size: len(raw), err: err}
+}
+
+// next returns the next frame of the pipeline (and its size),
+// in the order they were read
+func (p *verifyPipeline) next() (frame, int, error) {
+	result, ok := <-p.results
+	if !ok {
+		return frame{}, 0, io.EOF
+	}
+	res := <-result
+	p.add(-res.size)
+	return res.frame, res.size, res.err
+}
+
+// waitForSpace waits until fewer than maxBuffered bytes are read
+// ahead, returning false if the pipeline is closed in the meantime
+func (p *verifyPipeline) waitForSpace() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for p.maxBuffered > 0 && p.buffered >= p.maxBuffered && !p.stopped {
+		p.space.Wait()
+	}
+	return !p.stopped
+}
+
+// add adds the given number of bytes (negative once consumed) to those read ahead
+func (p *verifyPipeline) add(n int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.buffered += n
+	p.space.Signal()
+}
+
+// bufferedBytes returns the number of bytes read ahead
+func (p *verifyPipeline) bufferedBytes() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.buffered
+}
+
+// close stops reading frames ahead. A read already in progress
+// is not interrupted (i.e. until the underlying reader is closed).
+func (p *verifyPipeline) close() {
+	p.stop.Do(func() {
+		close(p.done)
+		p.lock.Lock()
+		p.stopped = true
+		p.space.Broadcast()
+		p.lock.Unlock()
+	})
+}
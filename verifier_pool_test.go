@@ -0,0 +1,137 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_VerifierPool(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	pool := NewVerifierPool(4)
+	defer pool.Close()
+
+	tests := []struct {
+		name        string
+		messages    int
+		tamper      int // index of the message to tamper with, if non-negative
+		opts        []Option
+		expectError error
+	}{
+		{
+			name:     "Happy path",
+			messages: 100,
+			tamper:   -1,
+		},
+		{
+			name:     "Happy path with close notification",
+			messages: 100,
+			tamper:   -1,
+			opts:     []Option{WithCloseNotify()},
+		},
+		{
+			name:     "Tampered message",
+			messages: 100,
+			tamper:   42,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer := NewAppendMACWriter(buf, mockKey, test.opts...)
+			for i := 0; i < test.messages; i++ {
+				message := fmt.Sprintf("message %d", i)
+				if i == test.tamper {
+					// sign a different message of the same size
+					message = fmt.Sprintf("MESSAGE %d", i)
+				}
+				_, err := writer.Write([]byte(message))
+				assert.Nil(t, err)
+			}
+			assert.Nil(t, writer.Close())
+
+			// restore the tampered message under its original MAC
+			data := bytes.Replace(buf.Bytes(), []byte(fmt.Sprintf("MESSAGE %d", test.tamper)), []byte(fmt.Sprintf("message %d", test.tamper)), 1)
+
+			reader := NewVerifyMACReader(bytes.NewReader(data), mockKey, append(test.opts, WithVerifierPool(pool))...)
+			defer reader.Close()
+			for i := 0; i < test.messages; i++ {
+				message, err := reader.Next()
+				if i == test.tamper {
+					assert.NotNil(t, err)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, fmt.Sprintf("message %d", i), string(message))
+			}
+			_, err := reader.Next()
+			assert.True(t, errors.Is(err, io.EOF))
+		})
+	}
+}
+
+func Test_VerifierPoolClosed(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	pool := NewVerifierPool(1)
+	pool.Close()
+
+	buf := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(buf, mockKey).Write([]byte("message"))
+	assert.Nil(t, err)
+
+	_, err = NewVerifyMACReader(buf, mockKey, WithVerifierPool(pool)).Next()
+	assert.True(t, errors.Is(err, ErrVerifierPoolClosed))
+}
+
+func Test_VerifierPoolMaxBufferedBytes(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	pool := NewVerifierPool(4)
+	defer pool.Close()
+
+	message := bytes.Repeat([]byte("a"), 1024)
+	frameSize := len(message) + NewAppendMACWriter(nil, mockKey).authHeaderLen
+
+	buf := &bytes.Buffer{}
+	writer := NewAppendMACWriter(buf, mockKey)
+	for i := 0; i < 10; i++ {
+		_, err := writer.Write(message)
+		assert.Nil(t, err)
+	}
+
+	source := &countingReader{reader: buf}
+	reader := NewVerifyMACReader(source, mockKey, WithVerifierPool(pool), WithMaxBufferedBytes(2*frameSize))
+	defer reader.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err := reader.Next()
+		assert.Nil(t, err)
+		// give the pipeline time to read ahead as much as it may
+		time.Sleep(10 * time.Millisecond)
+		assert.True(t, reader.Buffered() <= 2*frameSize)
+		assert.True(t, source.n.Load() <= int64((i+3)*frameSize))
+	}
+	_, err := reader.Next()
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, 0, reader.Buffered())
+}
+
+// countingReader counts the bytes read from a reader
+type countingReader struct {
+	reader io.Reader
+	n      atomic.Int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.n.Add(int64(n))
+	return n, err
+}
@@ -1,9 +1,12 @@
 package authio
 
 import (
-	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
+	"sync/atomic"
 
+	"github.com/adrianosela/authio/metrics"
 	"github.com/adrianosela/authio/protocol/authenticator"
 )
 
@@ -12,22 +15,84 @@ type VerifyMACReader struct {
 	reader        io.Reader // underlying io.Reader to read from
 	authenticator authenticator.MessageAuthenticator
 	authHeaderLen int
+	metrics       metrics.Metrics
+	logger        Logger
+	closeNotify   bool
+	maxMessageLen int
+	config        EffectiveConfig
+	recordSize    int // if positive, the size of fixed size records (see WithFixedRecords)
+
+	// pool, if set, verifies the frames read ahead by pipeline,
+	// up to maxBuffered bytes of them if positive
+	pool        *VerifierPool
+	pipeline    atomic.Pointer[verifyPipeline]
+	maxBuffered int
+
+	// resync, if set, reads frames skipping over corrupted data
+	resync *resyncReader
+
+	// extensions of the message last read
+	extensions []authenticator.Extension
+	allowlist  *Allowlist
+
+	errorPolicy FrameErrorPolicy
+	onBadFrame  func(err error)
+	oversized   OversizedFramePolicy
+	onOversized func(err error)
+	trailing    TrailingDataPolicy
+	err         error // sticky error as per FailClosed
+	midFrame    bool  // whether a read timed out mid-frame (see readNext)
 
 	readReadyBytes []byte
+	readyLen       atomic.Int64 // len(readReadyBytes), for Buffered
+	closed         bool         // whether a close notification was received
+
+	// progress through the stream, for State and FrameErrors
+	offset   int64
+	messages uint64
+	frames   uint64
+
+	// onControl, if set, is called with the payload of every control frame
+	// received, otherwise they are passed to controlHandlers (if any)
+	onControl       func(payload []byte)
+	controlHandlers map[ControlType]func(payload []byte)
 }
 
 // ensure VerifyMACReader implements io.Reader at compile-time
 var _ io.Reader = (*VerifyMACReader)(nil)
 
 // NewVerifyMACReader returns a new VerifyMACReader
-func NewVerifyMACReader(reader io.Reader, key []byte) *VerifyMACReader {
-	authenticator := authenticator.NewDefaultMessageAuthenticator(sha256.New, key)
-	return &VerifyMACReader{
-		reader:         reader,
-		authenticator:  authenticator,
-		authHeaderLen:  authenticator.GetMessageAuthenticationHeaderLength(),
-		readReadyBytes: []byte{},
+func NewVerifyMACReader(reader io.Reader, key []byte, opts ...Option) *VerifyMACReader {
+	config := newConfig(opts...)
+	authenticator := config.newAuthenticator(key)
+	r := &VerifyMACReader{
+		reader:          reader,
+		authenticator:   authenticator,
+		authHeaderLen:   authenticator.GetMessageAuthenticationHeaderLength(),
+		metrics:         config.metrics,
+		logger:          config.logger,
+		closeNotify:     config.closeNotify,
+		maxMessageLen:   config.maxMessageSize,
+		config:          config.effectiveConfig(authenticator),
+		recordSize:      config.recordSize,
+		pool:            config.verifierPool,
+		maxBuffered:     config.maxBufferedBytes,
+		errorPolicy:     config.frameErrorPolicy,
+		onBadFrame:      config.onBadFrame,
+		oversized:       config.oversizedPolicy,
+		onOversized:     config.onOversized,
+		trailing:        config.trailingDataPolicy,
+		allowlist:       config.allowlist,
+		controlHandlers: config.controlHandlers,
+		readReadyBytes:  []byte{},
+	}
+	if config.resync {
+		r.resync = &resyncReader{r: r, onSkip: config.onResync}
+	}
+	if config.verifyState != nil {
+		r.resume(*config.verifyState)
 	}
+	return r
 }
 
 // Read reads data onto the given buffer
@@ -40,14 +105,13 @@ func (r *VerifyMACReader) Read(b []byte) (int, error) {
 		// copy n bytes where n is the smallest of len(b) and len(r.readReadyBytes)
 		n += copy(b, r.readReadyBytes)
 		// adjust the in-memory already verified bytes
-		r.readReadyBytes = r.readReadyBytes[n:]
-		// no point continuing if we've already filled b; return
-		if n == len(b) {
-			return n, nil
-		}
+		r.setReadReady(r.readReadyBytes[n:])
+		// return what is ready rather than blocking on the next
+		// message, like reads of any other stream (e.g. net.Conn)
+		return n, nil
 	}
 
-	message, err := r.authenticator.ReadNext(r.reader)
+	message, err := r.readNext()
 	if err != nil {
 		return n, err
 	}
@@ -57,9 +121,272 @@ func (r *VerifyMACReader) Read(b []byte) (int, error) {
 	// if more bytes were received than the space available
 	// in b, save them to be returned on the next read
 	if len(message) > (len(b) - n) {
-		r.readReadyBytes = append(r.readReadyBytes, message[m:]...)
+		r.setReadReady(append(r.readReadyBytes, message[m:]...))
 	}
 
 	n += m
 	return n, nil
 }
+
+// Next reads and returns exactly one verified message. If the previous
+// message was only partially consumed through Read, its remaining bytes
+// are returned instead. Next returns io.EOF once the underlying reader
+// is exhausted at a message boundary.
+func (r *VerifyMACReader) Next() ([]byte, error) {
+	if len(r.readReadyBytes) > 0 {
+		message := r.readReadyBytes
+		r.setReadReady([]byte{})
+		return message, nil
+	}
+	return r.readNext()
+}
+
+// Peek returns the next n verified bytes without consuming them, like
+// bufio.Reader.Peek, reading (and verifying) as many messages as needed. The
+// bytes are only valid until the next read. If fewer than n bytes can be read,
+// it returns those along with the error why (e.g. io.EOF). Note that Next
+// returns all the bytes peeked (i.e. across messages) as one message.
+func (r *VerifyMACReader) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid peek size %d", n)
+	}
+	for len(r.readReadyBytes) < n {
+		message, err := r.readNext()
+		if err != nil {
+			return r.readReadyBytes, err
+		}
+		r.setReadReady(append(r.readReadyBytes, message...))
+	}
+	return r.readReadyBytes[:n], nil
+}
+
+// Discard skips the next n verified bytes, like bufio.Reader.Discard,
+// returning the number of bytes discarded. If fewer than n bytes were
+// discarded, it also returns the error why (e.g. io.EOF).
+func (r *VerifyMACReader) Discard(n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("invalid discard size %d", n)
+	}
+	discarded := 0
+	for discarded < n {
+		if len(r.readReadyBytes) == 0 {
+			message, err := r.readNext()
+			if err != nil {
+				return discarded, err
+			}
+			r.setReadReady(message)
+		}
+		m := n - discarded
+		if m > len(r.readReadyBytes) {
+			m = len(r.readReadyBytes)
+		}
+		r.setReadReady(r.readReadyBytes[m:])
+		discarded += m
+	}
+	return discarded, nil
+}
+
+// NextWithExtensions is like Next, but also returns the extensions (see
+// authenticator.Extension) of the message, which are covered by its MAC
+func (r *VerifyMACReader) NextWithExtensions() ([]byte, []authenticator.Extension, error) {
+	message, err := r.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	return message, r.extensions, nil
+}
+
+// Buffered returns the number of bytes read from the underlying reader but
+// not yet returned, i.e. the rest of a message partially consumed through
+// Read, and any frames read ahead WithVerifierPool (see WithMaxBufferedBytes).
+// It is safe to call concurrently with reads, e.g. to monitor backpressure.
+func (r *VerifyMACReader) Buffered() int {
+	n := int(r.readyLen.Load())
+	if p := r.pipeline.Load(); p != nil {
+		n += p.bufferedBytes()
+	}
+	return n
+}
+
+func (r *VerifyMACReader) setReadReady(b []byte) {
+	r.readReadyBytes = b
+	r.readyLen.Store(int64(len(b)))
+}
+
+func (r *VerifyMACReader) readNext() ([]byte, error) {
+	if r.closed {
+		return nil, io.EOF
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	message, err := r.readNextMessage()
+	if err != nil {
+		if errors.Is(err, authenticator.ErrCloseNotify) {
+			r.closed = true
+			return nil, io.EOF
+		}
+		if r.trailing == IgnoreTrailingData && errors.Is(err, authenticator.ErrTruncatedMessage) {
+			r.logger.Warn("ignored partial frame at the end of the stream", "error", err)
+			err = io.EOF
+		}
+		if errors.Is(err, io.EOF) && r.closeNotify {
+			// the stream ends where the next frame should have started
+			err = frameError(r.frames, r.offset, ErrTruncatedStream)
+		}
+		if !errors.Is(err, io.EOF) {
+			r.metrics.VerificationFailed()
+			r.logger.Warn("failed to read authenticated message", "error", err, "buffered", len(r.readReadyBytes))
+			// only a timeout between frames can be retried: the
+			// bytes read of a partial frame are lost, such that
+			// reading on would start in the middle of a frame
+			if !isTimeout(err) || r.midFrame {
+				r.err = err
+			}
+		}
+		return nil, err
+	}
+	r.messages++
+	r.metrics.MessageVerified(len(message))
+	return message, nil
+}
+
+// Close destroys the key material held by the VerifyMACReader, after
+// which reads fail. It does not close the underlying reader.
+func (r *VerifyMACReader) Close() error {
+	if p := r.pipeline.Load(); p != nil {
+		p.close()
+	}
+	destroyKey(r.authenticator)
+	return nil
+}
+
+// readNextMessage reads the next message, handling any control
+// frames before it if the MessageAuthenticator supports them
+func (r *VerifyMACReader) readNextMessage() ([]byte, error) {
+	readFrame := func() (frame, error) { return r.readFrame(offsetReader{r: r}) }
+	if r.resync != nil {
+		readFrame = r.resync.next
+	} else if r.pool != nil {
+		p := r.pipeline.Load()
+		if p == nil {
+			p = r.startPipeline()
+			r.pipeline.Store(p)
+		}
+		readFrame = func() (frame, error) {
+			f, size, err := p.next()
+			r.offset += int64(size)
+			return f, err
+		}
+	}
+	for {
+		index, offset := r.frames, r.offset
+		f, err := readFrame()
+		if isTimeout(err) && (r.offset != offset || r.pool != nil) {
+			// a pipeline stops reading ahead on timeouts (which may
+			// also have been mid-frame), so it cannot be resumed either
+			r.midFrame = true
+			err = fmt.Errorf("timed out mid-frame: %w", err)
+		}
+		if err == nil || !(errors.Is(err, io.EOF) || isTimeout(err)) {
+			r.frames++
+		}
+		if r.resync != nil {
+			// the frame starts after any data skipped over
+			offset = r.resync.frameOffset
+		}
+		err = frameError(index, offset, err)
+		if r.errorPolicy == SkipAndReport && errors.Is(err, authenticator.ErrMACMismatch) {
+			// the whole frame was read, so the next one can be read
+			r.metrics.VerificationFailed()
+			r.logger.Warn("skipped authenticated message failing verification", "error", err, "frame", index, "offset", offset)
+			if r.onBadFrame != nil {
+				r.onBadFrame(err)
+			}
+			continue
+		}
+		if r.drainsOversized() {
+			drained, drainErr := r.drainOversizedFrame(err)
+			if drainErr != nil {
+				return nil, frameError(index, offset, drainErr)
+			}
+			if drained {
+				r.metrics.VerificationFailed()
+				r.logger.Warn("drained authenticated message larger than the max message size", "error", err, "frame", index, "offset", offset)
+				if r.onOversized != nil {
+					r.onOversized(err)
+				}
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !f.control && r.recordSize > 0 {
+			if f.payload, err = decodeRecord(r.recordSize, f.payload); err != nil {
+				return nil, frameError(index, offset, err)
+			}
+		}
+		if !f.control {
+			if err := r.allowlist.checkExtensions(f.extensions); err != nil {
+				return nil, frameError(index, offset, err)
+			}
+			if f.payload, f.extensions, err = decompressMessage(f.payload, f.extensions, r.maxMessageLen); err != nil {
+				return nil, frameError(index, offset, err)
+			}
+			r.extensions = withoutPadding(f.extensions)
+			return f.payload, nil
+		}
+		if r.onControl != nil {
+			r.onControl(f.payload)
+			continue
+		}
+		r.handleControlFrame(f.payload)
+	}
+}
+
+// drainsOversized returns whether frames larger than the max message
+// size are drained, which is only possible when reading frames directly
+// from the underlying reader (i.e. neither pipelined nor resynced)
+func (r *VerifyMACReader) drainsOversized() bool {
+	return r.oversized == DrainOversizedFrame && r.pool == nil && r.resync == nil
+}
+
+// drainOversizedFrame discards the rest of the frame if the given error
+// is that of a frame larger than the max message size. It returns whether
+// the frame was drained, and the error draining it (if any).
+func (r *VerifyMACReader) drainOversizedFrame(err error) (bool, error) {
+	var tooLarge *authenticator.MessageTooLargeError
+	if !errors.As(err, &tooLarge) {
+		return false, nil
+	}
+	if _, err := io.CopyN(io.Discard, offsetReader{r: r}, int64(tooLarge.Remaining)); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, fmt.Errorf("%w: read message too short, does not match message size from header", authenticator.ErrTruncatedMessage)
+		}
+		return false, fmt.Errorf("failed to drain oversized message: %w", err)
+	}
+	return true, nil
+}
+
+// frame is a verified frame
+type frame struct {
+	payload    []byte
+	extensions []authenticator.Extension
+	control    bool
+}
+
+// readFrame reads and verifies a single frame from the given reader
+func (r *VerifyMACReader) readFrame(reader io.Reader) (frame, error) {
+	switch framer := r.authenticator.(type) {
+	case authenticator.ExtensionFramer:
+		payload, extensions, control, err := framer.ReadNextFrameWithExtensions(reader)
+		return frame{payload: payload, extensions: extensions, control: control}, err
+	case authenticator.ControlFramer:
+		payload, control, err := framer.ReadNextFrame(reader)
+		return frame{payload: payload, control: control}, err
+	default:
+		payload, err := r.authenticator.ReadNext(reader)
+		return frame{payload: payload}, err
+	}
+}
@@ -0,0 +1,110 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_VerifyMACReaderSmallBuffers(t *testing.T) {
+	mockKey := []byte("mock key")
+	messages := []string{"first message", "", "second message", "3"}
+
+	signed := &bytes.Buffer{}
+	writer := NewWriter(signed, mockKey)
+	expected := ""
+	for _, message := range messages {
+		_, err := writer.Write([]byte(message))
+		assert.Nil(t, err)
+		expected += message
+	}
+
+	tests := []struct {
+		name    string
+		bufSize int
+	}{
+		{name: "one byte", bufSize: 1},
+		{name: "three bytes", bufSize: 3},
+		{name: "one byte short of a message", bufSize: len(messages[0]) - 1},
+		{name: "exactly a message", bufSize: len(messages[0])},
+		{name: "larger than all messages", bufSize: 1024},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewReader(bytes.NewReader(signed.Bytes()), mockKey)
+
+			// verified bytes which do not fit the buffer are
+			// returned on the next reads rather than dropped
+			out := &bytes.Buffer{}
+			buf := make([]byte, test.bufSize)
+			for {
+				n, err := reader.Read(buf)
+				assert.True(t, n <= len(buf))
+				out.Write(buf[:n])
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, expected, out.String())
+			assert.Equal(t, 0, reader.Buffered())
+		})
+	}
+}
+
+func Test_VerifyMACReaderPeekDiscard(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	signed := &bytes.Buffer{}
+	writer := NewWriter(signed, mockKey)
+	for _, message := range []string{"GET", " /index", "", " HTTP/1.1\r\n"} {
+		_, err := writer.Write([]byte(message))
+		assert.Nil(t, err)
+	}
+	reader := NewReader(bytes.NewReader(signed.Bytes()), mockKey)
+
+	// peeking does not consume bytes, even across messages
+	peeked, err := reader.Peek(3)
+	assert.Nil(t, err)
+	assert.Equal(t, "GET", string(peeked))
+	peeked, err = reader.Peek(5)
+	assert.Nil(t, err)
+	assert.Equal(t, "GET /", string(peeked))
+	assert.Equal(t, len("GET /index"), reader.Buffered())
+
+	discarded, err := reader.Discard(4)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, discarded)
+	peeked, err = reader.Peek(0)
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(peeked))
+
+	buf := make([]byte, 5)
+	n, err := reader.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "/inde", string(buf[:n]))
+
+	// discarding also spans messages
+	discarded, err = reader.Discard(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, discarded)
+	peeked, err = reader.Peek(8)
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/1.1", string(peeked))
+
+	// fewer bytes than requested are returned with the error why
+	peeked, err = reader.Peek(64)
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, "HTTP/1.1\r\n", string(peeked))
+	discarded, err = reader.Discard(64)
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, len("HTTP/1.1\r\n"), discarded)
+
+	_, err = reader.Peek(-1)
+	assert.NotNil(t, err)
+	_, err = reader.Discard(-1)
+	assert.NotNil(t, err)
+}
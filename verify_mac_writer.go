@@ -1,10 +1,12 @@
 package authio
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
+	"github.com/adrianosela/authio/metrics"
 	"github.com/adrianosela/authio/protocol/authenticator"
 )
 
@@ -14,30 +16,210 @@ type VerifyMACWriter struct {
 	writer        io.Writer // underlying io.Writer to write to
 	authenticator authenticator.MessageAuthenticator
 	authHeaderLen int
+	metrics       metrics.Metrics
+	logger        Logger
+	closeNotify   bool
+	maxMessageLen int
+	recordSize    int    // if positive, the size of fixed size records (see WithFixedRecords)
+	closed        bool   // whether a close notification was received
+	buf           []byte // partial frame written but not yet verified
+	offset        int64  // offset in the stream of the start of buf
+
+	// if true, the length of partial frames is read from their header (see
+	// authenticator.ParseFrameHeader), such that they are not parsed again
+	// until the buffer holds all of needed bytes
+	defaultFraming bool
+	needed         int
 }
 
 // ensure VerifyMACWriter implements io.Writer at compile-time
 var _ io.Writer = (*VerifyMACWriter)(nil)
 
 // NewVerifyMACWriter wraps an io.Writer in an VerifyMACWriter
-func NewVerifyMACWriter(writer io.Writer, key []byte) *VerifyMACWriter {
-	authenticator := authenticator.NewDefaultMessageAuthenticator(sha256.New, key)
+func NewVerifyMACWriter(writer io.Writer, key []byte, opts ...Option) *VerifyMACWriter {
+	config := newConfig(opts...)
+	authenticator := config.newAuthenticator(key)
 	return &VerifyMACWriter{
 		writer:        writer,
 		authenticator: authenticator,
 		authHeaderLen: authenticator.GetMessageAuthenticationHeaderLength(),
+		metrics:       config.metrics,
+		logger:        config.logger,
+		closeNotify:   config.closeNotify,
+		recordSize:    config.recordSize,
+		maxMessageLen: config.maxMessageSize,
+
+		defaultFraming: config.defaultFraming(),
 	}
 }
 
-// Write writes the contents of a buffer to a writer (with MAC excluded)
+// Write verifies and strips the MACs of the frames in the given buffer,
+// writing their messages to the underlying writer. Frames may be split
+// across Writes: a partial frame at the end of the buffer is kept until
+// the rest of it is written. The returned count only includes the bytes of
+// frames whose messages were written in full to the underlying writer (and
+// of the partial frame kept, if all were), such that on failure the rest of
+// the buffer may be written again.
 func (w *VerifyMACWriter) Write(b []byte) (int, error) {
-	msg, subMsgCount, err := w.authenticator.AuthenticateMessages(b)
-	if err != nil {
-		return 0, fmt.Errorf("failed message authentication verification: %s", err)
+	prevLen := len(w.buf)
+	w.buf = append(w.buf, b...)
+	if len(w.buf) < w.needed {
+		// the partial frame is still incomplete
+		return len(b), nil
+	}
+
+	msg := []byte{}
+	frames := []verifiedFrame{}
+	closed := w.closed
+	offset := 0
+	for offset < len(w.buf) {
+		if closed {
+			w.buf = w.buf[:prevLen]
+			return 0, fmt.Errorf("message at offset %d follows close notification", w.offset+int64(offset))
+		}
+		reader := &partialFrameReader{Reader: bytes.NewReader(w.buf[offset:])}
+		subMsg, err := w.readMessage(reader)
+		if errors.Is(err, authenticator.ErrCloseNotify) {
+			closed = true
+			offset = len(w.buf) - reader.Len()
+			frames = append(frames, verifiedFrame{end: offset, messageEnd: len(msg), closeNotify: true})
+			continue
+		}
+		if err != nil && reader.eof {
+			// the rest of the frame is yet to be written
+			err = w.checkPartialFrame(w.buf[offset:])
+			if err == nil {
+				break
+			}
+		}
+		if err == nil && w.recordSize > 0 {
+			subMsg, err = decodeRecord(w.recordSize, subMsg)
+		}
+		if err != nil {
+			w.metrics.VerificationFailed()
+			w.logger.Warn("failed to verify authenticated message", "error", err, "message_index", len(frames), "offset", w.offset+int64(offset))
+			w.buf = w.buf[:prevLen]
+			return 0, fmt.Errorf("failed message authentication verification: %w", err)
+		}
+		w.metrics.MessageVerified(len(subMsg))
+		msg = append(msg, subMsg...)
+		offset = len(w.buf) - reader.Len()
+		frames = append(frames, verifiedFrame{end: offset, messageEnd: len(msg)})
 	}
+
 	n, err := w.writer.Write(msg)
+	if err == nil && n < len(msg) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		// only the frames whose messages were written in full are consumed
+		consumed := 0
+		for _, frame := range frames {
+			if frame.messageEnd > n {
+				break
+			}
+			consumed = frame.end
+			w.closed = w.closed || frame.closeNotify
+		}
+		w.offset += int64(consumed)
+		if consumed < prevLen {
+			w.buf = append([]byte(nil), w.buf[consumed:prevLen]...)
+			w.needed = w.partialFrameLength(w.buf)
+			return 0, fmt.Errorf("failed to write verified message: %w", err)
+		}
+		w.buf, w.needed = nil, 0
+		return consumed - prevLen, fmt.Errorf("failed to write verified message: %w", err)
+	}
+
+	w.closed = closed
+	w.offset += int64(offset)
+	w.buf = append([]byte(nil), w.buf[offset:]...)
+	w.needed = w.partialFrameLength(w.buf)
+	return len(b), nil
+}
+
+// checkPartialFrame returns an error if the header of the given partial
+// frame (if written in full) claims a message larger than the max size,
+// such that the rest of the frame is not buffered only to be rejected
+func (w *VerifyMACWriter) checkPartialFrame(partial []byte) error {
+	if !w.defaultFraming || w.maxMessageLen <= 0 || len(partial) < w.authHeaderLen {
+		return nil
+	}
+	info, err := authenticator.ParseFrameHeader(partial[:w.authHeaderLen])
+	if err != nil {
+		return err
+	}
+	if info.PayloadLength > uint64(w.maxMessageLen) {
+		return &authenticator.MessageTooLargeError{Size: info.PayloadLength, Max: w.maxMessageLen, Remaining: info.PayloadLength - uint64(len(partial)-w.authHeaderLen)}
+	}
+	return nil
+}
+
+// partialFrameLength returns the length of the given partial frame as per its
+// header, or zero if unknown (e.g. if the header itself is partial)
+func (w *VerifyMACWriter) partialFrameLength(partial []byte) int {
+	if !w.defaultFraming || len(partial) < w.authHeaderLen {
+		return 0
+	}
+	info, err := authenticator.ParseFrameHeader(partial[:w.authHeaderLen])
 	if err != nil {
-		return n + (subMsgCount * w.authHeaderLen), fmt.Errorf("failed to write verified message: %s", err)
+		return 0
+	}
+	return int(info.Length)
+}
+
+// verifiedFrame is the position of a frame verified by a VerifyMACWriter
+// in its buffer, and of its message in the messages to write
+type verifiedFrame struct {
+	end         int // offset in the buffer at which the frame ends
+	messageEnd  int // offset in the messages at which its message ends
+	closeNotify bool
+}
+
+// readMessage reads and verifies a single message, decompressing it
+// if it was compressed (see WithCompression)
+func (w *VerifyMACWriter) readMessage(r io.Reader) ([]byte, error) {
+	framer, ok := w.authenticator.(authenticator.ExtensionFramer)
+	if !ok {
+		return w.authenticator.ReadNext(r)
+	}
+	msg, extensions, control, err := framer.ReadNextFrameWithExtensions(r)
+	if err != nil {
+		return nil, err
+	}
+	if control {
+		return nil, fmt.Errorf("unexpected control frame")
+	}
+	msg, _, err = decompressMessage(msg, extensions, w.maxMessageLen)
+	return msg, err
+}
+
+// partialFrameReader is a bytes.Reader which records whether it was read to
+// the end, to tell frames failing to be read because they are incomplete
+type partialFrameReader struct {
+	*bytes.Reader
+	eof bool
+}
+
+func (r *partialFrameReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		r.eof = true
+	}
+	return n, err
+}
+
+// Close destroys the key material held by the VerifyMACWriter, after
+// which writes fail. It does not close the underlying writer. It returns
+// ErrTruncatedStream if a partial frame was written to the VerifyMACWriter,
+// or, if configured WithCloseNotify, if no close notification was.
+func (w *VerifyMACWriter) Close() error {
+	destroyKey(w.authenticator)
+	if len(w.buf) > 0 {
+		return fmt.Errorf("%w: %d bytes of a partial frame", ErrTruncatedStream, len(w.buf))
+	}
+	if w.closeNotify && !w.closed {
+		return ErrTruncatedStream
 	}
-	return n + (subMsgCount * w.authHeaderLen), nil
+	return nil
 }
@@ -0,0 +1,126 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/adrianosela/authio/protocol/authenticator"
+	"github.com/autarch/testify/assert"
+)
+
+func Test_VerifyMACWriterPartialFrames(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	frames := &bytes.Buffer{}
+	writer := NewAppendMACWriter(frames, mockKey)
+	for _, msg := range []string{"hello", " ", "world"} {
+		_, err := writer.Write([]byte(msg))
+		assert.Nil(t, err)
+	}
+
+	tests := []struct {
+		name      string
+		chunkSize int
+	}{
+		{name: "all frames in a single write", chunkSize: frames.Len()},
+		{name: "one byte per write", chunkSize: 1},
+		{name: "frames split across writes", chunkSize: 7},
+		{name: "several frames per write", chunkSize: frames.Len() - 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			w := NewVerifyMACWriter(out, mockKey)
+
+			data := frames.Bytes()
+			for len(data) > 0 {
+				size := test.chunkSize
+				if size > len(data) {
+					size = len(data)
+				}
+				n, err := w.Write(data[:size])
+				assert.Nil(t, err)
+				assert.Equal(t, size, n)
+				data = data[size:]
+			}
+			assert.Nil(t, w.Close())
+			assert.Equal(t, "hello world", out.String())
+		})
+	}
+}
+
+func Test_VerifyMACWriterTruncated(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	frames := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(frames, mockKey).Write([]byte("hello"))
+	assert.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	w := NewVerifyMACWriter(out, mockKey)
+	_, err = w.Write(frames.Bytes()[:frames.Len()-1])
+	assert.Nil(t, err)
+	assert.Equal(t, 0, out.Len())
+	assert.True(t, errors.Is(w.Close(), ErrTruncatedStream))
+}
+
+func Test_VerifyMACWriterCorrupted(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	frames := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(frames, mockKey).Write([]byte("hello"))
+	assert.Nil(t, err)
+	corrupted := frames.Bytes()
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	w := NewVerifyMACWriter(&bytes.Buffer{}, mockKey)
+	n, err := w.Write(corrupted)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func Test_VerifyMACWriterLargeFrameInSmallWrites(t *testing.T) {
+	mockKey := []byte("mock key")
+	mockMessage := bytes.Repeat([]byte("mock data "), 100000)
+
+	frames := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(frames, mockKey).Write(mockMessage)
+	assert.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	w := NewVerifyMACWriter(out, mockKey)
+	data := frames.Bytes()
+	for i := 0; i < len(data); i += 100 {
+		end := i + 100
+		if end > len(data) {
+			end = len(data)
+		}
+		_, err := w.Write(data[i:end])
+		assert.Nil(t, err)
+		if end < len(data) {
+			// the frame is only parsed again once written in full
+			assert.Equal(t, len(data), w.needed)
+		}
+	}
+	assert.Nil(t, w.Close())
+	assert.Equal(t, mockMessage, out.Bytes())
+}
+
+func Test_VerifyMACWriterHeaderTooLarge(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	frames := &bytes.Buffer{}
+	_, err := NewAppendMACWriter(frames, mockKey).Write([]byte("hello world"))
+	assert.Nil(t, err)
+
+	// only the header is written, the frame is rejected before the rest is buffered
+	w := NewVerifyMACWriter(&bytes.Buffer{}, mockKey, WithMaxMessageSize(5))
+	headerLen := w.authHeaderLen
+	n, err := w.Write(frames.Bytes()[:headerLen+1])
+	var tooLarge *authenticator.MessageTooLargeError
+	assert.True(t, errors.As(err, &tooLarge), "expected %T, got %v", tooLarge, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, len(w.buf))
+}
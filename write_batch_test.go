@@ -0,0 +1,75 @@
+package authio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func Test_WriteBatch(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	tests := []struct {
+		name     string
+		messages [][]byte
+		opts     []Option
+		expected [][]byte
+	}{
+		{
+			name:     "Happy path",
+			messages: [][]byte{[]byte("one"), []byte("two"), []byte("three")},
+			expected: [][]byte{[]byte("one"), []byte("two"), []byte("three")},
+		},
+		{
+			name:     "Empty message",
+			messages: [][]byte{[]byte("one"), {}, []byte("three")},
+			expected: [][]byte{[]byte("one"), {}, []byte("three")},
+		},
+		{
+			name:     "Message larger than max message size",
+			messages: [][]byte{[]byte("one"), []byte("three")},
+			opts:     []Option{WithMaxMessageSize(3)},
+			expected: [][]byte{[]byte("one"), []byte("thr"), []byte("ee")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			n, err := NewAppendMACWriter(buf, mockKey, test.opts...).WriteBatch(test.messages)
+			assert.Nil(t, err)
+			assert.Equal(t, len(test.messages), n)
+
+			reader := NewVerifyMACReader(buf, mockKey, test.opts...)
+			for _, expected := range test.expected {
+				message, err := reader.Next()
+				assert.Nil(t, err)
+				assert.Equal(t, string(expected), string(message))
+			}
+			_, err = reader.Next()
+			assert.True(t, errors.Is(err, io.EOF))
+		})
+	}
+}
+
+func Test_ConnWriteBatch(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	messages := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	go NewClientConn(a, mockKey).WriteBatch(messages)
+
+	reader := NewServerConn(b, mockKey)
+	for _, expected := range messages {
+		buf := make([]byte, 64)
+		n, err := reader.Read(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), string(buf[:n]))
+	}
+}
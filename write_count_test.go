@@ -0,0 +1,106 @@
+package authio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+// limitedWriter writes up to limit bytes to the underlying buffer, after
+// which writes are short and fail
+type limitedWriter struct {
+	bytes.Buffer
+	limit int
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if w.Len()+len(b) <= w.limit {
+		return w.Buffer.Write(b)
+	}
+	n, _ := w.Buffer.Write(b[:w.limit-w.Len()])
+	return n, errMockWrite
+}
+
+func Test_AppendMACWriterWriteCount(t *testing.T) {
+	mockKey := []byte("mock key")
+	headerLen := NewAppendMACWriter(nil, mockKey).authHeaderLen
+	message := []byte("hello world")
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		limit    int
+		expected int
+	}{
+		{name: "Nothing written", limit: 0, expected: 0},
+		{name: "Partial header", limit: headerLen - 1, expected: 0},
+		{name: "Header only", limit: headerLen, expected: 0},
+		{name: "Partial message", limit: headerLen + 5, expected: 5},
+		{name: "Split message, first frame written", opts: []Option{WithMaxMessageSize(5)}, limit: headerLen + 5, expected: 5},
+		{name: "Split message, partial header of second frame", opts: []Option{WithMaxMessageSize(5)}, limit: 2*headerLen + 4, expected: 5},
+		{name: "Split message, partial second frame", opts: []Option{WithMaxMessageSize(5)}, limit: 2*headerLen + 7, expected: 7},
+		{name: "Padded partial message", opts: []Option{WithPadding(16)}, limit: headerLen + 5, expected: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := NewAppendMACWriter(&limitedWriter{limit: test.limit}, mockKey, test.opts...)
+			n, err := w.Write(message)
+			assert.Error(t, err)
+			assert.Equal(t, test.expected, n)
+		})
+	}
+
+	// buffered bytes are only counted once they are buffered
+	w := NewBufferedAppendMACWriter(&limitedWriter{limit: 0}, mockKey, 4)
+	n, err := w.Write(message)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	n, err = w.Write(message)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func Test_VerifyMACWriterWriteCount(t *testing.T) {
+	mockKey := []byte("mock key")
+
+	frames := &bytes.Buffer{}
+	writer := NewAppendMACWriter(frames, mockKey, WithCloseNotify())
+	frameEnds := []int{}
+	for _, message := range []string{"first", "second"} {
+		_, err := writer.Write([]byte(message))
+		assert.NoError(t, err)
+		frameEnds = append(frameEnds, frames.Len())
+	}
+	assert.NoError(t, writer.Close())
+	stream := frames.Bytes()
+
+	tests := []struct {
+		name        string
+		limit       int
+		expected    int
+		expectedOut string
+	}{
+		{name: "Nothing written", limit: 0, expected: 0, expectedOut: "firstsecond"},
+		{name: "Partial first message", limit: 3, expected: 0, expectedOut: "fir" + "firstsecond"},
+		{name: "First message", limit: len("first"), expected: frameEnds[0], expectedOut: "firstsecond"},
+		{name: "Partial second message", limit: len("firstsec"), expected: frameEnds[0], expectedOut: "firstsec" + "second"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &limitedWriter{limit: test.limit}
+			w := NewVerifyMACWriter(out, mockKey, WithCloseNotify())
+			n, err := w.Write(stream)
+			assert.Error(t, err)
+			assert.Equal(t, test.expected, n)
+
+			// the rest of the stream may be written again once the writer recovers
+			out.limit = len(stream)
+			n, err = w.Write(stream[n:])
+			assert.NoError(t, err)
+			assert.Equal(t, len(stream)-test.expected, n)
+			assert.NoError(t, w.Close())
+			assert.Equal(t, test.expectedOut, out.String())
+		})
+	}
+}
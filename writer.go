@@ -13,6 +13,6 @@ type Writer struct {
 var _ io.Writer = (*AppendMACWriter)(nil)
 
 // NewWriter returns a new Writer
-func NewWriter(writer io.Writer, key []byte) *Writer {
-	return &Writer{NewAppendMACWriter(writer, key)}
+func NewWriter(writer io.Writer, key []byte, opts ...Option) *Writer {
+	return &Writer{NewAppendMACWriter(writer, key, opts...)}
 }